@@ -0,0 +1,76 @@
+package vcdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DeltaFormatVCDIFF is the format Ably names VCDIFF deltas by
+const DeltaFormatVCDIFF = "vcdiff"
+
+// DeltaExtras describes what an Ably delta message applies to. Ably sends it
+// as the delta member of a message's extras:
+//
+//	{"delta": {"from": "<id of the base message>", "format": "vcdiff"}}
+//
+// The same object can be carried in the delta's application header with
+// AppHeader, so deltas stored or relayed without their message still say
+// which base they need.
+type DeltaExtras struct {
+	From   string `json:"from"`   // ID of the message whose data is the base
+	Format string `json:"format"` // Delta encoding, DeltaFormatVCDIFF for this package
+}
+
+// ParseDeltaExtras reads the delta member of an Ably message's extras JSON.
+// It returns false if the message is not a delta.
+func ParseDeltaExtras(extras []byte) (DeltaExtras, bool, error) {
+	var message struct {
+		Delta *DeltaExtras `json:"delta"`
+	}
+	if err := json.Unmarshal(extras, &message); err != nil {
+		return DeltaExtras{}, false, fmt.Errorf("%w: message extras: %v", ErrInvalidFormat, err)
+	}
+	if message.Delta == nil {
+		return DeltaExtras{}, false, nil
+	}
+	return *message.Delta, true, nil
+}
+
+// AppHeader encodes the extras as a JSON object for WithAppHeader
+func (x DeltaExtras) AppHeader() []byte {
+	// Two strings always marshal
+	b, _ := json.Marshal(x)
+	return b
+}
+
+// ParseDeltaExtrasAppHeader decodes extras from an application header written
+// by AppHeader. It returns false if the header does not carry them, as for
+// source fingerprints and other application data.
+func ParseDeltaExtrasAppHeader(appHeader []byte) (DeltaExtras, bool, error) {
+	var x DeltaExtras
+	if !bytes.HasPrefix(appHeader, []byte("{")) {
+		return x, false, nil
+	}
+	if err := json.Unmarshal(appHeader, &x); err != nil {
+		return x, true, fmt.Errorf("%w: delta extras: %v", ErrInvalidFormat, err)
+	}
+	if x.Format == "" {
+		return x, true, fmt.Errorf("%w: delta extras name no format", ErrInvalidFormat)
+	}
+	return x, true, nil
+}
+
+// Check reports whether a delta described by the extras can be decoded
+// against the data of message baseID, before decoding it. It fails with an
+// error wrapping ErrUnsupported for formats other than VCDIFF, and
+// ErrSourceMismatch when the delta was made from a different message.
+func (x DeltaExtras) Check(baseID string) error {
+	if x.Format != DeltaFormatVCDIFF {
+		return fmt.Errorf("%w: delta format %q", ErrUnsupported, x.Format)
+	}
+	if x.From != baseID {
+		return fmt.Errorf("%w: delta applies to message %q, base is message %q", ErrSourceMismatch, x.From, baseID)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package vcdiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeltaExtras(t *testing.T) {
+	x, ok, err := ParseDeltaExtras([]byte(`{"headers": {"a": "b"}, "delta": {"from": "msg:0", "format": "vcdiff"}}`))
+	if err != nil || !ok {
+		t.Fatalf("ParseDeltaExtras = %v, %v", ok, err)
+	}
+	if x != (DeltaExtras{From: "msg:0", Format: DeltaFormatVCDIFF}) {
+		t.Fatalf("unexpected extras %+v", x)
+	}
+	if err := x.Check("msg:0"); err != nil {
+		t.Errorf("matching base: %v", err)
+	}
+	if err := x.Check("msg:1"); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("other base: got %v, expected ErrSourceMismatch", err)
+	}
+	if err := (DeltaExtras{From: "msg:0", Format: "xdelta"}).Check("msg:0"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("other format: got %v, expected ErrUnsupported", err)
+	}
+
+	if _, ok, err := ParseDeltaExtras([]byte(`{"headers": {}}`)); ok || err != nil {
+		t.Errorf("message without delta: got %v, %v", ok, err)
+	}
+	if _, _, err := ParseDeltaExtras([]byte(`{"delta": 1}`)); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("malformed extras: got %v, expected ErrInvalidFormat", err)
+	}
+
+	// Carried in the application header of the delta itself
+	source, target := []byte(`{"count": 1}`), []byte(`{"count": 2}`)
+	delta, err := Encode(source, target, WithAppHeader(x.AppHeader()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := ParseHeader(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := ParseDeltaExtrasAppHeader(header.AppHeader)
+	if err != nil || !ok || got != x {
+		t.Fatalf("ParseDeltaExtrasAppHeader = %+v, %v, %v", got, ok, err)
+	}
+	if decoded, err := Decode(source, delta); err != nil || string(decoded) != string(target) {
+		t.Fatalf("Decode = %q, %v", decoded, err)
+	}
+
+	if _, ok, _ := ParseDeltaExtrasAppHeader(NewSourceFingerprint(source).AppHeader()); ok {
+		t.Error("a source fingerprint was taken for delta extras")
+	}
+	if _, _, err := ParseDeltaExtrasAppHeader([]byte(`{"from": "msg:0"}`)); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("extras without format: got %v, expected ErrInvalidFormat", err)
+	}
+}
@@ -10,10 +10,28 @@ const (
 	HereMode = 1
 )
 
+// Address mode limits - RFC 3284 Section 5.3
+const (
+	fixedAddressModes = 2   // SELF and HERE precede the near and same cache modes
+	maxAddressModes   = 256 // Modes are stored in a byte of the code table
+)
+
+// checkCacheSizes reports whether near and same cache sizes give a valid
+// number of address modes
+func checkCacheSizes(near, same int) error {
+	if near < 0 || same < 0 || fixedAddressModes+near+same > maxAddressModes {
+		return fmt.Errorf("invalid address cache sizes near=%d same=%d: sizes must be non-negative with at most %d modes in total",
+			near, same, maxAddressModes)
+	}
+	return nil
+}
+
 // AddressCache manages address encoding/decoding for COPY instructions
 type AddressCache struct {
 	nearSize      int
 	sameSize      int
+	defaultNear   int // Sizes given to NewAddressCache, used with the default code table
+	defaultSame   int
 	near          []uint32
 	nextNearSlot  int
 	same          []uint32
@@ -23,28 +41,45 @@ type AddressCache struct {
 // NewAddressCache creates a new address cache with the specified sizes
 func NewAddressCache(nearSize, sameSize int) *AddressCache {
 	return &AddressCache{
-		nearSize: nearSize,
-		sameSize: sameSize,
-		near:     make([]uint32, nearSize),
-		same:     make([]uint32, sameSize*256),
+		nearSize:    nearSize,
+		sameSize:    sameSize,
+		defaultNear: nearSize,
+		defaultSame: sameSize,
+		near:        make([]uint32, nearSize),
+		same:        make([]uint32, sameSize*256),
 	}
 }
 
-// Reset resets the address cache for a new window
+// resetFor resets the cache for window, first resizing it to the caches of
+// the window's code table, or back to the sizes it was created with if the
+// window uses the default table
+func (ac *AddressCache) resetFor(window *Window) {
+	near, same := window.cacheSizes(ac.defaultNear, ac.defaultSame)
+	if near != ac.nearSize || same != ac.sameSize {
+		ac.nearSize, ac.sameSize = near, same
+		ac.near, ac.same = make([]uint32, near), make([]uint32, same*256)
+	}
+	ac.Reset(window.AddressSection)
+}
+
+// Reset resets the address cache for a new window. It reuses the cache's
+// storage, so one cache can serve every window of a delta.
 func (ac *AddressCache) Reset(addresses []byte) {
 	ac.nextNearSlot = 0
 
-	// Clear near cache
-	for i := range ac.near {
-		ac.near[i] = 0
-	}
+	clear(ac.near)
+	clear(ac.same)
 
-	// Clear same cache
-	for i := range ac.same {
-		ac.same[i] = 0
+	if ac.addressStream == nil {
+		ac.addressStream = bytes.NewReader(addresses)
+	} else {
+		ac.addressStream.Reset(addresses)
 	}
+}
 
-	ac.addressStream = bytes.NewReader(addresses)
+// remaining returns the number of address bytes not yet decoded
+func (ac *AddressCache) remaining() int {
+	return ac.addressStream.Len()
 }
 
 // DecodeAddress decodes an address using the specified mode
@@ -52,49 +87,48 @@ func (ac *AddressCache) DecodeAddress(here uint32, mode byte) (uint32, error) {
 	var addr uint32
 	var err error
 
-	// Validate addressing mode
-	if mode > 8 {
-		return 0, fmt.Errorf("invalid address cache mode %d: valid modes are 0-8", mode)
+	// Validate addressing mode against the configured cache sizes
+	if modes := fixedAddressModes + ac.nearSize + ac.sameSize; int(mode) >= modes {
+		return 0, fmt.Errorf("%w: invalid address cache mode %d: valid modes are 0-%d", errBadAddress, mode, modes-1)
 	}
 
 	switch mode {
 	case SelfMode:
 		addr, err = ReadVarint(ac.addressStream)
 		if err != nil {
-			return 0, fmt.Errorf("error reading address for SELF mode: %v", err)
+			return 0, fmt.Errorf("error reading address for SELF mode: %w", err)
 		}
 
 	case HereMode:
 		offset, err := ReadVarint(ac.addressStream)
 		if err != nil {
-			return 0, fmt.Errorf("error reading offset for HERE mode: %v", err)
+			return 0, fmt.Errorf("error reading offset for HERE mode: %w", err)
 		}
 		if offset > here {
-			return 0, fmt.Errorf("HERE mode offset %d exceeds current position %d", offset, here)
+			return 0, fmt.Errorf("%w: HERE mode offset %d exceeds current position %d", errBadAddress, offset, here)
 		}
 		addr = here - offset
 
 	default:
 		if int(mode-2) < ac.nearSize {
 			// Near cache
+			// Slots not yet written hold 0, which is a valid base - RFC
+			// 3284 Section 5.1
 			cacheIndex := mode - 2
-			if ac.near[cacheIndex] == 0 {
-				return 0, fmt.Errorf("near cache slot %d is uninitialized", cacheIndex)
-			}
 			offset, err := ReadVarint(ac.addressStream)
 			if err != nil {
-				return 0, fmt.Errorf("error reading offset for near cache mode %d: %v", mode, err)
+				return 0, fmt.Errorf("error reading offset for near cache mode %d: %w", mode, err)
 			}
 			addr = ac.near[cacheIndex] + offset
 		} else {
 			// Same cache
 			m := int(mode) - (2 + ac.nearSize)
 			if m >= ac.sameSize {
-				return 0, fmt.Errorf("same cache mode %d exceeds available slots (max %d)", mode, 2+ac.nearSize+ac.sameSize-1)
+				return 0, fmt.Errorf("%w: same cache mode %d exceeds available slots (max %d)", errBadAddress, mode, 2+ac.nearSize+ac.sameSize-1)
 			}
 			b, err := ac.addressStream.ReadByte()
 			if err != nil {
-				return 0, err
+				return 0, errUnexpectedEOF(fmt.Sprintf("address for same cache mode %d", mode), 1)
 			}
 			addr = ac.same[m*256+int(b)]
 		}
@@ -104,6 +138,33 @@ func (ac *AddressCache) DecodeAddress(here uint32, mode byte) (uint32, error) {
 	return addr, nil
 }
 
+// EncodeAddress picks the addressing mode that stores addr in the fewest
+// bytes, given the current position here, and appends the encoded address to
+// dst. The cache is updated exactly as DecodeAddress will update it when the
+// address is read back.
+func (ac *AddressCache) EncodeAddress(addr, here uint32, dst []byte) (byte, []byte) {
+	mode, value := byte(SelfMode), addr
+	best := varintLength(addr)
+	if offset := here - addr; addr <= here && varintLength(offset) < best {
+		mode, value, best = HereMode, offset, varintLength(offset)
+	}
+	for i, base := range ac.near {
+		if addr >= base && varintLength(addr-base) < best {
+			mode, value, best = byte(fixedAddressModes+i), addr-base, varintLength(addr-base)
+		}
+	}
+
+	if ac.sameSize > 0 {
+		slot := addr % (uint32(ac.sameSize) * 256)
+		if ac.same[slot] == addr && best > 1 {
+			ac.Update(addr)
+			return byte(fixedAddressModes+ac.nearSize) + byte(slot/256), append(dst, byte(slot%256))
+		}
+	}
+	ac.Update(addr)
+	return mode, AppendVarint(dst, value)
+}
+
 // Update updates the address cache with a new address
 func (ac *AddressCache) Update(address uint32) {
 	if ac.nearSize > 0 {
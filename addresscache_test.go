@@ -0,0 +1,266 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// referenceCache is a deliberately naive model of the RFC 3284 Section 5.3
+// address caches, written for clarity rather than speed
+type referenceCache struct {
+	near   []uint32       // most recent addresses, oldest first
+	same   map[int]uint32 // address % (s_same*256) -> address
+	stream *bytes.Reader
+}
+
+func newReferenceCache(addresses []byte) *referenceCache {
+	return &referenceCache{
+		same:   map[int]uint32{},
+		stream: bytes.NewReader(addresses),
+	}
+}
+
+// nearSlot returns the value in near slot i, where slots are filled
+// round-robin starting from slot 0 and are zero until first written
+func (r *referenceCache) nearSlot(i int) uint32 {
+	// Writes are round-robin, so the slot holding an address is its index
+	// in the full history modulo s_near; only the latest write per slot counts
+	value := uint32(0)
+	for written, addr := range r.near {
+		if written%NearCacheSize == i {
+			value = addr
+		}
+	}
+	return value
+}
+
+func (r *referenceCache) decode(here uint32, mode byte) (uint32, error) {
+	var addr uint32
+	switch {
+	case mode == SelfMode:
+		v, err := ReadVarint(r.stream)
+		if err != nil {
+			return 0, err
+		}
+		addr = v
+
+	case mode == HereMode:
+		v, err := ReadVarint(r.stream)
+		if err != nil {
+			return 0, err
+		}
+		if v > here {
+			return 0, ErrInvalidFormat
+		}
+		addr = here - v
+
+	case int(mode) < 2+NearCacheSize:
+		v, err := ReadVarint(r.stream)
+		if err != nil {
+			return 0, err
+		}
+		addr = r.nearSlot(int(mode)-2) + v
+
+	case int(mode) < 2+NearCacheSize+SameCacheModes:
+		b, err := r.stream.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		addr = r.same[(int(mode)-2-NearCacheSize)*256+int(b)]
+
+	default:
+		return 0, ErrInvalidFormat
+	}
+
+	r.near = append(r.near, addr)
+	r.same[int(addr%SameCacheSize)] = addr
+	return addr, nil
+}
+
+// randomAddressStep produces a mode, a current position and the encoded
+// address bytes for one COPY, biased towards values the caches will hit
+func randomAddressStep(rng *rand.Rand, history []uint32) (byte, uint32, []byte) {
+	mode := byte(rng.Intn(2 + NearCacheSize + SameCacheModes + 1)) // includes one invalid mode
+	here := uint32(rng.Intn(1 << 20))
+
+	var encoded []byte
+	switch {
+	case mode == SelfMode || mode == HereMode:
+		encoded = AppendVarint(nil, uint32(rng.Intn(int(here)+2)))
+	case int(mode) < 2+NearCacheSize:
+		encoded = AppendVarint(nil, uint32(rng.Intn(1<<10)))
+	default:
+		b := byte(rng.Intn(256))
+		if len(history) > 0 && rng.Intn(2) == 0 {
+			b = byte(history[rng.Intn(len(history))] % 256)
+		}
+		encoded = []byte{b}
+	}
+
+	// Occasionally truncate the address bytes
+	if len(encoded) > 0 && rng.Intn(20) == 0 {
+		encoded = encoded[:len(encoded)-1]
+	}
+	return mode, here, encoded
+}
+
+func TestAddressCacheMatchesReferenceModel(t *testing.T) {
+	const sequences = 2000
+	const stepsPerSequence = 64
+
+	for seed := int64(0); seed < sequences; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+
+		// Build the sequence step by step against a shadow model so that
+		// generation can see the cache state
+		shadow := newReferenceCache(nil)
+		var modes []byte
+		var heres []uint32
+		var addresses []byte
+		var history []uint32
+		for i := 0; i < stepsPerSequence; i++ {
+			mode, here, encoded := randomAddressStep(rng, history)
+
+			modes = append(modes, mode)
+			heres = append(heres, here)
+			addresses = append(addresses, encoded...)
+
+			shadow.stream = bytes.NewReader(encoded)
+			addr, err := shadow.decode(here, mode)
+			if err != nil {
+				break
+			}
+			history = append(history, addr)
+		}
+
+		cache := NewAddressCache(NearCacheSize, SameCacheModes)
+		cache.Reset(addresses)
+		model := newReferenceCache(addresses)
+
+		for i, mode := range modes {
+			want, wantErr := model.decode(heres[i], mode)
+			got, gotErr := cache.DecodeAddress(heres[i], mode)
+
+			if (wantErr != nil) != (gotErr != nil) {
+				t.Fatalf("seed %d step %d mode %d: reference error %v, implementation error %v",
+					seed, i, mode, wantErr, gotErr)
+			}
+			if wantErr != nil {
+				break
+			}
+			if got != want {
+				t.Fatalf("seed %d step %d mode %d here %d: reference address %d, implementation %d",
+					seed, i, mode, heres[i], want, got)
+			}
+			if cache.addressStream.Len() != model.stream.Len() {
+				t.Fatalf("seed %d step %d mode %d: reference left %d address bytes, implementation %d",
+					seed, i, mode, model.stream.Len(), cache.addressStream.Len())
+			}
+		}
+	}
+}
+
+func TestAddressCacheSameModeLargeAddresses(t *testing.T) {
+	cache := NewAddressCache(NearCacheSize, SameCacheModes)
+
+	// Prime the same cache with an address beyond the first 768 entries
+	const addr = 100000
+	cache.Reset(append(AppendVarint(nil, addr), byte(addr%256)))
+
+	if got, err := cache.DecodeAddress(addr+1, SelfMode); err != nil || got != addr {
+		t.Fatalf("SELF decode: got %d, %v", got, err)
+	}
+
+	sameMode := byte(2 + NearCacheSize + (addr%SameCacheSize)/256)
+	got, err := cache.DecodeAddress(addr+1, sameMode)
+	if err != nil {
+		t.Fatalf("SAME decode failed: %v", err)
+	}
+	if got != addr {
+		t.Fatalf("SAME decode: got %d, expected %d", got, addr)
+	}
+}
+
+func TestAddressCacheResetClearsState(t *testing.T) {
+	cache := NewAddressCache(NearCacheSize, SameCacheModes)
+
+	// Fill the caches from a first window
+	const addr = 1234
+	cache.Reset(AppendVarint(nil, addr))
+	if _, err := cache.DecodeAddress(addr+1, SelfMode); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reused cache must behave exactly like a fresh one
+	second := append(AppendVarint(nil, 0), byte(addr%256))
+	fresh := NewAddressCache(NearCacheSize, SameCacheModes)
+	fresh.Reset(second)
+	cache.Reset(second)
+
+	sameMode := byte(2 + NearCacheSize + (addr%SameCacheSize)/256)
+	for _, mode := range []byte{SelfMode, sameMode} {
+		want, wantErr := fresh.DecodeAddress(addr+1, mode)
+		got, gotErr := cache.DecodeAddress(addr+1, mode)
+		if got != want || (gotErr != nil) != (wantErr != nil) {
+			t.Fatalf("mode %d: reused cache gave %d, %v; fresh cache gave %d, %v", mode, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestAddressCacheUnwrittenNearSlot(t *testing.T) {
+	// Near slots start at 0, a usable base: offset 5 from slot 2 is address 5
+	cache := NewAddressCache(NearCacheSize, SameCacheModes)
+	cache.Reset([]byte{5})
+	if addr, err := cache.DecodeAddress(100, 2+2); err != nil || addr != 5 {
+		t.Fatalf("got %d, %v; expected address 5", addr, err)
+	}
+}
+
+func TestDecoderCacheSizes(t *testing.T) {
+	const addr = 7*256 + 4
+	source := bytes.Repeat([]byte{'.'}, addr+4)
+	copy(source[4:], "4567")
+	copy(source[addr:], "wxyz")
+
+	// Two COPYs of source[addr:addr+4]: the first in SELF mode, the second in
+	// mode 3 with the byte 4 in the address stream. With one near slot and
+	// seven same cache modes, mode 3 is the first same cache mode, where addr
+	// is stored in bucket 4. With the default four near slots it is near slot
+	// 1, which has never been written and so holds 0, giving address 4.
+	instructions := []byte{copyCode, 4, copyCode + 3*genCopyCodesPerMode, 4}
+	addresses := append(AppendVarint(nil, addr), 4)
+	target := []byte("wxyzwxyz")
+
+	encoding := AppendVarint(nil, uint32(len(target)))
+	encoding = append(encoding, 0, 0, byte(len(instructions)), byte(len(addresses)))
+	encoding = append(encoding, instructions...)
+	encoding = append(encoding, addresses...)
+	delta := []byte{VCDIFFMagic1, VCDIFFMagic2, VCDIFFMagic3, VCDIFFVersion, 0, VCDSource}
+	delta = AppendVarint(delta, uint32(len(source)))
+	delta = AppendVarint(delta, 0)
+	delta = AppendVarint(delta, uint32(len(encoding)))
+	delta = append(delta, encoding...)
+
+	if result, err := Decode(source, delta); err != nil || string(result) != "wxyz4567" {
+		t.Errorf("default cache sizes gave %q, %v; expected %q", result, err, "wxyz4567")
+	}
+	result, err := NewDecoder(source, WithCacheSizes(1, 7)).Decode(delta)
+	if err != nil {
+		t.Fatalf("decode with near=1 same=7 failed: %v", err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Errorf("got %q, expected %q", result, target)
+	}
+
+	// Modes beyond the configured caches are rejected
+	if _, err := NewDecoder(source, WithCacheSizes(1, 0)).Decode(delta); err == nil {
+		t.Error("mode 3 accepted with only 3 address modes")
+	}
+
+	for _, sizes := range [][2]int{{-1, 3}, {4, -1}, {200, 100}} {
+		if _, err := NewDecoder(source, WithCacheSizes(sizes[0], sizes[1])).Decode(delta); err == nil {
+			t.Errorf("cache sizes near=%d same=%d accepted", sizes[0], sizes[1])
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package vcdiff
 
+import "hash"
+
 // Adler32 implements the Adler-32 checksum algorithm
 type Adler32 struct{}
 
@@ -41,3 +43,53 @@ func ComputeChecksum(initial uint32, data []byte) uint32 {
 
 	return (s2 << 16) | s1
 }
+
+// CombineChecksums returns the Adler-32 of the concatenation of two blocks,
+// given the checksum of each and the length of the second, as zlib's
+// adler32_combine does
+func CombineChecksums(first, second uint32, secondLength uint64) uint32 {
+	rem := uint32(secondLength % adler32Base)
+	s1 := first & 0xffff
+	s2 := uint32(uint64(rem) * uint64(s1) % adler32Base)
+	s1 += (second & 0xffff) + adler32Base - 1
+	s2 += (first >> 16) + (second >> 16) + adler32Base - rem
+
+	for s1 >= adler32Base {
+		s1 -= adler32Base
+	}
+	for s2 >= adler32Base {
+		s2 -= adler32Base
+	}
+	return (s2 << 16) | s1
+}
+
+// adler32Digest is the running Adler-32 of the bytes written to it
+type adler32Digest uint32
+
+// NewAdler32 returns a hash.Hash32 computing the Adler-32 of the bytes
+// written to it, for checking a window's VCD_ADLER32 checksum as its target
+// is produced instead of over the finished buffer. Sum32 gives the value
+// ComputeChecksum(1, data) would for all the data written.
+func NewAdler32() hash.Hash32 {
+	d := adler32Digest(1)
+	return &d
+}
+
+func (d *adler32Digest) Write(p []byte) (int, error) {
+	*d = adler32Digest(ComputeChecksum(uint32(*d), p))
+	return len(p), nil
+}
+
+func (d *adler32Digest) Sum32() uint32 { return uint32(*d) }
+
+// Sum appends the checksum to b in big-endian order, as VCDIFF stores it
+func (d *adler32Digest) Sum(b []byte) []byte {
+	s := uint32(*d)
+	return append(b, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+func (d *adler32Digest) Reset() { *d = 1 }
+
+func (d *adler32Digest) Size() int { return 4 }
+
+func (d *adler32Digest) BlockSize() int { return 4 }
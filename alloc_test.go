@@ -0,0 +1,93 @@
+package vcdiff
+
+import (
+	"runtime"
+	"testing"
+)
+
+// BenchmarkDecodeAllocs reports the allocations of decoding a delta with many
+// windows, where per-window allocations dominate
+func BenchmarkDecodeAllocs(b *testing.B) {
+	g := GenerateDelta(1, ProfileManyWindows)
+	dst := make([]byte, len(g.Target))
+	decoder := NewDecoder(g.Source)
+
+	benchmarks := map[string]func() error{
+		"Decode": func() error {
+			_, err := Decode(g.Source, g.Delta)
+			return err
+		},
+		"ReusedDecoder": func() error {
+			_, err := decoder.Decode(g.Delta)
+			return err
+		},
+		"DecodeInto": func() error {
+			_, err := DecodeInto(dst, g.Source, g.Delta)
+			return err
+		},
+	}
+	for name, decode := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(g.Target)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestDecodeIntoAllocations checks that decoding into a caller's buffer
+// makes a few small allocations per delta, none per window and none near the
+// size of the target
+func TestDecodeIntoAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector drops pooled scratch space")
+	}
+	g := GenerateDelta(1, ProfileManyWindows)
+	dst := make([]byte, len(g.Target))
+	decode := func() {
+		if _, err := DecodeInto(dst, g.Source, g.Delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if allocs := testing.AllocsPerRun(20, decode); allocs > 4 {
+		t.Errorf("decoding %d windows made %v allocations", ProfileManyWindows.Windows, allocs)
+	}
+	if bytes := bytesPerRun(20, decode); bytes > float64(len(g.Target)/16) {
+		t.Errorf("decoding a %d byte target allocated %v bytes", len(g.Target), bytes)
+	}
+}
+
+// TestDecodeAllocations checks that a decode allocates little more than the
+// target, rather than a buffer per window
+func TestDecodeAllocations(t *testing.T) {
+	g := GenerateDelta(1, ProfileManyWindows)
+	decoder := NewDecoder(g.Source)
+	allocs := testing.AllocsPerRun(20, func() {
+		if _, err := decoder.Decode(g.Delta); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if windows := ProfileManyWindows.Windows; allocs > float64(windows/8) {
+		t.Errorf("decoding %d windows made %v allocations", windows, allocs)
+	}
+}
+
+// bytesPerRun is testing.AllocsPerRun for the bytes f allocates
+func bytesPerRun(runs int, f func()) float64 {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	f()
+	// Collect garbage first, so that no collection empties the scratch pool
+	// part way through
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		f()
+	}
+	runtime.ReadMemStats(&after)
+	return float64(after.TotalAlloc-before.TotalAlloc) / float64(runs)
+}
@@ -0,0 +1,160 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Patch bundle layout. A bundle holds deltas from several base versions to
+// the same target, so one file can be distributed to clients on assorted old
+// versions. Varints are RFC 3284 variable-length integers.
+//
+//	size    field
+//	4       tag "VCDB"
+//	1       layout version (1)
+//	varint  number of entries
+//
+// followed by each entry:
+//
+//	varint  fingerprint length
+//	..      source fingerprint, in the application header layout
+//	varint  delta length
+//	..      delta
+const BundleVersion = 1 // Current layout version
+
+// bundleTag marks a file as a patch bundle
+var bundleTag = []byte("VCDB")
+
+// BundleEntry is one delta in a bundle and the source it applies to
+type BundleEntry struct {
+	Source SourceFingerprint
+	Delta  []byte
+}
+
+// Bundle holds deltas from several sources to one target
+type Bundle struct {
+	Entries []BundleEntry
+}
+
+// Add appends a delta made against source
+func (b *Bundle) Add(source, delta []byte) {
+	b.Entries = append(b.Entries, BundleEntry{Source: NewSourceFingerprint(source), Delta: delta})
+}
+
+// Select returns the entry whose fingerprint matches source, or an error
+// wrapping ErrSourceMismatch if none does. source is hashed once however
+// many entries there are.
+func (b *Bundle) Select(source []byte) (*BundleEntry, error) {
+	local := NewSourceFingerprint(source)
+	for i := range b.Entries {
+		f := &b.Entries[i].Source
+		if f.Length == local.Length &&
+			(!f.HasAdler32 || f.Adler32 == local.Adler32) &&
+			(!f.HasSHA256 || f.SHA256 == local.SHA256) {
+			return &b.Entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: none of the bundle's %d deltas was made against this source", ErrSourceMismatch, len(b.Entries))
+}
+
+// IsBundle reports whether data starts with the bundle tag
+func IsBundle(data []byte) bool {
+	return bytes.HasPrefix(data, bundleTag)
+}
+
+// MarshalBundle serializes a bundle
+func MarshalBundle(b *Bundle) ([]byte, error) {
+	if uint64(len(b.Entries)) > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: bundle has %d entries", ErrInvalidFormat, len(b.Entries))
+	}
+
+	data := append([]byte{}, bundleTag...)
+	data = append(data, BundleVersion)
+	data = AppendVarint(data, uint32(len(b.Entries)))
+	for i, entry := range b.Entries {
+		if uint64(len(entry.Delta)) > math.MaxUint32 {
+			return nil, fmt.Errorf("%w: bundle entry %d: delta of %d bytes", ErrInvalidFormat, i, len(entry.Delta))
+		}
+		fingerprint := entry.Source.AppHeader()
+		data = AppendVarint(data, uint32(len(fingerprint)))
+		data = append(data, fingerprint...)
+		data = AppendVarint(data, uint32(len(entry.Delta)))
+		data = append(data, entry.Delta...)
+	}
+	return data, nil
+}
+
+// ParseBundle decodes a bundle. Entry deltas share storage with data and are
+// not parsed until applied.
+func ParseBundle(data []byte) (*Bundle, error) {
+	if !IsBundle(data) {
+		return nil, fmt.Errorf("%w: not a patch bundle", ErrInvalidFormat)
+	}
+	reader := bytes.NewReader(data[len(bundleTag):])
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: bundle truncated", ErrInvalidFormat)
+	}
+	if version != BundleVersion {
+		return nil, errInvalidValue("bundle version", len(bundleTag), version, "unknown layout")
+	}
+	count, err := ReadVarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bundle entry count: %v", ErrInvalidFormat, err)
+	}
+
+	// Each entry takes at least two bytes, which bounds the allocation
+	b := &Bundle{Entries: make([]BundleEntry, 0, min(int(count), reader.Len()/2))}
+	for i := uint32(0); i < count; i++ {
+		fingerprint, err := readBundleField(data, reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bundle entry %d fingerprint: %v", ErrInvalidFormat, i, err)
+		}
+		f, ok, err := ParseSourceFingerprint(fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("bundle entry %d: %w", i, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: bundle entry %d has no source fingerprint", ErrInvalidFormat, i)
+		}
+		delta, err := readBundleField(data, reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bundle entry %d delta: %v", ErrInvalidFormat, i, err)
+		}
+		b.Entries = append(b.Entries, BundleEntry{Source: f, Delta: delta})
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes after the bundle's entries", ErrInvalidFormat, reader.Len())
+	}
+	return b, nil
+}
+
+// readBundleField reads a length-prefixed field and returns it as a slice of
+// data, which reader reads from the end of
+func readBundleField(data []byte, reader *bytes.Reader) ([]byte, error) {
+	length, err := ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if int64(length) > int64(reader.Len()) {
+		return nil, fmt.Errorf("length %d exceeds the %d bytes remaining", length, reader.Len())
+	}
+	start := len(data) - reader.Len()
+	reader.Seek(int64(length), io.SeekCurrent)
+	return data[start : start+int(length)], nil
+}
+
+// ApplyBundle selects the delta in bundle made against source and applies it
+func ApplyBundle(source, bundle []byte, opts ...DecoderOption) ([]byte, error) {
+	b, err := ParseBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := b.Select(source)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(source, opts...).Decode(entry.Delta)
+}
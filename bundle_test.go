@@ -0,0 +1,71 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	var b Bundle
+	var generated []GeneratedDelta
+	for seed := int64(0); seed < 3; seed++ {
+		g := GenerateDelta(seed, ProfileSmall)
+		b.Add(g.Source, g.Delta)
+		generated = append(generated, g)
+	}
+
+	data, err := MarshalBundle(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBundle(data) {
+		t.Fatal("IsBundle does not recognize a marshaled bundle")
+	}
+	parsed, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Entries) != len(b.Entries) {
+		t.Fatalf("parsed %d entries, expected %d", len(parsed.Entries), len(b.Entries))
+	}
+
+	// Each base selects its own delta
+	for i, g := range generated {
+		target, err := ApplyBundle(g.Source, data)
+		if err != nil {
+			t.Fatalf("base %d: %v", i, err)
+		}
+		if !bytes.Equal(target, g.Target) {
+			t.Fatalf("base %d: applied the wrong delta", i)
+		}
+	}
+
+	if _, err := ApplyBundle([]byte("unrelated base"), data); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("unknown base: got %v, expected ErrSourceMismatch", err)
+	}
+}
+
+func TestParseBundleInvalid(t *testing.T) {
+	var b Bundle
+	b.Add([]byte("base"), []byte("delta"))
+	valid, err := MarshalBundle(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badVersion := append([]byte{}, valid...)
+	badVersion[len(bundleTag)] = BundleVersion + 1
+
+	for name, data := range map[string][]byte{
+		"not a bundle":    []byte("VCDIFF"),
+		"no version":      valid[:len(bundleTag)],
+		"unknown version": badVersion,
+		"truncated entry": valid[:len(valid)-1],
+		"trailing bytes":  append(append([]byte{}, valid...), 0),
+	} {
+		if _, err := ParseBundle(data); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: got error %v, expected ErrInvalidFormat", name, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package vcdiff
+
+import (
+	"math"
+	"slices"
+)
+
+// Size limits of this decoder. Lengths, positions and sizes are decoded as
+// 32-bit integers (RFC 3284 Section 2), so these are the largest values the
+// format can express to it.
+const (
+	maxWindowLength  = math.MaxUint32 // Largest target window length
+	maxSourceSegment = math.MaxUint32 // Largest source segment position plus size
+)
+
+// DecoderCapabilities describes the delta features this decoder handles, so
+// protocols built on top can tell a peer which encoder options it may use
+type DecoderCapabilities struct {
+	TargetSegments       bool   // Windows copying from earlier target data (VCD_TARGET)
+	CustomCodeTables     bool   // Code tables carried in the header (VCD_CODETABLE)
+	SecondaryCompressors []byte // IDs of the secondary compressors that can be decoded
+	Checksums            bool   // VCD_ADLER32 window checksums are verified
+	SourceFingerprints   bool   // Source fingerprints in the application header are verified
+	Concatenated         bool   // Back-to-back deltas are accepted with WithConcatenated
+	Interleaved          bool   // open-vcdiff's version 'S' deltas, with interleaved sections and varint checksums
+	MaxWindowLength      uint64 // Largest target window length
+	MaxSourceLength      uint64 // Largest source length a window can reference
+}
+
+// Capabilities reports the delta features supported by this decoder
+func Capabilities() DecoderCapabilities {
+	return DecoderCapabilities{
+		CustomCodeTables:   true,
+		Checksums:          true,
+		SourceFingerprints: true,
+		Concatenated:       true,
+		Interleaved:        true,
+		MaxWindowLength:    maxWindowLength,
+		MaxSourceLength:    maxSourceSegment,
+	}
+}
+
+// SupportsCompressor reports whether deltas compressed with the secondary
+// compressor id can be decoded
+func (c DecoderCapabilities) SupportsCompressor(id byte) bool {
+	return slices.Contains(c.SecondaryCompressors, id)
+}
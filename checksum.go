@@ -0,0 +1,47 @@
+package vcdiff
+
+// ChecksumValidator computes the value a VCD_ADLER32 window's stored checksum
+// is compared against. The default, Adler32, follows xdelta3: the Adler-32 of
+// the window's reconstructed target. Encoders that checksum different bytes
+// or use a different algorithm can be decoded by supplying their semantics
+// through WithChecksumValidator rather than skipping verification.
+type ChecksumValidator interface {
+	Checksum(window *Window, target []byte) uint32
+}
+
+// ChecksumFunc adapts an ordinary function to a ChecksumValidator
+type ChecksumFunc func(window *Window, target []byte) uint32
+
+// Checksum calls f(window, target)
+func (f ChecksumFunc) Checksum(window *Window, target []byte) uint32 {
+	return f(window, target)
+}
+
+// Checksum returns the Adler-32 of the window's target
+func (Adler32) Checksum(_ *Window, target []byte) uint32 {
+	return ComputeChecksum(1, target) // Adler32 starts with initial value 1
+}
+
+// WithChecksumValidator replaces the algorithm used to verify VCD_ADLER32
+// window checksums
+func WithChecksumValidator(v ChecksumValidator) DecoderOption {
+	return func(d *decoder) {
+		d.checksum = v
+	}
+}
+
+// WithoutChecksumVerification skips the verification of VCD_ADLER32 window
+// checksums, saving a pass over each target window where the payload is
+// already integrity-protected at a higher layer. Checksums are still parsed,
+// so the delta must be well formed, but Hooks.OnChecksum is not called and a
+// corrupt target is not detected.
+func WithoutChecksumVerification() DecoderOption {
+	return func(d *decoder) {
+		d.skipChecksum = true
+	}
+}
+
+// verifiesChecksum reports whether window's checksum is to be verified
+func (d *decoder) verifiesChecksum(window *Window) bool {
+	return window.HasChecksum && !d.skipChecksum
+}
@@ -0,0 +1,101 @@
+package vcdiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/adler32"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestChecksumValidator(t *testing.T) {
+	source := make([]byte, 300)
+	rand.New(rand.NewSource(3)).Read(source)
+	delta, target := fuzzyDelta(source, true, nil)
+
+	// Rewrite the stored Adler-32 as the CRC-32 a non-standard encoder would emit
+	adler := binary.BigEndian.AppendUint32(nil, ComputeChecksum(1, target))
+	at := bytes.Index(delta, adler)
+	if at < 0 {
+		t.Fatal("stored checksum not found in delta")
+	}
+	binary.BigEndian.PutUint32(delta[at:], crc32.ChecksumIEEE(target))
+
+	if _, err := Decode(source, delta); !errors.Is(err, ErrInvalidChecksum) {
+		t.Fatalf("default validator: got %v, expected ErrInvalidChecksum", err)
+	}
+
+	crc := ChecksumFunc(func(_ *Window, target []byte) uint32 {
+		return crc32.ChecksumIEEE(target)
+	})
+	var expected, computed uint32
+	hooks := Hooks{OnChecksum: func(_ int, e, c uint32) error {
+		expected, computed = e, c
+		return nil
+	}}
+	result, err := NewDecoder(source, WithChecksumValidator(crc), WithHooks(hooks)).Decode(delta)
+	if err != nil {
+		t.Fatalf("CRC-32 validator: %v", err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Fatal("decoded target differs")
+	}
+	if expected != computed || computed != crc32.ChecksumIEEE(target) {
+		t.Errorf("OnChecksum saw expected 0x%08x, computed 0x%08x", expected, computed)
+	}
+}
+
+func TestWithoutChecksumVerification(t *testing.T) {
+	source := make([]byte, 300)
+	rand.New(rand.NewSource(3)).Read(source)
+	delta, target := fuzzyDelta(source, true, nil)
+
+	adler := binary.BigEndian.AppendUint32(nil, ComputeChecksum(1, target))
+	at := bytes.Index(delta, adler)
+	if at < 0 {
+		t.Fatal("stored checksum not found in delta")
+	}
+	delta[at]++
+
+	if _, err := Decode(source, delta); !errors.Is(err, ErrInvalidChecksum) {
+		t.Fatalf("got %v, expected ErrInvalidChecksum", err)
+	}
+	hooks := Hooks{OnChecksum: func(int, uint32, uint32) error {
+		t.Error("OnChecksum called with verification skipped")
+		return nil
+	}}
+	result, err := NewDecoder(source, WithoutChecksumVerification(), WithHooks(hooks)).Decode(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Fatal("decoded target differs")
+	}
+}
+
+func TestNewAdler32(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	data := make([]byte, 20000)
+	rng.Read(data)
+
+	h := NewAdler32()
+	for rest := data; len(rest) > 0; {
+		n := min(rng.Intn(7000), len(rest))
+		h.Write(rest[:n])
+		rest = rest[n:]
+	}
+	want := adler32.Checksum(data)
+	if h.Sum32() != want || h.Sum32() != ComputeChecksum(1, data) {
+		t.Fatalf("got 0x%08x, expected 0x%08x", h.Sum32(), want)
+	}
+	if sum := h.Sum([]byte{'x'}); !bytes.Equal(sum, binary.BigEndian.AppendUint32([]byte{'x'}, want)) {
+		t.Fatalf("Sum gave % x", sum)
+	}
+
+	h.Reset()
+	if h.Sum32() != 1 || h.Size() != 4 {
+		t.Fatalf("reset digest is 0x%08x, size %d", h.Sum32(), h.Size())
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// auditFile describes one file read or written by an audited operation
+type auditFile struct {
+	Role   string `json:"role"`
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// auditRecord is the JSON line appended to the audit log for each operation
+type auditRecord struct {
+	Time       time.Time   `json:"time"`
+	Command    string      `json:"command"`
+	Inputs     []auditFile `json:"inputs"`
+	Output     *auditFile  `json:"output,omitempty"`
+	Result     string      `json:"result"`
+	Error      string      `json:"error,omitempty"`
+	DurationMS float64     `json:"duration_ms"`
+	User       string      `json:"user"`
+	Host       string      `json:"host"`
+
+	path string // audit log destination
+}
+
+// Audit record results
+const (
+	auditResultOK    = "ok"
+	auditResultError = "error"
+)
+
+// startAudit begins an audit record for command, or returns nil when path is
+// empty. All auditRecord methods are no-ops on a nil record.
+func startAudit(path, command string) *auditRecord {
+	if path == "" {
+		return nil
+	}
+	return &auditRecord{
+		Time:    time.Now().UTC(),
+		Command: command,
+		Inputs:  []auditFile{},
+		path:    path,
+	}
+}
+
+func newAuditFile(role, path string, data []byte) auditFile {
+	sum := sha256.Sum256(data)
+	return auditFile{Role: role, Path: path, Size: len(data), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// input records a file the operation read
+func (r *auditRecord) input(role, path string, data []byte) {
+	if r == nil {
+		return
+	}
+	r.Inputs = append(r.Inputs, newAuditFile(role, path, data))
+}
+
+// output records what the operation produced; an empty path means stdout
+func (r *auditRecord) output(path string, data []byte) {
+	if r == nil {
+		return
+	}
+	if path == "" {
+		path = "-"
+	}
+	f := newAuditFile("output", path, data)
+	r.Output = &f
+}
+
+// outputFile records an output written directly to path, reading it back to
+// fingerprint it
+func (r *auditRecord) outputFile(path string) error {
+	if r == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading output for audit log: %w", err)
+	}
+	r.output(path, data)
+	return nil
+}
+
+// finish appends the record to the audit log and returns opErr. Failing to
+// write the audit log is itself an error, since an unrecorded operation would
+// defeat its purpose.
+func (r *auditRecord) finish(opErr error) error {
+	if r == nil {
+		return opErr
+	}
+
+	r.DurationMS = float64(time.Since(r.Time)) / float64(time.Millisecond)
+	r.Result = auditResultOK
+	if opErr != nil {
+		r.Result = auditResultError
+		r.Error = opErr.Error()
+	}
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	} else {
+		r.User = os.Getenv("USER")
+	}
+	r.Host, _ = os.Hostname()
+
+	if err := r.write(); err != nil {
+		return errors.Join(opErr, err)
+	}
+	return opErr
+}
+
+// write appends the record as a single JSON line
+func (r *auditRecord) write() error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding audit record: %w", err)
+	}
+
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return nil
+}
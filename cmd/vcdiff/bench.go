@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// benchResult is one row of the bench table
+type benchResult struct {
+	tool      string
+	encode    time.Duration // Mean encode time, zero if the tool was not asked to encode
+	decode    time.Duration // Mean decode time
+	deltaSize int           // Size of the delta decoded
+	skipped   string        // Why the tool was not measured, if it was not
+}
+
+// externalTool is another VCDIFF implementation driven through its command line
+type externalTool struct {
+	name   string
+	binary string
+	encode func(base, target, delta string) []string
+	decode func(base, delta, output string) []string
+}
+
+// externalTools are the implementations bench --compare looks for on PATH
+var externalTools = []externalTool{
+	{
+		name:   "xdelta3",
+		binary: "xdelta3",
+		// Plain VCDIFF without secondary compression or an application header
+		encode: func(base, target, delta string) []string {
+			return []string{"-e", "-S", "-A", "-f", "-s", base, target, delta}
+		},
+		decode: func(base, delta, output string) []string {
+			return []string{"-d", "-f", "-s", base, delta, output}
+		},
+	},
+	{
+		name:   "open-vcdiff",
+		binary: "vcdiff",
+		encode: func(base, target, delta string) []string {
+			return []string{"encode", "-dictionary", base, "-target", target, "-delta", delta}
+		},
+		decode: func(base, delta, output string) []string {
+			return []string{"decode", "-dictionary", base, "-delta", delta, "-target", output}
+		},
+	},
+}
+
+// benchDecoder times this library decoding delta against base. If target is
+// not nil the decoded output must match it, and encoding target against base
+// is timed too.
+func benchDecoder(base, delta, target []byte, iterations int) (benchResult, error) {
+	result := benchResult{tool: "vcdiff-go", deltaSize: len(delta)}
+
+	if target != nil {
+		start := time.Now()
+		for i := 0; i < iterations; i++ {
+			if _, err := vcdiff.Encode(base, target); err != nil {
+				return result, fmt.Errorf("error encoding target: %w", err)
+			}
+		}
+		result.encode = time.Since(start) / time.Duration(iterations)
+	}
+
+	start := time.Now()
+	var decoded []byte
+	for i := 0; i < iterations; i++ {
+		var err error
+		if decoded, err = vcdiff.Decode(base, delta); err != nil {
+			return result, fmt.Errorf("error applying delta: %w", err)
+		}
+	}
+	result.decode = time.Since(start) / time.Duration(iterations)
+
+	if target != nil && !bytes.Equal(decoded, target) {
+		return result, errors.New("decoded output differs from the target file")
+	}
+	return result, nil
+}
+
+// benchTool times an external implementation encoding target against base
+// and decoding the result. A tool that is missing or fails is reported in the
+// row rather than as an error, so the other rows are still printed.
+func benchTool(tool externalTool, basePath, targetPath string, iterations int) benchResult {
+	result := benchResult{tool: tool.name}
+
+	path, err := exec.LookPath(tool.binary)
+	if err != nil || isSelf(path) {
+		// open-vcdiff's binary shares this tool's name
+		result.skipped = "not found on PATH"
+		return result
+	}
+
+	dir, err := os.MkdirTemp("", "vcdiff-bench")
+	if err != nil {
+		result.skipped = err.Error()
+		return result
+	}
+	defer os.RemoveAll(dir)
+	delta := filepath.Join(dir, "delta")
+	output := filepath.Join(dir, "output")
+
+	if result.encode, err = timeCommand(path, tool.encode(basePath, targetPath, delta), iterations); err != nil {
+		result.skipped = "encode failed: " + err.Error()
+		return result
+	}
+	if result.decode, err = timeCommand(path, tool.decode(basePath, delta, output), iterations); err != nil {
+		result.skipped = "decode failed: " + err.Error()
+		return result
+	}
+
+	deltaData, err := os.ReadFile(delta)
+	if err != nil {
+		result.skipped = err.Error()
+		return result
+	}
+	result.deltaSize = len(deltaData)
+
+	decoded, err := os.ReadFile(output)
+	target, targetErr := os.ReadFile(targetPath)
+	if err != nil || targetErr != nil || !bytes.Equal(decoded, target) {
+		result.skipped = "decoded output differs from the target file"
+	}
+	return result
+}
+
+// timeCommand runs a command iterations times and returns the mean duration
+func timeCommand(path string, args []string, iterations int) (time.Duration, error) {
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		var stderr bytes.Buffer
+		cmd := exec.Command(path, args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return 0, fmt.Errorf("%w: %s", err, firstLine(msg))
+			}
+			return 0, err
+		}
+	}
+	return time.Since(start) / time.Duration(iterations), nil
+}
+
+// isSelf reports whether path is this executable
+func isSelf(path string) bool {
+	self, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	a, errA := filepath.EvalSymlinks(self)
+	b, errB := filepath.EvalSymlinks(path)
+	return errA == nil && errB == nil && a == b
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
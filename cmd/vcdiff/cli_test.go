@@ -0,0 +1,587 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// resetFlags restores every flag of cmd and its subcommands to its default,
+// since the commands and their flag variables are package-level
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// runCLI executes the CLI in-process and returns its output and exit code
+func runCLI(args ...string) (stdout, stderr []byte, code int) {
+	return runCLIInput(nil, args...)
+}
+
+// runCLIInput is runCLI with stdin supplying standard input
+func runCLIInput(stdin []byte, args ...string) (stdout, stderr []byte, code int) {
+	resetFlags(rootCmd)
+	var out, errOut bytes.Buffer
+	code = run(args, bytes.NewReader(stdin), &out, &errOut)
+	return out.Bytes(), errOut.Bytes(), code
+}
+
+// cliTranscript renders a CLI run in the golden file format
+func cliTranscript(args []string, stdout, stderr []byte, code int) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "$ vcdiff %q\n", args)
+	fmt.Fprintf(&b, "exit: %d\n", code)
+	fmt.Fprintf(&b, "--- stdout ---\n%s", stdout)
+	fmt.Fprintf(&b, "--- stderr ---\n%s", stderr)
+	return b.Bytes()
+}
+
+func TestCLIGolden(t *testing.T) {
+	td := func(name string) string { return filepath.Join("testdata", name) }
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"apply-text", []string{"apply", "-b", td("text.source"), "-d", td("text.vcdiff")}},
+		{"apply-missing-delta-flag", []string{"apply", "-b", td("text.source")}},
+		{"apply-missing-base-file", []string{"apply", "-b", td("missing.source"), "-d", td("text.vcdiff")}},
+		{"apply-wrong-base", []string{"apply", "-b", td("no-source.source"), "-d", td("text.vcdiff")}},
+		{"apply-not-a-delta", []string{"apply", "-b", td("text.source"), "-d", td("text.source")}},
+		{"apply-checksum-mismatch", []string{"apply", "-b", td("text.source"), "-d", td("checksummed.vcdiff")}},
+		{"apply-fingerprint-mismatch", []string{"apply", "-b", td("mixed.source"), "-d", td("fingerprinted.vcdiff")}},
+		{"apply-resync-strict", []string{"apply", "-b", td("resync.shifted"), "-d", td("resync.vcdiff")}},
+		{"apply-resync-fuzzy", []string{"apply", "-b", td("resync.shifted"), "-d", td("resync.vcdiff"), "--fuzzy"}},
+		{"apply-fuzzy-unverified", []string{"apply", "-b", td("fingerprinted.edited"), "-d", td("fingerprinted.vcdiff"), "--fuzzy"}},
+		{"apply-both-stdin", []string{"apply", "-b", "-", "-d", "-"}},
+		{"apply-chain-wrong-base", []string{"apply", "-b", td("text.source"), "-d", td("text.vcdiff"), "-d", td("fingerprinted.vcdiff")}},
+		{"apply-sparse-without-output", []string{"apply", "-b", td("text.source"), "-d", td("text.vcdiff"), "--sparse"}},
+		{"parse-text", []string{"parse", "-d", td("text.vcdiff")}},
+		{"parse-checksummed", []string{"parse", "-d", td("checksummed.vcdiff")}},
+		{"parse-not-a-delta", []string{"parse", "-d", td("text.target")}},
+		{"analyze-text", []string{"analyze", "-b", td("text.source"), "-d", td("text.vcdiff")}},
+		{"analyze-missing-base-flag", []string{"analyze", "-d", td("text.vcdiff")}},
+		{"analyze-provenance", []string{"analyze", "-b", td("text.source"), "-d", td("text.vcdiff"), "--provenance"}},
+		{"analyze-provenance-wrong-base", []string{"analyze", "-b", td("mixed.source"), "-d", td("fingerprinted.vcdiff"), "--provenance"}},
+		{"id-fingerprinted", []string{"id", "-d", td("fingerprinted.vcdiff")}},
+		{"id-missing-delta-flag", []string{"id"}},
+		{"stats-json", []string{"stats", "-d", td("checksummed.vcdiff"), "--json"}},
+		{"stats-missing-delta-flag", []string{"stats"}},
+		{"grep-text", []string{"grep", "-b", td("text.source"), "-d", td("text.vcdiff"), "red fox|cat"}},
+		{"grep-fixed", []string{"grep", "-b", td("text.source"), "-d", td("text.vcdiff"), "-F", "jugs.!"}},
+		{"grep-no-match", []string{"grep", "-b", td("text.source"), "-d", td("text.vcdiff"), "dog"}},
+		{"grep-invalid-pattern", []string{"grep", "-b", td("text.source"), "-d", td("text.vcdiff"), "("}},
+		{"grep-missing-pattern", []string{"grep", "-b", td("text.source"), "-d", td("text.vcdiff")}},
+		{"split-missing-delta-flag", []string{"split"}},
+		{"split-not-a-delta", []string{"split", "-d", td("text.source")}},
+		{"bundle-odd-arguments", []string{"bundle", td("text.source")}},
+		{"textdiff", []string{"textdiff", "-b", td("text.source"), "-d", td("text.vcdiff")}},
+		{"textdiff-word", []string{"textdiff", "-b", td("text.source"), "-d", td("text.vcdiff"), "--word"}},
+		{"textdiff-binary", []string{"textdiff", "-b", td("mixed.source"), "-d", td("mixed.vcdiff")}},
+		{"split-align-without-base", []string{"split", "-d", td("checksummed.vcdiff"), "--align", "50"}},
+		{"merge-header-mismatch", []string{"merge", td("text.vcdiff"), td("fingerprinted.vcdiff")}},
+		{"merge-no-chunks", []string{"merge"}},
+		{"recode-add-checksums-without-base", []string{"recode", "-d", td("text.vcdiff"), "--add-checksums"}},
+		{"recode-add-and-strip-checksums", []string{"recode", "-b", td("checksummed.source"), "-d", td("checksummed.vcdiff"), "--add-checksums", "--strip-checksums"}},
+		{"bench-missing-delta-flag", []string{"bench", "-b", td("text.source")}},
+		{"bench-compare-without-target", []string{"bench", "-b", td("text.source"), "-d", td("text.vcdiff"), "--compare"}},
+		{"bench-wrong-target", []string{"bench", "-b", td("text.source"), "-d", td("text.vcdiff"), "-t", td("text.source")}},
+		{"unknown-command", []string{"frobnicate"}},
+		{"help", []string{"--help"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runCLI(tt.args...)
+			checkGolden(t, filepath.Join("cli", tt.name+".txt"), cliTranscript(tt.args, stdout, stderr, code))
+		})
+	}
+}
+
+func TestCLISplitMerge(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "chunk")
+	stdout, stderr, code := runCLI("split", "-d", "testdata/checksummed.vcdiff", "-o", prefix)
+	if code != 0 {
+		t.Fatalf("split exited %d: %s", code, stderr)
+	}
+	chunks, err := filepath.Glob(prefix + ".*.vcdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || !bytes.Contains(stdout, []byte(chunks[1])) {
+		t.Fatalf("split wrote %v, reported:\n%s", chunks, stdout)
+	}
+
+	// The chunks apply independently and their targets concatenate to the original
+	var target []byte
+	for _, chunk := range chunks {
+		out, stderr, code := runCLI("apply", "-b", "testdata/checksummed.source", "-d", chunk)
+		if code != 0 {
+			t.Fatalf("apply %s exited %d: %s", chunk, code, stderr)
+		}
+		target = append(target, out...)
+	}
+	want, err := os.ReadFile("testdata/checksummed.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(target, want) {
+		t.Fatal("applied chunks differ from the expected target")
+	}
+
+	merged, stderr, code := runCLI(append([]string{"merge"}, chunks...)...)
+	if code != 0 {
+		t.Fatalf("merge exited %d: %s", code, stderr)
+	}
+	original, err := os.ReadFile("testdata/checksummed.vcdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(merged, original) {
+		t.Fatal("merged chunks differ from the original delta")
+	}
+}
+
+func TestCLISplitAlign(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "chunk")
+	_, stderr, code := runCLI("split", "-d", "testdata/checksummed.vcdiff", "-b", "testdata/checksummed.source", "--align", "50", "-o", prefix)
+	if code != 0 {
+		t.Fatalf("split --align exited %d: %s", code, stderr)
+	}
+	chunks, err := filepath.Glob(prefix + ".*.vcdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every chunk but the last produces exactly 50 bytes
+	want, err := os.ReadFile("testdata/checksummed.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var target []byte
+	for i, chunk := range chunks {
+		out, stderr, code := runCLI("apply", "-b", "testdata/checksummed.source", "-d", chunk)
+		if code != 0 {
+			t.Fatalf("apply %s exited %d: %s", chunk, code, stderr)
+		}
+		if i < len(chunks)-1 && len(out) != 50 {
+			t.Fatalf("chunk %d produces %d bytes", i, len(out))
+		}
+		target = append(target, out...)
+	}
+	if len(chunks) != len(want)/50+1 || !bytes.Equal(target, want) {
+		t.Fatalf("%d aligned chunks do not reproduce the target", len(chunks))
+	}
+}
+
+func TestCLIBundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "update.vcdb")
+	_, stderr, code := runCLI("bundle", "-o", bundle,
+		"testdata/text.source", "testdata/text.vcdiff",
+		"testdata/mixed.source", "testdata/mixed.vcdiff")
+	if code != 0 {
+		t.Fatalf("bundle exited %d: %s", code, stderr)
+	}
+
+	// apply picks the delta made against whichever base it is given
+	for _, name := range []string{"text", "mixed"} {
+		out, stderr, code := runCLI("apply", "-b", "testdata/"+name+".source", "-d", bundle)
+		if code != 0 {
+			t.Fatalf("apply %s base exited %d: %s", name, code, stderr)
+		}
+		want, err := os.ReadFile("testdata/" + name + ".target")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Fatalf("apply %s base produced the wrong target", name)
+		}
+	}
+
+	_, stderr, code = runCLI("apply", "-b", "testdata/checksummed.source", "-d", bundle)
+	if code == 0 || !bytes.Contains(stderr, []byte("error selecting delta from bundle")) {
+		t.Fatalf("apply with an unknown base exited %d: %s", code, stderr)
+	}
+}
+
+func TestCLIRebase(t *testing.T) {
+	rebased := filepath.Join(t.TempDir(), "rebased.vcdiff")
+	_, stderr, code := runCLI("rebase", "-b", "testdata/resync.shifted", "-d", "testdata/resync.vcdiff", "-o", rebased)
+	if code != 0 {
+		t.Fatalf("rebase exited %d: %s", code, stderr)
+	}
+	if !bytes.Contains(stderr, []byte("shifted by")) || bytes.Contains(stderr, []byte("conflict")) {
+		t.Fatalf("unexpected rebase report:\n%s", stderr)
+	}
+
+	// The rebased delta applies to the shifted base without --fuzzy
+	out, stderr, code := runCLI("apply", "-b", "testdata/resync.shifted", "-d", rebased)
+	if code != 0 {
+		t.Fatalf("apply rebased delta exited %d: %s", code, stderr)
+	}
+	want, err := os.ReadFile("testdata/resync.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Fatal("rebased delta produces a different target")
+	}
+}
+
+func TestCLIRecode(t *testing.T) {
+	dir := t.TempDir()
+	stripped := filepath.Join(dir, "stripped.vcdiff")
+	_, stderr, code := runCLI("recode", "-d", "testdata/checksummed.vcdiff", "--strip-checksums", "-o", stripped)
+	if code != 0 {
+		t.Fatalf("recode --strip-checksums exited %d: %s", code, stderr)
+	}
+	checked, stderr, code := runCLI("recode", "-b", "testdata/checksummed.source", "-d", stripped, "--add-checksums")
+	if code != 0 {
+		t.Fatalf("recode --add-checksums exited %d: %s", code, stderr)
+	}
+
+	// Stripping and adding the checksums back restores the original delta
+	original, err := os.ReadFile("testdata/checksummed.vcdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checked, original) {
+		t.Fatal("recoded delta differs from the original")
+	}
+}
+
+func TestCLIBenchCompare(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stand-in tools are shell scripts")
+	}
+
+	// Stand-ins for the external tools: xdelta3 "encodes" by copying the
+	// target and decodes by copying it back, and open-vcdiff always fails
+	bin := t.TempDir()
+	scripts := map[string]string{
+		"xdelta3": "#!/bin/sh\nif [ \"$1\" = -e ]; then cp \"$7\" \"$8\"; else cp \"$5\" \"$6\"; fi\n",
+		"vcdiff":  "#!/bin/sh\necho unsupported >&2\nexit 1\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	stdout, stderr, code := runCLI("bench", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff",
+		"-t", "testdata/text.target", "--compare", "-n", "2")
+	if code != 0 {
+		t.Fatalf("bench exited %d: %s", code, stderr)
+	}
+
+	target, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	if len(rows) != 4 {
+		t.Fatalf("got %d table rows, expected a header and 3 tools:\n%s", len(rows), stdout)
+	}
+	checks := []struct {
+		prefix string
+		fields []string
+	}{
+		{"vcdiff-go", []string{"39"}},
+		{"xdelta3", []string{fmt.Sprint(len(target))}},
+		{"open-vcdiff", []string{"encode failed: exit status 1: unsupported"}},
+	}
+	for i, check := range checks {
+		row := rows[i+1]
+		if !strings.HasPrefix(row, check.prefix) {
+			t.Errorf("row %d is %q, expected %s", i+1, row, check.prefix)
+		}
+		for _, field := range check.fields {
+			if !strings.Contains(row, field) {
+				t.Errorf("row %q does not contain %q", row, field)
+			}
+		}
+	}
+}
+
+func TestCLIApplyOutputFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out")
+	stdout, stderr, code := runCLI("apply", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff", "-o", output)
+	if code != 0 {
+		t.Fatalf("apply exited %d: %s", code, stderr)
+	}
+	if len(stdout) != 0 {
+		t.Fatalf("apply with -o wrote %d bytes to stdout", len(stdout))
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output file differs from expected target")
+	}
+}
+
+func TestCLIStdin(t *testing.T) {
+	delta, err := os.ReadFile("testdata/text.vcdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := os.ReadFile("testdata/text.source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		stdin []byte
+		args  []string
+	}{
+		{delta, []string{"apply", "-b", "testdata/text.source", "-d", "-"}},
+		{base, []string{"apply", "-b", "-", "-d", "testdata/text.vcdiff", "-o", "-"}},
+	} {
+		stdout, stderr, code := runCLIInput(tt.stdin, tt.args...)
+		if code != 0 || !bytes.Equal(stdout, want) {
+			t.Fatalf("%q exited %d with %d bytes of output: %s", tt.args, code, len(stdout), stderr)
+		}
+	}
+
+	parsed, _, code := runCLIInput(delta, "parse", "-d", "-")
+	fromFile, _, _ := runCLI("parse", "-d", "testdata/text.vcdiff")
+	if code != 0 || !bytes.Equal(parsed, fromFile) {
+		t.Fatalf("parse of standard input exited %d and differs from parse of the file", code)
+	}
+	analyzed, _, code := runCLIInput(base, "analyze", "-b", "-", "-d", "testdata/text.vcdiff")
+	fromFile, _, _ = runCLI("analyze", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff")
+	if code != 0 || !bytes.Equal(analyzed, fromFile) {
+		t.Fatalf("analyze of standard input exited %d and differs from analyze of the files", code)
+	}
+}
+
+func TestCLIApplyChain(t *testing.T) {
+	intermediate, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("Second release: "), intermediate...)
+	delta, err := vcdiff.Encode(intermediate, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(t.TempDir(), "second.vcdiff")
+	if err := os.WriteFile(second, delta, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCLI("apply", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff", "-d", second)
+	if code != 0 || !bytes.Equal(stdout, want) {
+		t.Fatalf("chained apply exited %d with output %q: %s", code, stdout, stderr)
+	}
+}
+
+func TestCLIApplySparse(t *testing.T) {
+	want, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := os.ReadFile("testdata/text.source")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	copyPath := filepath.Join(dir, "base")
+	if err := os.WriteFile(copyPath, base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Into a clone of the base, and then patching the base in place
+	for _, output := range []string{filepath.Join(dir, "out"), copyPath} {
+		_, stderr, code := runCLI("apply", "-b", copyPath, "-d", "testdata/text.vcdiff", "-o", output, "--sparse")
+		if code != 0 {
+			t.Fatalf("apply --sparse exited %d: %s", code, stderr)
+		}
+		got, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("sparse output %s differs from expected target", output)
+		}
+	}
+}
+
+func TestCloneFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.WriteFile(src, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Whether reflinked or copied, the clone must be independent of its source
+	if err := cloneFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("modified"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "original" {
+		t.Fatalf("writing the clone changed its source to %q", got)
+	}
+
+	// An existing destination is replaced rather than left in place
+	if err := os.WriteFile(dst, []byte("a longer existing output"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cloneFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(dst); err != nil || string(got) != "original" {
+		t.Fatalf("cloning over an existing file gave %q, %v", got, err)
+	}
+}
+
+func TestCLIProfiles(t *testing.T) {
+	want, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"apply", []string{"apply", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff"}, 0},
+		{"parse", []string{"parse", "-d", "testdata/text.vcdiff"}, 0},
+		// Profiling must stop when a command fails, or the next run could
+		// not start it
+		{"failing", []string{"apply", "-b", "testdata/text.target", "-d", "testdata/checksummed.vcdiff"}, 1},
+		{"after failure", []string{"apply", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cpu := filepath.Join(dir, "cpu.pprof")
+			mem := filepath.Join(dir, "mem.pprof")
+
+			stdout, stderr, code := runCLI(append(tt.args, "--cpuprofile", cpu, "--memprofile", mem)...)
+			if code != tt.code {
+				t.Fatalf("%s exited %d: %s", tt.args[0], code, stderr)
+			}
+			if tt.args[0] == "apply" && code == 0 && !bytes.Equal(stdout, want) {
+				t.Fatalf("apply output differs from expected target")
+			}
+
+			for _, path := range []string{cpu, mem} {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("profile not written: %v", err)
+				}
+				if info.Size() == 0 {
+					t.Fatalf("profile %s is empty", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
+
+func TestCLIApplyAuditLog(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	if _, stderr, code := runCLI("apply", "-b", "testdata/text.source", "-d", "testdata/text.vcdiff",
+		"--audit-log", log); code != 0 {
+		t.Fatalf("apply exited %d: %s", code, stderr)
+	}
+	if _, _, code := runCLI("apply", "-b", "testdata/text.source", "-d", "testdata/checksummed.vcdiff",
+		"--audit-log", log); code == 0 {
+		t.Fatal("apply with the wrong base succeeded")
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d audit records, expected 2", len(lines))
+	}
+
+	var records [2]auditRecord
+	for i, line := range lines {
+		if err := json.Unmarshal(line, &records[i]); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if records[i].Command != "apply" || len(records[i].Inputs) != 2 || records[i].Host == "" {
+			t.Errorf("record %d incomplete: %s", i, line)
+		}
+	}
+
+	target, err := os.ReadFile("testdata/text.target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := records[0]
+	if ok.Result != auditResultOK || ok.Output == nil || ok.Output.Path != "-" ||
+		ok.Output.SHA256 != newAuditFile("", "", target).SHA256 {
+		t.Errorf("unexpected success record: %+v", ok)
+	}
+
+	failed := records[1]
+	if failed.Result != auditResultError || failed.Error == "" || failed.Output != nil {
+		t.Errorf("unexpected failure record: %+v", failed)
+	}
+}
+
+func TestCLIApplyReferenceDeltas(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			stdout, stderr, code := runCLI("apply", "-b", filepath.Join("testdata", name+".source"),
+				"-d", filepath.Join("testdata", name+".vcdiff"))
+			if code != 0 {
+				t.Fatalf("apply exited %d: %s", code, stderr)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", name+".target"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stdout, want) {
+				t.Fatalf("apply output differs from expected target")
+			}
+		})
+	}
+}
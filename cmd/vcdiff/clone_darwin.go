@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink creates dst as a clone of src on APFS, sharing its blocks until
+// either is written. clonefile will not replace an existing file, so the
+// clone is made under a temporary name beside dst and renamed over it. It
+// fails with ENOTSUP on filesystems that cannot clone and EXDEV across
+// volumes, which are reported as errors.ErrUnsupported.
+func reflink(src, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".clone-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	if err := os.Remove(tmpPath); err != nil {
+		return err
+	}
+
+	err = unix.Clonefile(src, tmpPath, unix.CLONE_NOFOLLOW)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return errors.Join(errors.ErrUnsupported, err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink creates dst sharing src's blocks on filesystems such as Btrfs and
+// XFS. The FICLONE ioctl fails with EOPNOTSUPP, EXDEV or EINVAL when the
+// filesystem cannot clone, which is reported as errors.ErrUnsupported; any
+// other failure is returned as is.
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		out.Close()
+		err = &os.SyscallError{Syscall: "ioctl FICLONE", Err: err}
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) {
+			return errors.Join(errors.ErrUnsupported, err)
+		}
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// reflink is not available on this platform, so cloneFile always copies
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	vcdiff "github.com/ably/vcdiff-go"
 	"github.com/spf13/cobra"
@@ -16,15 +21,59 @@ var rootCmd = &cobra.Command{
 	Long: `A command-line tool for working with VCDIFF (RFC 3284) delta files.
 
 VCDIFF is a format for expressing one data stream as a variant of another data stream,
-commonly used for binary differencing, compression, and patch applications.`,
+commonly used for binary differencing, compression, and patch applications.
+
+Every command accepts --cpuprofile and --memprofile to write pprof profiles
+of its run.`,
 	Version: "1.0.0",
+
+	// Errors and usage are reported by run
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the CLI with the given arguments and returns the process exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+
+	cmd, err := rootCmd.ExecuteC()
+	if stopErr := stopProfiling(); err == nil {
+		err = stopErr
 	}
+	if err != nil {
+		// Usage goes to stderr so it never mixes with command output
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, cmd.UsageString())
+		return 1
+	}
+	return 0
+}
+
+// stdioPath is the file argument that stands for standard input or output
+const stdioPath = "-"
+
+// readInput reads the file at path, or standard input when path is "-"
+func readInput(cmd *cobra.Command, path string) ([]byte, error) {
+	if path == stdioPath {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+	return os.ReadFile(path)
+}
+
+// checkStdin rejects reading more than one of the input paths from standard
+// input
+func checkStdin(paths ...string) error {
+	if n := slices.Index(paths, stdioPath); n >= 0 && slices.Contains(paths[n+1:], stdioPath) {
+		return fmt.Errorf("only one of --base and --delta can be read from standard input")
+	}
+	return nil
 }
 
 func init() {
@@ -32,6 +81,16 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(parseCmd)
 	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(idCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(grepCmd)
+	rootCmd.AddCommand(textdiffCmd)
+	rootCmd.AddCommand(splitCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(rebaseCmd)
+	rootCmd.AddCommand(recodeCmd)
+	rootCmd.AddCommand(benchCmd)
 }
 
 var applyCmd = &cobra.Command{
@@ -40,45 +99,114 @@ var applyCmd = &cobra.Command{
 	Long: `Apply a VCDIFF delta to a base document to produce the target document.
 
 The base document is the original file, and the delta contains the changes
-needed to transform it into the target document.`,
+needed to transform it into the target document. If the delta file is a patch
+bundle made with 'bundle', the delta made against this base is selected from it.
+
+Repeat --delta to apply a chain of incremental patches: each delta is applied
+to the result of the one before it, and only the final result is written.`,
 	Example: `  vcdiff apply -base old.txt -delta patch.vcdiff -output new.txt
-  vcdiff apply -base old.txt -delta patch.vcdiff  # Output to stdout`,
+  vcdiff apply -base old.txt -delta patch.vcdiff  # Output to stdout
+  vcdiff apply -b v1.bin -d v1-v2.vcdiff -d v2-v3.vcdiff -o v3.bin  # Apply in sequence
+  vcdiff apply -b old.txt -d patch.vcdiff -o new.txt --cpuprofile cpu.pprof
+  vcdiff apply -b disk.img -d patch.vcdiff -o disk.img --sparse  # Patch in place`,
 	RunE: runApply,
 }
 
 var (
 	applyBaseFile   string
-	applyDeltaFile  string
+	applyDeltaFiles []string
 	applyOutputFile string
+	applyAuditLog   string
+	applyFuzzy      bool
+	applyFuzzyRange int
+	applySparse     bool
 )
 
+// defaultFuzzyRange is how far, in bytes, --fuzzy searches for shifted source data
+const defaultFuzzyRange = 64
+
 func init() {
-	applyCmd.Flags().StringVarP(&applyBaseFile, "base", "b", "", "Path to base document file")
-	applyCmd.Flags().StringVarP(&applyDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
-	applyCmd.Flags().StringVarP(&applyOutputFile, "output", "o", "", "Path to output file (default: stdout)")
+	applyCmd.Flags().StringVarP(&applyBaseFile, "base", "b", "", "Path to base document file, or - for standard input")
+	applyCmd.Flags().StringArrayVarP(&applyDeltaFiles, "delta", "d", nil, "Path to VCDIFF delta file, or - for standard input; repeat to apply deltas in sequence")
+	applyCmd.Flags().StringVarP(&applyOutputFile, "output", "o", "", "Path to output file, or - for standard output (default: stdout)")
+	applyCmd.Flags().BoolVar(&applyFuzzy, "fuzzy", false, "Tolerate a base that differs slightly from the one the delta was made against")
+	applyCmd.Flags().IntVar(&applyFuzzyRange, "fuzzy-range", defaultFuzzyRange, "Maximum source offset shift, in bytes, searched by --fuzzy")
+	applyCmd.Flags().BoolVar(&applySparse, "sparse", false, "Clone the base into --output and write only the ranges the delta changes")
+	applyCmd.Flags().StringVar(&applyAuditLog, "audit-log", "", "Append a JSON audit record of this operation to this file")
 
 	// Mark required flags
 	applyCmd.MarkFlagRequired("base")
 	applyCmd.MarkFlagRequired("delta")
 }
 
-func runApply(cmd *cobra.Command, args []string) error {
-	baseData, err := os.ReadFile(applyBaseFile)
+func runApply(cmd *cobra.Command, args []string) (err error) {
+	audit := startAudit(applyAuditLog, cmd.Name())
+	defer func() { err = audit.finish(err) }()
+
+	if err := checkStdin(append([]string{applyBaseFile}, applyDeltaFiles...)...); err != nil {
+		return err
+	}
+	baseData, err := readInput(cmd, applyBaseFile)
 	if err != nil {
 		return fmt.Errorf("error reading base file: %w", err)
 	}
+	audit.input("base", applyBaseFile, baseData)
 
-	deltaData, err := os.ReadFile(applyDeltaFile)
-	if err != nil {
-		return fmt.Errorf("error reading delta file: %w", err)
+	deltas := make([][]byte, len(applyDeltaFiles))
+	for i, path := range applyDeltaFiles {
+		if deltas[i], err = readInput(cmd, path); err != nil {
+			return fmt.Errorf("error reading delta file: %w", err)
+		}
+		audit.input("delta", path, deltas[i])
 	}
 
-	result, err := vcdiff.Decode(baseData, deltaData)
-	if err != nil {
-		return fmt.Errorf("error applying delta: %w", err)
+	if applyOutputFile == stdioPath {
+		applyOutputFile = ""
+	}
+	if applySparse {
+		if applyOutputFile == "" || applyFuzzy || len(deltas) > 1 {
+			return fmt.Errorf("--sparse requires --output and a single --delta, and cannot be combined with --fuzzy")
+		}
+		if applyBaseFile == stdioPath {
+			return fmt.Errorf("--sparse clones the base file, so it cannot be read from standard input")
+		}
+		deltaData := deltas[0]
+		if vcdiff.IsBundle(deltaData) {
+			if deltaData, err = selectBundleDelta(baseData, deltaData); err != nil {
+				return err
+			}
+		}
+		if err := writeSparse(applyBaseFile, baseData, deltaData, applyOutputFile); err != nil {
+			return err
+		}
+		return audit.outputFile(applyOutputFile)
 	}
 
-	var output io.Writer = os.Stdout
+	// Each delta applies to the result of the one before it
+	result := baseData
+	for i, deltaData := range deltas {
+		if vcdiff.IsBundle(deltaData) {
+			if deltaData, err = selectBundleDelta(result, deltaData); err != nil {
+				return err
+			}
+		}
+
+		var opts []vcdiff.DecoderOption
+		var report vcdiff.FuzzyReport
+		if applyFuzzy {
+			opts = append(opts, vcdiff.WithFuzzy(applyFuzzyRange, &report))
+		}
+
+		if result, err = vcdiff.NewDecoder(result, opts...).Decode(deltaData); err != nil {
+			if len(deltas) > 1 {
+				return fmt.Errorf("error applying delta %d of %d (%s): %w", i+1, len(deltas), applyDeltaFiles[i], err)
+			}
+			return fmt.Errorf("error applying delta: %w", err)
+		}
+		printFuzzyReport(&report, cmd.ErrOrStderr())
+	}
+
+	output := cmd.OutOrStdout()
 	if applyOutputFile != "" {
 		file, err := os.Create(applyOutputFile)
 		if err != nil {
@@ -91,6 +219,7 @@ func runApply(cmd *cobra.Command, args []string) error {
 	if _, err := output.Write(result); err != nil {
 		return fmt.Errorf("error writing output: %w", err)
 	}
+	audit.output(applyOutputFile, result)
 
 	return nil
 }
@@ -110,12 +239,12 @@ instruction sequences contained in the delta file.`,
 var parseDeltaFile string
 
 func init() {
-	parseCmd.Flags().StringVarP(&parseDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	parseCmd.Flags().StringVarP(&parseDeltaFile, "delta", "d", "", "Path to VCDIFF delta file, or - for standard input")
 	parseCmd.MarkFlagRequired("delta")
 }
 
 func runParse(cmd *cobra.Command, args []string) error {
-	deltaData, err := os.ReadFile(parseDeltaFile)
+	deltaData, err := readInput(cmd, parseDeltaFile)
 	if err != nil {
 		return fmt.Errorf("error reading delta file: %w", err)
 	}
@@ -125,14 +254,7 @@ func runParse(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error parsing delta: %w", err)
 	}
 
-	printDelta(parsed)
-	fmt.Println()
-
-	if err := printInstructions(parsed, os.Stdout); err != nil {
-		return fmt.Errorf("error printing instructions: %w", err)
-	}
-
-	return nil
+	return renderParse(parsed, cmd.OutOrStdout())
 }
 
 var analyzeCmd = &cobra.Command{
@@ -142,20 +264,29 @@ var analyzeCmd = &cobra.Command{
 detailed information about the instructions and referenced data.
 
 This command shows the same information as 'parse' but also includes
-hexdump-style output of the actual data chunks referenced by COPY instructions.`,
+hexdump-style output of the actual data chunks referenced by COPY instructions.
+
+With --provenance, the delta is applied and the instructions are instead
+listed as a table ordered by target offset, giving for each range of the
+output the instruction that produced it and where its bytes came from: a
+range of the base, an earlier range of the target, or literal data in the
+delta.`,
 	Example: `  vcdiff analyze -base old.txt -delta patch.vcdiff
-  vcdiff analyze -b old.txt -d patch.vcdiff  # Short form`,
+  vcdiff analyze -b old.txt -d patch.vcdiff  # Short form
+  vcdiff analyze -b old.txt -d patch.vcdiff --provenance`,
 	RunE: runAnalyze,
 }
 
 var (
-	analyzeBaseFile  string
-	analyzeDeltaFile string
+	analyzeBaseFile   string
+	analyzeDeltaFile  string
+	analyzeProvenance bool
 )
 
 func init() {
-	analyzeCmd.Flags().StringVarP(&analyzeBaseFile, "base", "b", "", "Path to base document file")
-	analyzeCmd.Flags().StringVarP(&analyzeDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	analyzeCmd.Flags().StringVarP(&analyzeBaseFile, "base", "b", "", "Path to base document file, or - for standard input")
+	analyzeCmd.Flags().StringVarP(&analyzeDeltaFile, "delta", "d", "", "Path to VCDIFF delta file, or - for standard input")
+	analyzeCmd.Flags().BoolVar(&analyzeProvenance, "provenance", false, "List where each range of the target comes from, ordered by target offset")
 
 	// Mark required flags
 	analyzeCmd.MarkFlagRequired("base")
@@ -163,12 +294,15 @@ func init() {
 }
 
 func runAnalyze(cmd *cobra.Command, args []string) error {
-	baseData, err := os.ReadFile(analyzeBaseFile)
+	if err := checkStdin(analyzeBaseFile, analyzeDeltaFile); err != nil {
+		return err
+	}
+	baseData, err := readInput(cmd, analyzeBaseFile)
 	if err != nil {
 		return fmt.Errorf("error reading base file: %w", err)
 	}
 
-	deltaData, err := os.ReadFile(analyzeDeltaFile)
+	deltaData, err := readInput(cmd, analyzeDeltaFile)
 	if err != nil {
 		return fmt.Errorf("error reading delta file: %w", err)
 	}
@@ -178,302 +312,632 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error parsing delta: %w", err)
 	}
 
-	printDelta(parsed)
-	fmt.Println()
-
-	if err := printDetailedInstructions(parsed, baseData, os.Stdout); err != nil {
-		return fmt.Errorf("error printing detailed instructions: %w", err)
+	if analyzeProvenance {
+		_, spans, err := traceDecode(baseData, deltaData)
+		if err != nil {
+			return fmt.Errorf("error applying delta: %w", err)
+		}
+		return renderProvenance(parsed, spans, cmd.OutOrStdout())
 	}
+	return renderAnalyze(parsed, baseData, cmd.OutOrStdout())
+}
 
-	return nil
+var idCmd = &cobra.Command{
+	Use:   "id",
+	Short: "Print the fingerprints recoverable from a VCDIFF delta",
+	Long: `Print the identifying information that can be recovered from a delta alone,
+without its base document: the embedded source fingerprint (if present),
+the total target size, the application header, and each window's target
+length and Adler-32 checksum.
+
+This is useful for matching patches to the files they apply to in an archive.`,
+	Example: `  vcdiff id -delta patch.vcdiff
+  vcdiff id -d patch.vcdiff  # Short form`,
+	RunE: runID,
+}
+
+var idDeltaFile string
+
+func init() {
+	idCmd.Flags().StringVarP(&idDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	idCmd.MarkFlagRequired("delta")
 }
 
-func printDelta(parsed *vcdiff.ParsedDelta) {
-	printHeader(&parsed.Header)
-	fmt.Printf("  Windows:   %d\n", len(parsed.Windows))
+func runID(cmd *cobra.Command, args []string) error {
+	deltaData, err := os.ReadFile(idDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
 
-	for i, window := range parsed.Windows {
-		fmt.Printf("  Window %d:\n", i)
-		printWindow(&window)
+	parsed, err := vcdiff.ParseDelta(deltaData)
+	if err != nil {
+		return fmt.Errorf("error parsing delta: %w", err)
 	}
+
+	return renderID(parsed, cmd.OutOrStdout())
 }
 
-func printHeader(header *vcdiff.Header) {
-	fmt.Printf("VCDIFF Header:\n")
-	fmt.Printf("  Magic:     0x%02x 0x%02x 0x%02x\n",
-		header.Magic[0], header.Magic[1], header.Magic[2])
-	fmt.Printf("  Version:   0x%02x\n", header.Version)
-	fmt.Printf("  Indicator: 0x%02x", header.Indicator)
-	if header.Indicator != 0 {
-		fmt.Printf(" (")
-		var flags []string
-		if header.Indicator&vcdiff.VCDDecompress != 0 {
-			flags = append(flags, "VCD_DECOMPRESS")
-		}
-		if header.Indicator&vcdiff.VCDCodetable != 0 {
-			flags = append(flags, "VCD_CODETABLE")
-		}
-		if header.Indicator&vcdiff.VCDAppHeader != 0 {
-			flags = append(flags, "VCD_APPHEADER")
-		}
-		for i, flag := range flags {
-			if i > 0 {
-				fmt.Printf(", ")
-			}
-			fmt.Printf("%s", flag)
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the instructions and sections of a VCDIFF delta",
+	Long: `Summarize what a delta is made of: instruction counts and the target bytes
+each type produces, how much is copied from the source, the sizes of the
+data, instruction and address sections, and each window's compression ratio
+and checksum.
+
+With --json the summary is written as JSON for dashboards and scripts.`,
+	Example: `  vcdiff stats -delta patch.vcdiff
+  vcdiff stats -d patch.vcdiff --json`,
+	RunE: runStats,
+}
+
+var (
+	statsDeltaFile string
+	statsJSON      bool
+)
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Write the summary as JSON")
+	statsCmd.MarkFlagRequired("delta")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	deltaData, err := os.ReadFile(statsDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+
+	parsed, err := vcdiff.ParseDelta(deltaData)
+	if err != nil {
+		return fmt.Errorf("error parsing delta: %w", err)
+	}
+
+	stats := vcdiff.Stats(parsed)
+	if statsJSON {
+		out, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
 		}
-		fmt.Printf(")")
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", out)
+		return err
 	}
-	fmt.Printf("\n")
+	return renderStats(stats, cmd.OutOrStdout())
 }
 
-func printWindow(window *vcdiff.Window) {
-	fmt.Printf("    WinIndicator:   0x%02x", window.WinIndicator)
-	if window.WinIndicator != 0 {
-		fmt.Printf(" (")
-		var flags []string
-		if window.WinIndicator&vcdiff.VCDSource != 0 {
-			flags = append(flags, "VCD_SOURCE")
-		}
-		if window.WinIndicator&vcdiff.VCDTarget != 0 {
-			flags = append(flags, "VCD_TARGET")
+var grepCmd = &cobra.Command{
+	Use:   "grep PATTERN",
+	Short: "Search the reconstructed target and show where matches came from",
+	Long: `Apply a VCDIFF delta in memory, search the reconstructed target for a
+regular expression, and report each match's target offsets together with the
+instructions that produced its bytes and, for COPYs, the base or target region
+they were copied from.
+
+This answers questions like "where did this corrupted string come from?"
+without writing the target anywhere.`,
+	Example: `  vcdiff grep -base old.txt -delta patch.vcdiff 'red fox'
+  vcdiff grep -b old.txt -d patch.vcdiff -F '(null)'  # Literal pattern`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGrep,
+}
+
+var (
+	grepBaseFile  string
+	grepDeltaFile string
+	grepFixed     bool
+)
+
+func init() {
+	grepCmd.Flags().StringVarP(&grepBaseFile, "base", "b", "", "Path to base document file")
+	grepCmd.Flags().StringVarP(&grepDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	grepCmd.Flags().BoolVarP(&grepFixed, "fixed-strings", "F", false, "Treat PATTERN as a literal string rather than a regular expression")
+
+	// Mark required flags
+	grepCmd.MarkFlagRequired("base")
+	grepCmd.MarkFlagRequired("delta")
+}
+
+func runGrep(cmd *cobra.Command, args []string) error {
+	expr := args[0]
+	if grepFixed {
+		expr = regexp.QuoteMeta(expr)
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	baseData, err := os.ReadFile(grepBaseFile)
+	if err != nil {
+		return fmt.Errorf("error reading base file: %w", err)
+	}
+
+	deltaData, err := os.ReadFile(grepDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+
+	target, spans, err := traceDecode(baseData, deltaData)
+	if err != nil {
+		return fmt.Errorf("error applying delta: %w", err)
+	}
+
+	renderGrep(target, spans, pattern, cmd.OutOrStdout())
+	return nil
+}
+
+var textdiffCmd = &cobra.Command{
+	Use:   "textdiff",
+	Short: "Show a text diff of what a VCDIFF delta changes",
+	Long: `Apply a VCDIFF delta in memory and print a unified diff between the base
+document and the reconstructed target, so reviewers can see what a binary
+patch changes. With --word, the whole target is printed with removed words
+marked [-like this-] and added words {+like this+}.
+
+Both documents must be text: valid UTF-8 without NUL bytes.`,
+	Example: `  vcdiff textdiff -base config.json -delta patch.vcdiff
+  vcdiff textdiff -b notes.txt -d patch.vcdiff --word`,
+	RunE: runTextdiff,
+}
+
+var (
+	textdiffBaseFile  string
+	textdiffDeltaFile string
+	textdiffContext   int
+	textdiffWord      bool
+)
+
+func init() {
+	textdiffCmd.Flags().StringVarP(&textdiffBaseFile, "base", "b", "", "Path to base document file")
+	textdiffCmd.Flags().StringVarP(&textdiffDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	textdiffCmd.Flags().IntVarP(&textdiffContext, "unified", "U", defaultDiffContext, "Number of unchanged lines shown around each change")
+	textdiffCmd.Flags().BoolVar(&textdiffWord, "word", false, "Show a word diff instead of a line diff")
+
+	// Mark required flags
+	textdiffCmd.MarkFlagRequired("base")
+	textdiffCmd.MarkFlagRequired("delta")
+}
+
+func runTextdiff(cmd *cobra.Command, args []string) error {
+	baseData, err := os.ReadFile(textdiffBaseFile)
+	if err != nil {
+		return fmt.Errorf("error reading base file: %w", err)
+	}
+	deltaData, err := os.ReadFile(textdiffDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+
+	target, err := vcdiff.Decode(baseData, deltaData)
+	if err != nil {
+		return fmt.Errorf("error applying delta: %w", err)
+	}
+	if !isText(baseData) || !isText(target) {
+		return fmt.Errorf("base and target must both be text; use 'analyze' for binary documents")
+	}
+
+	if textdiffWord {
+		renderWordDiff(diffTokens(splitWords(baseData), splitWords(target)), cmd.OutOrStdout())
+		return nil
+	}
+	hunks := diffHunks(diffTokens(splitLines(baseData), splitLines(target)), max(0, textdiffContext))
+	renderUnifiedDiff(textdiffBaseFile, "target", hunks, cmd.OutOrStdout())
+	return nil
+}
+
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split a multi-window VCDIFF delta into single-window deltas",
+	Long: `Split a VCDIFF delta into one standalone delta file per window, so a large
+patch can be distributed and fetched in parallel chunks.
+
+Each chunk carries the original header and applies to the same base document;
+applying the chunks in order and concatenating their output reproduces the
+original target. Use 'merge' to reassemble the chunks into one delta.
+
+With --align, windows are first re-cut so that every chunk except the last
+produces exactly that many target bytes, so chunk N covers a predictable
+target range. Re-cutting needs the base document.`,
+	Example: `  vcdiff split -delta patch.vcdiff  # Writes patch.000.vcdiff, patch.001.vcdiff, ...
+  vcdiff split -d patch.vcdiff -o chunks/part
+  vcdiff split -d patch.vcdiff -b base.img --align 4194304  # One chunk per 4 MiB of target`,
+	RunE: runSplit,
+}
+
+var (
+	splitDeltaFile    string
+	splitOutputPrefix string
+	splitBaseFile     string
+	splitAlign        uint32
+)
+
+// minChunkDigits is the minimum width of the chunk number in split file names
+const minChunkDigits = 3
+
+func init() {
+	splitCmd.Flags().StringVarP(&splitDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	splitCmd.Flags().StringVarP(&splitOutputPrefix, "output", "o", "", "Prefix of the chunk files (default: the delta path without its extension)")
+	splitCmd.Flags().StringVarP(&splitBaseFile, "base", "b", "", "Path to base document, required by --align")
+	splitCmd.Flags().Uint32Var(&splitAlign, "align", 0, "Re-cut windows so each chunk produces this many target bytes")
+	splitCmd.MarkFlagRequired("delta")
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	deltaData, err := os.ReadFile(splitDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+
+	if splitAlign > 0 {
+		if splitBaseFile == "" {
+			return fmt.Errorf("--align requires --base")
 		}
-		if window.WinIndicator&vcdiff.VCDAdler32 != 0 {
-			flags = append(flags, "VCD_ADLER32")
+		baseData, err := os.ReadFile(splitBaseFile)
+		if err != nil {
+			return fmt.Errorf("error reading base file: %w", err)
 		}
-		for j, flag := range flags {
-			if j > 0 {
-				fmt.Printf(", ")
-			}
-			fmt.Printf("%s", flag)
+		if deltaData, err = alignDelta(deltaData, baseData, splitAlign); err != nil {
+			return fmt.Errorf("error aligning delta: %w", err)
 		}
-		fmt.Printf(")")
-	}
-	fmt.Printf("\n")
-	fmt.Printf("    SourceSegmentSize:  0x%x (%d)\n", window.SourceSegmentSize, window.SourceSegmentSize)
-	fmt.Printf("    SourceSegmentPosition:   0x%x (%d)\n", window.SourceSegmentPosition, window.SourceSegmentPosition)
-	fmt.Printf("    TargetWindowLength:  0x%x (%d)\n", window.TargetWindowLength, window.TargetWindowLength)
-	fmt.Printf("    DeltaEncodingLength: 0x%x (%d)\n", window.DeltaEncodingLength, window.DeltaEncodingLength)
-	fmt.Printf("    DeltaIndicator: 0x%02x\n", window.DeltaIndicator)
-	fmt.Printf("    DataSectionLength: 0x%x (%d)\n", window.DataSectionLength, window.DataSectionLength)
-	fmt.Printf("    InstructionSectionLength: 0x%x (%d)\n", window.InstructionSectionLength, window.InstructionSectionLength)
-	fmt.Printf("    AddressSectionLength: 0x%x (%d)\n", window.AddressSectionLength, window.AddressSectionLength)
-	if window.HasChecksum {
-		fmt.Printf("    Adler32:     0x%08x\n", window.Checksum)
-	}
-}
-
-func printDetailedInstructions(parsed *vcdiff.ParsedDelta, baseData []byte, w io.Writer) error {
-	fmt.Fprintf(w, "Instructions with Data Context:\n")
-	fmt.Fprintf(w, "===============================\n\n")
-
-	for i, instruction := range parsed.Instructions {
-		fmt.Fprintf(w, "Instruction %d:\n", i+1)
-
-		var instType string
-		switch instruction.Type {
-		case vcdiff.Add:
-			instType = "ADD"
-		case vcdiff.Copy:
-			instType = "COPY"
-		case vcdiff.Run:
-			instType = "RUN"
-		case vcdiff.NoOp:
-			instType = "NOOP"
-		default:
-			instType = fmt.Sprintf("UNK(%02x)", instruction.Type)
+	}
+
+	chunks, err := splitDelta(deltaData)
+	if err != nil {
+		return fmt.Errorf("error splitting delta: %w", err)
+	}
+
+	prefix := splitOutputPrefix
+	if prefix == "" {
+		prefix = strings.TrimSuffix(splitDeltaFile, filepath.Ext(splitDeltaFile))
+	}
+	digits := max(minChunkDigits, len(strconv.Itoa(len(chunks)-1)))
+	for i, chunk := range chunks {
+		path := fmt.Sprintf("%s.%0*d.vcdiff", prefix, digits, i)
+		if err := os.WriteFile(path, chunk, 0o644); err != nil {
+			return fmt.Errorf("error writing chunk: %w", err)
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s (%d bytes)\n", path, len(chunk))
+	}
+	return nil
+}
 
-		fmt.Fprintf(w, "  Type: %s\n", instType)
-		fmt.Fprintf(w, "  Mode: 0x%02x\n", instruction.Mode)
-		fmt.Fprintf(w, "  Size: 0x%x (%d bytes)\n", instruction.Size, instruction.Size)
+var mergeCmd = &cobra.Command{
+	Use:   "merge CHUNK...",
+	Short: "Merge VCDIFF deltas produced by split back into one delta",
+	Long: `Merge VCDIFF deltas that share the same header, such as the chunks written
+by 'split', into a single delta containing all of their windows in the order
+given.`,
+	Example: `  vcdiff merge -o patch.vcdiff patch.000.vcdiff patch.001.vcdiff
+  vcdiff merge patch.*.vcdiff > patch.vcdiff`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMerge,
+}
 
-		if instruction.Type == vcdiff.Copy {
-			fmt.Fprintf(w, "  Addr: 0x%x (%d)\n", instruction.Addr, instruction.Addr)
+var mergeOutputFile string
 
-			if instruction.Addr < uint32(len(baseData)) {
-				endAddr := instruction.Addr + instruction.Size
-				if endAddr > uint32(len(baseData)) {
-					endAddr = uint32(len(baseData))
-				}
+func init() {
+	mergeCmd.Flags().StringVarP(&mergeOutputFile, "output", "o", "", "Path to output file (default: stdout)")
+}
 
-				fmt.Fprintf(w, "  Data from base [0x%x:0x%x]:\n", instruction.Addr, endAddr)
-				printHexDump(baseData[instruction.Addr:endAddr], w, int(instruction.Addr))
-			} else {
-				fmt.Fprintf(w, "  Data: <address out of bounds>\n")
-			}
-		} else if len(instruction.Data) > 0 {
-			fmt.Fprintf(w, "  Data:\n")
-			printHexDump(instruction.Data, w, 0)
+func runMerge(cmd *cobra.Command, args []string) error {
+	var deltas [][]byte
+	for _, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading delta file: %w", err)
 		}
+		deltas = append(deltas, data)
+	}
+
+	merged, err := mergeDeltas(deltas)
+	if err != nil {
+		return fmt.Errorf("error merging deltas: %w", err)
+	}
 
-		fmt.Fprintf(w, "\n")
+	output := cmd.OutOrStdout()
+	if mergeOutputFile != "" {
+		file, err := os.Create(mergeOutputFile)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		defer file.Close()
+		output = file
 	}
 
+	if _, err := output.Write(merged); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
 	return nil
 }
 
-func printHexDump(data []byte, w io.Writer, baseOffset int) {
-	const bytesPerLine = 16
-
-	for i := 0; i < len(data); i += bytesPerLine {
-		end := i + bytesPerLine
-		if end > len(data) {
-			end = len(data)
+var bundleCmd = &cobra.Command{
+	Use:   "bundle BASE DELTA [BASE DELTA]...",
+	Short: "Bundle deltas from several base versions to one target",
+	Long: `Combine deltas made against different base versions of the same target into
+one patch bundle. Each delta is stored with a fingerprint of its base, so
+'apply' can pick the delta that matches whichever base a client has.`,
+	Example: `  vcdiff bundle -o update.vcdb v1.bin v1-v3.vcdiff v2.bin v2-v3.vcdiff
+  vcdiff apply -b v2.bin -d update.vcdb -o v3.bin`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 || len(args)%2 != 0 {
+			return fmt.Errorf("expected BASE DELTA pairs, got %d arguments", len(args))
 		}
+		return nil
+	},
+	RunE: runBundle,
+}
 
-		line := data[i:end]
-
-		fmt.Fprintf(w, "    %08x  ", baseOffset+i)
+var bundleOutputFile string
 
-		for j := 0; j < bytesPerLine; j++ {
-			if j < len(line) {
-				fmt.Fprintf(w, "%02x ", line[j])
-			} else {
-				fmt.Fprintf(w, "   ")
-			}
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleOutputFile, "output", "o", "", "Path to output file (default: stdout)")
+}
 
-			if j == 7 {
-				fmt.Fprintf(w, " ")
-			}
+func runBundle(cmd *cobra.Command, args []string) error {
+	var bundle vcdiff.Bundle
+	for i := 0; i < len(args); i += 2 {
+		baseData, err := os.ReadFile(args[i])
+		if err != nil {
+			return fmt.Errorf("error reading base file: %w", err)
+		}
+		deltaData, err := os.ReadFile(args[i+1])
+		if err != nil {
+			return fmt.Errorf("error reading delta file: %w", err)
 		}
+		bundle.Add(baseData, deltaData)
+	}
 
-		fmt.Fprintf(w, " |")
-		for j := 0; j < len(line); j++ {
-			if line[j] >= 32 && line[j] <= 126 {
-				fmt.Fprintf(w, "%c", line[j])
-			} else {
-				fmt.Fprintf(w, ".")
-			}
+	data, err := vcdiff.MarshalBundle(&bundle)
+	if err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+
+	output := cmd.OutOrStdout()
+	if bundleOutputFile != "" {
+		file, err := os.Create(bundleOutputFile)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
 		}
+		defer file.Close()
+		output = file
+	}
 
-		fmt.Fprintf(w, "|\n")
+	if _, err := output.Write(data); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// selectBundleDelta returns the delta in bundle made against base
+func selectBundleDelta(base, bundle []byte) ([]byte, error) {
+	parsed, err := vcdiff.ParseBundle(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle: %w", err)
+	}
+	entry, err := parsed.Select(base)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting delta from bundle: %w", err)
 	}
+	return entry.Delta, nil
+}
+
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase",
+	Short: "Rewrite a VCDIFF delta to apply to a locally modified base",
+	Long: `Rewrite a VCDIFF delta so that it applies to a locally modified copy of the
+base it was made against, much like a three-way merge.
+
+Data the delta copies from the base is searched for in the modified base,
+up to --fuzzy-range bytes from where it was, and the delta is re-addressed
+to copy it from there. Windows whose data cannot be found are conflicts: they
+are kept as encoded, without their checksum, and reported on stderr so the
+rebased delta still applies.`,
+	Example: `  vcdiff rebase -b modified.bin -d patch.vcdiff -o rebased.vcdiff
+  vcdiff rebase -b modified.bin -d patch.vcdiff --fuzzy-range 4096 > rebased.vcdiff`,
+	RunE: runRebase,
+}
+
+var (
+	rebaseBaseFile   string
+	rebaseDeltaFile  string
+	rebaseOutputFile string
+	rebaseRange      int
+)
+
+func init() {
+	rebaseCmd.Flags().StringVarP(&rebaseBaseFile, "base", "b", "", "Path to the modified base document")
+	rebaseCmd.Flags().StringVarP(&rebaseDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	rebaseCmd.Flags().StringVarP(&rebaseOutputFile, "output", "o", "", "Path to output file (default: stdout)")
+	rebaseCmd.Flags().IntVar(&rebaseRange, "fuzzy-range", defaultFuzzyRange, "Maximum source offset shift, in bytes, searched for copied data")
+	rebaseCmd.MarkFlagRequired("base")
+	rebaseCmd.MarkFlagRequired("delta")
 }
 
-func printInstructions(parsed *vcdiff.ParsedDelta, w io.Writer) error {
-	fmt.Fprintf(w, "  Offset Code Type1 Size1  @Addr1 + Type2 Size2 @Addr2\n")
+func runRebase(cmd *cobra.Command, args []string) error {
+	baseData, err := os.ReadFile(rebaseBaseFile)
+	if err != nil {
+		return fmt.Errorf("error reading base file: %w", err)
+	}
+	deltaData, err := os.ReadFile(rebaseDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
 
-	for _, window := range parsed.Windows {
-		err := printWindowInstructions(&window, w)
+	result, err := vcdiff.Rebase(deltaData, baseData, rebaseRange)
+	if err != nil {
+		return fmt.Errorf("error rebasing delta: %w", err)
+	}
+	printRebaseReport(result, cmd.ErrOrStderr())
+
+	output := cmd.OutOrStdout()
+	if rebaseOutputFile != "" {
+		file, err := os.Create(rebaseOutputFile)
 		if err != nil {
-			return err
+			return fmt.Errorf("error creating output file: %w", err)
 		}
+		defer file.Close()
+		output = file
 	}
 
+	if _, err := output.Write(result.Delta); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
 	return nil
 }
 
-func printWindowInstructions(window *vcdiff.Window, w io.Writer) error {
-	instructionStream := bytes.NewReader(window.InstructionSection)
-	addressStream := bytes.NewReader(window.AddressSection)
+var recodeCmd = &cobra.Command{
+	Use:   "recode",
+	Short: "Rewrite a VCDIFF delta with checksums, app header or source positions changed",
+	Long: `Rewrite a VCDIFF delta without changing what it produces, to normalize deltas
+made by different encoders.
+
+Checksums can be added to every window or stripped from all of them. Adding
+them decodes the delta, so it needs the base. The application header can be
+dropped, and every source segment can be moved by --shift-source bytes so the
+delta applies to a base with data inserted (positive) or removed (negative)
+ahead of everything it copies.`,
+	Example: `  vcdiff recode -d patch.vcdiff --strip-checksums --drop-app-header -o plain.vcdiff
+  vcdiff recode -b base.bin -d patch.vcdiff --add-checksums > checked.vcdiff
+  vcdiff recode -d patch.vcdiff --shift-source 512 -o shifted.vcdiff`,
+	RunE: runRecode,
+}
 
-	offset := 0
+var (
+	recodeBaseFile       string
+	recodeDeltaFile      string
+	recodeOutputFile     string
+	recodeAddChecksums   bool
+	recodeStripChecksums bool
+	recodeDropAppHeader  bool
+	recodeShiftSource    int64
+)
 
-	for {
-		code, err := instructionStream.ReadByte()
-		if err == io.EOF {
-			break
+func init() {
+	recodeCmd.Flags().StringVarP(&recodeBaseFile, "base", "b", "", "Path to base document, required by --add-checksums")
+	recodeCmd.Flags().StringVarP(&recodeDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	recodeCmd.Flags().StringVarP(&recodeOutputFile, "output", "o", "", "Path to output file (default: stdout)")
+	recodeCmd.Flags().BoolVar(&recodeAddChecksums, "add-checksums", false, "Add an Adler-32 checksum to every window")
+	recodeCmd.Flags().BoolVar(&recodeStripChecksums, "strip-checksums", false, "Remove every window's checksum")
+	recodeCmd.Flags().BoolVar(&recodeDropAppHeader, "drop-app-header", false, "Remove the application header")
+	recodeCmd.Flags().Int64Var(&recodeShiftSource, "shift-source", 0, "Move every source segment by this many bytes")
+	recodeCmd.MarkFlagRequired("delta")
+}
+
+func runRecode(cmd *cobra.Command, args []string) error {
+	if recodeAddChecksums && recodeBaseFile == "" {
+		return fmt.Errorf("--add-checksums requires --base")
+	}
+	deltaData, err := os.ReadFile(recodeDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+	var baseData []byte
+	if recodeBaseFile != "" {
+		if baseData, err = os.ReadFile(recodeBaseFile); err != nil {
+			return fmt.Errorf("error reading base file: %w", err)
 		}
+	}
+
+	recoded, err := vcdiff.Recode(deltaData, baseData, vcdiff.RecodeOptions{
+		AddChecksums:   recodeAddChecksums,
+		StripChecksums: recodeStripChecksums,
+		DropAppHeader:  recodeDropAppHeader,
+		ShiftSource:    recodeShiftSource,
+	})
+	if err != nil {
+		return fmt.Errorf("error recoding delta: %w", err)
+	}
+
+	output := cmd.OutOrStdout()
+	if recodeOutputFile != "" {
+		file, err := os.Create(recodeOutputFile)
 		if err != nil {
-			return err
+			return fmt.Errorf("error creating output file: %w", err)
 		}
+		defer file.Close()
+		output = file
+	}
 
-		// Look up instructions from code table
-		inst1 := vcdiff.DefaultCodeTable.Get(code, 0)
-		inst2 := vcdiff.DefaultCodeTable.Get(code, 1)
+	if _, err := output.Write(recoded); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
 
-		fmt.Fprintf(w, "  %06x %03d  ", offset, code)
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark encoding and decoding, optionally against xdelta3 and open-vcdiff",
+	Long: `Time how long this tool takes to apply a VCDIFF delta, and with --target
+how long it takes to encode the target against the base.
+
+With --compare, the base and target files are also run through xdelta3 and
+open-vcdiff when they are found on PATH. Each one encodes the pair and
+decodes its own delta, and a side-by-side table of encode time, decode time
+and delta size is printed. This tool's decode time and delta size are those
+of the --delta file.`,
+	Example: `  vcdiff bench -base old.txt -delta patch.vcdiff
+  vcdiff bench -b old.txt -d patch.vcdiff -t new.txt --compare -n 50`,
+	RunE: runBench,
+}
 
-		// Print first instruction
-		if inst1.Type != vcdiff.NoOp {
-			err := printSingleInstruction(inst1, instructionStream, addressStream, w)
-			if err != nil {
-				return err
-			}
-		}
+var (
+	benchBaseFile   string
+	benchDeltaFile  string
+	benchTargetFile string
+	benchCompare    bool
+	benchIterations int
+)
 
-		// Print second instruction if it exists
-		if inst2.Type != vcdiff.NoOp {
-			fmt.Fprintf(w, " + ")
-			err := printSingleInstruction(inst2, instructionStream, addressStream, w)
-			if err != nil {
-				return err
-			}
-		}
+// defaultBenchIterations is how many times each encode and decode is timed
+const defaultBenchIterations = 10
 
-		fmt.Fprintf(w, "\n")
-		offset++
-	}
+func init() {
+	benchCmd.Flags().StringVarP(&benchBaseFile, "base", "b", "", "Path to base document file")
+	benchCmd.Flags().StringVarP(&benchDeltaFile, "delta", "d", "", "Path to VCDIFF delta file")
+	benchCmd.Flags().StringVarP(&benchTargetFile, "target", "t", "", "Path to target document file, checked against decoded output (required with --compare)")
+	benchCmd.Flags().BoolVar(&benchCompare, "compare", false, "Also benchmark xdelta3 and open-vcdiff if they are on PATH")
+	benchCmd.Flags().IntVarP(&benchIterations, "iterations", "n", defaultBenchIterations, "Number of timed runs to average")
 
-	return nil
+	// Mark required flags
+	benchCmd.MarkFlagRequired("base")
+	benchCmd.MarkFlagRequired("delta")
 }
 
-func printSingleInstruction(inst vcdiff.Instruction, instructionStream *bytes.Reader, addressStream *bytes.Reader, w io.Writer) error {
-	// Get instruction type string
-	var typeStr string
-	switch inst.Type {
-	case vcdiff.Add:
-		typeStr = "ADD"
-	case vcdiff.Copy:
-		typeStr = fmt.Sprintf("CPY_%d", inst.Mode)
-	case vcdiff.Run:
-		typeStr = "RUN"
-	case vcdiff.NoOp:
-		typeStr = "NOOP"
-	default:
-		typeStr = fmt.Sprintf("UNK_%02x", inst.Type)
-	}
-
-	// Get size
-	size := uint32(inst.Size)
-	if size == 0 && inst.Type != vcdiff.NoOp {
-		var err error
-		size, err = vcdiff.ReadVarint(instructionStream)
-		if err != nil {
-			return err
-		}
+func runBench(cmd *cobra.Command, args []string) error {
+	if benchIterations < 1 {
+		return fmt.Errorf("--iterations must be at least 1, got %d", benchIterations)
+	}
+	if benchCompare && benchTargetFile == "" {
+		return fmt.Errorf("--compare requires --target")
 	}
 
-	// Get address for COPY instructions
-	var addrStr string
-	if inst.Type == vcdiff.Copy {
-		switch inst.Mode {
-		case 0: // SELF mode
-			addr, err := vcdiff.ReadVarint(addressStream)
-			if err != nil {
-				return err
-			}
-			addrStr = fmt.Sprintf("S@%d", addr)
-		case 1: // HERE mode
-			offset, err := vcdiff.ReadVarint(addressStream)
-			if err != nil {
-				return err
-			}
-			addrStr = fmt.Sprintf("H@%d", offset)
-		default:
-			// Near/Same cache modes
-			if inst.Mode < 6 {
-				offset, err := vcdiff.ReadVarint(addressStream)
-				if err != nil {
-					return err
-				}
-				addrStr = fmt.Sprintf("N%d@%d", inst.Mode-2, offset)
-			} else {
-				b, err := addressStream.ReadByte()
-				if err != nil {
-					return err
-				}
-				addrStr = fmt.Sprintf("S%d@%d", inst.Mode-6, b)
-			}
+	baseData, err := os.ReadFile(benchBaseFile)
+	if err != nil {
+		return fmt.Errorf("error reading base file: %w", err)
+	}
+
+	deltaData, err := os.ReadFile(benchDeltaFile)
+	if err != nil {
+		return fmt.Errorf("error reading delta file: %w", err)
+	}
+
+	var targetData []byte
+	if benchTargetFile != "" {
+		if targetData, err = os.ReadFile(benchTargetFile); err != nil {
+			return fmt.Errorf("error reading target file: %w", err)
 		}
 	}
 
-	if inst.Type == vcdiff.Copy {
-		fmt.Fprintf(w, "%s %6d %s", typeStr, size, addrStr)
-	} else {
-		fmt.Fprintf(w, "%s %6d", typeStr, size)
+	ours, err := benchDecoder(baseData, deltaData, targetData, benchIterations)
+	if err != nil {
+		return err
+	}
+	results := []benchResult{ours}
+	if benchCompare {
+		for _, tool := range externalTools {
+			results = append(results, benchTool(tool, benchBaseFile, benchTargetFile, benchIterations))
+		}
 	}
 
-	return nil
+	return renderBench(results, cmd.OutOrStdout())
 }
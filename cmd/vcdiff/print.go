@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// renderParse writes the output of the parse command
+func renderParse(parsed *vcdiff.ParsedDelta, w io.Writer) error {
+	if err := vcdiff.Disassemble(w, parsed); err != nil {
+		return fmt.Errorf("error printing instructions: %w", err)
+	}
+
+	return nil
+}
+
+// renderAnalyze writes the output of the analyze command
+func renderAnalyze(parsed *vcdiff.ParsedDelta, baseData []byte, w io.Writer) error {
+	printDelta(parsed, w)
+	fmt.Fprintln(w)
+
+	if err := printDetailedInstructions(parsed, baseData, w); err != nil {
+		return fmt.Errorf("error printing detailed instructions: %w", err)
+	}
+
+	return nil
+}
+
+// renderProvenance writes the output of analyze --provenance: the delta's
+// structure, then one row per instruction in target order showing where its
+// bytes came from
+func renderProvenance(parsed *vcdiff.ParsedDelta, spans []span, w io.Writer) error {
+	printDelta(parsed, w)
+	fmt.Fprintf(w, "\nTarget Provenance:\n")
+	fmt.Fprintf(w, "==================\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Target\tLength\tWindow\tInstruction\tOrigin\n")
+	for _, s := range spans {
+		origin := "literal"
+		if s.typ == vcdiff.Copy {
+			from := "target"
+			if s.fromSource {
+				from = "base"
+			}
+			origin = fmt.Sprintf("%s %d-%d", from, s.from, s.from+s.end-s.start)
+		}
+		fmt.Fprintf(tw, "%d-%d\t%d\t%d\t%s\t%s\n", s.start, s.end, s.end-s.start, s.window, s.typ, origin)
+	}
+	return tw.Flush()
+}
+
+// renderID writes the output of the id command: the fingerprints that can be
+// recovered from the delta alone
+func renderID(parsed *vcdiff.ParsedDelta, w io.Writer) error {
+	var targetSize uint64
+	for _, window := range parsed.Windows {
+		targetSize += uint64(window.TargetWindowLength)
+	}
+	fmt.Fprintf(w, "Target size: %d\n", targetSize)
+
+	fingerprint, ok, err := vcdiff.ParseSourceFingerprint(parsed.Header.AppHeader)
+	if err != nil {
+		return fmt.Errorf("error reading source fingerprint: %w", err)
+	}
+	if ok {
+		fmt.Fprintf(w, "Source fingerprint:\n")
+		fmt.Fprintf(w, "  Length:  %d\n", fingerprint.Length)
+		if fingerprint.HasAdler32 {
+			fmt.Fprintf(w, "  Adler32: 0x%08x\n", fingerprint.Adler32)
+		}
+		if fingerprint.HasSHA256 {
+			fmt.Fprintf(w, "  SHA256:  %x\n", fingerprint.SHA256)
+		}
+	} else {
+		fmt.Fprintf(w, "Source fingerprint: none\n")
+	}
+
+	switch {
+	case parsed.Header.Indicator&vcdiff.VCDAppHeader == 0:
+		fmt.Fprintf(w, "App header: none\n")
+	case ok:
+		fmt.Fprintf(w, "App header: source fingerprint (%d bytes)\n", len(parsed.Header.AppHeader))
+	default:
+		fmt.Fprintf(w, "App header: %d bytes %q\n", len(parsed.Header.AppHeader), parsed.Header.AppHeader)
+	}
+
+	fmt.Fprintf(w, "Windows: %d\n", len(parsed.Windows))
+	for i, window := range parsed.Windows {
+		fmt.Fprintf(w, "  Window %d: %d bytes", i, window.TargetWindowLength)
+		if window.HasChecksum {
+			fmt.Fprintf(w, ", Adler32 0x%08x", window.Checksum)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	return nil
+}
+
+// renderStats writes the output of the stats command
+func renderStats(stats vcdiff.DeltaStats, w io.Writer) error {
+	fmt.Fprintf(w, "Delta size:  %d\n", stats.DeltaLength)
+	fmt.Fprintf(w, "Target size: %d\n", stats.TargetLength)
+	fmt.Fprintf(w, "Ratio:       %.3f\n", stats.Ratio)
+	fmt.Fprintf(w, "Checksums:   %d of %d windows\n\n", stats.ChecksummedWindows, len(stats.Windows))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Instruction\tCount\tBytes\n")
+	fmt.Fprintf(tw, "ADD\t%d\t%d\n", stats.Add.Count, stats.Add.Bytes)
+	fmt.Fprintf(tw, "COPY\t%d\t%d\n", stats.Copy.Count, stats.Copy.Bytes)
+	fmt.Fprintf(tw, "  from source\t%d\t%d\n", stats.SourceCopy.Count, stats.SourceCopy.Bytes)
+	fmt.Fprintf(tw, "RUN\t%d\t%d\n", stats.Run.Count, stats.Run.Bytes)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	data, instructions, addresses := stats.Sections.Shares()
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(tw, "Section\tBytes\tShare\n")
+	fmt.Fprintf(tw, "data\t%d\t%.1f%%\n", stats.Sections.Data, 100*data)
+	fmt.Fprintf(tw, "instructions\t%d\t%.1f%%\n", stats.Sections.Instructions, 100*instructions)
+	fmt.Fprintf(tw, "addresses\t%d\t%.1f%%\n", stats.Sections.Addresses, 100*addresses)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(tw, "Window\tEncoded\tTarget\tRatio\tADD\tCOPY\tRUN\tChecksum\n")
+	for i, window := range stats.Windows {
+		checksum := "no"
+		if window.HasChecksum {
+			checksum = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.3f\t%d\t%d\t%d\t%s\n", i, window.EncodedLength, window.TargetLength, window.Ratio,
+			window.Add.Count, window.Copy.Count, window.Run.Count, checksum)
+	}
+	return tw.Flush()
+}
+
+// renderUnifiedDiff writes hunks in diff -u format
+func renderUnifiedDiff(baseName, targetName string, hunks []diffHunk, w io.Writer) {
+	if len(hunks) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", baseName, targetName)
+	for _, h := range hunks {
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(h.baseStart, h.baseLen), hunkRange(h.tgtStart, h.tgtLen))
+		for _, op := range h.ops {
+			fmt.Fprintf(w, "%c%s", op.kind, op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				fmt.Fprintf(w, "\n\\ No newline at end of file\n")
+			}
+		}
+	}
+}
+
+// hunkRange formats one side of a hunk header, omitting a length of one
+func hunkRange(start, length int) string {
+	if length == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// renderWordDiff writes the target with removed words as [-...-] and added
+// words as {+...+}, like git diff --word-diff
+func renderWordDiff(ops []diffOp, w io.Writer) {
+	for i := 0; i < len(ops); {
+		kind := ops[i].kind
+		var run strings.Builder
+		for ; i < len(ops) && ops[i].kind == kind; i++ {
+			run.WriteString(ops[i].text)
+		}
+		switch kind {
+		case '-':
+			fmt.Fprintf(w, "[-%s-]", run.String())
+		case '+':
+			fmt.Fprintf(w, "{+%s+}", run.String())
+		default:
+			io.WriteString(w, run.String())
+		}
+	}
+}
+
+// renderGrep writes the output of the grep command: each match of pattern in
+// the target, followed by the instructions that produced its bytes
+func renderGrep(target []byte, spans []span, pattern *regexp.Regexp, w io.Writer) {
+	matches := pattern.FindAllIndex(target, -1)
+	if len(matches) == 0 {
+		fmt.Fprintf(w, "No matches\n")
+		return
+	}
+
+	for _, m := range matches {
+		start, end := uint64(m[0]), uint64(m[1])
+		fmt.Fprintf(w, "Match at target %d-%d: %q\n", start, end, target[start:end])
+		for _, s := range overlapping(spans, start, end) {
+			fmt.Fprintf(w, "  %d-%d window %d %s", s.start, s.end, s.window, s.typ)
+			if s.typ == vcdiff.Copy {
+				from := "target"
+				if s.fromSource {
+					from = "base"
+				}
+				fmt.Fprintf(w, " from %s %d-%d", from, s.from, s.from+s.end-s.start)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
+
+// renderBench writes the bench results as a table. Encode time is shown only
+// for tools that encoded the file pair themselves.
+func renderBench(results []benchResult, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Tool\tEncode\tDecode\tDelta size\n")
+	for _, r := range results {
+		if r.skipped != "" {
+			fmt.Fprintf(tw, "%s\t%s\n", r.tool, r.skipped)
+			continue
+		}
+		encode := "-"
+		if r.encode != 0 {
+			encode = r.encode.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", r.tool, encode, r.decode.Round(time.Microsecond), r.deltaSize)
+	}
+	return tw.Flush()
+}
+
+// printFuzzyReport warns about the parts of a fuzzy apply that were
+// reconstructed with reduced confidence
+func printFuzzyReport(report *vcdiff.FuzzyReport, w io.Writer) {
+	if report.SourceMismatch {
+		fmt.Fprintf(w, "warning: base does not match the delta's source fingerprint\n")
+	}
+	for _, region := range report.Regions {
+		end := region.TargetOffset + uint64(region.Length)
+		if region.Verified {
+			fmt.Fprintf(w, "warning: window %d: target bytes %d-%d copied from base shifted by %+d (checksum verified)\n",
+				region.Window, region.TargetOffset, end, region.Shift)
+		} else {
+			fmt.Fprintf(w, "warning: window %d: target bytes %d-%d copied from base without verification\n",
+				region.Window, region.TargetOffset, end)
+		}
+	}
+}
+
+func printRebaseReport(result *vcdiff.RebaseResult, w io.Writer) {
+	for _, region := range result.Shifted {
+		fmt.Fprintf(w, "window %d: target bytes %d-%d now copied from base shifted by %+d\n",
+			region.Window, region.TargetOffset, region.TargetOffset+uint64(region.Length), region.Shift)
+	}
+	for _, region := range result.Conflicts {
+		fmt.Fprintf(w, "conflict: window %d: target bytes %d-%d copied from the modified base unverified\n",
+			region.Window, region.TargetOffset, region.TargetOffset+uint64(region.Length))
+	}
+}
+
+func printDelta(parsed *vcdiff.ParsedDelta, w io.Writer) {
+	printHeader(&parsed.Header, w)
+	fmt.Fprintf(w, "  Windows:   %d\n", len(parsed.Windows))
+
+	for i, window := range parsed.Windows {
+		fmt.Fprintf(w, "  Window %d:\n", i)
+		printWindow(&window, w)
+	}
+}
+
+func printHeader(header *vcdiff.Header, w io.Writer) {
+	fmt.Fprintf(w, "VCDIFF Header:\n")
+	fmt.Fprintf(w, "  Magic:     0x%02x 0x%02x 0x%02x\n",
+		header.Magic[0], header.Magic[1], header.Magic[2])
+	fmt.Fprintf(w, "  Version:   0x%02x\n", header.Version)
+	fmt.Fprintf(w, "  Indicator: 0x%02x", header.Indicator)
+	if header.Indicator != 0 {
+		fmt.Fprintf(w, " (")
+		var flags []string
+		if header.Indicator&vcdiff.VCDDecompress != 0 {
+			flags = append(flags, "VCD_DECOMPRESS")
+		}
+		if header.Indicator&vcdiff.VCDCodetable != 0 {
+			flags = append(flags, "VCD_CODETABLE")
+		}
+		if header.Indicator&vcdiff.VCDAppHeader != 0 {
+			flags = append(flags, "VCD_APPHEADER")
+		}
+		for i, flag := range flags {
+			if i > 0 {
+				fmt.Fprintf(w, ", ")
+			}
+			fmt.Fprintf(w, "%s", flag)
+		}
+		fmt.Fprintf(w, ")")
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+func printWindow(window *vcdiff.Window, w io.Writer) {
+	fmt.Fprintf(w, "    WinIndicator:   0x%02x", window.WinIndicator)
+	if window.WinIndicator != 0 {
+		fmt.Fprintf(w, " (")
+		var flags []string
+		if window.WinIndicator&vcdiff.VCDSource != 0 {
+			flags = append(flags, "VCD_SOURCE")
+		}
+		if window.WinIndicator&vcdiff.VCDTarget != 0 {
+			flags = append(flags, "VCD_TARGET")
+		}
+		if window.WinIndicator&vcdiff.VCDAdler32 != 0 {
+			flags = append(flags, "VCD_ADLER32")
+		}
+		for j, flag := range flags {
+			if j > 0 {
+				fmt.Fprintf(w, ", ")
+			}
+			fmt.Fprintf(w, "%s", flag)
+		}
+		fmt.Fprintf(w, ")")
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "    SourceSegmentSize:  0x%x (%d)\n", window.SourceSegmentSize, window.SourceSegmentSize)
+	fmt.Fprintf(w, "    SourceSegmentPosition:   0x%x (%d)\n", window.SourceSegmentPosition, window.SourceSegmentPosition)
+	fmt.Fprintf(w, "    TargetWindowLength:  0x%x (%d)\n", window.TargetWindowLength, window.TargetWindowLength)
+	fmt.Fprintf(w, "    DeltaEncodingLength: 0x%x (%d)\n", window.DeltaEncodingLength, window.DeltaEncodingLength)
+	fmt.Fprintf(w, "    DeltaIndicator: 0x%02x\n", window.DeltaIndicator)
+	fmt.Fprintf(w, "    DataSectionLength: 0x%x (%d)\n", window.DataSectionLength, window.DataSectionLength)
+	fmt.Fprintf(w, "    InstructionSectionLength: 0x%x (%d)\n", window.InstructionSectionLength, window.InstructionSectionLength)
+	fmt.Fprintf(w, "    AddressSectionLength: 0x%x (%d)\n", window.AddressSectionLength, window.AddressSectionLength)
+	if window.Interleaved {
+		fmt.Fprintf(w, "    Layout: interleaved (open-vcdiff)\n")
+	}
+	if window.HasChecksum {
+		fmt.Fprintf(w, "    Adler32:     0x%08x\n", window.Checksum)
+	}
+}
+
+func printDetailedInstructions(parsed *vcdiff.ParsedDelta, baseData []byte, w io.Writer) error {
+	fmt.Fprintf(w, "Instructions with Data Context:\n")
+	fmt.Fprintf(w, "===============================\n\n")
+
+	for i, instruction := range parsed.Instructions {
+		fmt.Fprintf(w, "Instruction %d:\n", i+1)
+
+		fmt.Fprintf(w, "  Type: %s\n", instruction.Type)
+		fmt.Fprintf(w, "  Mode: 0x%02x\n", instruction.Mode)
+		fmt.Fprintf(w, "  Size: 0x%x (%d bytes)\n", instruction.Size, instruction.Size)
+
+		if instruction.Type == vcdiff.Copy {
+			fmt.Fprintf(w, "  Addr: 0x%x (%d)\n", instruction.Addr, instruction.Addr)
+
+			if instruction.Addr < uint32(len(baseData)) {
+				endAddr := instruction.Addr + instruction.Size
+				if endAddr > uint32(len(baseData)) {
+					endAddr = uint32(len(baseData))
+				}
+
+				fmt.Fprintf(w, "  Data from base [0x%x:0x%x]:\n", instruction.Addr, endAddr)
+				printHexDump(baseData[instruction.Addr:endAddr], w, int(instruction.Addr))
+			} else {
+				fmt.Fprintf(w, "  Data: <address out of bounds>\n")
+			}
+		} else if len(instruction.Data) > 0 {
+			fmt.Fprintf(w, "  Data:\n")
+			printHexDump(instruction.Data, w, 0)
+		}
+
+		fmt.Fprintf(w, "\n")
+	}
+
+	return nil
+}
+
+func printHexDump(data []byte, w io.Writer, baseOffset int) {
+	const bytesPerLine = 16
+
+	for i := 0; i < len(data); i += bytesPerLine {
+		end := i + bytesPerLine
+		if end > len(data) {
+			end = len(data)
+		}
+
+		line := data[i:end]
+
+		fmt.Fprintf(w, "    %08x  ", baseOffset+i)
+
+		for j := 0; j < bytesPerLine; j++ {
+			if j < len(line) {
+				fmt.Fprintf(w, "%02x ", line[j])
+			} else {
+				fmt.Fprintf(w, "   ")
+			}
+
+			if j == 7 {
+				fmt.Fprintf(w, " ")
+			}
+		}
+
+		fmt.Fprintf(w, " |")
+		for j := 0; j < len(line); j++ {
+			if line[j] >= 32 && line[j] <= 126 {
+				fmt.Fprintf(w, "%c", line[j])
+			} else {
+				fmt.Fprintf(w, ".")
+			}
+		}
+
+		fmt.Fprintf(w, "|\n")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with current output")
+
+// referenceDeltas returns the names of the checked-in reference deltas
+func referenceDeltas(t *testing.T) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.vcdiff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no reference deltas found in testdata")
+	}
+
+	var names []string
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".vcdiff"))
+	}
+	return names
+}
+
+// checkGolden compares got with the named golden file, rewriting it when -update is set
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run go test -update): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update to accept)\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+func TestParseGolden(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			delta, err := os.ReadFile(filepath.Join("testdata", name+".vcdiff"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := vcdiff.ParseDelta(delta)
+			if err != nil {
+				t.Fatalf("ParseDelta failed: %v", err)
+			}
+
+			var text bytes.Buffer
+			if err := renderParse(parsed, &text); err != nil {
+				t.Fatalf("renderParse failed: %v", err)
+			}
+			checkGolden(t, name+".parse.txt", text.Bytes())
+
+			structure, err := json.MarshalIndent(parsed, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, name+".parse.json", append(structure, '\n'))
+		})
+	}
+}
+
+func TestAnalyzeGolden(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			delta, err := os.ReadFile(filepath.Join("testdata", name+".vcdiff"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			base, err := os.ReadFile(filepath.Join("testdata", name+".source"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := vcdiff.ParseDelta(delta)
+			if err != nil {
+				t.Fatalf("ParseDelta failed: %v", err)
+			}
+
+			var text bytes.Buffer
+			if err := renderAnalyze(parsed, base, &text); err != nil {
+				t.Fatalf("renderAnalyze failed: %v", err)
+			}
+			checkGolden(t, name+".analyze.txt", text.Bytes())
+		})
+	}
+}
+
+func TestIDGolden(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			delta, err := os.ReadFile(filepath.Join("testdata", name+".vcdiff"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := vcdiff.ParseDelta(delta)
+			if err != nil {
+				t.Fatalf("ParseDelta failed: %v", err)
+			}
+
+			var text bytes.Buffer
+			if err := renderID(parsed, &text); err != nil {
+				t.Fatalf("renderID failed: %v", err)
+			}
+			checkGolden(t, name+".id.txt", text.Bytes())
+		})
+	}
+}
+
+func TestStatsGolden(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			delta, err := os.ReadFile(filepath.Join("testdata", name+".vcdiff"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := vcdiff.ParseDelta(delta)
+			if err != nil {
+				t.Fatalf("ParseDelta failed: %v", err)
+			}
+
+			var text bytes.Buffer
+			if err := renderStats(vcdiff.Stats(parsed), &text); err != nil {
+				t.Fatalf("renderStats failed: %v", err)
+			}
+			checkGolden(t, name+".stats.txt", text.Bytes())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/spf13/cobra"
+)
+
+// profileFlags holds the pprof output paths
+type profileFlags struct {
+	cpuProfile string
+	memProfile string
+}
+
+var (
+	// profiling holds the paths given to --cpuprofile and --memprofile,
+	// which every command accepts
+	profiling profileFlags
+
+	// stopProfile ends the profiles of the running command, once started
+	stopProfile func() error
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&profiling.cpuProfile, "cpuprofile", "", "Write a CPU profile of the command to this file")
+	rootCmd.PersistentFlags().StringVar(&profiling.memProfile, "memprofile", "", "Write a heap profile to this file when the command finishes")
+
+	// Profiles start once flags are parsed, so they cover exactly the
+	// command's own work
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		stop, err := profiling.start()
+		if err != nil {
+			return err
+		}
+		stopProfile = stop
+		return nil
+	}
+}
+
+// stopProfiling ends the profiles of the command that ran, if it started
+// any. run calls it rather than a post-run hook, which cobra skips when the
+// command fails.
+func stopProfiling() error {
+	stop := stopProfile
+	stopProfile = nil
+	if stop == nil {
+		return nil
+	}
+	return stop()
+}
+
+// start begins CPU profiling if requested and returns a function that stops
+// it and writes the heap profile
+func (p *profileFlags) start() (func() error, error) {
+	var cpuFile *os.File
+	if p.cpuProfile != "" {
+		f, err := os.Create(p.cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("error creating CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return fmt.Errorf("error writing CPU profile: %w", err)
+			}
+		}
+
+		if p.memProfile != "" {
+			f, err := os.Create(p.memProfile)
+			if err != nil {
+				return fmt.Errorf("error creating memory profile: %w", err)
+			}
+			defer f.Close()
+
+			// Collect garbage so the profile reflects live allocations
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				return fmt.Errorf("error writing memory profile: %w", err)
+			}
+		}
+		return nil
+	}, nil
+}
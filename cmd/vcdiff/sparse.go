@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// writeSparse writes the target to outputPath by cloning the base there and
+// then writing only the ranges the delta changes. When outputPath is the base
+// itself it is patched in place.
+func writeSparse(basePath string, baseData, delta []byte, outputPath string) (err error) {
+	if !sameFile(basePath, outputPath) {
+		if err := cloneFile(basePath, outputPath); err != nil {
+			return fmt.Errorf("error cloning base file: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(outputPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error opening output file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("error writing output: %w", closeErr)
+		}
+	}()
+
+	result, err := vcdiff.ApplySparse(file, baseData, delta)
+	if err != nil {
+		return fmt.Errorf("error applying delta: %w", err)
+	}
+	if err := file.Truncate(result.TargetLength); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// cloneFile copies src to dst. It first asks the filesystem for a reflink,
+// which shares every block with src and takes no time or space regardless of
+// size. Where that is unsupported it falls back to a plain copy, which still
+// lets the kernel use copy_file_range.
+func cloneFile(src, dst string) error {
+	if err := reflink(src, dst); !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return errors.Join(err, out.Close())
+}
+
+// sameFile reports whether both paths name the same existing file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
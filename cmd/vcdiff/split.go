@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// splitDelta breaks a delta into one standalone delta per window. Each keeps
+// the original header, and source positions stay absolute, so every chunk
+// applies to the same base and their targets concatenate to the original.
+func splitDelta(delta []byte) ([][]byte, error) {
+	ranges, err := vcdiff.IndexWindows(delta)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("delta has no windows")
+	}
+
+	header := delta[:ranges[0].Offset]
+	chunks := make([][]byte, len(ranges))
+	for i, r := range ranges {
+		chunk := append([]byte{}, header...)
+		chunks[i] = append(chunk, delta[r.Offset:r.Offset+r.Length]...)
+	}
+	return chunks, nil
+}
+
+// alignDelta re-cuts the windows of delta so that each produces boundary
+// bytes of target, apart from the last
+func alignDelta(delta, base []byte, boundary uint32) ([]byte, error) {
+	parsed, err := vcdiff.ParseDelta(delta)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.AlignWindows(boundary, base); err != nil {
+		return nil, err
+	}
+	return vcdiff.MarshalDelta(parsed)
+}
+
+// mergeDeltas joins deltas that share a header into a single delta holding
+// all of their windows in order
+func mergeDeltas(deltas [][]byte) ([]byte, error) {
+	var header, merged []byte
+	for i, delta := range deltas {
+		ranges, err := vcdiff.IndexWindows(delta)
+		if err != nil {
+			return nil, fmt.Errorf("delta %d: %w", i, err)
+		}
+
+		end := uint64(len(delta))
+		if len(ranges) > 0 {
+			end = ranges[0].Offset
+		}
+		if i == 0 {
+			header = delta[:end]
+			merged = append(merged, header...)
+		} else if !bytes.Equal(delta[:end], header) {
+			return nil, fmt.Errorf("delta %d: header differs from the first delta's", i)
+		}
+		merged = append(merged, delta[end:]...)
+	}
+	return merged, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultDiffContext is the number of unchanged lines around each hunk, as
+// in diff -u
+const defaultDiffContext = 3
+
+// diffOp is one token of an edit script: kept (' '), removed ('-') or added ('+')
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// isText reports whether data looks like text: valid UTF-8 without NULs
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// splitLines splits data into lines, each keeping its trailing newline
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		lines = append(lines, string(data[:end]))
+		data = data[end:]
+	}
+	return lines
+}
+
+// splitWords splits data into alternating runs of whitespace and
+// non-whitespace
+func splitWords(data []byte) []string {
+	var words []string
+	for len(data) > 0 {
+		r, _ := utf8.DecodeRune(data)
+		space := unicode.IsSpace(r)
+		end := bytes.IndexFunc(data, func(r rune) bool { return unicode.IsSpace(r) != space })
+		if end < 0 {
+			end = len(data)
+		}
+		words = append(words, string(data[:end]))
+		data = data[end:]
+	}
+	return words
+}
+
+// diffTokens returns a shortest edit script turning a into b, using Myers'
+// O(ND) algorithm on what remains after trimming the common prefix and suffix
+func diffTokens(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, s := range a[:prefix] {
+		ops = append(ops, diffOp{' ', s})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, s := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', s})
+	}
+	return ops
+}
+
+// myers finds a shortest edit script between a and b. For each edit distance
+// d it records the furthest x reached on diagonals -d..d, then backtracks
+// through those records from the end.
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= n+m; d++ {
+		// Diagonals -d-1..d+1 are all the backtrack step for d can read
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack rebuilds the edit script from the per-distance snapshots
+func backtrack(a, b []string, trace [][]int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+				y--
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffHunk is a run of ops shown together, with the line each side starts at
+type diffHunk struct {
+	ops                []diffOp
+	baseStart, baseLen int
+	tgtStart, tgtLen   int
+}
+
+// diffHunks groups changed lines with up to context unchanged lines either
+// side, merging changes whose context would overlap
+func diffHunks(ops []diffOp, context int) []diffHunk {
+	var hunks []diffHunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend over later changes separated by at most 2*context unchanged lines
+		start, end := max(0, i-context), i
+		for j := i; j < len(ops) && j-end <= 2*context+1; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		end = min(len(ops), end+context+1)
+
+		h := diffHunk{ops: ops[start:end], baseStart: 1, tgtStart: 1}
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				h.baseStart++
+			}
+			if op.kind != '-' {
+				h.tgtStart++
+			}
+		}
+		for _, op := range h.ops {
+			if op.kind != '+' {
+				h.baseLen++
+			}
+			if op.kind != '-' {
+				h.tgtLen++
+			}
+		}
+
+		// An empty side is numbered by the line before it, as diff -u does
+		if h.baseLen == 0 {
+			h.baseStart--
+		}
+		if h.tgtLen == 0 {
+			h.tgtStart--
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
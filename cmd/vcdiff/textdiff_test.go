@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiffTokensIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		a := randomTokens(rng)
+		b := randomTokens(rng)
+		ops := diffTokens(a, b)
+
+		// Each side is recovered from the script
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				gotA = append(gotA, op.text)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.text)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("%q -> %q: script %v does not reproduce both sides", a, b, ops)
+		}
+
+		// A shortest script edits everything outside a longest common subsequence
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("%q -> %q: %d edits, shortest is %d", a, b, edits, want)
+		}
+	}
+}
+
+func randomTokens(rng *rand.Rand) []string {
+	tokens := make([]string, rng.Intn(12))
+	for i := range tokens {
+		tokens[i] = string(rune('a' + rng.Intn(3)))
+	}
+	return tokens
+}
+
+func lcsLength(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func TestRenderUnifiedDiff(t *testing.T) {
+	base := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	target := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	hunks := diffHunks(diffTokens(splitLines([]byte(base)), splitLines([]byte(target))), 2)
+
+	var out bytes.Buffer
+	renderUnifiedDiff("a", "b", hunks, &out)
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -11,2 +11,3 @@
+ 11
+ 12
++13
+\ No newline at end of file
+`
+	if out.String() != want {
+		t.Fatalf("got:\n%s\nexpected:\n%s", out.String(), want)
+	}
+}
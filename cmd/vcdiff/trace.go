@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sort"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// span is the stretch of the target produced by one instruction
+type span struct {
+	window     int
+	start, end uint64 // Target range written by the instruction
+	typ        vcdiff.InstructionType
+	fromSource bool   // A COPY reading the base rather than earlier target
+	from       uint64 // Offset in the base or target the COPY starts reading at
+}
+
+// traceDecode decodes delta against base and records the span of every
+// instruction, so any target byte can be traced back to what produced it
+func traceDecode(base, delta []byte) ([]byte, []span, error) {
+	var spans []span
+	var offset, windowStart, segmentPosition uint64
+	var segmentSize uint32
+
+	hooks := vcdiff.Hooks{
+		OnWindowStart: func(index int, window *vcdiff.Window) error {
+			windowStart = offset
+			segmentPosition, segmentSize = 0, 0
+			if window.WinIndicator&vcdiff.VCDSource != 0 {
+				segmentPosition, segmentSize = uint64(window.SourceSegmentPosition), window.SourceSegmentSize
+			}
+			return nil
+		},
+		OnInstruction: func(index int, inst vcdiff.RuntimeInstruction) error {
+			s := span{window: index, start: offset, end: offset + uint64(inst.Size), typ: inst.Type}
+			if inst.Type == vcdiff.Copy {
+				if inst.Addr < segmentSize {
+					s.fromSource = true
+					s.from = segmentPosition + uint64(inst.Addr)
+				} else {
+					s.from = windowStart + uint64(inst.Addr-segmentSize)
+				}
+			}
+			spans = append(spans, s)
+			offset = s.end
+			return nil
+		},
+	}
+
+	target, err := vcdiff.NewDecoder(base, vcdiff.WithHooks(hooks)).Decode(delta)
+	if err != nil {
+		return nil, nil, err
+	}
+	return target, spans, nil
+}
+
+// overlapping returns the spans that produced target bytes start to end,
+// each clipped to that range
+func overlapping(spans []span, start, end uint64) []span {
+	var out []span
+	first := sort.Search(len(spans), func(i int) bool { return spans[i].end > start })
+	for _, s := range spans[first:] {
+		if s.start >= end {
+			break
+		}
+		if s.start < start {
+			s.from += start - s.start
+			s.start = start
+		}
+		s.end = min(s.end, end)
+		out = append(out, s)
+	}
+	return out
+}
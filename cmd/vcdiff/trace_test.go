@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+func TestTraceDecodeSpans(t *testing.T) {
+	for _, name := range referenceDeltas(t) {
+		t.Run(name, func(t *testing.T) {
+			base, err := os.ReadFile(filepath.Join("testdata", name+".source"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			delta, err := os.ReadFile(filepath.Join("testdata", name+".vcdiff"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target, spans, err := traceDecode(base, delta)
+			if err != nil {
+				t.Fatalf("traceDecode failed: %v", err)
+			}
+
+			// The spans tile the target, and every COPY span holds the bytes it claims to copy
+			var offset uint64
+			for i, s := range spans {
+				if s.start != offset {
+					t.Fatalf("span %d starts at %d, expected %d", i, s.start, offset)
+				}
+				offset = s.end
+				if s.typ != vcdiff.Copy {
+					continue
+				}
+				from := target
+				if s.fromSource {
+					from = base
+				}
+				if !bytes.Equal(target[s.start:s.end], from[s.from:s.from+s.end-s.start]) {
+					t.Errorf("span %d: target bytes differ from the copied region", i)
+				}
+			}
+			if offset != uint64(len(target)) {
+				t.Errorf("spans cover %d bytes, target has %d", offset, len(target))
+			}
+
+			// Clipping keeps copied offsets aligned with the target
+			if len(target) > 2 {
+				for _, s := range overlapping(spans, 1, uint64(len(target))-1) {
+					if s.typ == vcdiff.Copy && !s.fromSource && s.from >= s.start {
+						t.Errorf("target COPY at %d reads from %d, which is not earlier target", s.start, s.from)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package vcdiff
 
+import "fmt"
+
 // CodeTable represents the VCDIFF instruction code table
 type CodeTable struct {
 	entries [256][2]Instruction
@@ -75,3 +77,128 @@ func BuildDefaultCodeTable() *CodeTable {
 
 // DefaultCodeTable is the default code table instance
 var DefaultCodeTable = BuildDefaultCodeTable()
+
+// headerCodeTable is the code table a delta's windows are read with and the
+// address cache sizes its COPY modes refer to, when either is not the
+// default: a table carried in the header, or the default table with the
+// cache sizes of WithCacheSizes
+type headerCodeTable struct {
+	table    *CodeTable
+	nearSize int
+	sameSize int
+}
+
+// codeTable returns the table the window's instructions are coded with
+func (w *Window) codeTable() *CodeTable {
+	if w.codes != nil {
+		return w.codes.table
+	}
+	return DefaultCodeTable
+}
+
+// cacheSizes returns the address cache sizes of the window's code table, or
+// nearSize and sameSize if it uses the default table
+func (w *Window) cacheSizes(nearSize, sameSize int) (int, int) {
+	if w.codes != nil {
+		return w.codes.nearSize, w.codes.sameSize
+	}
+	return nearSize, sameSize
+}
+
+// Set replaces the instruction at the given code and slot
+func (ct *CodeTable) Set(code byte, slot int, inst Instruction) {
+	ct.entries[code][slot] = inst
+}
+
+// codeTableFields is the number of 256-byte arrays a code table is written
+// as: inst1, inst2, size1, size2, mode1 and mode2 - RFC 3284 Section 7
+const codeTableFields = 6
+
+// appendCodeTable appends the table as the string of RFC 3284 Section 7
+func appendCodeTable(dst []byte, ct *CodeTable) []byte {
+	var s [codeTableFields * InstructionTableSize]byte
+	for code, entry := range ct.entries {
+		for slot, inst := range entry {
+			s[slot*InstructionTableSize+code] = byte(inst.Type)
+			s[(2+slot)*InstructionTableSize+code] = inst.Size
+			s[(4+slot)*InstructionTableSize+code] = inst.Mode
+		}
+	}
+	return append(dst, s[:]...)
+}
+
+// EncodeCodeTable encodes a custom code table as the code table data of a
+// header with VCD_CODETABLE set - RFC 3284 Section 7: the near and same
+// cache sizes, each in a byte, followed by a delta that rebuilds the table's
+// string from the default table's.
+func EncodeCodeTable(ct *CodeTable, nearSize, sameSize int) ([]byte, error) {
+	if err := checkCodeTable(ct, nearSize, sameSize); err != nil {
+		return nil, err
+	}
+	delta, err := Encode(appendCodeTable(nil, DefaultCodeTable), appendCodeTable(nil, ct))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(nearSize), byte(sameSize)}, delta...), nil
+}
+
+// DecodeCodeTable decodes the code table data of a header with
+// VCD_CODETABLE set, as written by EncodeCodeTable, returning the table and
+// the address cache sizes it is used with. Errors wrap ErrInvalidFormat.
+func DecodeCodeTable(data []byte) (ct *CodeTable, nearSize, sameSize int, err error) {
+	if len(data) < 2 {
+		return nil, 0, 0, errUnexpectedEOF("code table cache sizes", 2-len(data))
+	}
+	nearSize, sameSize = int(data[0]), int(data[1])
+
+	// The delta is read with the default table, so it cannot carry a table
+	// of its own, and it may produce nothing beyond the table itself.
+	// Otherwise a small header could nest tables or declare a huge target.
+	delta := data[2:]
+	if len(delta) > MinimumFileSize && delta[MinimumFileSize]&(VCDDecompress|VCDCodetable|VCDAppHeader) != 0 {
+		return nil, 0, 0, fmt.Errorf("%w: code table delta with header indicator 0x%02x", ErrInvalidFormat, delta[MinimumFileSize])
+	}
+	limits := DecodeLimits{MaxTargetSize: codeTableFields * InstructionTableSize}
+	s, err := NewDecoder(appendCodeTable(nil, DefaultCodeTable), WithLimits(limits)).Decode(delta)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: code table delta: %v", ErrInvalidFormat, err)
+	}
+	if len(s) != codeTableFields*InstructionTableSize {
+		return nil, 0, 0, fmt.Errorf("%w: code table of %d bytes, expected %d", ErrInvalidFormat, len(s), codeTableFields*InstructionTableSize)
+	}
+
+	ct = &CodeTable{}
+	for code := range ct.entries {
+		for slot := range ct.entries[code] {
+			ct.entries[code][slot] = Instruction{
+				Type: InstructionType(s[slot*InstructionTableSize+code]),
+				Size: s[(2+slot)*InstructionTableSize+code],
+				Mode: s[(4+slot)*InstructionTableSize+code],
+			}
+		}
+	}
+	if err := checkCodeTable(ct, nearSize, sameSize); err != nil {
+		return nil, 0, 0, err
+	}
+	return ct, nearSize, sameSize, nil
+}
+
+// checkCodeTable checks the cache sizes and that every entry has a known
+// type and a mode those caches provide
+func checkCodeTable(ct *CodeTable, nearSize, sameSize int) error {
+	if err := checkCacheSizes(nearSize, sameSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	modes := fixedAddressModes + nearSize + sameSize
+	for code, entry := range ct.entries {
+		for slot, inst := range entry {
+			if inst.Type > Copy {
+				return fmt.Errorf("%w: code %d slot %d has unknown instruction type %d", ErrInvalidFormat, code, slot, inst.Type)
+			}
+			if inst.Type == Copy && int(inst.Mode) >= modes {
+				return fmt.Errorf("%w: code %d slot %d uses mode %d of %d", ErrInvalidFormat, code, slot, inst.Mode, modes)
+			}
+		}
+	}
+	return nil
+}
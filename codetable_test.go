@@ -0,0 +1,220 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCodeTableRoundTrip(t *testing.T) {
+	custom := BuildDefaultCodeTable()
+	custom.Set(20, 0, Instruction{Type: Copy, Size: 0, Mode: 5})
+	custom.Set(200, 1, Instruction{Type: Run, Size: 3})
+
+	for _, tc := range []struct {
+		name           string
+		table          *CodeTable
+		nearSize, same int
+	}{
+		{"default", DefaultCodeTable, NearCacheSize, SameCacheModes},
+		{"custom", custom, 3, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := EncodeCodeTable(tc.table, tc.nearSize, tc.same)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table, nearSize, sameSize, err := DecodeCodeTable(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nearSize != tc.nearSize || sameSize != tc.same {
+				t.Fatalf("got cache sizes %d/%d, expected %d/%d", nearSize, sameSize, tc.nearSize, tc.same)
+			}
+			if *table != *tc.table {
+				t.Fatal("decoded table differs from the encoded one")
+			}
+		})
+	}
+}
+
+func TestCodeTableInvalid(t *testing.T) {
+	// A COPY mode of 8 needs at least 9 address modes
+	if _, err := EncodeCodeTable(DefaultCodeTable, 2, 2); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrInvalidFormat for a mode the caches lack", err)
+	}
+	if _, err := EncodeCodeTable(DefaultCodeTable, 200, 100); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrInvalidFormat for too many modes", err)
+	}
+
+	unknown := BuildDefaultCodeTable()
+	unknown.Set(0, 1, Instruction{Type: 4})
+	if _, err := EncodeCodeTable(unknown, NearCacheSize, SameCacheModes); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrInvalidFormat for an unknown type", err)
+	}
+
+	data, err := EncodeCodeTable(DefaultCodeTable, NearCacheSize, SameCacheModes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no delta", data[:2]},
+		{"truncated delta", data[:len(data)-1]},
+		{"cache sizes lacking modes", append([]byte{1, 1}, data[2:]...)},
+		{"nested table", append(data[:2:2], withHeaderSection(data[2:], VCDCodetable, data)...)},
+		{"application header", append(data[:2:2], withHeaderSection(data[2:], VCDAppHeader, []byte("x"))...)},
+	} {
+		if _, _, _, err := DecodeCodeTable(tc.data); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: got %v, expected ErrInvalidFormat", tc.name, err)
+		}
+	}
+
+	// A delta of the wrong length is rejected
+	short, err := Encode(appendCodeTable(nil, DefaultCodeTable), []byte("short"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := DecodeCodeTable(append([]byte{NearCacheSize, SameCacheModes}, short...)); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrInvalidFormat for a short table", err)
+	}
+
+	// A table delta may not produce more than the table, however long a
+	// target it declares
+	long, err := Encode(nil, bytes.Repeat([]byte{0}, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := DecodeCodeTable(append([]byte{NearCacheSize, SameCacheModes}, long...)); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrInvalidFormat for a long table", err)
+	}
+}
+
+// A table nested in a table delta is rejected at the first level, so deep
+// nesting costs no more than one
+func TestCodeTableNested(t *testing.T) {
+	data, err := EncodeCodeTable(DefaultCodeTable, NearCacheSize, SameCacheModes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := data
+	for range 2000 {
+		nested = append(data[:2:2], withHeaderSection(data[2:], VCDCodetable, nested)...)
+	}
+	delta := withHeaderSection(singleAddDelta(0, 0), VCDCodetable, nested)
+
+	allocs := testing.AllocsPerRun(1, func() {
+		if _, err := Decode(nil, delta); !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("got %v, expected ErrInvalidFormat", err)
+		}
+	})
+	if allocs > 100 {
+		t.Errorf("rejecting a nested table made %.0f allocations", allocs)
+	}
+}
+
+// customTableDelta assembles a one-window delta of target against source,
+// coded with a custom table that reverses the default table's codes and with
+// cache sizes other than the default. It returns the delta and the COPY
+// modes it used.
+func customTableDelta(t *testing.T, source []byte, copies [][2]uint32) ([]byte, []byte, []byte) {
+	t.Helper()
+	const nearSize, sameSize = 5, 2
+	custom := &CodeTable{}
+	for code := range InstructionTableSize {
+		for slot := range 2 {
+			custom.Set(byte(InstructionTableSize-1-code), slot, DefaultCodeTable.Get(byte(code), slot))
+		}
+	}
+	table, err := EncodeCodeTable(custom, nearSize, sameSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(len(source))}
+	addressCache := NewAddressCache(nearSize, sameSize)
+	var target, modes []byte
+	for _, c := range copies {
+		addr, size := c[0], c[1]
+		var mode byte
+		mode, window.AddressSection = addressCache.EncodeAddress(addr, uint32(len(source)+len(target)), window.AddressSection)
+		window.InstructionSection = append(window.InstructionSection, byte(InstructionTableSize-1-(copyCode+16*int(mode))))
+		window.InstructionSection = AppendVarint(window.InstructionSection, size)
+		target = append(target, source[addr:addr+size]...)
+		modes = append(modes, mode)
+	}
+	window.DataSection = []byte("end")
+	window.InstructionSection = append(window.InstructionSection, byte(InstructionTableSize-1-addCode))
+	window.InstructionSection = AppendVarint(window.InstructionSection, 3)
+	target = append(target, "end"...)
+	window.TargetWindowLength = uint32(len(target))
+
+	delta, err := MarshalDelta(&ParsedDelta{
+		Header:  Header{Indicator: VCDCodetable, CodeTable: table},
+		Windows: []Window{window},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta, target, modes
+}
+
+func TestDecodeCustomCodeTable(t *testing.T) {
+	source := make([]byte, 1<<16)
+	for i := range source {
+		source[i] = byte(i * 7 >> 3)
+	}
+	// The sixth COPY is near the fifth, in a near slot the default caches
+	// lack, and the last repeats the first from a same cache slot
+	delta, target, modes := customTableDelta(t, source, [][2]uint32{
+		{20000, 8}, {30000, 8}, {40000, 8}, {50000, 8}, {60000, 8}, {60010, 8}, {20000, 8},
+	})
+	if !bytes.Contains(modes, []byte{fixedAddressModes + 4}) {
+		t.Fatalf("modes %v do not use the fifth near slot", modes)
+	}
+
+	got, err := Decode(source, delta)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatal("Decode output differs from the target")
+	}
+	streamed, err := io.ReadAll(NewReader(source, bytes.NewReader(delta)))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if !bytes.Equal(streamed, target) {
+		t.Fatal("NewReader output differs from the target")
+	}
+	if err := Validate(delta); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	req, err := Requirements(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.CustomCodeTable {
+		t.Error("Requirements does not report the custom code table")
+	}
+	if err := req.Check(source); err != nil {
+		t.Errorf("Check rejected the delta: %v", err)
+	}
+
+	// Edits that re-encode windows write the default table
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.SplitWindow(0, 8, source); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SplitWindow: got %v, expected ErrUnsupported", err)
+	}
+	if _, err := Rebase(delta, source, 0); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Rebase: got %v, expected ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,75 @@
+package vcdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseDeltas(t *testing.T) {
+	var stream []byte
+	var windows []int
+	for seed := int64(0); seed < 3; seed++ {
+		g := GenerateDelta(seed, ProfileNoSource)
+		stream = append(stream, g.Delta...)
+		windows = append(windows, ProfileNoSource.Windows)
+	}
+
+	deltas, err := ParseDeltas(stream)
+	if err != nil {
+		t.Fatalf("ParseDeltas failed: %v", err)
+	}
+	if len(deltas) != len(windows) {
+		t.Fatalf("got %d deltas, expected %d", len(deltas), len(windows))
+	}
+	for i, parsed := range deltas {
+		if len(parsed.Windows) != windows[i] {
+			t.Errorf("delta %d: got %d windows, expected %d", i, len(parsed.Windows), windows[i])
+		}
+	}
+
+	// A single delta parses the same way as with ParseDelta
+	single := GenerateDelta(9, ProfileCopyHeavy)
+	deltas, err = ParseDeltas(single.Delta)
+	if err != nil || len(deltas) != 1 {
+		t.Fatalf("single delta: got %d deltas, error %v", len(deltas), err)
+	}
+
+	// ParseDelta still expects exactly one delta
+	if _, err := ParseDelta(stream); err == nil {
+		t.Error("ParseDelta accepted concatenated deltas")
+	}
+}
+
+func TestParseDeltasTruncated(t *testing.T) {
+	first := GenerateDelta(1, ProfileNoSource).Delta
+	second := GenerateDelta(2, ProfileNoSource).Delta
+	// Cut the second delta off inside its header
+	stream := append(append([]byte{}, first...), second[:len(VCDIFFMagic)+1]...)
+
+	_, err := ParseDeltas(stream)
+	if err == nil {
+		t.Fatal("ParseDeltas accepted a truncated second delta")
+	}
+	if !strings.Contains(err.Error(), "delta 1") {
+		t.Errorf("error %q does not identify the failing delta", err)
+	}
+}
+
+func TestDecodeConcatenated(t *testing.T) {
+	a := GenerateDelta(4, ProfileCopyHeavy)
+	b := GenerateDelta(4, ProfileCopyHeavy) // same seed, so the same source
+	stream := append(append([]byte{}, a.Delta...), b.Delta...)
+
+	result, err := NewDecoder(a.Source, WithConcatenated()).Decode(stream)
+	if err != nil {
+		t.Fatalf("concatenated decode failed: %v", err)
+	}
+	if !bytes.Equal(result, append(append([]byte{}, a.Target...), b.Target...)) {
+		t.Fatal("concatenated decode did not produce the targets in order")
+	}
+
+	if _, err := NewDecoder(a.Source).Decode(stream); err == nil {
+		t.Error("decoder without WithConcatenated accepted concatenated deltas")
+	}
+}
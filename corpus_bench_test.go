@@ -0,0 +1,99 @@
+package vcdiff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ably/vcdiff-go/internal/benchcorpus"
+)
+
+// corpusDelta returns an xdelta3-encoded delta for the pair, caching it
+// in the corpus directory keyed by the pair's contents
+func corpusDelta(b *testing.B, dir string, source, target []byte) []byte {
+	b.Helper()
+
+	h := sha256.New()
+	h.Write(source)
+	h.Write(target)
+	cached := filepath.Join(dir, "deltas", hex.EncodeToString(h.Sum(nil))+".vcdiff")
+	if delta, err := os.ReadFile(cached); err == nil {
+		return delta
+	}
+
+	xdelta3, err := exec.LookPath("xdelta3")
+	if err != nil {
+		b.Skip("xdelta3 not found on PATH, needed to encode corpus deltas")
+	}
+
+	tmp := b.TempDir()
+	sourceFile := filepath.Join(tmp, "source")
+	targetFile := filepath.Join(tmp, "target")
+	if err := os.WriteFile(sourceFile, source, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(targetFile, target, 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		b.Fatal(err)
+	}
+	out, err := exec.Command(xdelta3, "-e", "-S", "-A", "-f", "-s", sourceFile, targetFile, cached).CombinedOutput()
+	if err != nil {
+		b.Fatalf("xdelta3 failed: %v\n%s", err, out)
+	}
+
+	delta, err := os.ReadFile(cached)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return delta
+}
+
+// BenchmarkCorpusDecode decodes every pair of every fetched corpus. Fetch the
+// corpora with: go run ./internal/benchcorpus/fetch
+func BenchmarkCorpusDecode(b *testing.B) {
+	dir, err := benchcorpus.Dir()
+	if err != nil {
+		b.Skip(err)
+	}
+
+	ran := false
+	for _, c := range benchcorpus.Standard {
+		if !benchcorpus.Present(c, dir) {
+			continue
+		}
+		pairs, err := benchcorpus.Pairs(c, dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, pair := range pairs {
+			ran = true
+			b.Run(pair.Name, func(b *testing.B) {
+				source, target, err := pair.Load()
+				if err != nil {
+					b.Fatal(err)
+				}
+				delta := corpusDelta(b, dir, source, target)
+
+				b.SetBytes(int64(len(target)))
+				b.ReportMetric(float64(len(delta)), "delta-bytes")
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := Decode(source, delta); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+
+	if !ran {
+		b.Skip("no benchmark corpora present; benchcorpus.Standard lists those with pinned digests")
+	}
+}
@@ -0,0 +1,88 @@
+package vcdiff
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// SourceRange is the half-open range [Offset, Offset+Length) of the source
+type SourceRange struct {
+	Offset uint64
+	Length uint64
+}
+
+// Coverage maps which parts of the source a delta reads
+type Coverage struct {
+	SourceLength uint64        // Length of the source the map is for
+	Ranges       []SourceRange // Ranges read by COPYs, sorted, disjoint and not adjacent
+	Covered      uint64        // Total length of Ranges
+}
+
+// Fraction returns the share of the source the delta reads, 0 for an empty
+// source
+func (c *Coverage) Fraction() float64 {
+	return ratio(c.Covered, c.SourceLength)
+}
+
+// SourceCoverage returns the byte ranges of a source of sourceLength bytes
+// that the COPYs of parsed read from. Bytes outside them are not needed to
+// apply the delta, so a client holding part of the base, or a store serving
+// it, can fetch only these ranges. A COPY running past the end of its
+// source segment continues into the target and only covers the source up
+// to the segment's end. Errors wrap ErrSourceTooShort if a window's source
+// segment extends past sourceLength.
+func SourceCoverage(parsed *ParsedDelta, sourceLength uint64) (*Coverage, error) {
+	coverage := &Coverage{SourceLength: sourceLength}
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		if err := checkSupported(&parsed.Header, window); err != nil {
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+		r, err := resolveWindow(window, addressCache)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+		if window.WinIndicator&VCDSource == 0 || r.sourceCopies == 0 {
+			continue
+		}
+
+		position, segmentSize := uint64(window.SourceSegmentPosition), window.SourceSegmentSize
+		if position+uint64(segmentSize) > sourceLength {
+			return nil, fmt.Errorf("%w: window %d reads source bytes %d-%d of %d", ErrSourceTooShort,
+				i, position, position+uint64(segmentSize), sourceLength)
+		}
+		for _, inst := range r.instructions {
+			if inst.Type != Copy || inst.Addr >= segmentSize || inst.Size == 0 {
+				continue
+			}
+			end := min(uint64(inst.Addr)+uint64(inst.Size), uint64(segmentSize))
+			coverage.Ranges = append(coverage.Ranges, SourceRange{Offset: position + uint64(inst.Addr), Length: end - uint64(inst.Addr)})
+		}
+	}
+
+	coverage.Ranges = mergeRanges(coverage.Ranges)
+	for _, r := range coverage.Ranges {
+		coverage.Covered += r.Length
+	}
+	return coverage, nil
+}
+
+// mergeRanges sorts ranges and joins those that overlap or touch
+func mergeRanges(ranges []SourceRange) []SourceRange {
+	slices.SortFunc(ranges, func(a, b SourceRange) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.Offset <= merged[n-1].Offset+merged[n-1].Length {
+			last := &merged[n-1]
+			last.Length = max(last.Length, r.Offset+r.Length-last.Offset)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
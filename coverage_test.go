@@ -0,0 +1,63 @@
+package vcdiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSourceCoverage(t *testing.T) {
+	first := Window{WinIndicator: VCDSource, SourceSegmentSize: 20, SourceSegmentPosition: 100}
+	encodeInstructions(&first, []RuntimeInstruction{
+		{Type: Copy, Size: 4, Addr: 10},
+		{Type: Copy, Size: 3, Addr: 2},
+		{Type: Add, Size: 1, Data: []byte("x")},
+		{Type: Copy, Size: 5, Addr: 12}, // Overlaps the first COPY
+		{Type: Copy, Size: 6, Addr: 18}, // Runs into the target after 2 bytes
+		{Type: Copy, Size: 2, Addr: 20}, // Reads only the target
+	})
+	second := Window{WinIndicator: VCDSource, SourceSegmentSize: 8, SourceSegmentPosition: 5}
+	encodeInstructions(&second, []RuntimeInstruction{{Type: Copy, Size: 8, Addr: 0}})
+	parsed := &ParsedDelta{Windows: []Window{first, second, {}}}
+
+	coverage, err := SourceCoverage(parsed, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SourceRange{{5, 8}, {102, 3}, {110, 7}, {118, 2}}
+	if len(coverage.Ranges) != len(want) {
+		t.Fatalf("got ranges %v, expected %v", coverage.Ranges, want)
+	}
+	for i := range want {
+		if coverage.Ranges[i] != want[i] {
+			t.Fatalf("got ranges %v, expected %v", coverage.Ranges, want)
+		}
+	}
+	if coverage.Covered != 20 || coverage.Fraction() != 20.0/200 {
+		t.Fatalf("got %d bytes covered, fraction %v", coverage.Covered, coverage.Fraction())
+	}
+
+	if _, err := SourceCoverage(parsed, 119); !errors.Is(err, ErrSourceTooShort) {
+		t.Fatalf("got %v, expected ErrSourceTooShort", err)
+	}
+}
+
+func TestSourceCoverageEncoded(t *testing.T) {
+	source := []byte("The quick brown fox jumps over the lazy dog, again and again.")
+	target := append([]byte("A lazy dog"), source[:19]...)
+
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage, err := SourceCoverage(parsed, uint64(len(source)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coverage.Covered < 19 || coverage.Covered >= uint64(len(source)) {
+		t.Fatalf("got ranges %v for a target copying the first 19 source bytes", coverage.Ranges)
+	}
+}
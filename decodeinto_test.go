@@ -0,0 +1,40 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecodeInto(t *testing.T) {
+	delta := runDelta(t, 100, 0, 300, 50)
+	want, err := Decode(nil, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := bytes.Repeat([]byte{'-'}, len(want)+10)
+	n, err := DecodeInto(dst, nil, delta)
+	if err != nil || n != len(want) || !bytes.Equal(dst[:n], want) {
+		t.Fatalf("got %d bytes, %v", n, err)
+	}
+	if !bytes.Equal(dst[n:], bytes.Repeat([]byte{'-'}, 10)) {
+		t.Errorf("bytes past the target were overwritten: %q", dst[n:])
+	}
+
+	for _, short := range [][]byte{nil, make([]byte, len(want)-1)} {
+		if n, err := DecodeInto(short, nil, delta); !errors.Is(err, io.ErrShortBuffer) || n != 0 {
+			t.Errorf("%d byte buffer: got %d, %v, expected io.ErrShortBuffer", len(short), n, err)
+		}
+	}
+	if _, err := DecodeInto(dst, nil, delta[:len(delta)-1]); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated delta: got %v, expected ErrInvalidFormat", err)
+	}
+
+	into := testing.AllocsPerRun(10, func() { DecodeInto(dst, nil, delta) })
+	decode := testing.AllocsPerRun(10, func() { Decode(nil, delta) })
+	if into >= decode {
+		t.Errorf("DecodeInto made %v allocations, Decode %v", into, decode)
+	}
+}
@@ -0,0 +1,68 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// windowWriter records the size of each Write, failing once failAfter
+// bytes have been written when failAfter is set
+type windowWriter struct {
+	bytes.Buffer
+	writes    []int
+	failAfter int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *windowWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	if w.failAfter != 0 && w.Len()+len(p) > w.failAfter {
+		n := w.failAfter - w.Len()
+		w.Buffer.Write(p[:n])
+		return n, errWriteFailed
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestDecodeTo(t *testing.T) {
+	delta := runDelta(t, 100, 0, 300, 50)
+	want, err := Decode(nil, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w windowWriter
+	n, err := NewDecoder(nil).DecodeTo(&w, delta)
+	if err != nil || n != int64(len(want)) || !bytes.Equal(w.Bytes(), want) {
+		t.Fatalf("wrote %d bytes, %v", n, err)
+	}
+	if len(w.writes) != 4 || w.writes[0] != 100 || w.writes[2] != 300 {
+		t.Fatalf("got writes of %v, expected one per window", w.writes)
+	}
+}
+
+func TestDecodeToWriteError(t *testing.T) {
+	delta := runDelta(t, 100, 300)
+	w := windowWriter{failAfter: 150}
+	n, err := NewDecoder(nil).DecodeTo(&w, delta)
+	if err != errWriteFailed || n != 150 {
+		t.Fatalf("got %d bytes, error %v", n, err)
+	}
+}
+
+func TestDecodeToTextValidation(t *testing.T) {
+	window := Window{}
+	encodeInstructions(&window, []RuntimeInstruction{{Type: Add, Size: 2, Data: []byte{'o', 0xff}}})
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window, window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w windowWriter
+	n, err := NewDecoder(nil, WithTextValidation(TextUTF8)).DecodeTo(&w, delta)
+	if !errors.Is(err, ErrInvalidText) || n != 0 || len(w.writes) != 0 {
+		t.Fatalf("wrote %d bytes, error %v, expected nothing written", n, err)
+	}
+}
@@ -0,0 +1,112 @@
+package vcdiff
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// DeltaCache is a bounded LRU cache of parsed and validated deltas, keyed by
+// the SHA-256 of their content. Servers that apply the same popular delta to
+// many bases can share one cache between decoders so that each delta is
+// parsed and checked only once. It is safe for concurrent use.
+type DeltaCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[deltaCacheKey]*list.Element
+	order    *list.List // Most recently used at the front
+	hits     uint64
+	misses   uint64
+}
+
+// DeltaCacheStats counts lookups in a DeltaCache
+type DeltaCacheStats struct {
+	Hits    uint64 // Decodes that reused a cached delta
+	Misses  uint64 // Decodes that parsed the delta
+	Entries int    // Deltas currently cached
+}
+
+// deltaCacheKey identifies a delta and how it was parsed, since concatenated
+// mode and the address cache sizes read the same bytes differently
+type deltaCacheKey struct {
+	hash               [sha256.Size]byte
+	concatenated       bool
+	nearSize, sameSize int // Cache sizes of windows without a header code table
+}
+
+type deltaCacheEntry struct {
+	key      deltaCacheKey
+	prepared *preparedDelta
+}
+
+// NewDeltaCache returns a cache holding up to capacity deltas. A capacity
+// below one caches nothing but still counts misses.
+func NewDeltaCache(capacity int) *DeltaCache {
+	return &DeltaCache{
+		capacity: capacity,
+		entries:  make(map[deltaCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// WithDeltaCache makes Decode look deltas up in cache before parsing them
+// and store them there afterwards. Deltas that fail to parse are not cached.
+func WithDeltaCache(cache *DeltaCache) DecoderOption {
+	return func(d *decoder) {
+		d.deltaCache = cache
+	}
+}
+
+// Stats returns the cache's hit and miss counts and current size
+func (c *DeltaCache) Stats() DeltaCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return DeltaCacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+// prepare returns the cached preparation of delta, parsing and caching it on
+// a miss. The lock is not held while parsing, so concurrent misses on the
+// same delta may each parse it; the last to finish is kept.
+func (c *DeltaCache) prepare(delta []byte, concatenated bool, caches *headerCodeTable) (*preparedDelta, error) {
+	key := deltaCacheKey{hash: sha256.Sum256(delta), concatenated: concatenated, nearSize: NearCacheSize, sameSize: SameCacheModes}
+	if caches != nil {
+		key.nearSize, key.sameSize = caches.nearSize, caches.sameSize
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(element)
+		prepared := element.Value.(*deltaCacheEntry).prepared
+		c.mu.Unlock()
+		return prepared, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Cached windows outlive the decode, so their sections slice a copy of
+	// the delta
+	prepared := &preparedDelta{}
+	if err := prepareDelta(bytes.Clone(delta), concatenated, caches, prepared); err != nil {
+		return nil, err
+	}
+	if c.capacity < 1 {
+		return prepared, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*deltaCacheEntry).prepared = prepared
+		c.order.MoveToFront(element)
+		return prepared, nil
+	}
+	c.entries[key] = c.order.PushFront(&deltaCacheEntry{key: key, prepared: prepared})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*deltaCacheEntry).key)
+	}
+	return prepared, nil
+}
@@ -0,0 +1,94 @@
+package vcdiff
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestDeltaCacheHitsAndEviction(t *testing.T) {
+	cache := NewDeltaCache(2)
+	deltas := []GeneratedDelta{
+		GenerateDelta(1, ProfileSmall),
+		GenerateDelta(2, ProfileSmall),
+		GenerateDelta(3, ProfileSmall),
+	}
+	decode := func(g GeneratedDelta) {
+		t.Helper()
+		target, err := NewDecoder(g.Source, WithDeltaCache(cache)).Decode(g.Delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(target, g.Target) {
+			t.Fatal("cached decode produced the wrong target")
+		}
+	}
+
+	decode(deltas[0])
+	decode(deltas[0])
+	decode(deltas[1])
+	if got := cache.Stats(); got != (DeltaCacheStats{Hits: 1, Misses: 2, Entries: 2}) {
+		t.Fatalf("after repeating a delta: %+v", got)
+	}
+
+	// Touching delta 0 leaves delta 1 least recently used, so delta 2 evicts it
+	decode(deltas[0])
+	decode(deltas[2])
+	decode(deltas[0])
+	decode(deltas[1])
+	if got := cache.Stats(); got != (DeltaCacheStats{Hits: 3, Misses: 4, Entries: 2}) {
+		t.Fatalf("after eviction: %+v", got)
+	}
+
+	// Invalid deltas are not cached
+	if _, err := NewDecoder(nil, WithDeltaCache(cache)).Decode([]byte("not a delta")); err == nil {
+		t.Fatal("decoded an invalid delta")
+	}
+	if got := cache.Stats(); got.Misses != 5 || got.Entries != 2 {
+		t.Fatalf("after an invalid delta: %+v", got)
+	}
+}
+
+func TestDeltaCacheCopiesDelta(t *testing.T) {
+	cache := NewDeltaCache(1)
+	g := GenerateDelta(4, ProfileSmall)
+	delta := bytes.Clone(g.Delta)
+	if _, err := NewDecoder(g.Source, WithDeltaCache(cache)).Decode(delta); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cached windows must not alias the buffer the delta was decoded from
+	clear(delta)
+	target, err := NewDecoder(g.Source, WithDeltaCache(cache)).Decode(g.Delta)
+	if err != nil || !bytes.Equal(target, g.Target) {
+		t.Fatalf("decode after reusing the delta's buffer returned %v", err)
+	}
+	if got := cache.Stats(); got.Hits != 1 {
+		t.Fatalf("second decode missed the cache: %+v", got)
+	}
+}
+
+func TestDeltaCacheConcurrent(t *testing.T) {
+	cache := NewDeltaCache(4)
+	g := GenerateDelta(5, ProfileCopyHeavy)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				target, err := NewDecoder(g.Source, WithDeltaCache(cache)).Decode(g.Delta)
+				if err != nil || !bytes.Equal(target, g.Target) {
+					t.Errorf("concurrent decode failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := cache.Stats(); got.Hits+got.Misses != 160 || got.Entries != 1 {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+}
@@ -0,0 +1,134 @@
+package vcdiff
+
+// DeltaStats summarizes what a delta is made of, for tools and dashboards
+// that report on deltas without applying them
+type DeltaStats struct {
+	DeltaLength        uint64             // Bytes of the delta as MarshalDelta writes it
+	TargetLength       uint64             // Bytes of the reconstructed target
+	Ratio              float64            // DeltaLength divided by TargetLength, 0 for an empty target
+	Add                InstructionStats   // ADD instructions
+	Copy               InstructionStats   // COPY instructions, including those counted in SourceCopy
+	SourceCopy         InstructionStats   // COPYs reading from the source segment
+	Run                InstructionStats   // RUN instructions
+	Sections           SectionStats       // Section sizes summed over all windows
+	ChecksummedWindows int                // Windows carrying a VCD_ADLER32 checksum
+	Windows            []WindowDeltaStats // One entry per window
+}
+
+// WindowDeltaStats summarizes a single window of a delta
+type WindowDeltaStats struct {
+	EncodedLength uint64           // Bytes of the window as MarshalDelta writes it
+	TargetLength  uint64           // Length of the target window
+	Ratio         float64          // EncodedLength divided by TargetLength, 0 for an empty window
+	Add           InstructionStats // ADD instructions
+	Copy          InstructionStats // COPY instructions, including those counted in SourceCopy
+	SourceCopy    InstructionStats // COPYs reading from the source segment
+	Run           InstructionStats // RUN instructions
+	Sections      SectionStats     // Section sizes
+	HasChecksum   bool             // Whether the window carries a VCD_ADLER32 checksum
+}
+
+// InstructionStats counts the instructions of one type
+type InstructionStats struct {
+	Count int    // Number of instructions
+	Bytes uint64 // Target bytes they produce
+}
+
+// SectionStats holds the sizes of the three sections of a delta encoding -
+// RFC 3284 Section 4.3
+type SectionStats struct {
+	Data         uint64 // Data for ADDs and RUNs
+	Instructions uint64 // Instructions and sizes
+	Addresses    uint64 // Addresses for COPYs
+}
+
+// Shares returns each section's fraction of their combined size, all 0 when
+// the sections are empty
+func (s SectionStats) Shares() (data, instructions, addresses float64) {
+	total := float64(s.Data + s.Instructions + s.Addresses)
+	if total == 0 {
+		return 0, 0, 0
+	}
+	return float64(s.Data) / total, float64(s.Instructions) / total, float64(s.Addresses) / total
+}
+
+// Stats counts the instructions and bytes of a parsed delta, per window and
+// in total. COPYs whose addresses cannot be decoded are counted as COPYs but
+// not as source COPYs.
+func Stats(parsed *ParsedDelta) DeltaStats {
+	stats := DeltaStats{DeltaLength: uint64(len(appendHeader(nil, &parsed.Header)))}
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		w := WindowDeltaStats{
+			EncodedLength: uint64(len(appendWindow(nil, window))),
+			TargetLength:  uint64(window.TargetWindowLength),
+			Sections: SectionStats{
+				Data:         uint64(len(window.DataSection)),
+				Instructions: uint64(len(window.InstructionSection)),
+				Addresses:    uint64(len(window.AddressSection)),
+			},
+			HasChecksum: window.HasChecksum,
+		}
+		w.Ratio = ratio(w.EncodedLength, w.TargetLength)
+
+		instructions, resolved := windowInstructions(window, addressCache)
+		for _, inst := range instructions {
+			switch inst.Type {
+			case Add:
+				w.Add.add(inst.Size)
+			case Run:
+				w.Run.add(inst.Size)
+			case Copy:
+				w.Copy.add(inst.Size)
+				if resolved && window.WinIndicator&VCDSource != 0 && inst.Addr < window.SourceSegmentSize {
+					w.SourceCopy.add(inst.Size)
+				}
+			}
+		}
+
+		stats.DeltaLength += w.EncodedLength
+		stats.TargetLength += w.TargetLength
+		stats.Add.merge(w.Add)
+		stats.Copy.merge(w.Copy)
+		stats.SourceCopy.merge(w.SourceCopy)
+		stats.Run.merge(w.Run)
+		stats.Sections.Data += w.Sections.Data
+		stats.Sections.Instructions += w.Sections.Instructions
+		stats.Sections.Addresses += w.Sections.Addresses
+		if w.HasChecksum {
+			stats.ChecksummedWindows++
+		}
+		stats.Windows = append(stats.Windows, w)
+	}
+	stats.Ratio = ratio(stats.DeltaLength, stats.TargetLength)
+	return stats
+}
+
+// windowInstructions returns a window's instructions, with COPY addresses
+// decoded when resolved is true
+func windowInstructions(window *Window, addressCache *AddressCache) (instructions []RuntimeInstruction, resolved bool) {
+	if r, err := resolveWindow(window, addressCache); err == nil {
+		return r.instructions, true
+	}
+	instructions, _ = parseInstructions(window, addressCache)
+	return instructions, false
+}
+
+func (s *InstructionStats) add(size uint32) {
+	s.Count++
+	s.Bytes += uint64(size)
+}
+
+func (s *InstructionStats) merge(other InstructionStats) {
+	s.Count += other.Count
+	s.Bytes += other.Bytes
+}
+
+// ratio divides encoded by target, or returns 0 for an empty target
+func ratio(encoded, target uint64) float64 {
+	if target == 0 {
+		return 0
+	}
+	return float64(encoded) / float64(target)
+}
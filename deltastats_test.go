@@ -0,0 +1,87 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: 8}
+	encodeInstructions(&window, []RuntimeInstruction{
+		{Type: Add, Size: 2, Data: []byte("XY")},
+		{Type: Copy, Size: 4, Addr: 0},
+		{Type: Run, Size: 3, Data: []byte("z")},
+		{Type: Copy, Size: 3, Addr: 8}, // "XYa" from the target
+	})
+	parsed := &ParsedDelta{Windows: []Window{window, {}}}
+	parsed.Windows[1].HasChecksum = true
+
+	stats := Stats(parsed)
+	if stats.TargetLength != 12 || len(stats.Windows) != 2 || stats.ChecksummedWindows != 1 {
+		t.Fatalf("got target %d, %d windows, %d checksummed", stats.TargetLength, len(stats.Windows), stats.ChecksummedWindows)
+	}
+	want := WindowDeltaStats{
+		Add:        InstructionStats{Count: 1, Bytes: 2},
+		Copy:       InstructionStats{Count: 2, Bytes: 7},
+		SourceCopy: InstructionStats{Count: 1, Bytes: 4},
+		Run:        InstructionStats{Count: 1, Bytes: 3},
+	}
+	got := stats.Windows[0]
+	if got.Add != want.Add || got.Copy != want.Copy || got.SourceCopy != want.SourceCopy || got.Run != want.Run {
+		t.Fatalf("got instruction stats %+v", got)
+	}
+	if stats.Add != want.Add || stats.Copy != want.Copy || stats.Run != want.Run {
+		t.Fatalf("totals differ from the only non-empty window: %+v", stats)
+	}
+	if got.Sections.Data != 3 || got.Sections.Addresses != 2 || stats.Sections != got.Sections {
+		t.Fatalf("got sections %+v, totals %+v", got.Sections, stats.Sections)
+	}
+
+	delta, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeltaLength != uint64(len(delta)) {
+		t.Fatalf("got delta length %d, marshalled %d", stats.DeltaLength, len(delta))
+	}
+	if got.Ratio != float64(got.EncodedLength)/12 || stats.Windows[1].Ratio != 0 {
+		t.Fatalf("got ratios %v and %v", got.Ratio, stats.Windows[1].Ratio)
+	}
+
+	data, instructions, addresses := got.Sections.Shares()
+	if data+instructions+addresses < 0.999 || data != 3/float64(3+got.Sections.Instructions+2) {
+		t.Fatalf("got shares %v %v %v", data, instructions, addresses)
+	}
+}
+
+func TestStatsEncoded(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	source := make([]byte, 8192)
+	rng.Read(source)
+	target := append(bytes.Repeat([]byte{0}, 100), source[4000:]...)
+	target = append(target, source[:2000]...)
+
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := Stats(parsed)
+	if stats.DeltaLength != uint64(len(delta)) || stats.TargetLength != uint64(len(target)) {
+		t.Fatalf("got delta %d target %d, expected %d and %d", stats.DeltaLength, stats.TargetLength, len(delta), len(target))
+	}
+	if stats.Add.Bytes+stats.Copy.Bytes+stats.Run.Bytes != stats.TargetLength {
+		t.Fatalf("instructions produce %d bytes of a %d byte target", stats.Add.Bytes+stats.Copy.Bytes+stats.Run.Bytes, stats.TargetLength)
+	}
+	if stats.SourceCopy.Bytes < uint64(len(source)-2000) || stats.Run.Bytes != 100 {
+		t.Fatalf("got %d source-copied and %d run bytes", stats.SourceCopy.Bytes, stats.Run.Bytes)
+	}
+	if stats.Ratio >= 0.1 {
+		t.Fatalf("got ratio %v for a mostly copied target", stats.Ratio)
+	}
+}
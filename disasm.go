@@ -0,0 +1,176 @@
+package vcdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// indicatorFlag names one bit of an indicator byte
+type indicatorFlag struct {
+	bit  byte
+	name string
+}
+
+var (
+	headerFlags = []indicatorFlag{{VCDDecompress, "VCD_DECOMPRESS"}, {VCDCodetable, "VCD_CODETABLE"}, {VCDAppHeader, "VCD_APPHEADER"}}
+	windowFlags = []indicatorFlag{{VCDSource, "VCD_SOURCE"}, {VCDTarget, "VCD_TARGET"}, {VCDAdler32, "VCD_ADLER32"}}
+	deltaFlags  = []indicatorFlag{{VCDDataComp, "VCD_DATACOMP"}, {VCDInstComp, "VCD_INSTCOMP"}, {VCDAddrComp, "VCD_ADDRCOMP"}}
+)
+
+// indicatorNames returns the names of the bits set in indicator
+func indicatorNames(indicator byte, flags []indicatorFlag) []string {
+	names := []string{}
+	for _, flag := range flags {
+		if indicator&flag.bit != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+// formatIndicator formats an indicator as hex followed by the names of its
+// set bits, such as 0x05(VCD_SOURCE|VCD_ADLER32)
+func formatIndicator(indicator byte, flags []indicatorFlag) string {
+	names := indicatorNames(indicator, flags)
+	if len(names) == 0 {
+		return fmt.Sprintf("0x%02x", indicator)
+	}
+	return fmt.Sprintf("0x%02x(%s)", indicator, strings.Join(names, "|"))
+}
+
+// addressModeName names an address mode given the near cache size: self,
+// here, then near0 onwards and same0 onwards - RFC 3284 Section 5.3
+func addressModeName(mode byte, nearSize int) string {
+	switch {
+	case mode == SelfMode:
+		return "self"
+	case mode == HereMode:
+		return "here"
+	case int(mode) < fixedAddressModes+nearSize:
+		return fmt.Sprintf("near%d", int(mode)-fixedAddressModes)
+	default:
+		return fmt.Sprintf("same%d", int(mode)-fixedAddressModes-nearSize)
+	}
+}
+
+// Disassemble writes a text listing of parsed to w. The format is fixed so
+// that listings can be diffed, checked into tests and compared between
+// tools. The first line describes the header, followed by a line for each
+// optional header field present:
+//
+//	vcdiff version=0x00 indicator=0x04(VCD_APPHEADER) windows=1
+//	secondary_compressor id=<id>
+//	code_table length=<bytes>
+//	app_header length=5 data="hello"
+//
+// Each window has a line giving its indicator, source or target segment as
+// position+size, target offset+length, delta indicator, section lengths and,
+// when present, checksum and interleaved layout:
+//
+//	window 0 indicator=0x05(VCD_SOURCE|VCD_ADLER32) segment=source:0+128 target=0+87 delta_indicator=0x00 data=1 instructions=12 addresses=6 adler32=0x6d692b08
+//
+// followed by one indented line per instruction, starting with the target
+// offset it writes at. A code holding two instructions lists them as
+// code=<code>.1 and code=<code>.2. COPYs give the address mode, the decoded
+// address in the window's address space, and where that address lies in
+// the source or target:
+//
+//	0 COPY size=13 code=35 mode=here addr=15 from=source:15
+//	13 ADD size=3 code=4 data="abc"
+//	16 RUN size=10 code=0 byte=0x20
+//
+// Instructions of windows with compressed sections cannot be read and are
+// replaced by a single "  compressed" line. Data is quoted as Go string
+// literals. An error reading a window's instructions is returned after the
+// lines before it are written.
+func Disassemble(w io.Writer, parsed *ParsedDelta) error {
+	bw := bufio.NewWriter(w)
+	header := &parsed.Header
+	fmt.Fprintf(bw, "vcdiff version=0x%02x indicator=%s windows=%d\n",
+		header.Version, formatIndicator(header.Indicator, headerFlags), len(parsed.Windows))
+	if header.Indicator&VCDDecompress != 0 {
+		fmt.Fprintf(bw, "secondary_compressor id=%d\n", header.SecondaryCompressorID)
+	}
+	if header.Indicator&VCDCodetable != 0 {
+		fmt.Fprintf(bw, "code_table length=%d\n", len(header.CodeTable))
+	}
+	if header.Indicator&VCDAppHeader != 0 {
+		fmt.Fprintf(bw, "app_header length=%d data=%q\n", len(header.AppHeader), header.AppHeader)
+	}
+
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	var targetOffset uint64
+	for i := range parsed.Windows {
+		if err := disassembleWindow(bw, i, &parsed.Windows[i], addressCache, targetOffset); err != nil {
+			bw.Flush()
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+		targetOffset += uint64(parsed.Windows[i].TargetWindowLength)
+	}
+	return bw.Flush()
+}
+
+// disassembleWindow writes a window's line and its instructions. targetOffset
+// is where the window's target starts in the whole target.
+func disassembleWindow(w io.Writer, index int, window *Window, addressCache *AddressCache, targetOffset uint64) error {
+	fmt.Fprintf(w, "window %d indicator=%s", index, formatIndicator(window.WinIndicator, windowFlags))
+	segment := "source"
+	if window.WinIndicator&VCDTarget != 0 {
+		segment = "target"
+	}
+	var segmentSize uint32
+	if window.WinIndicator&(VCDSource|VCDTarget) != 0 {
+		segmentSize = window.SourceSegmentSize
+		fmt.Fprintf(w, " segment=%s:%d+%d", segment, window.SourceSegmentPosition, segmentSize)
+	}
+	fmt.Fprintf(w, " target=%d+%d delta_indicator=%s data=%d instructions=%d addresses=%d",
+		targetOffset, window.TargetWindowLength, formatIndicator(window.DeltaIndicator, deltaFlags),
+		len(window.DataSection), len(window.InstructionSection), len(window.AddressSection))
+	if window.HasChecksum {
+		fmt.Fprintf(w, " adler32=0x%08x", window.Checksum)
+	}
+	if window.Interleaved {
+		fmt.Fprintf(w, " layout=interleaved")
+	}
+	fmt.Fprintln(w)
+
+	if window.DeltaIndicator != 0 {
+		fmt.Fprintln(w, "  compressed")
+		return nil
+	}
+
+	addressCache.resetFor(window)
+	table := window.codeTable()
+	nearSize, _ := window.cacheSizes(NearCacheSize, SameCacheModes)
+	here := segmentSize
+	return scanCodes(window, func(code byte, slot int, inst RuntimeInstruction) error {
+		fmt.Fprintf(w, "  %d %s size=%d code=%d", targetOffset+uint64(here-segmentSize), inst.Type, inst.Size, code)
+		if table.Get(code, 1).Type != NoOp {
+			fmt.Fprintf(w, ".%d", slot+1)
+		}
+
+		switch inst.Type {
+		case Add:
+			fmt.Fprintf(w, " data=%q", inst.Data)
+		case Run:
+			fmt.Fprintf(w, " byte=0x%02x", inst.Data[0])
+		case Copy:
+			addr, err := addressCache.DecodeAddress(here, inst.Mode)
+			if err != nil {
+				fmt.Fprintln(w)
+				return err
+			}
+			fmt.Fprintf(w, " mode=%s addr=%d", addressModeName(inst.Mode, nearSize), addr)
+			if addr < segmentSize {
+				fmt.Fprintf(w, " from=%s:%d", segment, uint64(window.SourceSegmentPosition)+uint64(addr))
+			} else {
+				fmt.Fprintf(w, " from=target:%d", targetOffset+uint64(addr-segmentSize))
+			}
+		}
+		fmt.Fprintln(w)
+		here += inst.Size
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+package vcdiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	parsed := &ParsedDelta{
+		Header: Header{Magic: [3]byte{0xd6, 0xc3, 0xc4}, Indicator: VCDAppHeader, AppHeader: []byte("app")},
+		Windows: []Window{
+			{
+				WinIndicator:          VCDSource | VCDAdler32,
+				SourceSegmentPosition: 2,
+				SourceSegmentSize:     8,
+				TargetWindowLength:    13,
+				DataSection:           []byte("xz"),
+				// ADD 1 + COPY 4 self, COPY 5 here, RUN 3
+				InstructionSection: []byte{163, 35, 5, 0, 3},
+				AddressSection:     []byte{3, 1},
+				HasChecksum:        true,
+				Checksum:           0x01020304,
+			},
+			{
+				WinIndicator:       VCDTarget,
+				SourceSegmentSize:  4,
+				TargetWindowLength: 6,
+				// ADD 2, COPY 4 near0
+				DataSection:        []byte("ab"),
+				InstructionSection: []byte{3, 52},
+				AddressSection:     []byte{1},
+			},
+			{
+				TargetWindowLength: 7,
+				DeltaIndicator:     VCDDataComp,
+				DataSection:        []byte{0xff},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := Disassemble(&out, parsed); err != nil {
+		t.Fatal(err)
+	}
+	want := `vcdiff version=0x00 indicator=0x04(VCD_APPHEADER) windows=3
+app_header length=3 data="app"
+window 0 indicator=0x05(VCD_SOURCE|VCD_ADLER32) segment=source:2+8 target=0+13 delta_indicator=0x00 data=2 instructions=5 addresses=2 adler32=0x01020304
+  0 ADD size=1 code=163.1 data="x"
+  1 COPY size=4 code=163.2 mode=self addr=3 from=source:5
+  5 COPY size=5 code=35 mode=here addr=12 from=target:4
+  10 RUN size=3 code=0 byte=0x7a
+window 1 indicator=0x02(VCD_TARGET) segment=target:0+4 target=13+6 delta_indicator=0x00 data=2 instructions=2 addresses=1
+  13 ADD size=2 code=3 data="ab"
+  15 COPY size=4 code=52 mode=near0 addr=1 from=target:1
+window 2 indicator=0x00 target=19+7 delta_indicator=0x01(VCD_DATACOMP) data=1 instructions=0 addresses=0
+  compressed
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	// A truncated address section is reported after the lines before it
+	parsed.Windows[0].AddressSection = []byte{3}
+	out.Reset()
+	if err := Disassemble(&out, parsed); err == nil {
+		t.Fatal("expected an error for a missing address")
+	}
+	if !bytes.Contains(out.Bytes(), []byte("  1 COPY size=4 code=163.2 mode=self addr=3 from=source:5\n")) {
+		t.Errorf("lines before the error were not written:\n%s", out.String())
+	}
+}
@@ -0,0 +1,380 @@
+package vcdiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Editing helpers for normalizing deltas. Each keeps the parsed delta
+// self-consistent: length fields and Instructions are recomputed after every
+// edit, so the result can be passed straight to MarshalDelta. Windows that
+// are rebuilt (split or merged) are re-encoded with one instruction per code
+// and SELF mode addresses.
+
+// StripChecksums removes the VCD_ADLER32 checksum from every window
+func (p *ParsedDelta) StripChecksums() {
+	for i := range p.Windows {
+		p.Windows[i].WinIndicator &^= VCDAdler32
+		p.Windows[i].HasChecksum = false
+		p.Windows[i].Checksum = 0
+	}
+	p.refresh()
+}
+
+// AddChecksums decodes every window against source and stores the Adler-32
+// of its target, replacing any existing checksum
+func (p *ParsedDelta) AddChecksums(source []byte) error {
+	targets, err := p.windowTargets(source)
+	if err != nil {
+		return err
+	}
+	for i := range p.Windows {
+		p.Windows[i].WinIndicator |= VCDAdler32
+		p.Windows[i].HasChecksum = true
+		p.Windows[i].Checksum = ComputeChecksum(1, targets[i])
+	}
+	p.refresh()
+	return nil
+}
+
+// DropAppHeader removes the application header
+func (p *ParsedDelta) DropAppHeader() {
+	p.Header.Indicator &^= VCDAppHeader
+	p.Header.AppHeader = nil
+}
+
+// ShiftSource moves the source segment of every VCD_SOURCE window by offset
+// bytes, for applying the delta to a base with data inserted (positive) or
+// removed (negative) ahead of everything it references
+func (p *ParsedDelta) ShiftSource(offset int64) error {
+	for i, window := range p.Windows {
+		if window.WinIndicator&VCDSource == 0 {
+			continue
+		}
+		position := int64(window.SourceSegmentPosition) + offset
+		if position < 0 || position+int64(window.SourceSegmentSize) > math.MaxUint32 {
+			return fmt.Errorf("%w: window %d: source segment cannot move to position %d", ErrInvalidFormat, i, position)
+		}
+		p.Windows[i].SourceSegmentPosition = uint32(position)
+	}
+	return nil
+}
+
+// RecodeOptions selects the edits Recode makes to a delta
+type RecodeOptions struct {
+	AddChecksums   bool  // Store the Adler-32 of every window's target, which needs the source
+	StripChecksums bool  // Remove every window's checksum
+	DropAppHeader  bool  // Remove the application header
+	ShiftSource    int64 // Move every source segment by this many bytes, as ShiftSource does
+}
+
+// Recode rewrites delta with the edits selected by opts, for normalizing
+// deltas made by different encoders. source is the base the delta applies
+// to before any shift, and is only read when adding checksums.
+func Recode(delta, source []byte, opts RecodeOptions) ([]byte, error) {
+	if opts.AddChecksums && opts.StripChecksums {
+		return nil, errors.New("cannot both add and strip checksums")
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.AddChecksums:
+		if err := parsed.AddChecksums(source); err != nil {
+			return nil, err
+		}
+	case opts.StripChecksums:
+		parsed.StripChecksums()
+	}
+	if opts.DropAppHeader {
+		parsed.DropAppHeader()
+	}
+	if opts.ShiftSource != 0 {
+		if err := parsed.ShiftSource(opts.ShiftSource); err != nil {
+			return nil, err
+		}
+	}
+	return MarshalDelta(parsed)
+}
+
+// SplitWindow splits window index into two at target offset at, relative to
+// the window. COPYs in the second half that read target data from the first
+// half are replaced by ADDs of the bytes they produced, which is why source
+// is needed. Checksums are recomputed for both halves.
+func (p *ParsedDelta) SplitWindow(index int, at uint32, source []byte) error {
+	window, err := p.rebuildableWindow(index)
+	if err != nil {
+		return err
+	}
+	if at == 0 || at >= window.TargetWindowLength {
+		return fmt.Errorf("window %d: split offset %d outside 1-%d", index, at, window.TargetWindowLength-1)
+	}
+
+	r, err := resolveWindow(window, NewAddressCache(NearCacheSize, SameCacheModes))
+	if err != nil {
+		return fmt.Errorf("window %d: %w", index, err)
+	}
+	target, _, ok := r.execute(source, 0, 0)
+	if !ok {
+		return fmt.Errorf("%w: window %d references data outside the source", ErrSourceTooShort, index)
+	}
+
+	segmentSize := window.SourceSegmentSize
+	var first, second []RuntimeInstruction
+	var position uint32
+	for _, inst := range r.instructions {
+		if inst.Type == NoOp {
+			continue
+		}
+		head, tail := inst, inst
+		switch {
+		case position+inst.Size <= at:
+			first = append(first, inst)
+		case position >= at:
+			second = append(second, inst)
+		default:
+			// The instruction straddles the split
+			head.Size, tail.Size = at-position, position+inst.Size-at
+			switch inst.Type {
+			case Add:
+				head.Data, tail.Data = inst.Data[:head.Size], inst.Data[head.Size:]
+			case Copy:
+				tail.Addr += head.Size
+			}
+			first = append(first, head)
+			second = append(second, tail)
+		}
+		position += inst.Size
+	}
+
+	// Rebase the second half's target COPYs onto its own target
+	position = at
+	for i, inst := range second {
+		if inst.Type == Copy && inst.Addr >= segmentSize {
+			if inst.Addr-segmentSize < at {
+				second[i] = RuntimeInstruction{Type: Add, Size: inst.Size, Data: target[position : position+inst.Size]}
+			} else {
+				second[i].Addr -= at
+			}
+		}
+		position += inst.Size
+	}
+
+	head, tail := *window, *window
+	encodeInstructions(&head, first)
+	encodeInstructions(&tail, second)
+	if window.HasChecksum {
+		head.Checksum = ComputeChecksum(1, target[:at])
+		tail.Checksum = ComputeChecksum(1, target[at:])
+	}
+
+	p.Windows = append(p.Windows[:index], append([]Window{head, tail}, p.Windows[index+1:]...)...)
+	p.refresh()
+	return nil
+}
+
+// MergeWindows joins window index with the window after it. The merged
+// window's source segment spans both original segments. It carries a checksum
+// only if both windows did.
+func (p *ParsedDelta) MergeWindows(index int) error {
+	if index+1 >= len(p.Windows) {
+		return fmt.Errorf("window %d has no following window to merge with", index)
+	}
+	first, err := p.rebuildableWindow(index)
+	if err != nil {
+		return err
+	}
+	second, err := p.rebuildableWindow(index + 1)
+	if err != nil {
+		return err
+	}
+	if uint64(first.TargetWindowLength)+uint64(second.TargetWindowLength) > maxWindowLength {
+		return fmt.Errorf("%w: merged window would exceed %d bytes", ErrInvalidFormat, uint64(maxWindowLength))
+	}
+
+	merged := *first
+	merged.WinIndicator = (first.WinIndicator | second.WinIndicator) &^ VCDAdler32
+	merged.SourceSegmentPosition, merged.SourceSegmentSize = 0, 0
+	if merged.WinIndicator&VCDSource != 0 {
+		start, end := uint64(math.MaxUint32), uint64(0)
+		for _, w := range []*Window{first, second} {
+			if w.WinIndicator&VCDSource != 0 {
+				start = min(start, uint64(w.SourceSegmentPosition))
+				end = max(end, uint64(w.SourceSegmentPosition)+uint64(w.SourceSegmentSize))
+			}
+		}
+		if end > maxSourceSegment {
+			return fmt.Errorf("%w: merged source segment would end at %d", ErrInvalidFormat, end)
+		}
+		merged.SourceSegmentPosition, merged.SourceSegmentSize = uint32(start), uint32(end-start)
+	}
+
+	// Re-address both windows' COPYs in the merged address space: the merged
+	// source segment followed by the first window's target and then the second's
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	var instructions []RuntimeInstruction
+	var targetOffset uint32
+	for _, w := range []*Window{first, second} {
+		r, err := resolveWindow(w, addressCache)
+		if err != nil {
+			return err
+		}
+		segmentSize := uint32(0)
+		if w.WinIndicator&VCDSource != 0 {
+			segmentSize = w.SourceSegmentSize
+		}
+		for _, inst := range r.instructions {
+			if inst.Type == Copy {
+				if inst.Addr < segmentSize {
+					inst.Addr += w.SourceSegmentPosition - merged.SourceSegmentPosition
+				} else {
+					inst.Addr += merged.SourceSegmentSize + targetOffset - segmentSize
+				}
+			}
+			instructions = append(instructions, inst)
+		}
+		targetOffset += w.TargetWindowLength
+	}
+	encodeInstructions(&merged, instructions)
+
+	merged.HasChecksum = first.HasChecksum && second.HasChecksum
+	merged.Checksum = 0
+	if merged.HasChecksum {
+		merged.Checksum = CombineChecksums(first.Checksum, second.Checksum, uint64(second.TargetWindowLength))
+	}
+
+	p.Windows = append(append(p.Windows[:index], merged), p.Windows[index+2:]...)
+	p.refresh()
+	return nil
+}
+
+// AlignWindows splits and merges windows so that every window boundary falls
+// on a multiple of boundary bytes in the target, and every window except the
+// last produces exactly boundary bytes. Chunks of a delta aligned this way
+// cover predictable target ranges, so they can be cached, range-requested
+// and applied independently. source is needed to split windows.
+func (p *ParsedDelta) AlignWindows(boundary uint32, source []byte) error {
+	if boundary == 0 {
+		return errors.New("window boundary must be positive")
+	}
+
+	var start uint64
+	for i := 0; i < len(p.Windows); {
+		length := uint64(p.Windows[i].TargetWindowLength)
+		end := (start/uint64(boundary) + 1) * uint64(boundary)
+		switch {
+		case start+length > end:
+			if err := p.SplitWindow(i, uint32(end-start), source); err != nil {
+				return err
+			}
+		case start+length < end && i+1 < len(p.Windows):
+			// Absorb the next window and look at the result again
+			if err := p.MergeWindows(i); err != nil {
+				return err
+			}
+			continue
+		}
+		start += uint64(p.Windows[i].TargetWindowLength)
+		i++
+	}
+	return nil
+}
+
+// LimitWindows splits every window producing more than limit target bytes
+// and merges runs of adjacent windows that fit in limit together, for
+// decoders and transports that cap the window size. Every window then
+// produces at most limit bytes, in as few windows as this greedy packing
+// finds. source is needed to split windows.
+func (p *ParsedDelta) LimitWindows(limit uint32, source []byte) error {
+	if limit == 0 {
+		return errors.New("window limit must be positive")
+	}
+
+	for i := 0; i < len(p.Windows); {
+		length := p.Windows[i].TargetWindowLength
+		switch {
+		case length > limit:
+			// The remainder becomes window i+1 and is looked at next
+			if err := p.SplitWindow(i, limit, source); err != nil {
+				return err
+			}
+		case i+1 < len(p.Windows) && uint64(length)+uint64(p.Windows[i+1].TargetWindowLength) <= uint64(limit):
+			if err := p.MergeWindows(i); err != nil {
+				return err
+			}
+			continue
+		}
+		i++
+	}
+	return nil
+}
+
+// editableWindow returns window index if the helpers can rebuild it
+func (p *ParsedDelta) editableWindow(index int) (*Window, error) {
+	if index < 0 || index >= len(p.Windows) {
+		return nil, fmt.Errorf("window %d does not exist: delta has %d windows", index, len(p.Windows))
+	}
+	window := &p.Windows[index]
+	if err := checkSupported(&p.Header, window); err != nil {
+		return nil, fmt.Errorf("window %d: %w", index, err)
+	}
+	return window, nil
+}
+
+// rebuildableWindow is editableWindow for edits that re-encode the window's
+// instructions. Those are written with the default code table, so windows
+// of a delta carrying its own table are rejected.
+func (p *ParsedDelta) rebuildableWindow(index int) (*Window, error) {
+	window, err := p.editableWindow(index)
+	if err != nil {
+		return nil, err
+	}
+	if window.codes != nil {
+		return nil, fmt.Errorf("window %d: %w: rebuilding windows of a custom code table (VCD_CODETABLE)", index, ErrUnsupported)
+	}
+	return window, nil
+}
+
+// windowTargets decodes each window's target against source
+func (p *ParsedDelta) windowTargets(source []byte) ([][]byte, error) {
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	targets := make([][]byte, len(p.Windows))
+	for i := range p.Windows {
+		window, err := p.editableWindow(i)
+		if err != nil {
+			return nil, err
+		}
+		r, err := resolveWindow(window, addressCache)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+		target, _, ok := r.execute(source, 0, 0)
+		if !ok {
+			return nil, fmt.Errorf("%w: window %d references data outside the source", ErrSourceTooShort, i)
+		}
+		targets[i] = target
+	}
+	return targets, nil
+}
+
+// refresh recomputes every window's stored lengths and the flattened
+// instruction list after an edit
+func (p *ParsedDelta) refresh() {
+	p.Instructions = p.Instructions[:0]
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range p.Windows {
+		window := &p.Windows[i]
+		window.DataSectionLength = uint32(len(window.DataSection))
+		window.InstructionSectionLength = uint32(len(window.InstructionSection))
+		window.AddressSectionLength = uint32(len(window.AddressSection))
+		window.Interleaved = false
+		window.DeltaEncodingLength = uint32(len(appendEncoding(nil, window)))
+
+		// Windows were valid when parsed and edits only rebuild valid ones
+		instructions, _ := parseInstructions(window, addressCache)
+		p.Instructions = append(p.Instructions, instructions...)
+	}
+}
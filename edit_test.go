@@ -0,0 +1,252 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// remarshal serializes parsed and checks the result still decodes to target
+func remarshal(t *testing.T, parsed *ParsedDelta, source, target []byte) []byte {
+	t.Helper()
+	delta, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatalf("MarshalDelta failed: %v", err)
+	}
+	reparsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatalf("re-serialized delta does not parse: %v", err)
+	}
+	if len(reparsed.Windows) != len(parsed.Windows) || len(reparsed.Instructions) != len(parsed.Instructions) {
+		t.Fatalf("re-parsed %d windows, %d instructions, expected %d, %d",
+			len(reparsed.Windows), len(reparsed.Instructions), len(parsed.Windows), len(parsed.Instructions))
+	}
+	for i := range parsed.Windows {
+		if reparsed.Windows[i].DeltaEncodingLength != parsed.Windows[i].DeltaEncodingLength {
+			t.Fatalf("window %d: stored delta encoding length %d, serialized %d",
+				i, parsed.Windows[i].DeltaEncodingLength, reparsed.Windows[i].DeltaEncodingLength)
+		}
+	}
+	result, err := Decode(source, delta)
+	if err != nil {
+		t.Fatalf("re-serialized delta does not decode: %v", err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Fatal("re-serialized delta decodes to a different target")
+	}
+	return delta
+}
+
+func TestMarshalDeltaRoundTrip(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileNoSource} {
+		for seed := int64(0); seed < 20; seed++ {
+			g := GenerateDelta(seed, profile)
+			parsed, err := ParseDelta(g.Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			delta, err := MarshalDelta(parsed)
+			if err != nil {
+				t.Fatalf("seed %d: MarshalDelta failed: %v", seed, err)
+			}
+			if !bytes.Equal(delta, g.Delta) {
+				t.Fatalf("seed %d: round trip changed the delta", seed)
+			}
+		}
+	}
+
+	parsed := &ParsedDelta{Header: Header{Indicator: 0x80}}
+	if _, err := MarshalDelta(parsed); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("reserved header bits: got %v, expected ErrInvalidFormat", err)
+	}
+}
+
+func TestEditChecksumsAndAppHeader(t *testing.T) {
+	g := GenerateDelta(1, ProfileCopyHeavy)
+	parsed, err := ParseDelta(withHeaderSection(g.Delta, VCDAppHeader, []byte("meta")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed.StripChecksums()
+	parsed.DropAppHeader()
+	stripped := remarshal(t, parsed, g.Source, g.Target)
+	if len(stripped) >= len(g.Delta) {
+		t.Errorf("stripped delta is %d bytes, original %d", len(stripped), len(g.Delta))
+	}
+	if req, _ := Requirements(stripped); req == nil || req.Windows != ProfileCopyHeavy.Windows {
+		t.Fatalf("unexpected requirements for stripped delta: %+v", req)
+	}
+
+	if err := parsed.AddChecksums(g.Source); err != nil {
+		t.Fatal(err)
+	}
+	parsed.Header.Indicator = 0
+	if restored := remarshal(t, parsed, g.Source, g.Target); !bytes.Equal(restored, g.Delta) {
+		t.Error("adding checksums back did not restore the original delta")
+	}
+}
+
+func TestEditShiftSource(t *testing.T) {
+	g := GenerateDelta(2, ProfileCopyHeavy)
+	parsed, err := ParseDelta(g.Delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte("inserted header ")
+	if err := parsed.ShiftSource(int64(len(prefix))); err != nil {
+		t.Fatal(err)
+	}
+	remarshal(t, parsed, append(prefix, g.Source...), g.Target)
+
+	if err := parsed.ShiftSource(-1 << 40); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("shift before the start of the base: got %v, expected ErrInvalidFormat", err)
+	}
+}
+
+func TestRecode(t *testing.T) {
+	g := GenerateDelta(4, ProfileCopyHeavy)
+	delta := withHeaderSection(g.Delta, VCDAppHeader, []byte("meta"))
+
+	stripped, err := Recode(delta, nil, RecodeOptions{StripChecksums: true, DropAppHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Indicator != 0 || parsed.Windows[0].HasChecksum {
+		t.Fatalf("app header or checksums left after recoding: %+v", parsed.Header)
+	}
+
+	prefix := []byte("prefix")
+	recoded, err := Recode(stripped, g.Source, RecodeOptions{AddChecksums: true, ShiftSource: int64(len(prefix))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decode(append(prefix, g.Source...), recoded); err != nil || !bytes.Equal(got, g.Target) {
+		t.Fatalf("recoded delta does not decode against the shifted base: %v", err)
+	}
+	if parsed, _ := ParseDelta(recoded); !parsed.Windows[0].HasChecksum {
+		t.Error("checksums were not added")
+	}
+
+	if _, err := Recode(delta, g.Source, RecodeOptions{AddChecksums: true, StripChecksums: true}); err == nil {
+		t.Error("expected an error for adding and stripping checksums")
+	}
+	if _, err := Recode(delta, g.Source[:10], RecodeOptions{AddChecksums: true}); !errors.Is(err, ErrSourceTooShort) {
+		t.Errorf("adding checksums with a short source: got %v, expected ErrSourceTooShort", err)
+	}
+}
+
+func TestEditSplitAndMergeWindows(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileAddHeavy, ProfileNoSource} {
+		for seed := int64(0); seed < 20; seed++ {
+			g := GenerateDelta(seed, profile)
+			parsed, err := ParseDelta(g.Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Split every window in two where possible
+			for i := len(parsed.Windows) - 1; i >= 0; i-- {
+				if length := parsed.Windows[i].TargetWindowLength; length > 1 {
+					if err := parsed.SplitWindow(i, length/2, g.Source); err != nil {
+						t.Fatalf("seed %d: split window %d: %v", seed, i, err)
+					}
+				}
+			}
+			remarshal(t, parsed, g.Source, g.Target)
+
+			// Then merge everything into a single window
+			for len(parsed.Windows) > 1 {
+				if err := parsed.MergeWindows(0); err != nil {
+					t.Fatalf("seed %d: merge: %v", seed, err)
+				}
+			}
+			remarshal(t, parsed, g.Source, g.Target)
+			if profile.Checksums && !parsed.Windows[0].HasChecksum {
+				t.Fatalf("seed %d: merged window lost its checksum", seed)
+			}
+		}
+	}
+}
+
+func TestEditAlignWindows(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileManyWindows, ProfileNoSource} {
+		for seed := int64(0); seed < 20; seed++ {
+			g := GenerateDelta(seed, profile)
+			parsed, err := ParseDelta(g.Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			boundary := uint32(len(g.Target)/3 + 1)
+			if err := parsed.AlignWindows(boundary, g.Source); err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			remarshal(t, parsed, g.Source, g.Target)
+			for i, window := range parsed.Windows[:len(parsed.Windows)-1] {
+				if window.TargetWindowLength != boundary {
+					t.Fatalf("seed %d: window %d produces %d bytes, expected %d", seed, i, window.TargetWindowLength, boundary)
+				}
+			}
+		}
+	}
+}
+
+func TestEditLimitWindows(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileManyWindows, ProfileNoSource} {
+		for seed := int64(0); seed < 10; seed++ {
+			g := GenerateDelta(seed, profile)
+			for _, limit := range []uint32{uint32(len(g.Target)/5 + 1), uint32(len(g.Target) + 1)} {
+				parsed, err := ParseDelta(g.Delta)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := parsed.LimitWindows(limit, g.Source); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				remarshal(t, parsed, g.Source, g.Target)
+				for i, window := range parsed.Windows {
+					if window.TargetWindowLength > limit {
+						t.Fatalf("seed %d: window %d produces %d bytes, limit %d", seed, i, window.TargetWindowLength, limit)
+					}
+					if i > 0 && window.TargetWindowLength+parsed.Windows[i-1].TargetWindowLength <= limit {
+						t.Fatalf("seed %d: windows %d and %d fit in one window", seed, i-1, i)
+					}
+				}
+			}
+		}
+	}
+
+	var parsed ParsedDelta
+	if err := parsed.LimitWindows(0, nil); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
+
+func TestEditRejectsUnsupportedWindows(t *testing.T) {
+	parsed, err := ParseDelta(singleAddDelta(0, VCDDataComp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.SplitWindow(0, 1, nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("split of compressed window: got %v, expected ErrUnsupported", err)
+	}
+	if err := parsed.MergeWindows(0); err == nil {
+		t.Error("merge without a following window succeeded")
+	}
+}
+
+func TestCombineChecksums(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	for _, cut := range []int{0, 1, 20, len(data)} {
+		combined := CombineChecksums(ComputeChecksum(1, data[:cut]), ComputeChecksum(1, data[cut:]), uint64(len(data)-cut))
+		if want := ComputeChecksum(1, data); combined != want {
+			t.Errorf("cut at %d: got 0x%08x, expected 0x%08x", cut, combined, want)
+		}
+	}
+}
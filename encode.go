@@ -0,0 +1,561 @@
+package vcdiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Encoder tuning
+const (
+	minMatchLength = 4          // Shortest COPY; the default code table's smallest COPY size - RFC 3284 Section 5.6
+	minRunLength   = 8          // Shortest RUN; shorter runs cost more than the ADD bytes they replace
+	minHashBits    = 10         // Smallest hash table, 2^10 slots
+	hashMultiplier = 2654435761 // Knuth's multiplicative hash constant, 2^32 divided by the golden ratio
+
+	// Sparsely indexed sources are fingerprinted by longer blocks, so the
+	// sampled positions of repetitive data do not evict each other
+	sourceFingerprintLength = 32
+	fingerprintMultiplier   = 0x9e3779b97f4a7c15 // 2^64 divided by the golden ratio
+)
+
+// codeKey is one instruction of the default code table, with size 0 meaning
+// the size follows in the instruction stream
+type codeKey struct {
+	typ  InstructionType
+	size byte
+	mode byte
+}
+
+// singleCodes and pairCodes map instructions to their default code table
+// codes - RFC 3284 Section 5.6
+var singleCodes, pairCodes = indexCodeTable(DefaultCodeTable)
+
+func indexCodeTable(table *CodeTable) (map[codeKey]byte, map[[2]codeKey]byte) {
+	singles := make(map[codeKey]byte)
+	pairs := make(map[[2]codeKey]byte)
+	for code := 0; code < InstructionTableSize; code++ {
+		first, second := table.Get(byte(code), 0), table.Get(byte(code), 1)
+		key := codeKey{first.Type, first.Size, first.Mode}
+		switch {
+		case first.Type == NoOp:
+		case second.Type == NoOp:
+			singles[key] = byte(code)
+		default:
+			pairs[[2]codeKey{key, {second.Type, second.Size, second.Mode}}] = byte(code)
+		}
+	}
+	return singles, pairs
+}
+
+// encodeOp is an instruction chosen by the matcher. COPY addresses are
+// absolute source offsets or window-relative target offsets until the window
+// is serialized.
+type encodeOp struct {
+	typ        InstructionType
+	size       uint32
+	data       []byte // ADD bytes, or the RUN byte
+	fromSource bool
+	addr       uint32
+}
+
+// EncoderOption configures Encode
+type EncoderOption func(*encoder)
+
+// WithAppHeader writes data as the delta's application header and sets
+// VCD_APPHEADER, so that deltas can carry identifiers of their base and
+// target. Decoders return it in Header.AppHeader; a source fingerprint from
+// NewSourceFingerprint(source).AppHeader() is also verified on decode. A nil
+// data writes no application header.
+func WithAppHeader(data []byte) EncoderOption {
+	return func(e *encoder) {
+		e.appHeader = data
+	}
+}
+
+// WithInterleaved writes the delta in open-vcdiff's interleaved format:
+// version 'S', with each window's data and addresses carried in its
+// instruction section. SDCH-era decoders that only accept that layout can
+// then apply it; this package decodes both.
+func WithInterleaved() EncoderOption {
+	return func(e *encoder) {
+		e.interleaved = true
+	}
+}
+
+// WithMinMatchLength sets the shortest match Encode turns into a COPY;
+// shorter matches are folded into ADDs. A COPY costs its code and an address
+// of up to five bytes, so raising it from the default of 4 avoids COPYs of a
+// few scattered bytes that save less than they cost. Encode returns an error
+// for a length below 4, the shortest the matcher can find. 0 selects the
+// default.
+func WithMinMatchLength(n int) EncoderOption {
+	return func(e *encoder) {
+		e.minMatch = n
+	}
+}
+
+// WithConcurrency lets Encode match and serialize up to n windows at once,
+// each with its own target hash table, while still writing them in order;
+// runtime.GOMAXPROCS(0) uses every core. The delta is the same as with one
+// worker. It only helps targets longer than a window, and NewEncoder then
+// buffers n windows of the target. A SecondaryCompressor must be safe for
+// concurrent use. Values below 2 encode one window at a time.
+func WithConcurrency(n int) EncoderOption {
+	return func(e *encoder) {
+		e.concurrency = n
+	}
+}
+
+// Encode produces an RFC 3284 delta that reconstructs target from source,
+// using ADD, COPY and RUN with the default code table. COPYs may read from the
+// source or from earlier target data in the same window. The delta carries no
+// checksums; ParsedDelta.AddChecksums can add them.
+func Encode(source, target []byte, opts ...EncoderOption) ([]byte, error) {
+	e, err := newEncoder(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := commonSuffix(source, target); n >= e.minMatch {
+		e.suffixStart, e.suffixShift = len(target)-n, len(source)-len(target)
+	}
+
+	parsed := &ParsedDelta{Header: e.header()}
+	if parsed.Windows, err = e.encodeWindows(target, 0); err != nil {
+		return nil, err
+	}
+	if e.compressor != nil {
+		markCompressed(parsed, e.compressor)
+	}
+
+	delta, err := MarshalDelta(parsed)
+	if err != nil {
+		return nil, err
+	}
+	if e.interleaved {
+		// MarshalDelta writes version 0; the windows are only valid as version 'S'
+		delta[len(VCDIFFMagic)] = SDCHVersion
+	}
+	return delta, nil
+}
+
+// newEncoder applies opts and indexes source
+func newEncoder(source []byte, opts []EncoderOption) (*encoder, error) {
+	e := &encoder{source: source}
+	for _, opt := range opts {
+		opt(e)
+	}
+	params, ok := levels[e.level]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoder level %d", int(e.level))
+	}
+	e.levelParams = params
+	if e.minMatch == 0 {
+		e.minMatch = minMatchLength
+	} else if e.minMatch < minMatchLength {
+		return nil, fmt.Errorf("minimum match length %d is below %d", e.minMatch, minMatchLength)
+	}
+	if space := uint64(math.MaxUint32) - uint64(e.windowSize); uint64(len(source)) > space {
+		return nil, fmt.Errorf("%w: source of %d bytes exceeds the %d byte address space", ErrUnsupported, len(source), space)
+	}
+
+	e.suffixStart = math.MaxInt
+	return e, nil
+}
+
+// indexSource builds the source table. It is only called once a window needs
+// the matcher, so a target that matches the source at the same offsets never
+// pays for hashing it.
+func (e *encoder) indexSource() {
+	// A source with more positions than hash slots is sampled every stride
+	// bytes. Any match of at least stride+sourceFingerprintLength-1 bytes
+	// still covers a sampled block, and serializeWindow gives each window
+	// only the source segment its COPYs read.
+	e.sourceStride = max(1, len(e.source)>>e.maxHashBits)
+	width := minMatchLength
+	if e.sourceStride > 1 {
+		width = sourceFingerprintLength
+	}
+	e.sourceTable = newHashTable(len(e.source)/e.sourceStride, e.sourceStride, width, e.levelParams)
+	for i := 0; i+width <= len(e.source); i += e.sourceStride {
+		e.sourceTable.insert(e.source, i)
+	}
+}
+
+// header returns the delta header for the encoder's options, without the
+// secondary compression fields, which depend on whether any section shrank
+func (e *encoder) header() Header {
+	var header Header
+	if e.appHeader != nil {
+		header.Indicator |= VCDAppHeader
+		header.AppHeader = e.appHeader
+	}
+	return header
+}
+
+// encodeWindows splits target, which starts offset bytes into the whole
+// target, into windows and encodes them, up to e.concurrency at a time,
+// returning them in order. Each worker matches with its own target
+// table; the tables are sized by the first call's first window, which is
+// the largest of the delta, so the result does not depend on which worker
+// encodes which window.
+func (e *encoder) encodeWindows(target []byte, offset int) ([]Window, error) {
+	windows := make([]Window, (len(target)+e.windowSize-1)/e.windowSize)
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	if e.targetTables == nil {
+		e.targetTables = make([]*hashTable, max(1, e.concurrency))
+		e.targetTableSize = min(len(target), e.windowSize)
+	}
+	errs := make([]error, len(windows))
+	encode := func(worker, i int) {
+		start := i * e.windowSize
+		windows[i], errs[i] = e.encodeWindow(target[start:min(len(target), start+e.windowSize)], worker, offset+start)
+	}
+
+	if workers := min(len(e.targetTables), len(windows)); workers == 1 {
+		for i := range windows {
+			if encode(0, i); errs[i] != nil {
+				break
+			}
+		}
+	} else {
+		next := make(chan int, len(windows))
+		for i := range windows {
+			next <- i
+		}
+		close(next)
+		var wg sync.WaitGroup
+		for worker := 0; worker < workers; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := range next {
+					encode(worker, i)
+				}
+			}(worker)
+		}
+		wg.Wait()
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", offset/e.windowSize+i, err)
+		}
+	}
+	return windows, nil
+}
+
+// encodeWindow matches and serializes one target window, interleaving and
+// compressing its sections as the options ask
+func (e *encoder) encodeWindow(target []byte, worker, offset int) (Window, error) {
+	window := e.window(target, worker, offset)
+	if e.interleaved {
+		if err := interleave(&window); err != nil {
+			return Window{}, err
+		}
+	}
+	if e.compressor != nil {
+		if err := compressWindow(&window, e.compressor); err != nil {
+			return Window{}, err
+		}
+	}
+	return window, nil
+}
+
+// encoder holds the source index shared by all windows
+type encoder struct {
+	source       []byte
+	sourceIndex  sync.Once // Builds sourceTable
+	sourceTable  *hashTable
+	sourceStride int
+	suffixStart  int                 // Where the target's common suffix with the source starts, or math.MaxInt
+	suffixShift  int                 // Source offset minus target offset within the common suffix
+	appHeader    []byte              // Set by WithAppHeader
+	interleaved  bool                // Set by WithInterleaved
+	compressor   SecondaryCompressor // Set by WithSecondaryCompressor
+	level        Level               // Set by WithLevel
+	minMatch     int                 // Set by WithMinMatchLength
+	concurrency  int                 // Set by WithConcurrency
+	levelParams
+
+	targetTables    []*hashTable // One per worker, created when first needed
+	targetTableSize int          // Positions each target table is sized for
+}
+
+// window matches one target window, which starts offset bytes into the
+// whole target, using the worker's target table to find repeats within it,
+// and serializes it
+func (e *encoder) window(target []byte, worker, offset int) Window {
+	var ops []encodeOp
+	addStart, p := 0, 0
+	flushAdd := func(end int) {
+		if end > addStart {
+			ops = append(ops, encodeOp{typ: Add, size: uint32(end - addStart), data: target[addStart:end]})
+		}
+	}
+
+	// Positions before indexed are in the target table, or were skipped as
+	// part of a RUN or the common prefix
+	var table *hashTable
+	indexed := 0
+	index := func(end int) {
+		for ; indexed < end && indexed+minMatchLength <= len(target); indexed++ {
+			table.insert(target, indexed)
+		}
+	}
+
+	// Bytes equal to the source at the same offset, as when little or nothing
+	// changed, are copied without hashing. So is the common suffix of source
+	// and target, when the whole target is known.
+	if offset < len(e.source) {
+		if n := commonPrefix(target, e.source[offset:]); n >= e.minMatch {
+			ops = append(ops, encodeOp{typ: Copy, size: uint32(n), fromSource: true, addr: uint32(offset)})
+			p, addStart, indexed = n, n, n
+		}
+	}
+	end := len(target)
+	if suffix := max(e.suffixStart-offset, p); end-suffix >= e.minMatch {
+		end = suffix
+	}
+	// The matcher only sees the bytes before the suffix
+	target, whole := target[:end], target
+
+	if p+minMatchLength <= len(target) {
+		e.sourceIndex.Do(e.indexSource)
+		if table = e.targetTables[worker]; table == nil {
+			table = newHashTable(e.targetTableSize, 1, minMatchLength, e.levelParams)
+			e.targetTables[worker] = table
+		}
+		// Chains are only followed from the slots, so they need no clearing
+		clear(table.slots)
+	}
+	for p+minMatchLength <= len(target) {
+		if run := runLength(target[p:]); run >= minRunLength {
+			flushAdd(p)
+			ops = append(ops, encodeOp{typ: Run, size: uint32(run), data: target[p : p+1]})
+			p += run
+			addStart, indexed = p, p
+			continue
+		}
+
+		back, length, fromSource, addr := e.match(target, table, p, addStart)
+		if back+length < e.minMatch {
+			index(p + 1)
+			p++
+			continue
+		}
+		if e.lazy && p+1+minMatchLength <= len(target) {
+			index(p + 1)
+			if b, n, _, _ := e.match(target, table, p+1, addStart); b+n > back+length {
+				p++
+				continue
+			}
+		}
+		flushAdd(p - back)
+		ops = append(ops, encodeOp{typ: Copy, size: uint32(back + length), fromSource: fromSource, addr: uint32(addr)})
+		p += length
+		index(p)
+		addStart = p
+	}
+	flushAdd(len(target))
+	if end < len(whole) {
+		addr := offset + end + e.suffixShift
+		ops = append(ops, encodeOp{typ: Copy, size: uint32(len(whole) - end), fromSource: true, addr: uint32(addr)})
+	}
+
+	return serializeWindow(ops, uint32(len(whole)))
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b
+func commonPrefix(a, b []byte) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i+8 <= n && binary.LittleEndian.Uint64(a[i:]) == binary.LittleEndian.Uint64(b[i:]) {
+		i += 8
+	}
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// commonSuffix returns the length of the longest common suffix of a and b
+func commonSuffix(a, b []byte) int {
+	n := min(len(a), len(b))
+	a, b = a[len(a)-n:], b[len(b)-n:]
+	i := 0
+	for i+8 <= n && binary.LittleEndian.Uint64(a[n-i-8:]) == binary.LittleEndian.Uint64(b[n-i-8:]) {
+		i += 8
+	}
+	for i < n && a[n-i-1] == b[n-i-1] {
+		i++
+	}
+	return i
+}
+
+// serializeWindow writes ops into a window whose source segment spans exactly
+// the source data they copy, choosing the cheapest address mode for each
+// COPY and combining adjacent instructions into one code where the default
+// code table allows
+func serializeWindow(ops []encodeOp, targetLength uint32) Window {
+	window := Window{TargetWindowLength: targetLength}
+	low, high := uint32(math.MaxUint32), uint32(0)
+	for _, op := range ops {
+		if op.typ == Copy && op.fromSource {
+			low, high = min(low, op.addr), max(high, op.addr+op.size)
+		}
+	}
+	if high > 0 {
+		window.WinIndicator = VCDSource
+		window.SourceSegmentPosition, window.SourceSegmentSize = low, high-low
+	}
+
+	// Resolve every COPY's mode in order, as the decoder will
+	cache := NewAddressCache(NearCacheSize, SameCacheModes)
+	keys := make([]codeKey, len(ops))
+	here := window.SourceSegmentSize
+	for i, op := range ops {
+		keys[i] = codeKey{typ: op.typ}
+		switch op.typ {
+		case Add, Run:
+			window.DataSection = append(window.DataSection, op.data...)
+		case Copy:
+			addr := window.SourceSegmentSize + op.addr
+			if op.fromSource {
+				addr = op.addr - low
+			}
+			keys[i].mode, window.AddressSection = cache.EncodeAddress(addr, here, window.AddressSection)
+		}
+		if op.size <= math.MaxUint8 {
+			keys[i].size = byte(op.size)
+		}
+		here += op.size
+	}
+
+	for i := 0; i < len(ops); i++ {
+		if i+1 < len(ops) {
+			if code, ok := pairCodes[[2]codeKey{keys[i], keys[i+1]}]; ok {
+				window.InstructionSection = append(window.InstructionSection, code)
+				i++
+				continue
+			}
+		}
+		if code, ok := singleCodes[keys[i]]; ok && keys[i].size != 0 {
+			window.InstructionSection = append(window.InstructionSection, code)
+			continue
+		}
+		sized := keys[i]
+		sized.size = 0
+		window.InstructionSection = append(window.InstructionSection, singleCodes[sized])
+		window.InstructionSection = AppendVarint(window.InstructionSection, ops[i].size)
+	}
+	return window
+}
+
+// match returns the longest match for target[p:] among the candidates of the
+// source table and the window's target table, extended backwards over bytes not yet emitted. addr is
+// where the match starts in the source or the target.
+func (e *encoder) match(target []byte, table *hashTable, p, addStart int) (back, length int, fromSource bool, addr int) {
+	for q, i := e.sourceTable.lookup(target, p), 0; q >= 0 && i < e.depth; q, i = e.sourceTable.next(q), i+1 {
+		if b, n := matchAt(e.source, q, target, p, addStart); b+n > back+length {
+			back, length, fromSource, addr = b, n, true, q-b
+		}
+	}
+	for q, i := table.lookup(target, p), 0; q >= 0 && i < e.depth; q, i = table.next(q), i+1 {
+		if q >= p {
+			continue
+		}
+		if b, n := matchAt(target, q, target, p, addStart); b+n > back+length {
+			back, length, fromSource, addr = b, n, false, q-b
+		}
+	}
+	return back, length, fromSource, addr
+}
+
+// runLength returns how many times data's first byte repeats at its start
+func runLength(data []byte) int {
+	n := 1
+	for n < len(data) && data[n] == data[0] {
+		n++
+	}
+	return n
+}
+
+// matchAt compares a from q with b from p. It returns how far the match
+// extends backwards, without going before b's floor or a's start, and
+// forwards from the starting points.
+func matchAt(a []byte, q int, b []byte, p, floor int) (back, length int) {
+	for q+length < len(a) && p+length < len(b) && a[q+length] == b[p+length] {
+		length++
+	}
+	if length < minMatchLength {
+		return 0, 0
+	}
+	for p-back > floor && q-back > 0 && a[q-back-1] == b[p-back-1] {
+		back++
+	}
+	return back, length
+}
+
+// hashTable maps the hash of the width bytes at a position to the latest
+// position inserted with that hash
+type hashTable struct {
+	slots  []uint32 // Position plus one, zero when empty
+	chain  []uint32 // Previous position with the same hash plus one, by position over stride; nil at depth 1
+	bits   int
+	stride int // Distance between inserted positions
+	width  int // Bytes hashed: minMatchLength, or sourceFingerprintLength
+}
+
+// newHashTable sizes a table for indexing n positions spaced stride apart,
+// chaining positions that share a hash when the level tries more than one
+func newHashTable(n, stride, width int, params levelParams) *hashTable {
+	bits := minHashBits
+	for bits < params.maxHashBits && 1<<bits < n {
+		bits++
+	}
+	t := &hashTable{slots: make([]uint32, 1<<bits), bits: bits, stride: stride, width: width}
+	if params.depth > 1 {
+		t.chain = make([]uint32, n+1)
+	}
+	return t
+}
+
+func (t *hashTable) slot(data []byte, p int) uint32 {
+	if t.width == minMatchLength {
+		return binary.LittleEndian.Uint32(data[p:]) * hashMultiplier >> (32 - t.bits)
+	}
+	var h uint64
+	for i := 0; i < t.width; i += 8 {
+		h = (h ^ binary.LittleEndian.Uint64(data[p+i:])) * fingerprintMultiplier
+	}
+	return uint32(h >> (64 - t.bits))
+}
+
+func (t *hashTable) insert(data []byte, p int) {
+	slot := t.slot(data, p)
+	if t.chain != nil {
+		t.chain[p/t.stride] = t.slots[slot]
+	}
+	t.slots[slot] = uint32(p + 1)
+}
+
+// lookup returns the position stored for data[p:]'s hash, or -1
+func (t *hashTable) lookup(data []byte, p int) int {
+	if p+t.width > len(data) {
+		return -1
+	}
+	return int(t.slots[t.slot(data, p)]) - 1
+}
+
+// next returns the position inserted before q with the same hash, or -1
+func (t *hashTable) next(q int) int {
+	if t.chain == nil {
+		return -1
+	}
+	return int(t.chain[q/t.stride]) - 1
+}
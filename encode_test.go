@@ -0,0 +1,436 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// roundTrip encodes target against source and checks the delta decodes back
+func roundTrip(t *testing.T, source, target []byte) []byte {
+	t.Helper()
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	got, err := Decode(source, delta)
+	if err != nil {
+		t.Fatalf("encoded delta does not decode: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatal("encoded delta decodes to a different target")
+	}
+	return delta
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileAddHeavy, ProfileNoSource, ProfileManyWindows} {
+		for seed := int64(0); seed < 20; seed++ {
+			g := GenerateDelta(seed, profile)
+			roundTrip(t, g.Source, g.Target)
+		}
+	}
+
+	for name, pair := range map[string][2]string{
+		"empty target":   {"base", ""},
+		"empty source":   {"", "brand new content"},
+		"identical":      {"same same same", "same same same"},
+		"short":          {"ab", "abc"},
+		"run":            {"x", "y" + string(bytes.Repeat([]byte{'z'}, 1000))},
+		"self reference": {"", "abcdefgh-abcdefgh-abcdefgh-abcdefgh"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTrip(t, []byte(pair[0]), []byte(pair[1]))
+		})
+	}
+}
+
+func TestEncodeIsCompact(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	source := make([]byte, 1<<16)
+	rng.Read(source)
+
+	// A few scattered edits to a large random source
+	target := append([]byte{}, source...)
+	for i := 0; i < 10; i++ {
+		target[rng.Intn(len(target))] ^= 0xff
+	}
+	target = append(target[:1000], append([]byte("inserted text"), target[1000:]...)...)
+
+	delta := roundTrip(t, source, target)
+	if len(delta) > 200 {
+		t.Errorf("delta for a lightly edited 64 KiB file is %d bytes", len(delta))
+	}
+
+	// Repetition within the target is found without any source
+	repeated := bytes.Repeat(source[:4096], 8)
+	if delta := roundTrip(t, nil, repeated); len(delta) > 4096+100 {
+		t.Errorf("delta for 8 copies of 4 KiB is %d bytes", len(delta))
+	}
+}
+
+func TestEncodeRuns(t *testing.T) {
+	// Random records separated by zero padding, as in sparse binaries
+	rng := rand.New(rand.NewSource(4))
+	var target []byte
+	for i := 0; i < 16; i++ {
+		record := make([]byte, 32)
+		rng.Read(record)
+		target = append(append(target, record...), make([]byte, 1000+i)...)
+	}
+
+	delta := roundTrip(t, nil, target)
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	for _, inst := range parsed.Instructions {
+		if inst.Type == Run {
+			runs++
+		} else if inst.Size > 100 {
+			t.Fatalf("padding emitted as %s of %d bytes", inst.Type, inst.Size)
+		}
+	}
+	if runs != 16 {
+		t.Errorf("got %d RUNs, expected one per stretch of padding", runs)
+	}
+}
+
+func TestEncodeMultipleWindows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("encodes a target larger than one window")
+	}
+	source := make([]byte, levels[LevelDefault].windowSize/2)
+	rand.New(rand.NewSource(9)).Read(source)
+	target := append(append(append([]byte{}, source...), source...), source[:100]...)
+
+	delta := roundTrip(t, source, target)
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Windows) != 2 {
+		t.Fatalf("got %d windows, expected 2", len(parsed.Windows))
+	}
+}
+
+func TestEncodeAddressMatchesDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	encoder := NewAddressCache(NearCacheSize, SameCacheModes)
+	var stream []byte
+	var modes []byte
+	var addrs, heres []uint32
+	here := uint32(1000)
+	for i := 0; i < 500; i++ {
+		// Mix fresh, nearby and repeated addresses to exercise every mode
+		addr := uint32(rng.Intn(int(here)))
+		if i > 0 && rng.Intn(3) == 0 {
+			addr = addrs[rng.Intn(len(addrs))]
+		}
+		var mode byte
+		mode, stream = encoder.EncodeAddress(addr, here, stream)
+		modes, addrs, heres = append(modes, mode), append(addrs, addr), append(heres, here)
+		here += uint32(rng.Intn(50) + 1)
+	}
+
+	decoder := NewAddressCache(NearCacheSize, SameCacheModes)
+	decoder.Reset(stream)
+	used := map[byte]bool{}
+	for i := range addrs {
+		got, err := decoder.DecodeAddress(heres[i], modes[i])
+		if err != nil || got != addrs[i] {
+			t.Fatalf("address %d: mode %d decoded to %d (%v), expected %d", i, modes[i], got, err, addrs[i])
+		}
+		used[modes[i]] = true
+	}
+	if len(used) < fixedAddressModes+2 {
+		t.Errorf("only modes %v were used", used)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	rng := rand.New(rand.NewSource(11))
+	source := make([]byte, 1<<20)
+	rng.Read(source)
+	target := append([]byte{}, source...)
+	for i := 0; i < 100; i++ {
+		target[rng.Intn(len(target))] ^= 0xff
+	}
+	b.SetBytes(int64(len(target)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(source, target); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeAppHeader(t *testing.T) {
+	source, target := []byte("the base document"), []byte("the target document")
+
+	delta, err := Encode(source, target, WithAppHeader([]byte("v1..v2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Indicator&VCDAppHeader == 0 || string(parsed.Header.AppHeader) != "v1..v2" {
+		t.Fatalf("got indicator 0x%02x and app header %q", parsed.Header.Indicator, parsed.Header.AppHeader)
+	}
+	if got, err := Decode(source, delta); err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("delta with app header decoded to %q, %v", got, err)
+	}
+
+	// A source fingerprint in the header is checked against the base
+	delta, err = Encode(source, target, WithAppHeader(NewSourceFingerprint(source).AppHeader()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode([]byte("another base"), delta); !errors.Is(err, ErrSourceMismatch) {
+		t.Fatalf("got %v with the wrong base, expected ErrSourceMismatch", err)
+	}
+
+	plain := roundTrip(t, source, target)
+	if parsed, _ := ParseDelta(plain); parsed.Header.Indicator != 0 {
+		t.Fatalf("delta without the option has indicator 0x%02x", parsed.Header.Indicator)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	// Compressing with no source gives windows that copy from their own
+	// target only
+	target := bytes.Repeat([]byte("standalone compression, "), 200)
+	delta, err := Encode(nil, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, window := range parsed.Windows {
+		if window.WinIndicator&(VCDSource|VCDTarget) != 0 {
+			t.Fatalf("window indicator 0x%02x, expected no source or target segment", window.WinIndicator)
+		}
+	}
+	copies := 0
+	for _, inst := range parsed.Instructions {
+		if inst.Type == Copy {
+			copies++
+		}
+	}
+	if copies == 0 {
+		t.Fatal("expected the repeated target to be compressed with COPYs")
+	}
+	got, err := Decompress(delta)
+	if err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("Decompress: %v", err)
+	}
+
+	// A COPY overlapping the bytes it produces repeats them
+	overlapping := marshalWindow(t, Window{}, []RuntimeInstruction{
+		{Type: Add, Size: 2, Data: []byte("ab")},
+		{Type: Copy, Size: 6, Mode: SelfMode, Addr: 0},
+	}, nil)
+	if got, err := Decompress(overlapping); err != nil || string(got) != "abababab" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	// Deltas that read a source cannot be decompressed
+	withSource := roundTrip(t, []byte("0123456789"), []byte("0123456789!"))
+	if _, err := Decompress(withSource); !errors.Is(err, ErrSourceTooShort) || !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got %v, expected ErrSourceTooShort", err)
+	}
+}
+
+func TestEncodeMinMatchLength(t *testing.T) {
+	// Short source fragments between random bytes
+	rng := rand.New(rand.NewSource(7))
+	source := make([]byte, 4096)
+	rng.Read(source)
+	var target []byte
+	for i := 0; i < 200; i++ {
+		noise := make([]byte, 3)
+		rng.Read(noise)
+		offset := rng.Intn(len(source) - 16)
+		target = append(append(target, noise...), source[offset:offset+5+i%12]...)
+	}
+
+	for _, n := range []int{0, 8, 16} {
+		delta, err := Encode(source, target, WithMinMatchLength(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Decode(source, delta); err != nil || !bytes.Equal(got, target) {
+			t.Fatalf("minimum %d: delta does not decode to the target: %v", n, err)
+		}
+		parsed, err := ParseDelta(delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shortest := uint32(math.MaxUint32)
+		for _, inst := range parsed.Instructions {
+			if inst.Type == Copy {
+				shortest = min(shortest, inst.Size)
+			}
+		}
+		if want := uint32(max(n, minMatchLength)); shortest < want || shortest > want+1 {
+			t.Errorf("minimum %d: shortest COPY is %d bytes", n, shortest)
+		}
+	}
+
+	if _, err := Encode(source, target, WithMinMatchLength(3)); err == nil {
+		t.Error("expected an error for a minimum below 4")
+	}
+}
+
+func TestEncodeSparseSource(t *testing.T) {
+	// At LevelFast a 4 MiB source has more positions than hash slots and is
+	// sampled. Text from a small vocabulary repeats every 4-byte sequence
+	// throughout, so only longer fingerprints tell the samples apart.
+	rng := rand.New(rand.NewSource(6))
+	words := []string{"alpha ", "beta ", "gamma ", "delta ", "epsilon ", "zeta ", "eta ", "theta ", "iota ", "kappa "}
+	var source []byte
+	for len(source) < 4<<20 {
+		source = append(source, words[rng.Intn(len(words))]...)
+	}
+	target := append([]byte{}, source[3<<20:]...)
+	for i := 0; i < 20; i++ {
+		target[rng.Intn(len(target))] = '#'
+	}
+
+	delta, err := Encode(source, target, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decode(source, delta); err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("delta does not decode to the target: %v", err)
+	}
+	if len(delta) > 2000 {
+		t.Errorf("delta for 20 edits to 1 MiB of a sampled source is %d bytes", len(delta))
+	}
+}
+
+func TestEncodeConcurrency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("encodes a target of several windows")
+	}
+	rng := rand.New(rand.NewSource(10))
+	windowSize := levels[LevelFast].windowSize
+	source := make([]byte, windowSize)
+	rng.Read(source)
+	target := append(bytes.Repeat(source[:windowSize/2], 5), "tail"...)
+	for i := 0; i < 100; i++ {
+		target[rng.Intn(len(target))] ^= 0xff
+	}
+
+	want, err := Encode(source, target, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{2, 3, 8} {
+		got, err := Encode(source, target, WithLevel(LevelFast), WithConcurrency(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d workers: delta differs from encoding one window at a time", n)
+		}
+		if got := encodeStream(t, source, target, 1<<20, WithLevel(LevelFast), WithConcurrency(n)); !bytes.Equal(got, want) {
+			t.Fatalf("%d workers: streamed delta differs from encoding one window at a time", n)
+		}
+	}
+
+	failure := errors.New("compressor failed")
+	_, err = Encode(source, target, WithLevel(LevelFast), WithConcurrency(4), WithSecondaryCompressor(flateCompressor{err: failure}))
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, expected the compressor's error", err)
+	}
+}
+
+func TestEncodeIdentical(t *testing.T) {
+	rng := rand.New(rand.NewSource(12))
+	source := make([]byte, 1<<20)
+	rng.Read(source)
+
+	for _, tc := range []struct {
+		name    string
+		target  []byte
+		copies  int
+		matched bool // Whether any bytes are left for the matcher
+	}{
+		{"identical", source, 1, false},
+		{"appended", append(append([]byte{}, source...), "appended"...), 1, true},
+		{"prepended", append([]byte("prepended"), source...), 1, true},
+		{"edited middle", append(append(append([]byte{}, source[:1000]...), "edit"...), source[1004:]...), 2, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := newEncoder(source, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := commonSuffix(source, tc.target); n >= e.minMatch {
+				e.suffixStart, e.suffixShift = len(tc.target)-n, len(source)-len(tc.target)
+			}
+			windows, err := e.encodeWindows(tc.target, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if indexed := e.sourceTable != nil; indexed != tc.matched {
+				t.Errorf("source indexed: %v, expected %v", indexed, tc.matched)
+			}
+			copies, err := ParseDelta(roundTrip(t, source, tc.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for _, inst := range copies.Instructions {
+				if inst.Type == Copy {
+					n++
+				}
+			}
+			if len(windows) != 1 || n != tc.copies {
+				t.Errorf("got %d windows with %d COPYs, expected %d", len(windows), n, tc.copies)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeIdentical(b *testing.B) {
+	source := make([]byte, 1<<24)
+	rand.New(rand.NewSource(13)).Read(source)
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(source, source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeIdentical(b *testing.B) {
+	source := make([]byte, 1<<24)
+	rand.New(rand.NewSource(13)).Read(source)
+	delta, err := Encode(source, source)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(source, delta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package vcdiff
+
+import (
+	"errors"
+	"io"
+)
+
+// streamEncoder encodes target bytes written to it, one window at a time
+type streamEncoder struct {
+	e       *encoder
+	dst     io.Writer
+	pending []byte // Target bytes of the windows being filled
+	batch   int    // Target bytes encoded at once, a window per worker
+	windows int    // Windows written so far
+	started bool   // Header written
+	closed  bool
+	err     error // First error, returned from every later call
+}
+
+var errEncoderClosed = errors.New("vcdiff: write to closed encoder")
+
+// NewEncoder returns a writer that encodes the target written to it against
+// source, writing the delta to dst. Target bytes are buffered until a window
+// is full, and each window is written out as soon as it is encoded, so only
+// one window of the target is held in memory, or one per worker with
+// WithConcurrency. Close encodes the last window
+// and must be called to complete the delta.
+//
+// The delta decodes the same as Encode's from the whole target, and is
+// byte for byte the same unless the target ends with data from the end of
+// the source, which Encode copies without matching but the stream cannot
+// know until Close. With WithSecondaryCompressor the header is written
+// before any section is compressed, so it always sets VCD_DECOMPRESS and the
+// compressor ID. Errors from the options are returned here; errors encoding or writing
+// are returned from Write or Close, and from every call after them.
+func NewEncoder(dst io.Writer, source []byte, opts ...EncoderOption) (io.WriteCloser, error) {
+	e, err := newEncoder(source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &streamEncoder{e: e, dst: dst, batch: e.windowSize * max(1, e.concurrency)}, nil
+}
+
+func (s *streamEncoder) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.closed {
+		return 0, errEncoderClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), s.batch-len(s.pending))
+		s.pending = append(s.pending, p[:n]...)
+		p, written = p[n:], written+n
+		if len(s.pending) == s.batch {
+			if s.err = s.flush(); s.err != nil {
+				return written, s.err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close encodes any buffered target bytes as the last window. A delta with
+// no target is a header alone.
+func (s *streamEncoder) Close() error {
+	if s.closed || s.err != nil {
+		return s.err
+	}
+	s.closed = true
+	if len(s.pending) > 0 || !s.started {
+		s.err = s.flush()
+	}
+	return s.err
+}
+
+// flush writes the header if it has not been, then encodes the pending
+// target bytes and writes each window
+func (s *streamEncoder) flush() error {
+	var out []byte
+	if !s.started {
+		header := s.e.header()
+		if s.e.compressor != nil {
+			header.Indicator |= VCDDecompress
+			header.SecondaryCompressorID = s.e.compressor.ID()
+		}
+		out = appendHeader(out, &header)
+		if s.e.interleaved {
+			out[len(VCDIFFMagic)] = SDCHVersion
+		}
+		s.started = true
+	}
+
+	windows, err := s.e.encodeWindows(s.pending, s.windows*s.e.windowSize)
+	if err != nil {
+		return err
+	}
+	s.pending = s.pending[:0]
+	s.windows += len(windows)
+	if len(windows) == 0 {
+		_, err := s.dst.Write(out)
+		return err
+	}
+	for i := range windows {
+		out = appendWindow(out, &windows[i])
+		if _, err := s.dst.Write(out); err != nil {
+			return err
+		}
+		out = out[:0]
+	}
+	return nil
+}
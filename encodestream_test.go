@@ -0,0 +1,175 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// encodeStream encodes target through NewEncoder in writes of chunk bytes
+func encodeStream(t *testing.T, source, target []byte, chunk int, opts ...EncoderOption) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncoder(&out, source, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(target) > 0 {
+		n := min(chunk, len(target))
+		if _, err := w.Write(target[:n]); err != nil {
+			t.Fatal(err)
+		}
+		target = target[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestNewEncoder(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileNoSource} {
+		for seed := int64(0); seed < 5; seed++ {
+			g := GenerateDelta(seed, profile)
+			want, err := Encode(g.Source, g.Target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range []int{1, 7, 4096} {
+				if got := encodeStream(t, g.Source, g.Target, chunk); !bytes.Equal(got, want) {
+					t.Fatalf("seed %d, writes of %d: streamed delta differs from Encode", seed, chunk)
+				}
+			}
+		}
+	}
+
+	// No target gives a header alone
+	want, _ := Encode(nil, nil, WithInterleaved())
+	if got := encodeStream(t, nil, nil, 1, WithInterleaved()); !bytes.Equal(got, want) {
+		t.Fatalf("got %x, expected %x", got, want)
+	}
+
+	if _, err := NewEncoder(&bytes.Buffer{}, nil, WithLevel(Level(9))); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+}
+
+func TestNewEncoderWindows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("encodes a target larger than one window")
+	}
+	windowSize := levels[LevelFast].windowSize
+	source := make([]byte, windowSize/2)
+	rand.New(rand.NewSource(9)).Read(source)
+	target := bytes.Repeat(source, 5)
+
+	// Each full window is written before the next write returns
+	w := &windowWriter{}
+	enc, err := NewEncoder(w, source, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Write(target[:windowSize+1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("got %d writes after the first window filled, expected 1", len(w.writes))
+	}
+	if _, err := enc.Write(target[windowSize+1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 3 {
+		t.Fatalf("got %d writes, expected one per window", len(w.writes))
+	}
+
+	want, err := Encode(source, target, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Fatal("streamed delta differs from Encode")
+	}
+}
+
+func TestNewEncoderBatchOffsets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("encodes a target of several windows")
+	}
+	// A target equal to the source but for its last byte is copied at the
+	// same offsets in every window, without indexing the source, only if
+	// each batch knows where it starts in the target
+	windowSize := levels[LevelFast].windowSize
+	source := make([]byte, 3*windowSize)
+	rand.New(rand.NewSource(5)).Read(source)
+	target := bytes.Clone(source)
+	target[len(target)-1]++
+
+	var out bytes.Buffer
+	enc, err := NewEncoder(&out, source, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := 0; start < len(target); start += windowSize {
+		if _, err := enc.Write(target[start : start+windowSize]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if enc.(*streamEncoder).e.sourceTable != nil {
+		t.Error("the source was indexed for a target matching it at the same offsets")
+	}
+
+	want, err := Encode(source, target, WithLevel(LevelFast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatal("streamed delta differs from Encode")
+	}
+}
+
+func TestNewEncoderErrors(t *testing.T) {
+	g := GenerateDelta(1, ProfileSmall)
+	w := &windowWriter{failAfter: 2}
+	enc, err := NewEncoder(w, g.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Write(g.Target); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != errWriteFailed {
+		t.Fatalf("got %v, expected the writer's error", err)
+	}
+	if _, err := enc.Write([]byte("more")); err != errWriteFailed {
+		t.Fatalf("got %v from a write after the failure", err)
+	}
+
+	enc, _ = NewEncoder(&bytes.Buffer{}, nil)
+	enc.Close()
+	if _, err := enc.Write([]byte("late")); !errors.Is(err, errEncoderClosed) {
+		t.Fatalf("got %v from a write after Close", err)
+	}
+}
+
+func TestNewEncoderSecondaryCompression(t *testing.T) {
+	source, target := []byte("abcdefgh"), []byte("abcdefgh!")
+	delta := encodeStream(t, source, target, 4, WithSecondaryCompressor(flateCompressor{}))
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The header is written before it is known that nothing shrinks
+	if parsed.Header.Indicator&VCDDecompress == 0 || parsed.Header.SecondaryCompressorID != 0x7f {
+		t.Fatalf("got header indicator 0x%02x, compressor %d", parsed.Header.Indicator, parsed.Header.SecondaryCompressorID)
+	}
+	if parsed.Windows[0].DeltaIndicator != 0 {
+		t.Fatalf("got delta indicator 0x%02x for sections too short to shrink", parsed.Windows[0].DeltaIndicator)
+	}
+}
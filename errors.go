@@ -0,0 +1,245 @@
+package vcdiff
+
+import (
+	"fmt"
+	"io"
+)
+
+var (
+	ErrInvalidMagic    = codedSentinel("invalid VCDIFF magic bytes", ErrCodeBadMagic)
+	ErrInvalidVersion  = codedSentinel("unsupported VCDIFF version", ErrCodeBadVersion)
+	ErrInvalidFormat   = codedSentinel("invalid VCDIFF format", ErrCodeInvalidFormat)
+	ErrCorruptedData   = codedSentinel("corrupted VCDIFF data", ErrCodeCorruptedData)
+	ErrInvalidChecksum = codedSentinel("invalid checksum", ErrCodeChecksum)
+	ErrUnsupported     = codedSentinel("unsupported VCDIFF feature", ErrCodeUnsupported)
+	ErrSourceTooShort  = codedSentinel("source too short for delta", ErrCodeSourceTooShort)
+	ErrSourceMismatch  = codedSentinel("source does not match delta fingerprint", ErrCodeSourceMismatch)
+	ErrInvalidText     = codedSentinel("decoded target is not valid text", ErrCodeInvalidText)
+	ErrLimitExceeded   = codedSentinel("decode limit exceeded", ErrCodeLimitExceeded)
+)
+
+// Refinements of ErrInvalidFormat, matching it with a more specific code
+var (
+	errTruncated      = codedSentinel(ErrInvalidFormat.Error(), ErrCodeTruncated, ErrInvalidFormat)
+	errOverrun        = codedSentinel(ErrInvalidFormat.Error(), ErrCodeSectionOverrun, ErrInvalidFormat)
+	errBounds         = codedSentinel(ErrInvalidFormat.Error(), ErrCodeOutOfBounds, ErrInvalidFormat)
+	errReservedBits   = codedSentinel(ErrInvalidFormat.Error(), ErrCodeReservedBits, ErrInvalidFormat)
+	errBadAddress     = codedSentinel(ErrInvalidFormat.Error(), ErrCodeBadAddress, ErrInvalidFormat)
+	errTargetLength   = codedSentinel(ErrInvalidFormat.Error(), ErrCodeTargetLength, ErrInvalidFormat)
+	errBadVarint      = codedSentinel("invalid varint", ErrCodeBadVarint, ErrInvalidFormat)
+	errShortForSource = codedSentinel(ErrInvalidFormat.Error()+": "+ErrSourceTooShort.Error(), ErrCodeSourceTooShort, ErrInvalidFormat, ErrSourceTooShort)
+)
+
+// ErrorCode is a stable, machine-readable name for the kind of failure an
+// error reports. Every error matching one of the sentinels above carries
+// one, found with errors.As:
+//
+//	var code vcdiff.ErrorCode
+//	if errors.As(err, &code) && code == vcdiff.ErrCodeChecksum {
+//
+// Codes can be more specific than sentinels: a COPY outside its source
+// segment matches ErrInvalidFormat and has the code ErrCodeOutOfBounds.
+// They are the values of expected_error_type in test suite metadata.
+type ErrorCode string
+
+const (
+	ErrCodeBadMagic       ErrorCode = "bad_magic"        // ErrInvalidMagic
+	ErrCodeBadVersion     ErrorCode = "bad_version"      // ErrInvalidVersion
+	ErrCodeInvalidFormat  ErrorCode = "invalid_format"   // ErrInvalidFormat with no more specific code
+	ErrCodeReservedBits   ErrorCode = "reserved_bits"    // Reserved bits set in an indicator
+	ErrCodeBadVarint      ErrorCode = "bad_varint"       // Varint longer than 32 bits allow
+	ErrCodeTruncated      ErrorCode = "truncated"        // TruncatedError
+	ErrCodeSectionOverrun ErrorCode = "section_overrun"  // OverrunError
+	ErrCodeOutOfBounds    ErrorCode = "out_of_bounds"    // BoundsError
+	ErrCodeBadAddress     ErrorCode = "bad_address"      // COPY address mode or offset that cannot be decoded
+	ErrCodeTargetLength   ErrorCode = "target_length"    // Instructions producing more or fewer bytes than the target window
+	ErrCodeChecksum       ErrorCode = "checksum"         // ErrInvalidChecksum
+	ErrCodeCorruptedData  ErrorCode = "corrupted_data"   // ErrCorruptedData
+	ErrCodeUnsupported    ErrorCode = "unsupported"      // ErrUnsupported
+	ErrCodeSourceTooShort ErrorCode = "source_too_short" // ErrSourceTooShort
+	ErrCodeSourceMismatch ErrorCode = "source_mismatch"  // ErrSourceMismatch
+	ErrCodeInvalidText    ErrorCode = "invalid_text"     // ErrInvalidText
+	ErrCodeLimitExceeded  ErrorCode = "limit_exceeded"   // ErrLimitExceeded
+)
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
+// codedError is a sentinel carrying an ErrorCode. It also matches the more
+// general sentinels it refines.
+type codedError struct {
+	msg     string
+	code    ErrorCode
+	general []error
+}
+
+func codedSentinel(msg string, code ErrorCode, general ...error) error {
+	return &codedError{msg: msg, code: code, general: general}
+}
+
+func (e *codedError) Error() string {
+	return e.msg
+}
+
+// Unwrap lists the code first, so errors.As finds it before the codes of
+// the general sentinels
+func (e *codedError) Unwrap() []error {
+	return append([]error{e.code}, e.general...)
+}
+
+// TruncatedError reports a delta that ends part way through a field or
+// section. It matches ErrInvalidFormat and io.ErrUnexpectedEOF.
+type TruncatedError struct {
+	Context string // What was being read
+	Needed  int    // Bytes missing, or 0 when not known
+}
+
+func (e *TruncatedError) Error() string {
+	if e.Needed == 0 {
+		return fmt.Sprintf("unexpected EOF: delta ends inside %s", e.Context)
+	}
+	return fmt.Sprintf("unexpected EOF while reading %s: need %d bytes", e.Context, e.Needed)
+}
+
+func (e *TruncatedError) Unwrap() []error {
+	return []error{errTruncated, io.ErrUnexpectedEOF}
+}
+
+// OverrunError reports an instruction needing more bytes than remain in the
+// section that supplies them. It matches ErrInvalidFormat.
+type OverrunError struct {
+	Instruction string // Instruction type, such as "ADD"
+	Offset      int    // Offset of the instruction's code in the instruction section
+	Needed      int    // Bytes the instruction requires
+	Available   int    // Bytes left in the section
+}
+
+func (e *OverrunError) Error() string {
+	return fmt.Sprintf("%s instruction at offset %d requires %d bytes but only %d available in data section",
+		e.Instruction, e.Offset, e.Needed, e.Available)
+}
+
+func (e *OverrunError) Unwrap() error {
+	return errOverrun
+}
+
+// BoundsError reports a COPY reading outside the data available to it: past
+// the end of the source segment, or target data not yet decoded. It matches
+// ErrInvalidFormat.
+type BoundsError struct {
+	Instruction string // Instruction type, such as "COPY"
+	Address     uint32 // Address the instruction reads from
+	Size        uint32 // Bytes it reads
+	Limit       uint32 // Address at which readable data ends
+}
+
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("%s instruction address %d + size %d exceeds bounds (max %d)",
+		e.Instruction, e.Address, e.Size, e.Limit)
+}
+
+func (e *BoundsError) Unwrap() error {
+	return errBounds
+}
+
+// ChecksumError reports a window whose reconstructed target does not match
+// its VCD_ADLER32 checksum. It matches ErrInvalidChecksum.
+type ChecksumError struct {
+	Window   int    // Index of the window
+	Expected uint32 // Checksum carried by the delta
+	Computed uint32 // Checksum of the reconstructed target
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%v: expected 0x%08x, got 0x%08x", ErrInvalidChecksum, e.Expected, e.Computed)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrInvalidChecksum
+}
+
+// TargetLengthError reports a window whose instructions do not produce
+// exactly its declared target window length. It matches ErrInvalidFormat.
+type TargetLengthError struct {
+	Declared    uint32 // TargetWindowLength of the window
+	Produced    uint64 // Bytes produced, up to the end of Instruction if it is set
+	Instruction string // Type of the instruction overrunning the window, if any
+}
+
+func (e *TargetLengthError) Error() string {
+	if e.Instruction != "" {
+		return fmt.Sprintf("%v: %s instruction ending at target offset %d overruns the %d byte target window",
+			ErrInvalidFormat, e.Instruction, e.Produced, e.Declared)
+	}
+	return fmt.Sprintf("%v: instructions produce %d bytes of a %d byte target window", ErrInvalidFormat, e.Produced, e.Declared)
+}
+
+func (e *TargetLengthError) Unwrap() error {
+	return errTargetLength
+}
+
+// ValueError reports a field holding a value the format does not allow. It
+// matches Err, which is ErrInvalidFormat unless a more specific sentinel
+// such as ErrInvalidVersion applies.
+type ValueError struct {
+	Field  string      // Name of the field
+	Offset int         // Offset of the field in the data being parsed
+	Value  interface{} // Value found
+	Reason string      // Why it is invalid
+	Err    error       // Sentinel the error matches
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid %s at offset %d: value %v, %s", e.Field, e.Offset, e.Value, e.Reason)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError reports where in a delta parsing failed. It wraps Cause, so it
+// matches whatever Cause matches.
+type ParseError struct {
+	Offset      int    // Offset in the delta of the field or section that could not be parsed
+	Section     string // Part of the delta being parsed, such as "header" or "delta encoding"
+	WindowIndex int    // Index of the window, or -1 in the header
+	Cause       error  // What was wrong with it
+}
+
+func (e *ParseError) Error() string {
+	if e.WindowIndex < 0 {
+		return fmt.Sprintf("%s at offset %d: %v", e.Section, e.Offset, e.Cause)
+	}
+	return fmt.Sprintf("window %d: %s at offset %d: %v", e.WindowIndex, e.Section, e.Offset, e.Cause)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+// Enhanced error functions for detailed reporting
+func errUnexpectedEOF(context string, bytesNeeded int) error {
+	return &TruncatedError{Context: context, Needed: bytesNeeded}
+}
+
+func errDataOverrun(instruction string, offset int, needed int, available int) error {
+	return &OverrunError{Instruction: instruction, Offset: offset, Needed: needed, Available: available}
+}
+
+func errInvalidValue(field string, offset int, value interface{}, reason string) error {
+	return &ValueError{Field: field, Offset: offset, Value: value, Reason: reason, Err: ErrInvalidFormat}
+}
+
+func errReservedBitsSet(field string, offset int, value byte) error {
+	return &ValueError{Field: field, Offset: offset, Value: value, Reason: "reserved bits must be zero", Err: errReservedBits}
+}
+
+// errShortDelta reports a delta of length bytes, too short to hold a header
+func errShortDelta(length int) error {
+	return &ParseError{Offset: 0, Section: "header", WindowIndex: -1, Cause: errUnexpectedEOF("header", MinimumFileSize-length)}
+}
+
+func errOutOfBounds(instruction string, address uint32, size uint32, maxBound uint32) error {
+	return &BoundsError{Instruction: instruction, Address: address, Size: size, Limit: maxBound}
+}
@@ -0,0 +1,249 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// marshalWindow marshals a delta of one window built from instructions,
+// letting edit break the window before it is written
+func marshalWindow(t *testing.T, window Window, instructions []RuntimeInstruction, edit func(*Window)) []byte {
+	t.Helper()
+	encodeInstructions(&window, instructions)
+	if edit != nil {
+		edit(&window)
+	}
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func TestTypedErrors(t *testing.T) {
+	source := []byte("0123456789")
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+
+	t.Run("checksum", func(t *testing.T) {
+		delta := marshalWindow(t, Window{}, add, func(w *Window) {
+			w.HasChecksum = true
+			w.Checksum = 1
+		})
+		_, err := Decode(nil, delta)
+		var checksumErr *ChecksumError
+		if !errors.As(err, &checksumErr) || !errors.Is(err, ErrInvalidChecksum) {
+			t.Fatalf("got %v, expected a ChecksumError", err)
+		}
+		if checksumErr.Window != 0 || checksumErr.Expected != 1 || checksumErr.Computed != ComputeChecksum(1, []byte("hello")) {
+			t.Fatalf("got %+v", checksumErr)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		delta := marshalWindow(t, Window{}, add, nil)
+		_, err := Decode(nil, delta[:len(delta)-2])
+		var truncated *TruncatedError
+		if !errors.As(err, &truncated) || !errors.Is(err, ErrInvalidFormat) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("got %v, expected a TruncatedError", err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		delta := marshalWindow(t, Window{}, add, nil)
+		delta[3] = 0x07
+		_, err := Decode(nil, delta)
+		var valueErr *ValueError
+		if !errors.As(err, &valueErr) || !errors.Is(err, ErrInvalidVersion) || valueErr.Offset != 3 {
+			t.Fatalf("got %v, expected a ValueError matching ErrInvalidVersion", err)
+		}
+	})
+
+	t.Run("magic", func(t *testing.T) {
+		delta := marshalWindow(t, Window{}, add, nil)
+		delta[0] = 'x'
+		if _, err := Decode(nil, delta); !errors.Is(err, ErrInvalidMagic) {
+			t.Fatalf("got %v, expected ErrInvalidMagic", err)
+		}
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		window := Window{WinIndicator: VCDSource, SourceSegmentSize: 4}
+		delta := marshalWindow(t, window, []RuntimeInstruction{{Type: Copy, Size: 6, Addr: 0}}, nil)
+		_, err := Decode(source, delta)
+		var bounds *BoundsError
+		if !errors.As(err, &bounds) || !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("got %v, expected a BoundsError", err)
+		}
+		if bounds.Address != 0 || bounds.Size != 6 || bounds.Limit != 4 {
+			t.Fatalf("got %+v", bounds)
+		}
+	})
+
+	t.Run("overrun", func(t *testing.T) {
+		delta := marshalWindow(t, Window{}, add, func(w *Window) {
+			w.DataSection = w.DataSection[:3]
+		})
+		_, err := Decode(nil, delta)
+		var overrun *OverrunError
+		if !errors.As(err, &overrun) || !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("got %v, expected an OverrunError", err)
+		}
+		if overrun.Instruction != "ADD" || overrun.Needed != 5 || overrun.Available != 3 {
+			t.Fatalf("got %+v", overrun)
+		}
+	})
+
+	t.Run("target length", func(t *testing.T) {
+		for _, tt := range []struct {
+			declared    uint32
+			instruction string
+		}{{4, "ADD"}, {6, ""}} {
+			delta := marshalWindow(t, Window{}, add, func(w *Window) { w.TargetWindowLength = tt.declared })
+			for name, decode := range map[string]func() error{
+				"Decode":   func() error { _, err := Decode(nil, delta); return err },
+				"Validate": func() error { return Validate(delta) },
+				"fuzzy": func() error {
+					// A fingerprint of another base makes fuzzy mode re-execute the window
+					parsed, err := ParseDelta(delta)
+					if err != nil {
+						return err
+					}
+					parsed.Header.Indicator |= VCDAppHeader
+					parsed.Header.AppHeader = NewSourceFingerprint([]byte("other")).AppHeader()
+					fingerprinted, err := MarshalDelta(parsed)
+					if err != nil {
+						return err
+					}
+					_, err = NewDecoder(source, WithFuzzy(4, &FuzzyReport{})).Decode(fingerprinted)
+					return err
+				},
+			} {
+				err := decode()
+				var lengthErr *TargetLengthError
+				if !errors.As(err, &lengthErr) || !errors.Is(err, ErrInvalidFormat) {
+					t.Fatalf("%s of %d byte window: got %v, expected a TargetLengthError", name, tt.declared, err)
+				}
+				// Fuzzy mode checks the length of the whole window up front
+				if lengthErr.Declared != tt.declared || lengthErr.Produced != 5 || name != "fuzzy" && lengthErr.Instruction != tt.instruction {
+					t.Fatalf("%s: got %+v", name, lengthErr)
+				}
+			}
+		}
+	})
+}
+
+func TestParseShortReads(t *testing.T) {
+	header := []byte{0xd6, 0xc3, 0xc4, 0x00, 0x00}
+	tests := []struct {
+		name    string
+		delta   []byte
+		context string
+	}{
+		{"delta indicator", append(header[:5:5], 0x00, 0x01, 0x00), "delta indicator"},
+		// Two of the four checksum bytes, which were once read as 0xaabb0000
+		{"checksum", append(header[:5:5], VCDAdler32, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0xaa, 0xbb), "window checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDelta(tt.delta)
+			var truncated *TruncatedError
+			if !errors.As(err, &truncated) || truncated.Context != tt.context {
+				t.Fatalf("got %v, expected truncation in %s", err, tt.context)
+			}
+		})
+	}
+
+	// An empty final window reads zero-length sections at the end of the
+	// delta, which must not be mistaken for the end of the windows
+	parsed, err := ParseDelta(append(header[:5:5], 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00))
+	if err != nil || len(parsed.Windows) != 1 {
+		t.Fatalf("got %v, expected one empty window", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	header := []byte{0xd6, 0xc3, 0xc4, 0x00, 0x00}
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+	tests := []struct {
+		name  string
+		delta []byte
+		code  ErrorCode
+	}{
+		{"magic", []byte{'x', 0xc3, 0xc4, 0x00, 0x00}, ErrCodeBadMagic},
+		{"version", []byte{0xd6, 0xc3, 0xc4, 0x07, 0x00}, ErrCodeBadVersion},
+		{"reserved bits", append(header[:5:5], 0xf0), ErrCodeReservedBits},
+		{"varint", append(header[:5:5], 0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01), ErrCodeBadVarint},
+		{"truncated", marshalWindow(t, Window{}, add, nil)[:10], ErrCodeTruncated},
+		{"overrun", marshalWindow(t, Window{}, add, func(w *Window) { w.DataSection = w.DataSection[:3] }), ErrCodeSectionOverrun},
+		{"checksum", marshalWindow(t, Window{}, add, func(w *Window) { w.HasChecksum, w.Checksum = true, 1 }), ErrCodeChecksum},
+		{"target length", marshalWindow(t, Window{}, add, func(w *Window) { w.TargetWindowLength = 6 }), ErrCodeTargetLength},
+		{"bounds", marshalWindow(t, Window{WinIndicator: VCDSource, SourceSegmentSize: 4},
+			[]RuntimeInstruction{{Type: Copy, Size: 6}}, nil), ErrCodeOutOfBounds},
+		{"short source", marshalWindow(t, Window{WinIndicator: VCDSource, SourceSegmentSize: 40},
+			[]RuntimeInstruction{{Type: Copy, Size: 6}}, nil), ErrCodeSourceTooShort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte("0123456789"), tt.delta)
+			var code ErrorCode
+			if !errors.As(err, &code) || code != tt.code {
+				t.Fatalf("got %v with code %q, expected %q", err, code, tt.code)
+			}
+		})
+	}
+
+	// Codes refine sentinels without hiding them
+	if _, err := Decode(nil, tests[4].delta); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated delta: got %v, expected ErrInvalidFormat", err)
+	}
+	if _, err := Decode(nil, tests[9].delta); !errors.Is(err, ErrSourceTooShort) || !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("short source: got %v, expected ErrSourceTooShort and ErrInvalidFormat", err)
+	}
+}
+
+func TestParseErrorOffsets(t *testing.T) {
+	header := []byte{0xd6, 0xc3, 0xc4, 0x00, 0x00}
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+	good := marshalWindow(t, Window{}, add, nil)
+	overrun := marshalWindow(t, Window{}, append(add, add...), func(w *Window) { w.DataSection = w.DataSection[:7] })
+	tests := []struct {
+		name    string
+		delta   []byte
+		offset  int
+		section string
+		window  int
+	}{
+		{"version", []byte{0xd6, 0xc3, 0xc4, 0x07, 0x00}, 3, "header", -1},
+		{"short", header[:2], 0, "header", -1},
+		{"second window indicator", append(append([]byte{}, good...), 0xf0), len(good), "window header", 1},
+		// Window indicator, delta encoding length, then target length
+		{"delta indicator", append(header[:5:5], 0x00, 0x01, 0x00), 8, "delta encoding", 0},
+		{"checksum", append(header[:5:5], VCDAdler32, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0xaa, 0xbb), 12, "delta encoding", 0},
+		// The second ADD's code, after the data and the first ADD's code and size
+		{"instruction", overrun, len(overrun) - 2, "instructions section", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDelta(tt.delta)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got %v, expected a ParseError", err)
+			}
+			if parseErr.Offset != tt.offset || parseErr.Section != tt.section || parseErr.WindowIndex != tt.window {
+				t.Fatalf("got %+v, expected offset %d in %s of window %d", parseErr, tt.offset, tt.section, tt.window)
+			}
+		})
+	}
+
+	// Streamed windows are parsed on their own but report offsets in the delta
+	stream := append(append([]byte{}, good...), good[len(header):]...)
+	third := len(stream)
+	stream = append(stream, 0xf0, 0x00)
+	_, err := io.ReadAll(NewReader(nil, bytes.NewReader(stream)))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Offset != third || parseErr.WindowIndex != 2 {
+		t.Fatalf("streamed: got %v, expected window 2 at offset %d", err, third)
+	}
+}
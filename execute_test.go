@@ -0,0 +1,113 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestCopyWithin(t *testing.T) {
+	for _, tt := range []struct{ from, to, size uint32 }{
+		{0, 1, 9},  // Run of one byte
+		{0, 3, 10}, // Period 3, partial last repeat
+		{1, 4, 12}, // Period 3 starting inside the data
+		{0, 5, 3},  // No overlap
+		{2, 5, 3},  // Ends exactly where it starts
+	} {
+		target := []byte("abcde" + string(make([]byte, 20)))
+		want := append([]byte{}, target...)
+		for i := uint32(0); i < tt.size; i++ {
+			want[tt.to+i] = want[tt.from+i]
+		}
+
+		copyWithin(target, tt.from, tt.to, tt.size)
+		if !bytes.Equal(target, want) {
+			t.Errorf("copyWithin(%d, %d, %d) gave %q, expected %q", tt.from, tt.to, tt.size, target, want)
+		}
+	}
+}
+
+func TestFillRun(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 64, 1000} {
+		dst := make([]byte, n)
+		fillRun(dst, 'r')
+		if !bytes.Equal(dst, bytes.Repeat([]byte{'r'}, n)) {
+			t.Errorf("fillRun of %d bytes gave %q", n, dst)
+		}
+	}
+}
+
+func TestExecuteTargetLengthMismatch(t *testing.T) {
+	instructions := []RuntimeInstruction{
+		{Type: Add, Size: 3, Data: []byte("abc")},
+		{Type: Run, Size: 4, Data: []byte("z")},
+	}
+	for _, targetLength := range []uint32{5, 8} {
+		_, err := executeInstructions(instructions, nil, NewAddressCache(NearCacheSize, SameCacheModes), targetLength, nil, nil)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("7 bytes of instructions in a %d byte window gave %v, expected ErrInvalidFormat", targetLength, err)
+		}
+	}
+
+	target, err := executeInstructions(instructions, nil, NewAddressCache(NearCacheSize, SameCacheModes), 7, nil, nil)
+	if err != nil || string(target) != "abczzzz" {
+		t.Fatalf("got %q, %v", target, err)
+	}
+}
+
+func TestCorruptLengthAllocation(t *testing.T) {
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+	header := []byte{VCDIFFMagic[0], VCDIFFMagic[1], VCDIFFMagic[2], VCDIFFVersion, 0}
+
+	encoding := AppendVarint(append(header, 0), math.MaxUint32)
+	sections := AppendVarint([]byte{5, 0}, math.MaxUint32)
+	sections = append(append(append(header, 0), byte(len(sections)+2)), append(sections, 0, 0)...)
+	tests := map[string][]byte{
+		"delta encoding length": append(encoding, 1, 2, 3),
+		"section length":        sections,
+		"target window length":  marshalWindow(t, Window{}, add, func(w *Window) { w.TargetWindowLength = math.MaxUint32 }),
+	}
+	for name, delta := range tests {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := Decode(nil, delta)
+		runtime.ReadMemStats(&after)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: got %v, expected ErrInvalidFormat", name, err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: decoding a %d byte delta allocated %d bytes", name, len(delta), allocated)
+		}
+	}
+}
+
+// BenchmarkDecodeLargeWindow decodes one 8 MiB window made of long RUNs and
+// overlapping COPYs
+func BenchmarkDecodeLargeWindow(b *testing.B) {
+	const chunk = 1 << 20
+	var instructions []RuntimeInstruction
+	for i := 0; i < 4; i++ {
+		instructions = append(instructions,
+			RuntimeInstruction{Type: Add, Size: 3, Data: []byte("abc")},
+			RuntimeInstruction{Type: Copy, Size: chunk - 3, Addr: uint32(2 * i * chunk)},
+			RuntimeInstruction{Type: Run, Size: chunk, Data: []byte{byte(i)}},
+		)
+	}
+	window := Window{}
+	encodeInstructions(&window, instructions)
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(window.TargetWindowLength))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(nil, delta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
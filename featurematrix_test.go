@@ -0,0 +1,228 @@
+package vcdiff
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var featureMatrixPath = flag.String("feature-matrix", "", "write the RFC 3284 feature coverage matrix to this file")
+
+// Directories of the conformance suite scanned for the feature matrix
+var featureMatrixSuiteDirs = []string{
+	"submodules/vcdiff-tests/targeted-positive",
+	"submodules/vcdiff-tests/targeted-negative",
+	"submodules/vcdiff-tests/general-positive",
+}
+
+// rfcFeature is one row of the coverage matrix
+type rfcFeature struct {
+	category string
+	name     string
+}
+
+// rfcFeatures lists every tracked feature in report order
+var rfcFeatures = func() []rfcFeature {
+	features := []rfcFeature{
+		{"Header", "VCD_DECOMPRESS (secondary compression)"},
+		{"Header", "VCD_CODETABLE (custom code table)"},
+		{"Header", "VCD_APPHEADER (application header)"},
+		{"Window", "VCD_SOURCE"},
+		{"Window", "VCD_TARGET"},
+		{"Window", "VCD_ADLER32"},
+		{"Window", "No source or target segment"},
+		{"Window", "Empty target window"},
+		{"Window", "Multiple windows"},
+		{"Delta indicator", "VCD_DATACOMP"},
+		{"Delta indicator", "VCD_INSTCOMP"},
+		{"Delta indicator", "VCD_ADDRCOMP"},
+		{"Instruction", "RUN"},
+		{"Instruction", "ADD"},
+		{"Instruction", "COPY"},
+		{"Instruction", "Size from code table"},
+		{"Instruction", "Size in instruction stream"},
+		{"Instruction", "Combined ADD+COPY code"},
+		{"Instruction", "Combined COPY+ADD code"},
+	}
+	for mode := 0; mode < 2+NearCacheSize+SameCacheModes; mode++ {
+		features = append(features, rfcFeature{"Address mode", copyModeName(byte(mode))})
+	}
+	return features
+}()
+
+func copyModeName(mode byte) string {
+	switch {
+	case mode == SelfMode:
+		return "Mode 0 (SELF)"
+	case mode == HereMode:
+		return "Mode 1 (HERE)"
+	case int(mode) < 2+NearCacheSize:
+		return fmt.Sprintf("Mode %d (NEAR %d)", mode, mode-2)
+	default:
+		return fmt.Sprintf("Mode %d (SAME %d)", mode, int(mode)-2-NearCacheSize)
+	}
+}
+
+// featureMatrix counts the number of deltas exercising each feature
+type featureMatrix map[string]int
+
+// record scans a delta and counts each feature it uses at most once
+func (m featureMatrix) record(delta []byte) {
+	seen := map[string]bool{}
+	mark := func(name string) { seen[name] = true }
+
+	// Header flags are visible even when the rest of the delta is unsupported
+	if len(delta) >= MinimumFileSize+1 && bytes.Equal(delta[:3], VCDIFFMagic[:]) {
+		indicator := delta[MinimumFileSize]
+		if indicator&VCDDecompress != 0 {
+			mark("VCD_DECOMPRESS (secondary compression)")
+		}
+		if indicator&VCDCodetable != 0 {
+			mark("VCD_CODETABLE (custom code table)")
+		}
+		if indicator&VCDAppHeader != 0 {
+			mark("VCD_APPHEADER (application header)")
+		}
+	}
+
+	if parsed, err := ParseDelta(delta); err == nil {
+		if len(parsed.Windows) > 1 {
+			mark("Multiple windows")
+		}
+		for _, window := range parsed.Windows {
+			recordWindow(&window, mark)
+		}
+	}
+
+	for name := range seen {
+		m[name]++
+	}
+}
+
+func recordWindow(window *Window, mark func(string)) {
+	if window.WinIndicator&VCDSource != 0 {
+		mark("VCD_SOURCE")
+	}
+	if window.WinIndicator&VCDTarget != 0 {
+		mark("VCD_TARGET")
+	}
+	if window.WinIndicator&(VCDSource|VCDTarget) == 0 {
+		mark("No source or target segment")
+	}
+	if window.HasChecksum {
+		mark("VCD_ADLER32")
+	}
+	if window.TargetWindowLength == 0 {
+		mark("Empty target window")
+	}
+	if window.DeltaIndicator&VCDDataComp != 0 {
+		mark("VCD_DATACOMP")
+	}
+	if window.DeltaIndicator&VCDInstComp != 0 {
+		mark("VCD_INSTCOMP")
+	}
+	if window.DeltaIndicator&VCDAddrComp != 0 {
+		mark("VCD_ADDRCOMP")
+	}
+
+	stream := bytes.NewReader(window.InstructionSection)
+	for stream.Len() > 0 {
+		code, _ := stream.ReadByte()
+		first := DefaultCodeTable.Get(code, 0)
+		second := DefaultCodeTable.Get(code, 1)
+		if first.Type == Add && second.Type == Copy {
+			mark("Combined ADD+COPY code")
+		}
+		if first.Type == Copy && second.Type == Add {
+			mark("Combined COPY+ADD code")
+		}
+
+		for _, inst := range []Instruction{first, second} {
+			if inst.Type == NoOp {
+				continue
+			}
+			mark(inst.Type.String())
+			if inst.Type == Copy {
+				mark(copyModeName(inst.Mode))
+			}
+			if inst.Size == 0 {
+				mark("Size in instruction stream")
+				if _, err := ReadVarint(stream); err != nil {
+					return
+				}
+			} else {
+				mark("Size from code table")
+			}
+		}
+	}
+}
+
+// report renders the matrix as a Markdown table
+func (m featureMatrix) report(sources int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# RFC 3284 Feature Coverage\n\n")
+	fmt.Fprintf(&b, "Deltas scanned: %d\n\n", sources)
+	fmt.Fprintf(&b, "| Category | Feature | Deltas | Status |\n")
+	fmt.Fprintf(&b, "|----------|---------|--------|--------|\n")
+	for _, f := range rfcFeatures {
+		status := "covered"
+		if m[f.name] == 0 {
+			status = "**UNTESTED**"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %s |\n", f.category, f.name, m[f.name], status)
+	}
+	return b.String()
+}
+
+// TestFeatureMatrix records which RFC 3284 features the conformance suite
+// and generated deltas exercise. Run with -feature-matrix=path to write
+// the report; otherwise untested features are only logged.
+func TestFeatureMatrix(t *testing.T) {
+	matrix := featureMatrix{}
+	sources := 0
+
+	for _, dir := range featureMatrixSuiteDirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() != "delta.vcdiff" {
+				return err
+			}
+			delta, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			matrix.record(delta)
+			sources++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to scan %s: %v", dir, err)
+		}
+	}
+
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileAddHeavy, ProfileNoSource} {
+		for seed := int64(0); seed < 50; seed++ {
+			matrix.record(GenerateDelta(seed, profile).Delta)
+			sources++
+		}
+	}
+
+	for _, f := range rfcFeatures {
+		if matrix[f.name] == 0 {
+			t.Logf("untested: %s / %s", f.category, f.name)
+		}
+	}
+
+	if *featureMatrixPath != "" {
+		if err := os.WriteFile(*featureMatrixPath, []byte(matrix.report(sources)), 0o644); err != nil {
+			t.Fatalf("Failed to write feature matrix: %v", err)
+		}
+	}
+}
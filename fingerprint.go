@@ -0,0 +1,143 @@
+package vcdiff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Source fingerprint application header layout. All integers are big-endian.
+//
+//	offset  size  field
+//	0       4     tag "VCSF"
+//	4       1     layout version (1)
+//	5       1     flags: bit 0 Adler-32 present, bit 1 SHA-256 present
+//	6       8     source length
+//	14      4     Adler-32 of the source (if flagged)
+//	..      32    SHA-256 of the source (if flagged)
+//
+// Application headers that do not start with the tag are left alone, so
+// deltas carrying other application data decode as before.
+const (
+	FingerprintVersion  = 1    // Current layout version
+	FingerprintAdler32  = 0x01 // Flag: Adler-32 present
+	FingerprintSHA256   = 0x02 // Flag: SHA-256 present
+	fingerprintFixedLen = 14   // Tag, version, flags and length
+	adler32Len          = 4    // Size of an Adler-32 checksum
+)
+
+// fingerprintTag marks an application header as a source fingerprint
+var fingerprintTag = []byte("VCSF")
+
+// SourceFingerprint identifies the source a delta was encoded against
+type SourceFingerprint struct {
+	Length     uint64
+	HasAdler32 bool
+	Adler32    uint32
+	HasSHA256  bool
+	SHA256     [sha256.Size]byte
+}
+
+// NewSourceFingerprint computes the length, Adler-32 and SHA-256 of source
+func NewSourceFingerprint(source []byte) SourceFingerprint {
+	return SourceFingerprint{
+		Length:     uint64(len(source)),
+		HasAdler32: true,
+		Adler32:    ComputeChecksum(1, source),
+		HasSHA256:  true,
+		SHA256:     sha256.Sum256(source),
+	}
+}
+
+// AppHeader encodes the fingerprint in the application header layout
+func (f SourceFingerprint) AppHeader() []byte {
+	var flags byte
+	if f.HasAdler32 {
+		flags |= FingerprintAdler32
+	}
+	if f.HasSHA256 {
+		flags |= FingerprintSHA256
+	}
+
+	b := append([]byte{}, fingerprintTag...)
+	b = append(b, FingerprintVersion, flags)
+	b = binary.BigEndian.AppendUint64(b, f.Length)
+	if f.HasAdler32 {
+		b = binary.BigEndian.AppendUint32(b, f.Adler32)
+	}
+	if f.HasSHA256 {
+		b = append(b, f.SHA256[:]...)
+	}
+	return b
+}
+
+// ParseSourceFingerprint decodes a fingerprint from an application header.
+// It returns false if the header does not carry one.
+func ParseSourceFingerprint(appHeader []byte) (SourceFingerprint, bool, error) {
+	var f SourceFingerprint
+	if !bytes.HasPrefix(appHeader, fingerprintTag) {
+		return f, false, nil
+	}
+	if len(appHeader) < fingerprintFixedLen {
+		return f, true, fmt.Errorf("%w: source fingerprint truncated", ErrInvalidFormat)
+	}
+
+	version := appHeader[len(fingerprintTag)]
+	if version != FingerprintVersion {
+		return f, true, errInvalidValue("source fingerprint version", len(fingerprintTag), version, "unknown layout")
+	}
+	flags := appHeader[len(fingerprintTag)+1]
+	f.Length = binary.BigEndian.Uint64(appHeader[len(fingerprintTag)+2:])
+	rest := appHeader[fingerprintFixedLen:]
+
+	want := 0
+	if flags&FingerprintAdler32 != 0 {
+		want += adler32Len
+	}
+	if flags&FingerprintSHA256 != 0 {
+		want += sha256.Size
+	}
+	if len(rest) != want {
+		return f, true, fmt.Errorf("%w: source fingerprint has %d checksum bytes, expected %d", ErrInvalidFormat, len(rest), want)
+	}
+
+	if flags&FingerprintAdler32 != 0 {
+		f.HasAdler32 = true
+		f.Adler32 = binary.BigEndian.Uint32(rest)
+		rest = rest[adler32Len:]
+	}
+	if flags&FingerprintSHA256 != 0 {
+		f.HasSHA256 = true
+		copy(f.SHA256[:], rest)
+	}
+	return f, true, nil
+}
+
+// Verify checks that source matches the fingerprint, returning an error
+// wrapping ErrSourceMismatch if it does not
+func (f SourceFingerprint) Verify(source []byte) error {
+	if uint64(len(source)) != f.Length {
+		return fmt.Errorf("%w: length %d, delta expects %d", ErrSourceMismatch, len(source), f.Length)
+	}
+	if f.HasAdler32 {
+		if sum := ComputeChecksum(1, source); sum != f.Adler32 {
+			return fmt.Errorf("%w: Adler-32 0x%08x, delta expects 0x%08x", ErrSourceMismatch, sum, f.Adler32)
+		}
+	}
+	if f.HasSHA256 {
+		if sum := sha256.Sum256(source); sum != f.SHA256 {
+			return fmt.Errorf("%w: SHA-256 %x, delta expects %x", ErrSourceMismatch, sum, f.SHA256)
+		}
+	}
+	return nil
+}
+
+// verifySource checks source against the fingerprint in header, if any
+func verifySource(header *Header, source []byte) error {
+	f, ok, err := ParseSourceFingerprint(header.AppHeader)
+	if err != nil || !ok {
+		return err
+	}
+	return f.Verify(source)
+}
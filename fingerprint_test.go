@@ -0,0 +1,85 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSourceFingerprintRoundTrip(t *testing.T) {
+	source := []byte("the quick brown fox")
+
+	full := NewSourceFingerprint(source)
+	adlerOnly := SourceFingerprint{Length: full.Length, HasAdler32: true, Adler32: full.Adler32}
+	lengthOnly := SourceFingerprint{Length: full.Length}
+
+	for _, f := range []SourceFingerprint{full, adlerOnly, lengthOnly} {
+		got, ok, err := ParseSourceFingerprint(f.AppHeader())
+		if err != nil || !ok {
+			t.Fatalf("ParseSourceFingerprint: ok %v, error %v", ok, err)
+		}
+		if got != f {
+			t.Fatalf("got %+v, expected %+v", got, f)
+		}
+		if err := got.Verify(source); err != nil {
+			t.Errorf("Verify against the fingerprinted source: %v", err)
+		}
+	}
+
+	if err := full.Verify([]byte("the quick brown cat")); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("Verify with a same-length different source: got %v, expected ErrSourceMismatch", err)
+	}
+	if err := lengthOnly.Verify(source[1:]); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("Verify with a shorter source: got %v, expected ErrSourceMismatch", err)
+	}
+}
+
+func TestParseSourceFingerprintInvalid(t *testing.T) {
+	valid := NewSourceFingerprint([]byte("base")).AppHeader()
+
+	if _, ok, err := ParseSourceFingerprint([]byte("msg-id:1234")); ok || err != nil {
+		t.Errorf("foreign app header: ok %v, error %v", ok, err)
+	}
+	if _, ok, err := ParseSourceFingerprint(nil); ok || err != nil {
+		t.Errorf("no app header: ok %v, error %v", ok, err)
+	}
+
+	badVersion := append([]byte{}, valid...)
+	badVersion[len(fingerprintTag)] = FingerprintVersion + 1
+
+	for name, header := range map[string][]byte{
+		"truncated fixed part": valid[:fingerprintFixedLen-1],
+		"truncated checksums":  valid[:len(valid)-1],
+		"trailing bytes":       append(append([]byte{}, valid...), 0),
+		"unknown version":      badVersion,
+	} {
+		if _, ok, err := ParseSourceFingerprint(header); !ok || err == nil {
+			t.Errorf("%s: ok %v, error %v, expected a rejected fingerprint", name, ok, err)
+		}
+	}
+}
+
+func TestDecodeVerifiesSourceFingerprint(t *testing.T) {
+	g := GenerateDelta(12, ProfileCopyHeavy)
+	delta := withHeaderSection(g.Delta, VCDAppHeader, NewSourceFingerprint(g.Source).AppHeader())
+
+	result, err := Decode(g.Source, delta)
+	if err != nil {
+		t.Fatalf("Decode with the fingerprinted source failed: %v", err)
+	}
+	if !bytes.Equal(result, g.Target) {
+		t.Fatal("fingerprinted delta decoded to a different target")
+	}
+
+	wrong := append([]byte{}, g.Source...)
+	wrong[0] ^= 0xFF
+
+	started := false
+	hooks := Hooks{OnWindowStart: func(int, *Window) error { started = true; return nil }}
+	if _, err := NewDecoder(wrong, WithHooks(hooks)).Decode(delta); !errors.Is(err, ErrSourceMismatch) {
+		t.Fatalf("Decode with the wrong source: got %v, expected ErrSourceMismatch", err)
+	}
+	if started {
+		t.Error("windows were executed before the source fingerprint was checked")
+	}
+}
@@ -160,9 +160,120 @@ func FuzzInstructionParsing(f *testing.F) {
 		cache.Reset(addressSection)
 
 		// This should not panic regardless of input
-		_, err := parseInstructions(instructionData, dataSection, cache)
+		_, err := parseInstructions(&Window{InstructionSection: instructionData, DataSection: dataSection}, cache)
 
 		// We don't care about the specific error, just that it doesn't panic
 		_ = err
 	})
 }
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("The quick brown fox"), []byte("The quick red fox"))
+	f.Add([]byte(""), []byte("abcabcabcabc"))
+	f.Add([]byte("aaaaaaaaaaaaaaaa"), []byte("aaaaaaaabbbbbbbbbbbbaaaa"))
+
+	f.Fuzz(func(t *testing.T, source, target []byte) {
+		delta, err := Encode(source, target)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		result, err := Decode(source, delta)
+		if err != nil {
+			t.Fatalf("encoded delta does not decode: %v", err)
+		}
+		if !bytes.Equal(result, target) {
+			t.Fatalf("round trip changed the target")
+		}
+	})
+}
+
+// FuzzEncodeDecode encodes with the option combination selected by the bits
+// of options, and checks that the delta is valid and decodes to the target
+func FuzzEncodeDecode(f *testing.F) {
+	f.Add([]byte("The quick brown fox"), []byte("The quick red fox"), uint8(0))
+	f.Add([]byte(""), []byte("abcabcabcabc"), uint8(0x01))
+	f.Add([]byte("aaaaaaaaaaaaaaaa"), []byte("aaaaaaaabbbbbbbbbbbbaaaa"), uint8(0x06))
+	f.Add(bytes.Repeat([]byte("0123456789"), 20), bytes.Repeat([]byte("012345678"), 25), uint8(0x3f))
+	f.Add([]byte("VCSF source"), []byte("VCSF target"), uint8(0xc1))
+	f.Add([]byte("0"), []byte("SF0127SF0127"), uint8(0xff))
+
+	f.Fuzz(func(t *testing.T, source, target []byte, options uint8) {
+		var opts []EncoderOption
+		if options&0x01 != 0 {
+			opts = append(opts, WithInterleaved())
+		}
+		switch options >> 1 & 0x03 {
+		case 1:
+			opts = append(opts, WithLevel(LevelFast))
+		case 2:
+			opts = append(opts, WithLevel(LevelBest))
+		}
+		if options&0x08 != 0 {
+			opts = append(opts, WithMinMatchLength(16))
+		}
+		if options&0x10 != 0 {
+			opts = append(opts, WithConcurrency(2))
+		}
+		if options&0x40 != 0 {
+			opts = append(opts, WithSecondaryCompressor(flateCompressor{}))
+		}
+		var appHeader []byte
+		if options&0x80 != 0 {
+			// Build the header from the target, fingerprinting the source
+			// instead when it would otherwise be taken for a fingerprint
+			appHeader = append([]byte{}, target[:min(len(target), 8)]...)
+			if bytes.HasPrefix(appHeader, fingerprintTag) {
+				appHeader = NewSourceFingerprint(source).AppHeader()
+			}
+			opts = append(opts, WithAppHeader(appHeader))
+		}
+
+		var delta []byte
+		if options&0x20 != 0 {
+			// Stream the target in two writes
+			var buf bytes.Buffer
+			w, err := NewEncoder(&buf, source, opts...)
+			if err != nil {
+				t.Fatalf("NewEncoder failed: %v", err)
+			}
+			half := len(target) / 2
+			if _, err := w.Write(target[:half]); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if _, err := w.Write(target[half:]); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			delta = buf.Bytes()
+		} else {
+			var err error
+			if delta, err = Encode(source, target, opts...); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+		}
+
+		if options&0x40 != 0 {
+			delta = decompressSections(t, delta)
+		}
+		if err := Validate(delta); err != nil {
+			t.Fatalf("encoded delta is invalid: %v", err)
+		}
+		var header Header
+		decoder := NewDecoder(source, WithHooks(Hooks{OnHeader: func(_ int, h *Header) error {
+			header = *h
+			return nil
+		}}))
+		result, err := decoder.Decode(delta)
+		if err != nil {
+			t.Fatalf("encoded delta does not decode: %v", err)
+		}
+		if !bytes.Equal(result, target) {
+			t.Fatalf("round trip changed the target")
+		}
+		if !bytes.Equal(header.AppHeader, appHeader) {
+			t.Fatalf("got application header %q, expected %q", header.AppHeader, appHeader)
+		}
+	})
+}
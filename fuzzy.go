@@ -0,0 +1,214 @@
+package vcdiff
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// fuzzyMaxAttempts bounds the number of window re-executions tried while
+// resynchronizing a single window
+const fuzzyMaxAttempts = 1 << 14
+
+// FuzzyRegion is a stretch of the target rebuilt from source data whose
+// position in the base could not be confirmed as-is
+type FuzzyRegion struct {
+	Window       int    // Index of the window containing the region
+	TargetOffset uint64 // Offset of the region in the full target
+	Length       uint32 // Length of the region
+	Shift        int    // Adjustment applied to the region's source offset
+	Verified     bool   // The window checksum matched after the adjustment
+}
+
+// FuzzyReport describes what a fuzzy decode had to work around
+type FuzzyReport struct {
+	SourceMismatch bool          // The base did not match the delta's source fingerprint
+	Regions        []FuzzyRegion // Regions patched with reduced confidence
+}
+
+// fuzzyConfig holds the settings of WithFuzzy
+type fuzzyConfig struct {
+	maxShift int
+	report   *FuzzyReport
+}
+
+// WithFuzzy lets the decoder apply a delta to a base that differs slightly
+// from the one it was encoded against, much like patch's fuzz factor.
+//
+// Normally a source fingerprint mismatch or a failed window checksum is an
+// error. In fuzzy mode:
+//   - A fingerprint mismatch is recorded in the report and decoding continues.
+//   - A window that fails its checksum is re-executed with the source offsets
+//     of its COPYs shifted by up to maxShift bytes. The shift applies from
+//     some COPY onward, which models an insertion or deletion in the base.
+//     The first shift that satisfies the checksum wins.
+//   - Source-copied regions of windows without a checksum are reported as
+//     unverified when the base is known to differ.
+//
+// The report is reset at the start of every Decode call.
+func WithFuzzy(maxShift int, report *FuzzyReport) DecoderOption {
+	return func(d *decoder) {
+		d.fuzzy = &fuzzyConfig{maxShift: maxShift, report: report}
+	}
+}
+
+// resolvedWindow is a window whose COPY addresses have been decoded up front,
+// which is possible because they depend only on instruction sizes
+type resolvedWindow struct {
+	window       *Window
+	instructions []RuntimeInstruction
+	sourceCopies int // Number of COPYs reading from the source segment
+}
+
+func resolveWindow(window *Window, addressCache *AddressCache) (*resolvedWindow, error) {
+	instructions, err := parseInstructions(window, addressCache)
+	if err != nil {
+		return nil, err
+	}
+	// Shifted COPYs are executed without the checks of executeInstructions,
+	// so the window's length is checked here
+	if produced := instructionsLength(instructions); produced != uint64(window.TargetWindowLength) {
+		return nil, &TargetLengthError{Declared: window.TargetWindowLength, Produced: produced}
+	}
+	addressCache.resetFor(window)
+
+	r := &resolvedWindow{window: window, instructions: instructions}
+	segmentSize := uint32(0)
+	if window.WinIndicator&VCDSource != 0 {
+		segmentSize = window.SourceSegmentSize
+	}
+
+	here := segmentSize
+	for i := range instructions {
+		if instructions[i].Type == Copy {
+			addr, err := addressCache.DecodeAddress(here, instructions[i].Mode)
+			if err != nil {
+				return nil, err
+			}
+			instructions[i].Addr = addr
+			if addr < segmentSize {
+				r.sourceCopies++
+			}
+		}
+		here += instructions[i].Size
+	}
+	return r, nil
+}
+
+// execute rebuilds the target window, adding shift to the source offset of
+// every source COPY from the from'th onward. Regions records the target
+// extent of each shifted COPY. It returns false if a shifted COPY falls
+// outside source.
+func (r *resolvedWindow) execute(source []byte, from, shift int) (target []byte, regions []FuzzyRegion, ok bool) {
+	segmentStart, segmentSize := int64(0), uint32(0)
+	if r.window.WinIndicator&VCDSource != 0 {
+		segmentStart, segmentSize = int64(r.window.SourceSegmentPosition), r.window.SourceSegmentSize
+	}
+
+	target = make([]byte, 0, r.window.TargetWindowLength)
+	sourceCopy := 0
+	for _, inst := range r.instructions {
+		switch inst.Type {
+		case Add:
+			target = append(target, inst.Data...)
+		case Run:
+			position := len(target)
+			target = slices.Grow(target, int(inst.Size))[:position+int(inst.Size)]
+			fillRun(target[position:], inst.Data[0])
+		case Copy:
+			if inst.Addr < segmentSize {
+				start := segmentStart + int64(inst.Addr)
+				if sourceCopy >= from {
+					start += int64(shift)
+					regions = append(regions, FuzzyRegion{TargetOffset: uint64(len(target)), Length: inst.Size, Shift: shift})
+				}
+				sourceCopy++
+				if start < 0 || start+int64(inst.Size) > int64(len(source)) {
+					return nil, nil, false
+				}
+				target = append(target, source[start:start+int64(inst.Size)]...)
+				continue
+			}
+
+			targetAddr := inst.Addr - segmentSize
+			if targetAddr >= uint32(len(target)) {
+				return nil, nil, false
+			}
+			position := uint32(len(target))
+			target = slices.Grow(target, int(inst.Size))[:position+inst.Size]
+			copyWithin(target, targetAddr, position, inst.Size)
+		}
+	}
+	return target, regions, true
+}
+
+// fuzzyShifts lists candidate shifts, smallest magnitude first
+func fuzzyShifts(maxShift int) []int {
+	var shifts []int
+	for s := 1; s <= maxShift; s++ {
+		shifts = append(shifts, s, -s)
+	}
+	return shifts
+}
+
+// shouldResync reports whether a failed window is worth resynchronizing
+func (f *fuzzyConfig) shouldResync(err error) bool {
+	return errors.Is(err, ErrInvalidChecksum) || f.report.SourceMismatch
+}
+
+// resync re-executes a window that failed to decode against a differing base.
+// targetOffset is the window's offset in the full target, used for reporting.
+func (f *fuzzyConfig) resync(index int, window *Window, source []byte, addressCache *AddressCache, checksum ChecksumValidator, targetOffset uint64) ([]byte, error) {
+	r, err := resolveWindow(window, addressCache)
+	if err != nil {
+		return nil, err
+	}
+
+	if !window.HasChecksum {
+		// Nothing to verify against; rebuild as encoded and flag every source COPY
+		target, regions, ok := r.execute(source, 0, 0)
+		if !ok {
+			return nil, fmt.Errorf("window %d references data outside the base", index)
+		}
+		f.record(index, targetOffset, regions, false)
+		return target, nil
+	}
+
+	attempts := 0
+	for _, shift := range fuzzyShifts(f.maxShift) {
+		for from := 0; from < r.sourceCopies; from++ {
+			if attempts++; attempts > fuzzyMaxAttempts {
+				return nil, fmt.Errorf("%w: window %d not resynchronized after %d attempts", ErrInvalidChecksum, index, fuzzyMaxAttempts)
+			}
+
+			target, regions, ok := r.execute(source, from, shift)
+			if ok && checksum.Checksum(window, target) == window.Checksum {
+				f.record(index, targetOffset, regions, true)
+				return target, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: window %d could not be resynchronized within %d bytes", ErrInvalidChecksum, index, f.maxShift)
+}
+
+// recordUnverified flags the source COPYs of a window that decoded against a
+// base known to differ, when the window has no checksum to confirm them
+func (f *fuzzyConfig) recordUnverified(index int, window *Window, source []byte, addressCache *AddressCache, targetOffset uint64) error {
+	r, err := resolveWindow(window, addressCache)
+	if err != nil {
+		return err
+	}
+	_, regions, _ := r.execute(source, 0, 0)
+	f.record(index, targetOffset, regions, false)
+	return nil
+}
+
+// record adds a window's regions to the report, relative to the full target
+func (f *fuzzyConfig) record(index int, targetOffset uint64, regions []FuzzyRegion, verified bool) {
+	for _, region := range regions {
+		region.Window = index
+		region.TargetOffset += targetOffset
+		region.Verified = verified
+		f.report.Regions = append(f.report.Regions, region)
+	}
+}
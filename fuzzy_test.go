@@ -0,0 +1,136 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// fuzzyDelta assembles a single-window delta whose target is the source split
+// into three consecutive COPYs with a literal ADD after the first, the way an
+// encoder lays out a small edit
+func fuzzyDelta(source []byte, checksum bool, appHeader []byte) (delta, target []byte) {
+	cuts := []int{0, len(source) / 3, 2 * len(source) / 3, len(source)}
+	literal := []byte("edit")
+
+	var instructions, addresses []byte
+	for i := 0; i < 3; i++ {
+		instructions = append(instructions, genCopyCodeBase) // COPY, SELF mode, size follows
+		instructions = AppendVarint(instructions, uint32(cuts[i+1]-cuts[i]))
+		addresses = AppendVarint(addresses, uint32(cuts[i]))
+		target = append(target, source[cuts[i]:cuts[i+1]]...)
+		if i == 0 {
+			instructions = append(instructions, genAddCode)
+			instructions = AppendVarint(instructions, uint32(len(literal)))
+			target = append(target, literal...)
+		}
+	}
+
+	encoding := AppendVarint(nil, uint32(len(target)))
+	encoding = append(encoding, 0)
+	encoding = AppendVarint(encoding, uint32(len(literal)))
+	encoding = AppendVarint(encoding, uint32(len(instructions)))
+	encoding = AppendVarint(encoding, uint32(len(addresses)))
+	indicator := byte(VCDSource)
+	if checksum {
+		indicator |= VCDAdler32
+		sum := ComputeChecksum(1, target)
+		encoding = append(encoding, byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum))
+	}
+	encoding = append(encoding, literal...)
+	encoding = append(encoding, instructions...)
+	encoding = append(encoding, addresses...)
+
+	delta = []byte{VCDIFFMagic1, VCDIFFMagic2, VCDIFFMagic3, VCDIFFVersion, 0, indicator}
+	delta = AppendVarint(delta, uint32(len(source)))
+	delta = AppendVarint(delta, 0)
+	delta = AppendVarint(delta, uint32(len(encoding)))
+	delta = append(delta, encoding...)
+	if appHeader != nil {
+		delta = withHeaderSection(delta, VCDAppHeader, appHeader)
+	}
+	return delta, target
+}
+
+// insertBytes returns source with n bytes inserted at the start of its second third
+func insertBytes(source []byte, n int) []byte {
+	at := len(source) / 3
+	out := append([]byte{}, source[:at]...)
+	out = append(out, bytes.Repeat([]byte{'#'}, n)...)
+	return append(out, source[at:]...)
+}
+
+func TestFuzzyResynchronizesShiftedBase(t *testing.T) {
+	source := make([]byte, 300)
+	rand.New(rand.NewSource(1)).Read(source)
+	delta, target := fuzzyDelta(source, true, nil)
+
+	const inserted = 5
+	base := insertBytes(source, inserted)
+
+	if _, err := Decode(base, delta); !errors.Is(err, ErrInvalidChecksum) {
+		t.Fatalf("strict decode against shifted base: got %v, expected ErrInvalidChecksum", err)
+	}
+
+	var report FuzzyReport
+	result, err := NewDecoder(base, WithFuzzy(16, &report)).Decode(delta)
+	if err != nil {
+		t.Fatalf("fuzzy decode failed: %v", err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Fatal("fuzzy decode produced the wrong target")
+	}
+
+	// The second and third COPYs were shifted and confirmed by the checksum
+	if len(report.Regions) != 2 {
+		t.Fatalf("got %d fuzzy regions, expected 2: %+v", len(report.Regions), report.Regions)
+	}
+	for _, region := range report.Regions {
+		if region.Shift != inserted || !region.Verified {
+			t.Errorf("unexpected region %+v", region)
+		}
+	}
+
+	// A shift beyond the allowed range is still an error
+	if _, err := NewDecoder(base, WithFuzzy(inserted-1, &report)).Decode(delta); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("fuzzy decode with too small a range: got %v, expected ErrInvalidChecksum", err)
+	}
+}
+
+func TestFuzzyFlagsUnverifiedRegions(t *testing.T) {
+	source := make([]byte, 300)
+	rand.New(rand.NewSource(2)).Read(source)
+	delta, _ := fuzzyDelta(source, false, NewSourceFingerprint(source).AppHeader())
+
+	base := append([]byte{}, source...)
+	base[10] ^= 0xFF
+
+	if _, err := Decode(base, delta); !errors.Is(err, ErrSourceMismatch) {
+		t.Fatalf("strict decode against modified base: got %v, expected ErrSourceMismatch", err)
+	}
+
+	var report FuzzyReport
+	if _, err := NewDecoder(base, WithFuzzy(16, &report)).Decode(delta); err != nil {
+		t.Fatalf("fuzzy decode failed: %v", err)
+	}
+	if !report.SourceMismatch {
+		t.Error("report does not record the source mismatch")
+	}
+	if len(report.Regions) != 3 {
+		t.Fatalf("got %d fuzzy regions, expected one per source COPY: %+v", len(report.Regions), report.Regions)
+	}
+	for _, region := range report.Regions {
+		if region.Verified || region.Shift != 0 {
+			t.Errorf("unexpected region %+v", region)
+		}
+	}
+
+	// Against the right base nothing is reported
+	if _, err := NewDecoder(source, WithFuzzy(16, &report)).Decode(delta); err != nil {
+		t.Fatal(err)
+	}
+	if report.SourceMismatch || len(report.Regions) != 0 {
+		t.Errorf("report not reset for a clean decode: %+v", report)
+	}
+}
@@ -0,0 +1,305 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// Code table entries used by the generator - RFC 3284 Section 5.6
+const (
+	genRunCode          = 0   // RUN, size in instruction stream
+	genAddCode          = 1   // ADD, size in instruction stream
+	genAddSizedCodeBase = 1   // ADD with size 1-17 is code size+1
+	genAddSizedMax      = 17  // Largest ADD size with a dedicated code
+	genCopyCodeBase     = 19  // COPY mode 0, size in instruction stream
+	genCopyCodesPerMode = 16  // COPY codes per mode (size 0 plus sizes 4-18)
+	genCopySizedMin     = 4   // Smallest COPY size with a dedicated code
+	genCopySizedMax     = 18  // Largest COPY size with a dedicated code
+	genSameCacheBuckets = 256 // Entries per "same" cache mode - RFC 3284 Section 5.3
+)
+
+// DeltaProfile controls the characteristics of deltas produced by GenerateDelta
+type DeltaProfile struct {
+	SourceSize    int  // Length of the generated source
+	Windows       int  // Number of windows in the delta
+	MinWindowSize int  // Minimum target window length
+	MaxWindowSize int  // Maximum target window length
+	MaxInstSize   int  // Upper bound on the size of a single instruction
+	AddWeight     int  // Relative frequency of ADD instructions
+	CopyWeight    int  // Relative frequency of COPY instructions
+	RunWeight     int  // Relative frequency of RUN instructions
+	Checksums     bool // Whether windows carry VCD_ADLER32 checksums
+}
+
+// GeneratedDelta is a delta together with the source it applies to and the
+// target it reconstructs
+type GeneratedDelta struct {
+	Source []byte
+	Target []byte
+	Delta  []byte
+}
+
+// Profiles used by property tests and benchmarks
+var (
+	ProfileSmall = DeltaProfile{
+		SourceSize: 64, Windows: 1, MinWindowSize: 1, MaxWindowSize: 64, MaxInstSize: 16,
+		AddWeight: 1, CopyWeight: 1, RunWeight: 1,
+	}
+	ProfileCopyHeavy = DeltaProfile{
+		SourceSize: 4096, Windows: 3, MinWindowSize: 256, MaxWindowSize: 2048, MaxInstSize: 256,
+		AddWeight: 1, CopyWeight: 8, RunWeight: 1, Checksums: true,
+	}
+	ProfileAddHeavy = DeltaProfile{
+		SourceSize: 256, Windows: 2, MinWindowSize: 128, MaxWindowSize: 1024, MaxInstSize: 64,
+		AddWeight: 8, CopyWeight: 1, RunWeight: 1,
+	}
+	ProfileNoSource = DeltaProfile{
+		SourceSize: 0, Windows: 2, MinWindowSize: 1, MaxWindowSize: 512, MaxInstSize: 64,
+		AddWeight: 2, CopyWeight: 2, RunWeight: 1, Checksums: true,
+	}
+)
+
+// genAddressCache mirrors AddressCache in the encoding direction
+type genAddressCache struct {
+	near     [NearCacheSize]uint32
+	nextSlot int
+	same     [SameCacheSize]uint32
+}
+
+func (c *genAddressCache) update(addr uint32) {
+	c.near[c.nextSlot] = addr
+	c.nextSlot = (c.nextSlot + 1) % NearCacheSize
+	c.same[addr%SameCacheSize] = addr
+}
+
+// encode picks a random valid mode for addr and appends its encoding
+func (c *genAddressCache) encode(rng *rand.Rand, addresses []byte, addr, here uint32) ([]byte, byte) {
+	modes := []byte{SelfMode, HereMode}
+	for i, n := range c.near {
+		if addr >= n {
+			modes = append(modes, byte(2+i))
+		}
+	}
+	if c.same[addr%SameCacheSize] == addr {
+		modes = append(modes, byte(2+NearCacheSize+int(addr%SameCacheSize)/genSameCacheBuckets))
+	}
+
+	mode := modes[rng.Intn(len(modes))]
+	switch {
+	case mode == SelfMode:
+		addresses = AppendVarint(addresses, addr)
+	case mode == HereMode:
+		addresses = AppendVarint(addresses, here-addr)
+	case int(mode) < 2+NearCacheSize:
+		addresses = AppendVarint(addresses, addr-c.near[mode-2])
+	default:
+		addresses = append(addresses, byte(addr%genSameCacheBuckets))
+	}
+	c.update(addr)
+	return addresses, mode
+}
+
+// GenerateDelta deterministically produces a valid delta for the given seed
+// and profile, along with the source and target it relates
+func GenerateDelta(seed int64, profile DeltaProfile) GeneratedDelta {
+	rng := rand.New(rand.NewSource(seed))
+
+	source := make([]byte, profile.SourceSize)
+	rng.Read(source)
+
+	delta := append([]byte{}, VCDIFFMagic1, VCDIFFMagic2, VCDIFFMagic3, VCDIFFVersion, 0)
+	var target []byte
+
+	totalWeight := profile.AddWeight + profile.CopyWeight + profile.RunWeight
+	for w := 0; w < profile.Windows; w++ {
+		windowLength := profile.MinWindowSize
+		if span := profile.MaxWindowSize - profile.MinWindowSize; span > 0 {
+			windowLength += rng.Intn(span + 1)
+		}
+
+		var data, instructions, addresses []byte
+		var window []byte
+		cache := &genAddressCache{}
+		sourceLength := uint32(len(source))
+
+		for produced := 0; produced < windowLength; {
+			size := 1 + rng.Intn(profile.MaxInstSize)
+			if size > windowLength-produced {
+				size = windowLength - produced
+			}
+			here := sourceLength + uint32(len(window))
+
+			pick := rng.Intn(totalWeight)
+			switch {
+			case pick < profile.CopyWeight && (sourceLength > 0 || len(window) > 0):
+				var addr uint32
+				if sourceLength > 0 && (len(window) == 0 || rng.Intn(2) == 0) {
+					// Copy from the source segment, clamped so it stays in bounds
+					if size > int(sourceLength) {
+						size = int(sourceLength)
+					}
+					addr = uint32(rng.Intn(int(sourceLength) - size + 1))
+					window = append(window, source[addr:int(addr)+size]...)
+				} else {
+					// Copy from earlier in the target window, possibly overlapping
+					start := rng.Intn(len(window))
+					addr = sourceLength + uint32(start)
+					for i := 0; i < size; i++ {
+						window = append(window, window[start+i])
+					}
+				}
+
+				var mode byte
+				addresses, mode = cache.encode(rng, addresses, addr, here)
+				if size >= genCopySizedMin && size <= genCopySizedMax && rng.Intn(2) == 0 {
+					code := genCopyCodeBase + int(mode)*genCopyCodesPerMode + size - genCopySizedMin + 1
+					instructions = append(instructions, byte(code))
+				} else {
+					instructions = append(instructions, byte(genCopyCodeBase+int(mode)*genCopyCodesPerMode))
+					instructions = AppendVarint(instructions, uint32(size))
+				}
+
+			case pick < profile.CopyWeight+profile.RunWeight:
+				b := byte(rng.Intn(256))
+				data = append(data, b)
+				window = append(window, bytes.Repeat([]byte{b}, size)...)
+				instructions = append(instructions, genRunCode)
+				instructions = AppendVarint(instructions, uint32(size))
+
+			default:
+				literal := make([]byte, size)
+				rng.Read(literal)
+				data = append(data, literal...)
+				window = append(window, literal...)
+				if size <= genAddSizedMax && rng.Intn(2) == 0 {
+					instructions = append(instructions, byte(genAddSizedCodeBase+size))
+				} else {
+					instructions = append(instructions, genAddCode)
+					instructions = AppendVarint(instructions, uint32(size))
+				}
+			}
+			produced += size
+		}
+
+		var indicator byte
+		if sourceLength > 0 {
+			indicator |= VCDSource
+		}
+		if profile.Checksums {
+			indicator |= VCDAdler32
+		}
+
+		var encoding []byte
+		encoding = AppendVarint(encoding, uint32(len(window)))
+		encoding = append(encoding, 0) // Delta_Indicator: no secondary compression
+		encoding = AppendVarint(encoding, uint32(len(data)))
+		encoding = AppendVarint(encoding, uint32(len(instructions)))
+		encoding = AppendVarint(encoding, uint32(len(addresses)))
+		if profile.Checksums {
+			sum := ComputeChecksum(1, window)
+			encoding = append(encoding, byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum))
+		}
+		encoding = append(encoding, data...)
+		encoding = append(encoding, instructions...)
+		encoding = append(encoding, addresses...)
+
+		delta = append(delta, indicator)
+		if indicator&VCDSource != 0 {
+			delta = AppendVarint(delta, sourceLength)
+			delta = AppendVarint(delta, 0)
+		}
+		delta = AppendVarint(delta, uint32(len(encoding)))
+		delta = append(delta, encoding...)
+
+		target = append(target, window...)
+	}
+
+	if target == nil {
+		target = []byte{}
+	}
+	return GeneratedDelta{Source: source, Target: target, Delta: delta}
+}
+
+func TestGenerateDeltaDeterministic(t *testing.T) {
+	a := GenerateDelta(42, ProfileCopyHeavy)
+	b := GenerateDelta(42, ProfileCopyHeavy)
+	if !bytes.Equal(a.Delta, b.Delta) || !bytes.Equal(a.Source, b.Source) || !bytes.Equal(a.Target, b.Target) {
+		t.Fatal("GenerateDelta is not deterministic for a fixed seed")
+	}
+
+	c := GenerateDelta(43, ProfileCopyHeavy)
+	if bytes.Equal(a.Delta, c.Delta) {
+		t.Fatal("GenerateDelta produced identical deltas for different seeds")
+	}
+}
+
+func TestGenerateDeltaDecodes(t *testing.T) {
+	profiles := map[string]DeltaProfile{
+		"small":      ProfileSmall,
+		"copy-heavy": ProfileCopyHeavy,
+		"add-heavy":  ProfileAddHeavy,
+		"no-source":  ProfileNoSource,
+	}
+
+	for name, profile := range profiles {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < 200; seed++ {
+				g := GenerateDelta(seed, profile)
+
+				result, err := Decode(g.Source, g.Delta)
+				if err != nil {
+					t.Fatalf("seed %d: decode failed: %v", seed, err)
+				}
+				if !bytes.Equal(result, g.Target) {
+					t.Fatalf("seed %d: decoded target differs from generated target", seed)
+				}
+
+				parsed, err := ParseDelta(g.Delta)
+				if err != nil {
+					t.Fatalf("seed %d: parse failed: %v", seed, err)
+				}
+				if len(parsed.Windows) != profile.Windows {
+					t.Fatalf("seed %d: got %d windows, expected %d", seed, len(parsed.Windows), profile.Windows)
+				}
+				for i, window := range parsed.Windows {
+					if window.HasChecksum != profile.Checksums {
+						t.Fatalf("seed %d: window %d checksum presence %v, expected %v", seed, i, window.HasChecksum, profile.Checksums)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeGenerated(b *testing.B) {
+	g := GenerateDelta(1, ProfileCopyHeavy)
+	b.SetBytes(int64(len(g.Target)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(g.Source, g.Delta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// ProfileManyWindows splits a small target across many windows, so that
+// per-window overhead dominates
+var ProfileManyWindows = DeltaProfile{
+	SourceSize: 1024, Windows: 256, MinWindowSize: 16, MaxWindowSize: 64, MaxInstSize: 16,
+	AddWeight: 1, CopyWeight: 4, RunWeight: 1,
+}
+
+func BenchmarkDecodeManyWindows(b *testing.B) {
+	g := GenerateDelta(1, ProfileManyWindows)
+	b.SetBytes(int64(len(g.Target)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(g.Source, g.Delta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
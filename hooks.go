@@ -0,0 +1,46 @@
+package vcdiff
+
+// Hooks are optional callbacks invoked at points in the decode lifecycle.
+// Any hook may be nil. A hook returning a non-nil error aborts decoding, and
+// that error is returned from Decode unchanged so callers can match it with
+// errors.Is.
+type Hooks struct {
+	// OnHeader is called with each delta's header before the source is
+	// checked or any window decodes, so the application header can identify
+	// the base. index counts deltas in concatenated mode and is otherwise 0.
+	// The header may be shared with a DeltaCache and must not be modified.
+	OnHeader func(index int, header *Header) error
+
+	// OnWindowStart is called before a window's instructions are parsed. Like
+	// the window passed to OnWindowEnd, it may be shared with a DeltaCache
+	// and must not be modified.
+	OnWindowStart func(index int, window *Window) error
+
+	// OnInstruction is called before each instruction executes. For COPY
+	// instructions Addr holds the decoded address. Data aliases the delta's
+	// data section and must not be modified.
+	OnInstruction func(index int, instruction RuntimeInstruction) error
+
+	// OnChecksum is called with the expected and computed checksums of a
+	// VCD_ADLER32 window, before a mismatch is reported. The computed value
+	// comes from the decoder's ChecksumValidator.
+	OnChecksum func(index int, expected, computed uint32) error
+
+	// OnUnusedSections is called after a window's instructions execute if
+	// they left data or address section bytes unused, which points at
+	// corruption or an encoder bug. data and addresses count the unused
+	// bytes; the instruction section is always read to its end, or fails to
+	// parse. WithStrict rejects such windows before they decode.
+	OnUnusedSections func(index int, data, addresses int) error
+
+	// OnWindowEnd is called with a window's reconstructed target once it has
+	// decoded successfully
+	OnWindowEnd func(index int, window *Window, target []byte) error
+}
+
+// WithHooks registers lifecycle callbacks on the decoder
+func WithHooks(hooks Hooks) DecoderOption {
+	return func(d *decoder) {
+		d.hooks = hooks
+	}
+}
@@ -0,0 +1,156 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestHooksLifecycle(t *testing.T) {
+	g := GenerateDelta(3, ProfileCopyHeavy)
+
+	var events []string
+	var windowTargets []byte
+	var windowSize uint32
+	hooks := Hooks{
+		OnWindowStart: func(index int, window *Window) error {
+			events = append(events, fmt.Sprintf("start %d", index))
+			windowSize = 0
+			return nil
+		},
+		OnInstruction: func(index int, instruction RuntimeInstruction) error {
+			if instruction.Type == Copy && instruction.Addr >= uint32(len(g.Source))+windowSize {
+				return fmt.Errorf("COPY address %d not yet decoded", instruction.Addr)
+			}
+			windowSize += instruction.Size
+			return nil
+		},
+		OnChecksum: func(index int, expected, computed uint32) error {
+			if expected != computed {
+				return fmt.Errorf("window %d checksum mismatch", index)
+			}
+			events = append(events, fmt.Sprintf("checksum %d", index))
+			return nil
+		},
+		OnWindowEnd: func(index int, window *Window, target []byte) error {
+			if uint32(len(target)) != windowSize {
+				return fmt.Errorf("window %d: instructions sized %d, target %d", index, windowSize, len(target))
+			}
+			events = append(events, fmt.Sprintf("end %d", index))
+			windowTargets = append(windowTargets, target...)
+			return nil
+		},
+	}
+
+	result, err := NewDecoder(g.Source, WithHooks(hooks)).Decode(g.Delta)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !bytes.Equal(result, g.Target) || !bytes.Equal(windowTargets, g.Target) {
+		t.Fatal("hooked decode produced a different target")
+	}
+
+	var want []string
+	for i := 0; i < ProfileCopyHeavy.Windows; i++ {
+		want = append(want, fmt.Sprintf("start %d", i), fmt.Sprintf("checksum %d", i), fmt.Sprintf("end %d", i))
+	}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Fatalf("got events %v, expected %v", events, want)
+	}
+}
+
+func TestHooksAbort(t *testing.T) {
+	errStop := errors.New("stop")
+	g := GenerateDelta(5, ProfileCopyHeavy)
+
+	tests := map[string]Hooks{
+		"header":       {OnHeader: func(int, *Header) error { return errStop }},
+		"window start": {OnWindowStart: func(int, *Window) error { return errStop }},
+		"instruction":  {OnInstruction: func(int, RuntimeInstruction) error { return errStop }},
+		"checksum":     {OnChecksum: func(int, uint32, uint32) error { return errStop }},
+		"window end":   {OnWindowEnd: func(int, *Window, []byte) error { return errStop }},
+	}
+
+	for name, hooks := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := NewDecoder(g.Source, WithHooks(hooks)).Decode(g.Delta)
+			if !errors.Is(err, errStop) {
+				t.Fatalf("got error %v, expected hook error", err)
+			}
+			if result != nil {
+				t.Fatal("aborted decode returned a target")
+			}
+		})
+	}
+}
+
+func TestHooksHeader(t *testing.T) {
+	source := []byte("base version 7")
+	encoded, err := Encode(source, []byte("base version 8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Header.Indicator |= VCDAppHeader
+	parsed.Header.AppHeader = []byte("base-id=7")
+	delta, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	hooks := Hooks{OnHeader: func(index int, header *Header) error {
+		seen = append(seen, fmt.Sprintf("%d:%s", index, header.AppHeader))
+		return nil
+	}}
+	if _, err := NewDecoder(source, WithHooks(hooks), WithConcatenated()).Decode(append(delta, delta...)); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if want := "[0:base-id=7 1:base-id=7]"; fmt.Sprint(seen) != want {
+		t.Fatalf("got headers %v, expected %s", seen, want)
+	}
+}
+
+func TestHooksUnusedSections(t *testing.T) {
+	source := []byte("0123456789")
+	copyAdd := []RuntimeInstruction{{Type: Copy, Size: 4, Addr: 2}, {Type: Add, Size: 2, Data: []byte("ab")}}
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(len(source))}
+
+	tests := []struct {
+		name            string
+		edit            func(*Window)
+		data, addresses int
+	}{
+		{"clean", nil, 0, 0},
+		{"data", func(w *Window) { w.DataSection = append(w.DataSection, 'x', 'y') }, 2, 0},
+		{"addresses", func(w *Window) { w.AddressSection = append(w.AddressSection, 0) }, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := marshalWindow(t, window, copyAdd, tt.edit)
+			calls := 0
+			var data, addresses int
+			hooks := Hooks{OnUnusedSections: func(_ int, d, a int) error {
+				calls++
+				data, addresses = d, a
+				return nil
+			}}
+			target, err := NewDecoder(source, WithHooks(hooks)).Decode(delta)
+			if err != nil || string(target) != "2345ab" {
+				t.Fatalf("got %q, %v", target, err)
+			}
+			wantCalls := 1
+			if tt.data == 0 && tt.addresses == 0 {
+				wantCalls = 0
+			}
+			if calls != wantCalls || data != tt.data || addresses != tt.addresses {
+				t.Fatalf("got %d calls with %d data and %d address bytes, expected %d data and %d address bytes",
+					calls, data, addresses, tt.data, tt.addresses)
+			}
+		})
+	}
+}
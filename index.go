@@ -0,0 +1,71 @@
+package vcdiff
+
+import (
+	"bytes"
+	"io"
+)
+
+// WindowRange locates one window and its sections within a delta. Offsets
+// are absolute byte offsets into the delta.
+type WindowRange struct {
+	Offset             uint64 // Start of the window, at its Win_Indicator
+	Length             uint64 // Length of the whole window
+	DataOffset         uint64 // Start of the data section
+	DataLength         uint32 // Length of the data section
+	InstructionsOffset uint64 // Start of the instructions section
+	InstructionsLength uint32 // Length of the instructions section
+	AddressesOffset    uint64 // Start of the addresses section
+	AddressesLength    uint32 // Length of the addresses section
+	TargetWindowLength uint32 // Length of the target this window reconstructs
+	TargetWindowOffset uint64 // Offset of that target in the full target
+}
+
+// IndexWindows reads the header and window framing of a delta, without
+// parsing instructions, and returns where each window lies in it. A window's
+// bytes are delta[r.Offset : r.Offset+r.Length], which lets tools split
+// deltas, resume downloads at window boundaries or map single windows.
+func IndexWindows(delta []byte) ([]WindowRange, error) {
+	if len(delta) < MinimumFileSize {
+		return nil, errShortDelta(len(delta))
+	}
+
+	reader := bytes.NewReader(delta)
+	var header Header
+	if err := parseHeader(reader, &header); err != nil {
+		return nil, err
+	}
+
+	var ranges []WindowRange
+	var targetOffset uint64
+	for reader.Len() > 0 {
+		start := uint64(len(delta) - reader.Len())
+		var window Window
+		if err := parseWindow(reader, &window, &header, len(ranges)); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		end := uint64(len(delta) - reader.Len())
+
+		// The sections are the last bytes of the window, in this order
+		addresses := end - uint64(window.AddressSectionLength)
+		instructions := addresses - uint64(window.InstructionSectionLength)
+		data := instructions - uint64(window.DataSectionLength)
+		ranges = append(ranges, WindowRange{
+			Offset:             start,
+			Length:             end - start,
+			DataOffset:         data,
+			DataLength:         window.DataSectionLength,
+			InstructionsOffset: instructions,
+			InstructionsLength: window.InstructionSectionLength,
+			AddressesOffset:    addresses,
+			AddressesLength:    window.AddressSectionLength,
+			TargetWindowLength: window.TargetWindowLength,
+			TargetWindowOffset: targetOffset,
+		})
+		targetOffset += uint64(window.TargetWindowLength)
+	}
+
+	return ranges, nil
+}
@@ -0,0 +1,65 @@
+package vcdiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIndexWindows(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileManyWindows} {
+		for seed := int64(0); seed < 10; seed++ {
+			g := GenerateDelta(seed, profile)
+			delta := withHeaderSection(g.Delta, VCDAppHeader, []byte("app"))
+
+			ranges, err := IndexWindows(delta)
+			if err != nil {
+				t.Fatalf("seed %d: IndexWindows failed: %v", seed, err)
+			}
+			parsed, err := ParseDelta(delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ranges) != len(parsed.Windows) {
+				t.Fatalf("seed %d: got %d ranges, expected %d", seed, len(ranges), len(parsed.Windows))
+			}
+
+			var targetOffset uint64
+			for i, r := range ranges {
+				window := &parsed.Windows[i]
+				if i > 0 && r.Offset != ranges[i-1].Offset+ranges[i-1].Length {
+					t.Fatalf("seed %d: window %d does not follow window %d", seed, i, i-1)
+				}
+				sections := []struct {
+					name   string
+					offset uint64
+					length uint32
+					want   []byte
+				}{
+					{"data", r.DataOffset, r.DataLength, window.DataSection},
+					{"instructions", r.InstructionsOffset, r.InstructionsLength, window.InstructionSection},
+					{"addresses", r.AddressesOffset, r.AddressesLength, window.AddressSection},
+				}
+				for _, s := range sections {
+					if got := delta[s.offset : s.offset+uint64(s.length)]; !bytes.Equal(got, s.want) {
+						t.Fatalf("seed %d: window %d %s section range is wrong", seed, i, s.name)
+					}
+				}
+				if r.TargetWindowOffset != targetOffset || r.TargetWindowLength != window.TargetWindowLength {
+					t.Fatalf("seed %d: window %d target range %d+%d is wrong", seed, i, r.TargetWindowOffset, r.TargetWindowLength)
+				}
+				targetOffset += uint64(r.TargetWindowLength)
+
+				// Each window's bytes stand alone after the header
+				header := delta[:ranges[0].Offset]
+				single := append(append([]byte{}, header...), delta[r.Offset:r.Offset+r.Length]...)
+				if _, err := ParseDelta(single); err != nil {
+					t.Fatalf("seed %d: window %d does not parse on its own: %v", seed, i, err)
+				}
+			}
+			last := ranges[len(ranges)-1]
+			if last.Offset+last.Length != uint64(len(delta)) {
+				t.Fatalf("seed %d: windows end at %d, delta has %d bytes", seed, last.Offset+last.Length, len(delta))
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// deinterleave separates a window in open-vcdiff's interleaved layout into
+// the three sections of RFC 3284 Section 4.3. In that layout the data and
+// address sections are empty, and each instruction's size, ADD or RUN data
+// and COPY address follow its code in the instruction section, in that
+// order; the second instruction of a paired code follows the first one's
+// data or address. Every byte is moved unchanged, so the window then decodes,
+// edits and marshals like any other.
+func deinterleave(window *Window) error {
+	table := window.codeTable()
+	nearSize, _ := window.cacheSizes(NearCacheSize, SameCacheModes)
+	section := window.InstructionSection
+	stream := bytes.NewReader(section)
+	position := func() int { return len(section) - stream.Len() }
+
+	var data, instructions, addresses []byte
+	for stream.Len() > 0 {
+		offset := position()
+		code, _ := stream.ReadByte()
+		instructions = append(instructions, code)
+
+		for slot := 0; slot < 2; slot++ {
+			instruction := table.Get(code, slot)
+			if instruction.Type == NoOp {
+				continue
+			}
+
+			size := uint32(instruction.Size)
+			if size == 0 {
+				start := position()
+				var err error
+				if size, err = ReadVarint(stream); err != nil {
+					return fmt.Errorf("error reading size for %s instruction at offset %d: %w", instruction.Type, offset, err)
+				}
+				instructions = append(instructions, section[start:position()]...)
+			}
+
+			switch instruction.Type {
+			case Add, Run:
+				if instruction.Type == Run {
+					size = 1
+				}
+				if uint32(stream.Len()) < size {
+					return errDataOverrun(instruction.Type.String(), offset, int(size), stream.Len())
+				}
+				start := position()
+				data = append(data, section[start:start+int(size)]...)
+				stream.Seek(int64(size), io.SeekCurrent)
+
+			case Copy:
+				start := position()
+				if int(instruction.Mode) < fixedAddressModes+nearSize {
+					if _, err := ReadVarint(stream); err != nil {
+						return fmt.Errorf("error reading address for COPY instruction at offset %d: %w", offset, err)
+					}
+				} else if _, err := stream.ReadByte(); err != nil {
+					return errDataOverrun("COPY", offset, 1, 0)
+				}
+				addresses = append(addresses, section[start:position()]...)
+			}
+		}
+	}
+
+	window.DataSection, window.InstructionSection, window.AddressSection = data, instructions, addresses
+	window.Interleaved = true
+	return nil
+}
+
+// interleave is the inverse of deinterleave: it moves each instruction's ADD
+// or RUN data and COPY address into the instruction section after its code
+// and size, leaving the data and address sections empty. Only the
+// serialized form changes, so the result must be written with version 'S'.
+func interleave(window *Window) error {
+	instructions := bytes.NewReader(window.InstructionSection)
+	addresses := bytes.NewReader(window.AddressSection)
+	data := window.DataSection
+
+	var section []byte
+	for instructions.Len() > 0 {
+		offset := len(window.InstructionSection) - instructions.Len()
+		code, _ := instructions.ReadByte()
+		section = append(section, code)
+
+		for slot := 0; slot < 2; slot++ {
+			instruction := DefaultCodeTable.Get(code, slot)
+			if instruction.Type == NoOp {
+				continue
+			}
+
+			size := uint32(instruction.Size)
+			if size == 0 {
+				start := len(window.InstructionSection) - instructions.Len()
+				var err error
+				if size, err = ReadVarint(instructions); err != nil {
+					return fmt.Errorf("error reading size for %s instruction at offset %d: %w", instruction.Type, offset, err)
+				}
+				section = append(section, window.InstructionSection[start:len(window.InstructionSection)-instructions.Len()]...)
+			}
+
+			switch instruction.Type {
+			case Add, Run:
+				if instruction.Type == Run {
+					size = 1
+				}
+				if uint32(len(data)) < size {
+					return errDataOverrun(instruction.Type.String(), offset, int(size), len(data))
+				}
+				section = append(section, data[:size]...)
+				data = data[size:]
+
+			case Copy:
+				start := len(window.AddressSection) - addresses.Len()
+				if int(instruction.Mode) < fixedAddressModes+NearCacheSize {
+					if _, err := ReadVarint(addresses); err != nil {
+						return fmt.Errorf("error reading address for COPY instruction at offset %d: %w", offset, err)
+					}
+				} else if _, err := addresses.ReadByte(); err != nil {
+					return errDataOverrun("COPY", offset, 1, 0)
+				}
+				section = append(section, window.AddressSection[start:len(window.AddressSection)-addresses.Len()]...)
+			}
+		}
+	}
+
+	window.DataSection, window.InstructionSection, window.AddressSection = nil, section, nil
+	return nil
+}
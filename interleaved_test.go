@@ -0,0 +1,225 @@
+package vcdiff
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// sdchDelta is laid out as open-vcdiff writes with its interleaved and
+// checksum format flags: version 'S', empty data and address sections, and
+// the checksum as a varint
+var sdchDelta = []byte{
+	0xD6, 0xC3, 0xC4, SDCHVersion, 0x00,
+	VCDSource | VCDAdler32, 0x08, 0x00, // Source segment of 8 bytes at 0
+	0x11,                         // Delta encoding length
+	0x09, 0x00, 0x00, 0x07, 0x00, // Target 9 bytes; data 0, instructions 7, addresses 0
+	0x81, 0x89, 0xDC, 0x87, 0x2A, // Adler-32 0x113703aa as a varint
+	166, 'X', 'Y', 0x00, // ADD 2 + COPY 4 in SELF mode, then the ADD data and COPY address
+	0x00, 0x03, 'z', // RUN, size 3, then its byte
+}
+
+func TestDecodeInterleaved(t *testing.T) {
+	source := []byte("abcdefgh")
+	want := []byte("XYabcdzzz")
+
+	target, err := Decode(source, sdchDelta)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !bytes.Equal(target, want) {
+		t.Fatalf("got %q, expected %q", target, want)
+	}
+
+	streamed, err := io.ReadAll(NewReader(source, bytes.NewReader(sdchDelta)))
+	if err != nil || !bytes.Equal(streamed, want) {
+		t.Fatalf("streaming decode got %q, %v", streamed, err)
+	}
+
+	parsed, err := ParseDelta(sdchDelta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := parsed.Windows[0]
+	if !window.Interleaved || window.Checksum != 0x113703aa {
+		t.Fatalf("got interleaved %v checksum 0x%08x", window.Interleaved, window.Checksum)
+	}
+	if string(window.DataSection) != "XYz" || len(window.AddressSection) != 1 || len(window.InstructionSection) != 3 {
+		t.Fatalf("sections not separated: data %q, instructions %x, addresses %x",
+			window.DataSection, window.InstructionSection, window.AddressSection)
+	}
+
+	// Marshalling writes the standard layout, which decodes the same
+	marshalled, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, err := Decode(source, marshalled); err != nil || !bytes.Equal(target, want) {
+		t.Fatalf("marshalled delta decoded to %q, %v", target, err)
+	}
+}
+
+func TestDecodeInterleavedTruncated(t *testing.T) {
+	source := []byte("abcdefgh")
+	for _, n := range []int{1, 2, 4} {
+		delta := append([]byte{}, sdchDelta[:len(sdchDelta)-n]...)
+		delta[8] -= byte(n)
+		delta[12] -= byte(n)
+		if _, err := Decode(source, delta); err == nil {
+			t.Errorf("decoding with %d bytes cut from the instruction section succeeded", n)
+		}
+	}
+}
+
+func TestDecodeInterleavedEncoded(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	source := make([]byte, 4096)
+	rng.Read(source)
+	// Five distant COPYs push the first address out of the near cache, so
+	// repeating it uses a one-byte same cache address
+	var target []byte
+	for i, start := range []int{1000, 2000, 3000, 200, 3500, 1000} {
+		target = append(target, source[start:start+100]...)
+		target = append(target, 'a'+byte(i))
+	}
+	target = append(target, bytes.Repeat([]byte{'-'}, 40)...)
+
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interleaved := interleaveDelta(t, source, delta)
+	if bytes.Equal(interleaved, delta) {
+		t.Fatal("interleaving left the delta unchanged")
+	}
+	got, err := Decode(source, interleaved)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatal("interleaved delta decoded to a different target")
+	}
+}
+
+func TestDecodeInterleavedCacheSizes(t *testing.T) {
+	// The second COPY is in mode 3 with the address byte 200. With one near
+	// slot and seven same cache modes, mode 3 is the first same cache mode,
+	// whose address is that one byte. With the default four near slots it
+	// is a near slot, whose varint address would run on into the ADD after it.
+	const addr = 7*256 + 200
+	source := bytes.Repeat([]byte{'.'}, addr+4)
+	copy(source[addr:], "wxyz")
+	target := []byte("wxyzwxyz!")
+
+	section := AppendVarint([]byte{copyCode, 4}, addr)
+	section = append(section, copyCode+3*genCopyCodesPerMode, 4, 200, addCode, 1, '!')
+	encoding := AppendVarint(nil, uint32(len(target)))
+	encoding = append(encoding, 0, 0)
+	encoding = AppendVarint(encoding, uint32(len(section)))
+	encoding = append(encoding, 0)
+	encoding = append(encoding, section...)
+	delta := []byte{VCDIFFMagic1, VCDIFFMagic2, VCDIFFMagic3, SDCHVersion, 0, VCDSource}
+	delta = AppendVarint(delta, uint32(len(source)))
+	delta = AppendVarint(delta, 0)
+	delta = AppendVarint(delta, uint32(len(encoding)))
+	delta = append(delta, encoding...)
+
+	// The sections are split differently for each cache size, so a shared
+	// delta cache must not hand one decoder the other's windows
+	cache := NewDeltaCache(2)
+	result, err := NewDecoder(source, WithCacheSizes(1, 7), WithDeltaCache(cache)).Decode(delta)
+	if err != nil {
+		t.Fatalf("decode with near=1 same=7 failed: %v", err)
+	}
+	if !bytes.Equal(result, target) {
+		t.Errorf("got %q, expected %q", result, target)
+	}
+	if result, err := NewDecoder(source, WithDeltaCache(cache)).Decode(delta); err == nil && bytes.Equal(result, target) {
+		t.Error("delta decoded with the default cache sizes")
+	}
+}
+
+// interleaveDelta rewrites a standard delta in open-vcdiff's interleaved
+// layout, with a varint checksum on every window
+func interleaveDelta(t *testing.T, source, delta []byte) []byte {
+	t.Helper()
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := appendHeader(nil, &parsed.Header)
+	out[3] = SDCHVersion
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		window := parsed.Windows[i]
+		decoded, err := (&decoder{checksum: Adler32{}}).decodeWindow(i, &window, source, addressCache)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := interleave(&window); err != nil {
+			t.Fatal(err)
+		}
+		section := window.InstructionSection
+
+		encoding := AppendVarint(nil, window.TargetWindowLength)
+		encoding = append(encoding, 0, 0)
+		encoding = AppendVarint(encoding, uint32(len(section)))
+		encoding = append(encoding, 0)
+		encoding = AppendVarint(encoding, Adler32{}.Checksum(&window, decoded))
+		encoding = append(encoding, section...)
+
+		out = append(out, window.WinIndicator|VCDAdler32)
+		if window.WinIndicator&VCDSource != 0 {
+			out = AppendVarint(out, window.SourceSegmentSize)
+			out = AppendVarint(out, window.SourceSegmentPosition)
+		}
+		out = AppendVarint(out, uint32(len(encoding)))
+		out = append(out, encoding...)
+	}
+	return out
+}
+
+func TestEncodeInterleaved(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	source := make([]byte, 4096)
+	rng.Read(source)
+	var target []byte
+	for i, start := range []int{1000, 2000, 3000, 200, 3500, 1000} {
+		target = append(target, source[start:start+100]...)
+		target = append(target, 'a'+byte(i))
+	}
+	target = append(target, bytes.Repeat([]byte{'-'}, 40)...)
+
+	delta, err := Encode(source, target, WithInterleaved())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := IndexWindows(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta[3] != SDCHVersion || len(ranges) != 1 || ranges[0].DataLength != 0 || ranges[0].AddressesLength != 0 {
+		t.Fatalf("got version 0x%02x and windows %+v, expected one interleaved window", delta[3], ranges)
+	}
+	got, err := Decode(source, delta)
+	if err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("interleaved delta decoded to %d bytes, %v", len(got), err)
+	}
+
+	// Deinterleaving restores the sections of the standard encoding
+	standard, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Header.Version = VCDIFFVersion
+	remarshalled, err := MarshalDelta(parsed)
+	if err != nil || !bytes.Equal(remarshalled, standard) {
+		t.Fatalf("deinterleaved delta differs from the standard encoding: %v", err)
+	}
+}
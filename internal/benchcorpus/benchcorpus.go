@@ -0,0 +1,319 @@
+// Package benchcorpus downloads and caches the standard compression corpora
+// used by the corpus benchmarks, so that performance numbers are comparable
+// across machines and pull requests.
+package benchcorpus
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Archive formats understood by Fetch
+const (
+	FormatZip   = "zip"    // Zip archive of corpus files
+	FormatTarGz = "tar.gz" // Gzipped tar archive of corpus files
+	FormatGz    = "gz"     // Single gzipped file, stored decompressed
+)
+
+// Mutation pattern for pairs synthesized from single-file corpora
+const (
+	mutationSeed     = 3284      // Fixed seed so every machine sees the same source
+	mutationInterval = 64 * 1024 // Bytes between edits
+	mutationLength   = 16        // Bytes overwritten per edit
+)
+
+// EnvDir overrides the default cache directory
+const EnvDir = "VCDIFF_CORPUS_DIR"
+
+// ErrChecksumMismatch is returned when a download does not match its pinned
+// checksum, or when an archive has none to check it against
+var ErrChecksumMismatch = errors.New("corpus checksum mismatch")
+
+// Archive is a single downloadable corpus file
+type Archive struct {
+	Name   string // Cache file name
+	URL    string // Download location
+	Format string // One of the Format constants
+	SHA256 string // Expected hex digest; archives without one are not fetched
+}
+
+// Corpus is a named set of archives used to build benchmark pairs
+type Corpus struct {
+	Name     string
+	Archives []Archive
+	// Versioned corpora pair consecutive archives (old, new); others pair
+	// each file with a deterministically mutated copy of itself
+	Versioned bool
+}
+
+// Pair is a source/target file pair to benchmark
+type Pair struct {
+	Name   string
+	Source string
+	Target string
+	mutate bool
+}
+
+// Standard lists the corpora known to the benchmarks. A corpus is listed
+// only once the SHA256 of each of its archives is pinned, taken from a digest
+// its publisher signs or from a download checked against one. None are yet.
+var Standard []Corpus
+
+// Dir returns the corpus cache directory, creating it if necessary
+func Dir() (string, error) {
+	dir := os.Getenv(EnvDir)
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cache, "vcdiff-go", "corpus")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Fetch downloads, verifies and unpacks every archive of the corpus into dir,
+// skipping archives that are already present
+func Fetch(ctx context.Context, c Corpus, dir string) error {
+	for _, archive := range c.Archives {
+		if err := fetchArchive(ctx, archive, filepath.Join(dir, c.Name)); err != nil {
+			return fmt.Errorf("corpus %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Present reports whether the corpus has been fetched into dir
+func Present(c Corpus, dir string) bool {
+	for _, archive := range c.Archives {
+		if _, err := os.Stat(unpackedPath(archive, filepath.Join(dir, c.Name))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Pairs returns the benchmark pairs of a fetched corpus
+func Pairs(c Corpus, dir string) ([]Pair, error) {
+	corpusDir := filepath.Join(dir, c.Name)
+
+	if c.Versioned {
+		var pairs []Pair
+		for i := 1; i < len(c.Archives); i++ {
+			pairs = append(pairs, Pair{
+				Name:   c.Name + "/" + c.Archives[i].Name,
+				Source: unpackedPath(c.Archives[i-1], corpusDir),
+				Target: unpackedPath(c.Archives[i], corpusDir),
+			})
+		}
+		return pairs, nil
+	}
+
+	var pairs []Pair
+	for _, archive := range c.Archives {
+		root := unpackedPath(archive, corpusDir)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			pairs = append(pairs, Pair{
+				Name:   c.Name + "/" + filepath.ToSlash(rel),
+				Source: path,
+				Target: path,
+				mutate: true,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs, nil
+}
+
+// Load reads the pair's source and target. Pairs built from a single file
+// use a deterministically mutated copy of it as the source.
+func (p Pair) Load() (source, target []byte, err error) {
+	target, err = os.ReadFile(p.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !p.mutate {
+		source, err = os.ReadFile(p.Source)
+		return source, target, err
+	}
+
+	source = append([]byte(nil), target...)
+	rng := rand.New(rand.NewSource(mutationSeed))
+	for offset := mutationInterval / 2; offset+mutationLength <= len(source); offset += mutationInterval {
+		rng.Read(source[offset : offset+mutationLength])
+	}
+	return source, target, nil
+}
+
+// unpackedPath is where an archive's contents live once fetched
+func unpackedPath(archive Archive, corpusDir string) string {
+	if archive.Format == FormatGz {
+		return filepath.Join(corpusDir, archive.Name)
+	}
+	return filepath.Join(corpusDir, strings.TrimSuffix(archive.Name, "."+archive.Format))
+}
+
+func fetchArchive(ctx context.Context, archive Archive, corpusDir string) error {
+	dest := unpackedPath(archive, corpusDir)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+	if archive.SHA256 == "" {
+		return fmt.Errorf("%w: %s has no pinned sha256, so it is not fetched", ErrChecksumMismatch, archive.Name)
+	}
+	if err := os.MkdirAll(corpusDir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := download(ctx, archive.URL)
+	if err != nil {
+		return err
+	}
+	if err := verify(archive, data); err != nil {
+		return err
+	}
+
+	// Unpack into a temporary location so an interrupted fetch is retried
+	tmp := dest + ".partial"
+	os.RemoveAll(tmp)
+	switch archive.Format {
+	case FormatZip:
+		err = unzip(data, tmp)
+	case FormatTarGz:
+		err = untar(data, tmp)
+	case FormatGz:
+		err = gunzip(data, tmp)
+	default:
+		err = fmt.Errorf("unknown archive format %q", archive.Format)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("error unpacking %s: %w", archive.Name, err)
+	}
+	return os.Rename(tmp, dest)
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verify checks the archive digest against the manifest
+func verify(archive Archive, data []byte) error {
+	sum := sha256.Sum256(data)
+	got := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(got, archive.SHA256) {
+		return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, archive.Name, got, archive.SHA256)
+	}
+	return nil
+}
+
+func unzip(data []byte, dest string) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(dest, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func untar(data []byte, dest string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeFile(dest, hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func gunzip(data []byte, dest string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Dir(dest), filepath.Base(dest), gz)
+}
+
+// writeFile writes r to name under dir, refusing paths that escape dir
+func writeFile(dir, name string, r io.Reader) error {
+	path := filepath.Join(dir, name)
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+		return fmt.Errorf("archive entry %q escapes destination", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
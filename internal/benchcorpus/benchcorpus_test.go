@@ -0,0 +1,194 @@
+package benchcorpus
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// pinned returns archive with the digest of data
+func pinned(archive Archive, data []byte) Archive {
+	sum := sha256.Sum256(data)
+	archive.SHA256 = hex.EncodeToString(sum[:])
+	return archive
+}
+
+func serve(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchAndPairs(t *testing.T) {
+	small := tarGz(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"})
+	extra := zipped(t, map[string]string{"c.txt": "charlie"})
+	srv := serve(t, map[string][]byte{"/small.tar.gz": small, "/extra.zip": extra})
+	c := Corpus{
+		Name: "small",
+		Archives: []Archive{
+			pinned(Archive{Name: "small.tar.gz", URL: srv.URL + "/small.tar.gz", Format: FormatTarGz}, small),
+			pinned(Archive{Name: "extra.zip", URL: srv.URL + "/extra.zip", Format: FormatZip}, extra),
+		},
+	}
+	dir := t.TempDir()
+
+	if Present(c, dir) {
+		t.Fatal("corpus reported present before fetching")
+	}
+	if err := Fetch(context.Background(), c, dir); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if !Present(c, dir) {
+		t.Fatal("corpus not present after fetching")
+	}
+
+	pairs, err := Pairs(c, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range pairs {
+		names = append(names, p.Name)
+	}
+	want := []string{"small/a.txt", "small/c.txt", "small/sub/b.txt"}
+	if len(names) != len(want) {
+		t.Fatalf("got pairs %v, expected %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got pairs %v, expected %v", names, want)
+		}
+	}
+
+	source, target, err := pairs[0].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(target) != "alpha" || len(source) != len(target) {
+		t.Fatalf("unexpected pair contents %q / %q", source, target)
+	}
+}
+
+func TestFetchVersionedPairs(t *testing.T) {
+	gz := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(s))
+		w.Close()
+		return buf.Bytes()
+	}
+	v1, v2 := gz("version one"), gz("version two")
+	srv := serve(t, map[string][]byte{"/v1.gz": v1, "/v2.gz": v2})
+	c := Corpus{
+		Name: "versions",
+		Archives: []Archive{
+			pinned(Archive{Name: "v1", URL: srv.URL + "/v1.gz", Format: FormatGz}, v1),
+			pinned(Archive{Name: "v2", URL: srv.URL + "/v2.gz", Format: FormatGz}, v2),
+		},
+		Versioned: true,
+	}
+	dir := t.TempDir()
+	if err := Fetch(context.Background(), c, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := Pairs(c, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 {
+		t.Fatalf("got %d pairs, expected 1", len(pairs))
+	}
+	source, target, err := pairs[0].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(source) != "version one" || string(target) != "version two" {
+		t.Fatalf("unexpected pair contents %q / %q", source, target)
+	}
+}
+
+func TestFetchVerifiesChecksums(t *testing.T) {
+	data := tarGz(t, map[string]string{"a": "one"})
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	archive := Archive{Name: "data.tar.gz", URL: srv.URL + "/data.tar.gz", Format: FormatTarGz}
+
+	t.Run("manifest mismatch", func(t *testing.T) {
+		a := archive
+		a.SHA256 = "00"
+		err := Fetch(context.Background(), Corpus{Name: "c", Archives: []Archive{a}}, t.TempDir())
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("no digest", func(t *testing.T) {
+		requests = 0
+		dir := t.TempDir()
+		err := Fetch(context.Background(), Corpus{Name: "c", Archives: []Archive{archive}}, dir)
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+		}
+		if requests != 0 {
+			t.Fatal("downloaded an archive without a digest")
+		}
+	})
+}
+
+func TestWriteFileRejectsEscapingPaths(t *testing.T) {
+	if err := writeFile(t.TempDir(), "../escape", bytes.NewReader(nil)); err == nil {
+		t.Fatal("expected error for path escaping the destination")
+	}
+}
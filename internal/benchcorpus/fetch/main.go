@@ -0,0 +1,41 @@
+// Command fetch downloads the benchmark corpora into the local cache.
+//
+//	go run ./internal/benchcorpus/fetch [corpus...]
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ably/vcdiff-go/internal/benchcorpus"
+)
+
+func main() {
+	dir, err := benchcorpus.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(benchcorpus.Standard) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no corpora have pinned digests to fetch against")
+		os.Exit(1)
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range os.Args[1:] {
+		wanted[name] = true
+	}
+
+	for _, c := range benchcorpus.Standard {
+		if len(wanted) > 0 && !wanted[c.Name] {
+			continue
+		}
+		fmt.Printf("Fetching %s into %s\n", c.Name, dir)
+		if err := benchcorpus.Fetch(context.Background(), c, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
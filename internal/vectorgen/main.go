@@ -0,0 +1,158 @@
+// Command vectorgen runs another VCDIFF decoder over the hand-assembled
+// vectors in testdata/vectors and records what it decoded, or that it
+// rejected the delta, as vectors of its own.
+//
+//	go run ./internal/vectorgen -version VERSION -- COMMAND [ARG...]
+//
+// In the arguments, {source} and {delta} are replaced by files holding the
+// vector's source and delta. The command must write the target to stdout,
+// and exit non-zero if it rejects the delta. For xdelta3:
+//
+//	go run ./internal/vectorgen -version "$(xdelta3 -V 2>&1 | head -1)" -- xdelta3 -d -c -s {source} {delta}
+//
+// Each result is written next to its input as <name>.<generator>.json, with
+// the generator, its version and the command recorded.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// vector is the exchange format of testdata/vectors
+type vector struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	Generator        string `json:"generator"`
+	GeneratorVersion string `json:"generator_version,omitempty"`
+	GeneratorCommand string `json:"generator_command,omitempty"`
+	SourceHex        string `json:"source_hex"`
+	DeltaHex         string `json:"delta_hex"`
+	TargetHex        string `json:"target_hex"`
+	ExpectError      bool   `json:"expect_error,omitempty"`
+	ErrorCode        string `json:"error_code,omitempty"`
+}
+
+func main() {
+	dir := flag.String("dir", filepath.Join("testdata", "vectors"), "Directory of the vectors")
+	generator := flag.String("generator", "", "Name recorded for the decoder (default: the command's base name)")
+	version := flag.String("version", "", "Version of the decoder, recorded in each vector (required)")
+	flag.Parse()
+
+	if *version == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: vectorgen -version VERSION [-generator NAME] [-dir DIR] -- COMMAND [ARG...]")
+		os.Exit(2)
+	}
+	if *generator == "" {
+		*generator = filepath.Base(flag.Arg(0))
+	}
+	if err := run(*dir, *generator, *version, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run decodes every hand-assembled vector in dir with command and writes
+// the results
+func run(dir, generator, version string, command []string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "vectorgen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var in vector
+		if err := json.Unmarshal(data, &in); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		// Only vectors assembled by hand are inputs, not earlier results
+		if in.GeneratorCommand != "" {
+			continue
+		}
+
+		out, err := decode(tmp, in, command)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		out.Name = in.Name + "-" + generator
+		out.Description = in.Description
+		out.Generator = generator
+		out.GeneratorVersion = version
+		out.GeneratorCommand = strings.Join(command, " ")
+		out.SourceHex, out.DeltaHex = in.SourceHex, in.DeltaHex
+
+		data, err = json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json") + "." + generator + ".json"
+		if err := os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", name, outcome(out))
+	}
+	return nil
+}
+
+// decode runs command on the vector's source and delta, returning its
+// target, or ExpectError if it exits non-zero
+func decode(tmp string, in vector, command []string) (vector, error) {
+	files := map[string]string{"{source}": in.SourceHex, "{delta}": in.DeltaHex}
+	args := make([]string, len(command))
+	for i, arg := range command {
+		for placeholder, hexData := range files {
+			if !strings.Contains(arg, placeholder) {
+				continue
+			}
+			data, err := hex.DecodeString(hexData)
+			if err != nil {
+				return vector{}, err
+			}
+			file := filepath.Join(tmp, strings.Trim(placeholder, "{}"))
+			if err := os.WriteFile(file, data, 0o644); err != nil {
+				return vector{}, err
+			}
+			arg = strings.ReplaceAll(arg, placeholder, file)
+		}
+		args[i] = arg
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return vector{ExpectError: true}, nil
+	case err != nil:
+		return vector{}, err
+	}
+	return vector{TargetHex: hex.EncodeToString(stdout.Bytes())}, nil
+}
+
+// outcome summarises a result for the progress output
+func outcome(v vector) string {
+	if v.ExpectError {
+		return "rejected"
+	}
+	return fmt.Sprintf("decoded %d bytes", len(v.TargetHex)/2)
+}
@@ -0,0 +1,216 @@
+//go:build interop
+
+package vcdiff
+
+// Compatibility tests against other VCDIFF implementations. They run only
+// with the interop build tag, and only against the tools that are found:
+//
+//	go test -tags interop -run Interop .
+//
+// xdelta3 is looked up on PATH. open-vcdiff's command line tool is also
+// called vcdiff, like this package's, so it is only used when OPEN_VCDIFF
+// names it.
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// interopTool drives another implementation through its command line
+type interopTool struct {
+	name        string
+	path        string
+	encoders    map[string][]string // Encoder flags by variant name, placed before the file arguments
+	encode      func(flags []string, source, target, delta string) []string
+	decode      func(source, delta, target string) []string
+	interleaved bool // Decodes open-vcdiff's version 'S' deltas
+	checksums   bool // Verifies 4-byte Adler-32 checksums in version 0 deltas
+}
+
+func interopTools(t *testing.T) []interopTool {
+	var tools []interopTool
+	if path, err := exec.LookPath("xdelta3"); err == nil {
+		tools = append(tools, interopTool{
+			name: "xdelta3",
+			path: path,
+			encoders: map[string][]string{
+				"default":  nil,
+				"level 9":  {"-9"},
+				"no adler": {"-n"},
+			},
+			// Plain VCDIFF without secondary compression or an application header
+			encode: func(flags []string, source, target, delta string) []string {
+				return append(append([]string{"-e", "-S", "-A", "-f"}, flags...), "-s", source, target, delta)
+			},
+			decode: func(source, delta, target string) []string {
+				return []string{"-d", "-f", "-s", source, delta, target}
+			},
+			checksums: true,
+		})
+	}
+	if path := os.Getenv("OPEN_VCDIFF"); path != "" {
+		tools = append(tools, interopTool{
+			name: "open-vcdiff",
+			path: path,
+			encoders: map[string][]string{
+				"default":                    nil,
+				"interleaved with checksums": {"-interleaved", "-checksum"},
+				"no target matches":          {"-target_matches=false"},
+			},
+			encode: func(flags []string, source, target, delta string) []string {
+				return append([]string{"encode", "-dictionary", source, "-target", target, "-delta", delta}, flags...)
+			},
+			decode: func(source, delta, target string) []string {
+				return []string{"decode", "-dictionary", source, "-delta", delta, "-target", target}
+			},
+			interleaved: true,
+		})
+	}
+	if len(tools) == 0 {
+		t.Skip("no other implementation found: install xdelta3 or set OPEN_VCDIFF")
+	}
+	return tools
+}
+
+// interopCase is a source and target pair for both directions
+type interopCase struct {
+	name           string
+	source, target []byte
+}
+
+func interopCases() []interopCase {
+	rng := rand.New(rand.NewSource(21))
+	large := make([]byte, 3<<20)
+	rng.Read(large)
+	edited := append([]byte{}, large...)
+	for i := 0; i < 200; i++ {
+		edited[rng.Intn(len(edited))] ^= 0xff
+	}
+	edited = append(edited[:1<<20], append(bytes.Repeat([]byte{0}, 5000), edited[1<<20:]...)...)
+
+	cases := []interopCase{
+		{"empty target", []byte("some source"), nil},
+		{"empty source", nil, bytes.Repeat([]byte("no source at all, "), 100)},
+		{"identical", large[:1<<16], large[:1<<16]},
+		{"edited large", large, edited},
+	}
+	for _, profile := range []struct {
+		name    string
+		profile DeltaProfile
+	}{
+		{"small", ProfileSmall}, {"copy heavy", ProfileCopyHeavy}, {"add heavy", ProfileAddHeavy}, {"many windows", ProfileManyWindows},
+	} {
+		for seed := int64(0); seed < 3; seed++ {
+			g := GenerateDelta(seed, profile.profile)
+			cases = append(cases, interopCase{profile.name, g.Source, g.Target})
+		}
+	}
+	return cases
+}
+
+// runTool runs a tool, failing the test with its output if it fails
+func runTool(t *testing.T, tool interopTool, args []string) {
+	t.Helper()
+	if out, err := exec.Command(tool.path, args...).CombinedOutput(); err != nil {
+		t.Fatalf("%s %v: %v\n%s", tool.name, args, err, out)
+	}
+}
+
+// writeFiles writes each entry of data to the file of that name in dir
+func writeFiles(t *testing.T, dir string, data map[string][]byte) {
+	t.Helper()
+	for name, contents := range data {
+		if err := os.WriteFile(filepath.Join(dir, name), contents, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestInteropDecode decodes deltas encoded by the other implementations
+func TestInteropDecode(t *testing.T) {
+	for _, tool := range interopTools(t) {
+		for variant, flags := range tool.encoders {
+			t.Run(tool.name+"/"+variant, func(t *testing.T) {
+				for _, c := range interopCases() {
+					dir := t.TempDir()
+					writeFiles(t, dir, map[string][]byte{"source": c.source, "target": c.target})
+					delta := filepath.Join(dir, "delta")
+					runTool(t, tool, tool.encode(flags, filepath.Join(dir, "source"), filepath.Join(dir, "target"), delta))
+
+					data, err := os.ReadFile(delta)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := Decode(c.source, data)
+					if err != nil {
+						t.Fatalf("%s: decoding %s's delta: %v", c.name, tool.name, err)
+					}
+					if !bytes.Equal(got, c.target) {
+						t.Fatalf("%s: %s's delta decodes to a different target", c.name, tool.name)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestInteropEncode has the other implementations decode deltas from Encode
+func TestInteropEncode(t *testing.T) {
+	variants := []struct {
+		name        string
+		opts        []EncoderOption
+		interleaved bool
+		checksums   bool
+	}{
+		{name: "default"},
+		{name: "best level", opts: []EncoderOption{WithLevel(LevelBest)}},
+		{name: "long matches", opts: []EncoderOption{WithMinMatchLength(16)}},
+		{name: "checksums", checksums: true},
+		{name: "interleaved", opts: []EncoderOption{WithInterleaved()}, interleaved: true},
+	}
+
+	for _, tool := range interopTools(t) {
+		for _, v := range variants {
+			if v.interleaved && !tool.interleaved || v.checksums && !tool.checksums {
+				continue
+			}
+			t.Run(tool.name+"/"+v.name, func(t *testing.T) {
+				for _, c := range interopCases() {
+					delta, err := Encode(c.source, c.target, v.opts...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if v.checksums {
+						parsed, err := ParseDelta(delta)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if err := parsed.AddChecksums(c.source); err != nil {
+							t.Fatal(err)
+						}
+						if delta, err = MarshalDelta(parsed); err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					dir := t.TempDir()
+					writeFiles(t, dir, map[string][]byte{"source": c.source, "delta": delta})
+					target := filepath.Join(dir, "target")
+					runTool(t, tool, tool.decode(filepath.Join(dir, "source"), filepath.Join(dir, "delta"), target))
+
+					got, err := os.ReadFile(target)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, c.target) {
+						t.Fatalf("%s: %s decodes the delta to a different target", c.name, tool.name)
+					}
+				}
+			})
+		}
+	}
+}
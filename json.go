@@ -0,0 +1,104 @@
+package vcdiff
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON encodings of the parsed types, for logging and tooling. Fields keep
+// their Go names and order. Byte sections are hex strings rather than
+// base64, each indicator is followed by the names of its set flags, and
+// checksums are hex strings present only when the window has one.
+
+// MarshalJSON encodes the header with its magic and sections in hex and the
+// names of its indicator flags in IndicatorFlags
+func (h Header) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Magic                 string
+		Version               byte
+		Indicator             byte
+		IndicatorFlags        []string
+		SecondaryCompressorID byte
+		CodeTable             string
+		AppHeader             string
+	}{
+		Magic:                 hex.EncodeToString(h.Magic[:]),
+		Version:               h.Version,
+		Indicator:             h.Indicator,
+		IndicatorFlags:        indicatorNames(h.Indicator, headerFlags),
+		SecondaryCompressorID: h.SecondaryCompressorID,
+		CodeTable:             hex.EncodeToString(h.CodeTable),
+		AppHeader:             hex.EncodeToString(h.AppHeader),
+	})
+}
+
+// MarshalJSON encodes the window with its sections and checksum in hex and
+// the names of its indicator flags in WinFlags and DeltaFlags
+func (w Window) MarshalJSON() ([]byte, error) {
+	var checksum string
+	if w.HasChecksum {
+		checksum = fmt.Sprintf("0x%08x", w.Checksum)
+	}
+	return json.Marshal(struct {
+		WinIndicator             byte
+		WinFlags                 []string
+		SourceSegmentSize        uint32
+		SourceSegmentPosition    uint32
+		TargetWindowLength       uint32
+		DeltaEncodingLength      uint32
+		DeltaIndicator           byte
+		DeltaFlags               []string
+		DataSectionLength        uint32
+		InstructionSectionLength uint32
+		AddressSectionLength     uint32
+		DataSection              string
+		InstructionSection       string
+		AddressSection           string
+		Checksum                 string `json:",omitempty"`
+		HasChecksum              bool
+		Interleaved              bool
+	}{
+		WinIndicator:             w.WinIndicator,
+		WinFlags:                 indicatorNames(w.WinIndicator, windowFlags),
+		SourceSegmentSize:        w.SourceSegmentSize,
+		SourceSegmentPosition:    w.SourceSegmentPosition,
+		TargetWindowLength:       w.TargetWindowLength,
+		DeltaEncodingLength:      w.DeltaEncodingLength,
+		DeltaIndicator:           w.DeltaIndicator,
+		DeltaFlags:               indicatorNames(w.DeltaIndicator, deltaFlags),
+		DataSectionLength:        w.DataSectionLength,
+		InstructionSectionLength: w.InstructionSectionLength,
+		AddressSectionLength:     w.AddressSectionLength,
+		DataSection:              hex.EncodeToString(w.DataSection),
+		InstructionSection:       hex.EncodeToString(w.InstructionSection),
+		AddressSection:           hex.EncodeToString(w.AddressSection),
+		Checksum:                 checksum,
+		HasChecksum:              w.HasChecksum,
+		Interleaved:              w.Interleaved,
+	})
+}
+
+// MarshalJSON encodes the instruction with its type by name, the name of
+// its address mode for COPYs with the default cache sizes and its data in hex
+func (i RuntimeInstruction) MarshalJSON() ([]byte, error) {
+	var mode string
+	if i.Type == Copy {
+		mode = addressModeName(i.Mode, NearCacheSize)
+	}
+	return json.Marshal(struct {
+		Type     string
+		Size     uint32
+		Mode     byte
+		ModeName string `json:",omitempty"`
+		Addr     uint32
+		Data     string `json:",omitempty"`
+	}{
+		Type:     i.Type.String(),
+		Size:     i.Size,
+		Mode:     i.Mode,
+		ModeName: mode,
+		Addr:     i.Addr,
+		Data:     hex.EncodeToString(i.Data),
+	})
+}
@@ -0,0 +1,72 @@
+package vcdiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	parsed := &ParsedDelta{
+		Header: Header{Magic: [3]byte{0xd6, 0xc3, 0xc4}, Indicator: VCDAppHeader, AppHeader: []byte("app")},
+		Windows: []Window{{
+			WinIndicator:       VCDSource | VCDAdler32,
+			SourceSegmentSize:  8,
+			TargetWindowLength: 5,
+			DataSection:        []byte{0xab},
+			InstructionSection: []byte{2, 20},
+			AddressSection:     []byte{3},
+			Checksum:           0x01020304,
+			HasChecksum:        true,
+		}},
+		Instructions: []RuntimeInstruction{
+			{Type: Add, Size: 1, Data: []byte{0xab}},
+			{Type: Copy, Size: 4, Mode: 2, Addr: 3},
+		},
+	}
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	header := got["Header"].(map[string]any)
+	window := got["Windows"].([]any)[0].(map[string]any)
+	instructions := got["Instructions"].([]any)
+	for _, check := range []struct {
+		name      string
+		got, want any
+	}{
+		{"magic", header["Magic"], "d6c3c4"},
+		{"app header", header["AppHeader"], "617070"},
+		{"header flags", header["IndicatorFlags"], []any{"VCD_APPHEADER"}},
+		{"window flags", window["WinFlags"], []any{"VCD_SOURCE", "VCD_ADLER32"}},
+		{"delta flags", window["DeltaFlags"], []any{}},
+		{"instruction section", window["InstructionSection"], "0214"},
+		{"checksum", window["Checksum"], "0x01020304"},
+		{"target length", window["TargetWindowLength"], 5.0},
+		{"add", instructions[0], map[string]any{"Type": "ADD", "Size": 1.0, "Mode": 0.0, "Addr": 0.0, "Data": "ab"}},
+		{"copy", instructions[1], map[string]any{"Type": "COPY", "Size": 4.0, "Mode": 2.0, "ModeName": "near0", "Addr": 3.0}},
+	} {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("%s: got %v, expected %v", check.name, check.got, check.want)
+		}
+	}
+
+	// Windows without a checksum leave it out
+	parsed.Windows[0].HasChecksum = false
+	data, err = json.Marshal(parsed.Windows[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain map[string]any
+	if err := json.Unmarshal(data, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain["Checksum"]; ok {
+		t.Errorf("checksum present without VCD_ADLER32: %s", data)
+	}
+}
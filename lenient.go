@@ -0,0 +1,128 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Warning is an issue a lenient decode recovered from instead of failing
+type Warning struct {
+	Window  int    // Index of the window concerned, or -1 for a header
+	Offset  int    // Offset in the delta of the field or bytes concerned
+	Message string // What was found and how it was handled
+}
+
+func (w Warning) String() string {
+	if w.Window < 0 {
+		return fmt.Sprintf("header at offset %d: %s", w.Offset, w.Message)
+	}
+	return fmt.Sprintf("window %d at offset %d: %s", w.Window, w.Offset, w.Message)
+}
+
+// LenientReport collects what a lenient decode had to work around
+type LenientReport struct {
+	Warnings []Warning
+}
+
+func (r *LenientReport) warn(window, offset int, format string, args ...any) {
+	r.Warnings = append(r.Warnings, Warning{Window: window, Offset: offset, Message: fmt.Sprintf(format, args...)})
+}
+
+// WithLenient makes Decode recover from issues that leave the meaning of a
+// delta clear, for salvaging data from slightly mangled deltas. Instead of
+// failing, it records a warning in report and:
+//   - ignores reserved bits set in a header or window indicator
+//   - drops trailing bytes after the last complete window, which a delta
+//     with at least one window must have
+//
+// Slack that is otherwise tolerated silently is reported too, as it often
+// points at corruption: a delta encoding longer than its fields and sections,
+// and data or address section bytes no instruction uses.
+//
+// Anything else still fails. The report is reset at the start of every
+// Decode call. WithLenient cannot be combined with WithStrict.
+func WithLenient(report *LenientReport) DecoderOption {
+	return func(d *decoder) {
+		d.lenient = report
+	}
+}
+
+// repairDelta returns delta with the reserved bits of its indicators cleared
+// and anything after its last complete window removed, recording each repair
+// in report. delta is copied before being modified, and offsets in the
+// result are those in delta. It also returns the offset of every window.
+// Further deltas following a window are repaired too when concatenated is
+// set. A header or first window that cannot be read ends the repair, leaving
+// the error to the parser.
+func repairDelta(delta []byte, concatenated bool, report *LenientReport) ([]byte, []int) {
+	repaired := delta
+	mask := func(offset int, valid byte) byte {
+		reserved := repaired[offset] &^ valid
+		if reserved != 0 {
+			if &repaired[0] == &delta[0] {
+				repaired = bytes.Clone(delta)
+			}
+			repaired[offset] &= valid
+		}
+		return reserved
+	}
+
+	var offsets []int
+	for offset, start := 0, true; offset < len(repaired); start = false {
+		if start || concatenated && bytes.HasPrefix(repaired[offset:], VCDIFFMagic[:]) {
+			// The header indicator follows the magic bytes and version
+			indicator := offset + len(VCDIFFMagic) + 1
+			if len(repaired) <= indicator || !bytes.HasPrefix(repaired[offset:], VCDIFFMagic[:]) {
+				break
+			}
+			if reserved := mask(indicator, VCDDecompress|VCDCodetable|VCDAppHeader); reserved != 0 {
+				report.warn(-1, indicator, "ignored reserved bits 0x%02x of the header indicator", reserved)
+			}
+			reader := bytes.NewReader(repaired[offset:])
+			if err := parseHeader(reader, &Header{}); err != nil {
+				break
+			}
+			offset += int(reader.Size()) - reader.Len()
+			continue
+		}
+
+		reserved := mask(offset, VCDSource|VCDTarget|VCDAdler32)
+		reader := bytes.NewReader(repaired[offset:])
+		if _, err := skipWindow(reader); err != nil {
+			if len(offsets) == 0 {
+				break
+			}
+			report.warn(len(offsets), offset, "dropped %d trailing bytes that do not form a window: %v", len(repaired)-offset, err)
+			return repaired[:offset], offsets
+		}
+		if reserved != 0 {
+			report.warn(len(offsets), offset, "ignored reserved bits 0x%02x of the window indicator", reserved)
+		}
+		offsets = append(offsets, offset)
+		offset += int(reader.Size()) - reader.Len()
+	}
+	return repaired, offsets
+}
+
+// checkWindow reports the slack of window index, which starts at offset.
+// Its instructions are run without producing a target, through addressCache;
+// errors are left for decoding to report.
+func (r *LenientReport) checkWindow(index, offset int, window *Window, addressCache *AddressCache) {
+	// Interleaved sections are split apart when parsed, so their encoding
+	// cannot be rebuilt
+	if !window.Interleaved {
+		if length := len(appendEncoding(nil, window)); uint64(length) != uint64(window.DeltaEncodingLength) {
+			r.warn(index, offset, "delta encoding length %d, its fields and sections need %d", window.DeltaEncodingLength, length)
+		}
+	}
+	data, addresses, err := unusedSections(window, addressCache)
+	if err != nil {
+		return
+	}
+	if data != 0 {
+		r.warn(index, offset, "%d data section bytes are not used by any instruction", data)
+	}
+	if addresses != 0 {
+		r.warn(index, offset, "%d address section bytes are not used by any COPY", addresses)
+	}
+}
@@ -0,0 +1,77 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLenient(t *testing.T) {
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+	valid := marshalWindow(t, Window{}, add, nil)
+	// The header indicator follows the magic bytes and version, and the
+	// window indicator follows the 5 byte header
+	const headerIndicator, windowIndicator = 4, 5
+	const encodingLengthOffset = 6
+
+	reservedHeader := bytes.Clone(valid)
+	reservedHeader[headerIndicator] |= 0x80
+	reservedWindow := bytes.Clone(valid)
+	reservedWindow[windowIndicator] |= 0x10
+	trailing := append(bytes.Clone(valid), 0x01, 0x02)
+	slack := bytes.Clone(valid)
+	slack[encodingLengthOffset]++
+	slack = append(slack, 0)
+	unusedData := marshalWindow(t, Window{}, add, func(w *Window) { w.DataSection = append(w.DataSection, '!') })
+
+	tests := []struct {
+		name  string
+		delta []byte
+		fails bool // Decoding fails without lenient mode
+		want  []Warning
+	}{
+		{"valid", valid, false, nil},
+		{"reserved header bits", reservedHeader, true, []Warning{{-1, headerIndicator, "ignored reserved bits 0x80 of the header indicator"}}},
+		{"reserved window bits", reservedWindow, true, []Warning{{0, windowIndicator, "ignored reserved bits 0x10 of the window indicator"}}},
+		{"trailing bytes", trailing, true, nil},
+		{"delta encoding slack", slack, false, []Warning{{0, windowIndicator, "delta encoding length 13, its fields and sections need 12"}}},
+		{"unused data", unusedData, false, []Warning{{0, windowIndicator, "1 data section bytes are not used by any instruction"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := bytes.Clone(tt.delta)
+			if _, err := Decode(nil, tt.delta); (err != nil) != tt.fails {
+				t.Fatalf("decode without lenient mode returned %v", err)
+			}
+			report := &LenientReport{Warnings: []Warning{{}}}
+			got, err := NewDecoder(nil, WithLenient(report)).Decode(tt.delta)
+			if err != nil || string(got) != "hello" {
+				t.Fatalf("got %q, %v", got, err)
+			}
+			if !bytes.Equal(tt.delta, original) {
+				t.Error("lenient decode modified the delta")
+			}
+			if tt.name == "trailing bytes" {
+				if len(report.Warnings) != 1 || report.Warnings[0].Offset != len(valid) {
+					t.Fatalf("got warnings %v, expected one at offset %d", report.Warnings, len(valid))
+				}
+				return
+			}
+			if len(report.Warnings) != len(tt.want) {
+				t.Fatalf("got warnings %v, expected %v", report.Warnings, tt.want)
+			}
+			for i, w := range tt.want {
+				if report.Warnings[i] != w {
+					t.Errorf("got warning %v, expected %v", report.Warnings[i], w)
+				}
+			}
+		})
+	}
+
+	if _, err := NewDecoder(nil, WithLenient(&LenientReport{})).Decode(valid[:len(valid)-1]); err == nil {
+		t.Error("lenient decode of a delta truncated in its only window succeeded")
+	}
+	if _, err := NewDecoder(nil, WithStrict(), WithLenient(&LenientReport{})).Decode(valid); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got %v, expected ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,52 @@
+package vcdiff
+
+import "fmt"
+
+// Level trades encoding time and memory for delta size, like xdelta3's -1 to
+// -9. The zero value is LevelDefault.
+type Level int
+
+const (
+	LevelDefault Level = iota // Balanced; one candidate per hash, 8 MiB windows
+	LevelFast                 // Smaller hash tables and windows, for encoding on the request path
+	LevelBest                 // Chained hash candidates, lazy matching and 16 MiB windows
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDefault:
+		return "default"
+	case LevelFast:
+		return "fast"
+	case LevelBest:
+		return "best"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// levelParams are the encoder settings a Level selects
+type levelParams struct {
+	maxHashBits int  // Largest hash table, 2^maxHashBits slots; larger sources are indexed sparsely
+	depth       int  // Candidates tried per position, following the hash chain
+	lazy        bool // Defer a match by a byte when the next position matches further
+	windowSize  int  // Target bytes per window
+}
+
+var levels = map[Level]levelParams{
+	LevelDefault: {maxHashBits: 22, depth: 1, windowSize: 1 << 23}, // xdelta3's default input window
+	LevelFast:    {maxHashBits: 18, depth: 1, windowSize: 1 << 22},
+	LevelBest:    {maxHashBits: 24, depth: 32, lazy: true, windowSize: 1 << 24},
+}
+
+// WithLevel sets how hard Encode searches for matches. LevelFast indexes
+// less of a large source and uses smaller windows; LevelBest tries up to 32
+// earlier positions sharing each hash, defers a match by a byte when the next
+// position gives a longer one, and uses larger windows, so repeats further
+// apart in the target are found. Encode returns an error for an unknown
+// level.
+func WithLevel(level Level) EncoderOption {
+	return func(e *encoder) {
+		e.level = level
+	}
+}
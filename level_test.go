@@ -0,0 +1,57 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeLevels(t *testing.T) {
+	// Text from a small vocabulary, where many earlier positions share each
+	// hash and the latest is rarely the longest match
+	rng := rand.New(rand.NewSource(6))
+	words := []string{"alpha ", "beta ", "gamma ", "delta ", "epsilon ", "zeta ", "eta ", "theta "}
+	var source, target []byte
+	for len(source) < 1<<16 {
+		source = append(source, words[rng.Intn(len(words))]...)
+	}
+	for len(target) < 1<<16 {
+		target = append(target, words[rng.Intn(len(words))]...)
+	}
+
+	sizes := make(map[Level]int)
+	for _, level := range []Level{LevelFast, LevelDefault, LevelBest} {
+		delta, err := Encode(source, target, WithLevel(level))
+		if err != nil {
+			t.Fatalf("%v: %v", level, err)
+		}
+		if got, err := Decode(source, delta); err != nil || !bytes.Equal(got, target) {
+			t.Fatalf("%v: delta does not decode to the target: %v", level, err)
+		}
+		sizes[level] = len(delta)
+	}
+	if sizes[LevelBest] >= sizes[LevelDefault] {
+		t.Errorf("best level delta is %d bytes, default %d", sizes[LevelBest], sizes[LevelDefault])
+	}
+
+	if _, err := Encode(source, target, WithLevel(Level(9))); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestEncodeLevelsRoundTrip(t *testing.T) {
+	for _, level := range []Level{LevelFast, LevelBest} {
+		for _, profile := range []DeltaProfile{ProfileCopyHeavy, ProfileNoSource, ProfileManyWindows} {
+			for seed := int64(0); seed < 5; seed++ {
+				g := GenerateDelta(seed, profile)
+				delta, err := Encode(g.Source, g.Target, WithLevel(level))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err := Decode(g.Source, delta); err != nil || !bytes.Equal(got, g.Target) {
+					t.Fatalf("%v, %v seed %d: %v", level, profile, seed, err)
+				}
+			}
+		}
+	}
+}
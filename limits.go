@@ -0,0 +1,46 @@
+package vcdiff
+
+import "fmt"
+
+// DecodeLimits bounds the memory a single Decode may commit to. A delta of a
+// few bytes can declare windows of gigabytes, so services decoding deltas
+// from untrusted peers should set limits suited to their payloads. Zero
+// fields are unlimited.
+type DecodeLimits struct {
+	MaxTargetSize uint64 // Largest total target, summed over all windows
+	MaxWindowSize uint32 // Largest target window
+	MaxDeltaSize  uint64 // Largest delta accepted for decoding
+}
+
+// WithLimits makes Decode reject deltas exceeding limits with an error
+// wrapping ErrLimitExceeded. Window sizes are checked against the declared
+// lengths after parsing, before any target memory is allocated.
+func WithLimits(limits DecodeLimits) DecoderOption {
+	return func(d *decoder) {
+		d.limits = limits
+	}
+}
+
+// checkDelta checks the size of the delta itself
+func (l DecodeLimits) checkDelta(delta []byte) error {
+	if l.MaxDeltaSize != 0 && uint64(len(delta)) > l.MaxDeltaSize {
+		return fmt.Errorf("%w: delta of %d bytes exceeds the %d byte limit", ErrLimitExceeded, len(delta), l.MaxDeltaSize)
+	}
+	return nil
+}
+
+// checkWindows checks the target lengths the windows declare
+func (l DecodeLimits) checkWindows(windows []Window) error {
+	var total uint64
+	for i := range windows {
+		length := windows[i].TargetWindowLength
+		if l.MaxWindowSize != 0 && length > l.MaxWindowSize {
+			return fmt.Errorf("%w: window %d target of %d bytes exceeds the %d byte window limit", ErrLimitExceeded, i, length, l.MaxWindowSize)
+		}
+		total += uint64(length)
+		if l.MaxTargetSize != 0 && total > l.MaxTargetSize {
+			return fmt.Errorf("%w: target of at least %d bytes exceeds the %d byte limit", ErrLimitExceeded, total, l.MaxTargetSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+// runDelta returns a delta of one RUN window per length
+func runDelta(t *testing.T, lengths ...uint32) []byte {
+	t.Helper()
+	parsed := &ParsedDelta{}
+	for _, length := range lengths {
+		var window Window
+		encodeInstructions(&window, []RuntimeInstruction{{Type: Run, Size: length, Data: []byte{'x'}}})
+		parsed.Windows = append(parsed.Windows, window)
+	}
+	delta, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func TestLimits(t *testing.T) {
+	delta := runDelta(t, 600, 600)
+
+	tests := []struct {
+		name   string
+		limits DecodeLimits
+		ok     bool
+	}{
+		{"unlimited", DecodeLimits{}, true},
+		{"within", DecodeLimits{MaxTargetSize: 1200, MaxWindowSize: 600, MaxDeltaSize: uint64(len(delta))}, true},
+		{"window", DecodeLimits{MaxWindowSize: 599}, false},
+		{"target", DecodeLimits{MaxTargetSize: 1199}, false},
+		{"delta", DecodeLimits{MaxDeltaSize: uint64(len(delta)) - 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := NewDecoder(nil, WithLimits(tt.limits)).Decode(delta)
+			if tt.ok {
+				if err != nil || !bytes.Equal(target, bytes.Repeat([]byte{'x'}, 1200)) {
+					t.Fatalf("got %d bytes, %v", len(target), err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLimitExceeded) || target != nil {
+				t.Fatalf("got %d bytes, error %v, expected ErrLimitExceeded", len(target), err)
+			}
+		})
+	}
+}
+
+func TestLimitsPreventAllocation(t *testing.T) {
+	// A delta of a few bytes declaring a 1 GiB window
+	delta := runDelta(t, 1<<30)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := NewDecoder(nil, WithLimits(DecodeLimits{MaxWindowSize: 1 << 20})).Decode(delta)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("got error %v, expected ErrLimitExceeded", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("rejected delta allocated %d bytes", allocated)
+	}
+}
+
+func TestParseRejectsOversizedLengths(t *testing.T) {
+	// A window declaring a delta encoding far longer than the delta
+	delta := append(runDelta(t), 0x00)
+	delta = AppendVarint(delta, 1<<30)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ParseDelta(delta)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatal("parsed a window longer than the delta")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("rejected delta allocated %d bytes", allocated)
+	}
+}
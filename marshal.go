@@ -0,0 +1,110 @@
+package vcdiff
+
+import "fmt"
+
+// Single-instruction codes of the default code table - RFC 3284 Section 5.6.
+// Rebuilt windows use only these, with sizes in the instruction stream.
+const (
+	runCode  = 0  // RUN, size in instruction stream
+	addCode  = 1  // ADD, size in instruction stream
+	copyCode = 19 // COPY in SELF mode, size in instruction stream
+)
+
+// MarshalDelta serializes a parsed delta. Length fields are computed from the
+// sections themselves rather than taken from the stored values, so a delta
+// can be edited and re-serialized without fixing them up by hand. The
+// header's optional fields are written according to its indicator, and each
+// window's VCD_ADLER32 bit follows HasChecksum. Indicators with reserved
+// bits set are rejected with ErrInvalidFormat, since ParseDelta would not
+// accept the result.
+func MarshalDelta(parsed *ParsedDelta) ([]byte, error) {
+	if parsed.Header.Indicator&^(VCDDecompress|VCDCodetable|VCDAppHeader) != 0 {
+		return nil, fmt.Errorf("%w: reserved bits set in header indicator 0x%02x", ErrInvalidFormat, parsed.Header.Indicator)
+	}
+
+	out := appendHeader(nil, &parsed.Header)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		if window.WinIndicator&^(VCDSource|VCDTarget|VCDAdler32) != 0 {
+			return nil, fmt.Errorf("%w: window %d: reserved bits set in window indicator 0x%02x", ErrInvalidFormat, i, window.WinIndicator)
+		}
+		out = appendWindow(out, window)
+	}
+	return out, nil
+}
+
+// appendHeader appends the header - RFC 3284 Section 4.1
+func appendHeader(dst []byte, header *Header) []byte {
+	dst = append(dst, VCDIFFMagic[:]...)
+	dst = append(dst, VCDIFFVersion, header.Indicator)
+	if header.Indicator&VCDDecompress != 0 {
+		dst = append(dst, header.SecondaryCompressorID)
+	}
+	if header.Indicator&VCDCodetable != 0 {
+		dst = AppendVarint(dst, uint32(len(header.CodeTable)))
+		dst = append(dst, header.CodeTable...)
+	}
+	if header.Indicator&VCDAppHeader != 0 {
+		dst = AppendVarint(dst, uint32(len(header.AppHeader)))
+		dst = append(dst, header.AppHeader...)
+	}
+	return dst
+}
+
+// appendWindow appends a window - RFC 3284 Section 4.2
+func appendWindow(dst []byte, window *Window) []byte {
+	indicator := window.WinIndicator &^ VCDAdler32
+	if window.HasChecksum {
+		indicator |= VCDAdler32
+	}
+
+	dst = append(dst, indicator)
+	if indicator&(VCDSource|VCDTarget) != 0 {
+		dst = AppendVarint(dst, window.SourceSegmentSize)
+		dst = AppendVarint(dst, window.SourceSegmentPosition)
+	}
+	encoding := appendEncoding(nil, window)
+	dst = AppendVarint(dst, uint32(len(encoding)))
+	return append(dst, encoding...)
+}
+
+// appendEncoding appends a window's delta encoding - RFC 3284 Section 4.3
+func appendEncoding(dst []byte, window *Window) []byte {
+	dst = AppendVarint(dst, window.TargetWindowLength)
+	dst = append(dst, window.DeltaIndicator)
+	dst = AppendVarint(dst, uint32(len(window.DataSection)))
+	dst = AppendVarint(dst, uint32(len(window.InstructionSection)))
+	dst = AppendVarint(dst, uint32(len(window.AddressSection)))
+	if window.HasChecksum {
+		sum := window.Checksum
+		dst = append(dst, byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum))
+	}
+	dst = append(dst, window.DataSection...)
+	dst = append(dst, window.InstructionSection...)
+	return append(dst, window.AddressSection...)
+}
+
+// encodeInstructions fills a window's sections from instructions whose COPY
+// addresses are already resolved. Every COPY is written in SELF mode, so the
+// result does not depend on address cache state.
+func encodeInstructions(window *Window, instructions []RuntimeInstruction) {
+	window.DataSection, window.InstructionSection, window.AddressSection = nil, nil, nil
+	window.TargetWindowLength = 0
+	for _, inst := range instructions {
+		switch inst.Type {
+		case Add:
+			window.InstructionSection = append(window.InstructionSection, addCode)
+			window.DataSection = append(window.DataSection, inst.Data...)
+		case Run:
+			window.InstructionSection = append(window.InstructionSection, runCode)
+			window.DataSection = append(window.DataSection, inst.Data[0])
+		case Copy:
+			window.InstructionSection = append(window.InstructionSection, copyCode)
+			window.AddressSection = AppendVarint(window.AddressSection, inst.Addr)
+		default:
+			continue
+		}
+		window.InstructionSection = AppendVarint(window.InstructionSection, inst.Size)
+		window.TargetWindowLength += inst.Size
+	}
+}
@@ -0,0 +1,137 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// mutationOutcome classifies the decoder's response to a mutated delta
+type mutationOutcome int
+
+const (
+	mutationCorrect   mutationOutcome = iota // Decoded to the original target
+	mutationRejected                         // Failed with an error
+	mutationSilent                           // Decoded to different bytes without error
+	mutationTruncated                        // Parsed as fewer windows and decoded without error
+	mutationPanicked                         // Panicked
+)
+
+// mutationResult records the outcome of decoding one mutant
+type mutationResult struct {
+	offset  int
+	bit     uint
+	outcome mutationOutcome
+	detail  string
+	guarded bool // every window of the mutant still carries a checksum
+}
+
+// decodeMutant decodes a mutated delta and classifies the outcome
+func decodeMutant(source, target, mutant []byte, windows int) (result mutationResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result.outcome = mutationPanicked
+			result.detail = fmt.Sprint(r)
+		}
+	}()
+
+	parsedWindows := -1
+	if parsed, err := ParseDelta(mutant); err == nil && len(parsed.Windows) > 0 {
+		parsedWindows = len(parsed.Windows)
+		result.guarded = true
+		for _, window := range parsed.Windows {
+			result.guarded = result.guarded && window.HasChecksum
+		}
+	}
+
+	decoded, err := Decode(source, mutant)
+	switch {
+	case err != nil:
+		if err.Error() == "" {
+			result.outcome = mutationPanicked
+			result.detail = "error with empty message"
+			return result
+		}
+		result.outcome = mutationRejected
+		result.detail = err.Error()
+	case bytes.Equal(decoded, target):
+		result.outcome = mutationCorrect
+	case parsedWindows >= 0 && parsedWindows < windows:
+		result.outcome = mutationTruncated
+		result.detail = fmt.Sprintf("parsed %d of %d windows, decoded %d bytes, expected %d",
+			parsedWindows, windows, len(decoded), len(target))
+	default:
+		result.outcome = mutationSilent
+		result.detail = fmt.Sprintf("decoded %d bytes, expected %d", len(decoded), len(target))
+	}
+	return result
+}
+
+// mutateAll flips every bit of delta in turn and decodes each mutant
+func mutateAll(source, target, delta []byte, windows int) []mutationResult {
+	var results []mutationResult
+	mutant := make([]byte, len(delta))
+	for offset := range delta {
+		for bit := uint(0); bit < 8; bit++ {
+			copy(mutant, delta)
+			mutant[offset] ^= 1 << bit
+
+			result := decodeMutant(source, target, mutant, windows)
+			result.offset = offset
+			result.bit = bit
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// mutationProfile keeps deltas small enough that every bit can be flipped
+var mutationProfile = DeltaProfile{
+	SourceSize: 96, Windows: 2, MinWindowSize: 16, MaxWindowSize: 64, MaxInstSize: 24,
+	AddWeight: 1, CopyWeight: 2, RunWeight: 1, Checksums: true,
+}
+
+// TestMutationHarness asserts that no single-bit mutation of a known-good
+// delta panics, and that no mutation whose windows all carry checksums
+// decodes to the wrong bytes. Silent corruption of unchecksummed deltas is
+// reported but expected, since nothing in the format can detect it, as are
+// mutations that make the parser stop early and drop trailing windows.
+func TestMutationHarness(t *testing.T) {
+	seeds := int64(20)
+	if testing.Short() {
+		seeds = 3
+	}
+
+	for _, checksums := range []bool{true, false} {
+		profile := mutationProfile
+		profile.Checksums = checksums
+
+		t.Run(fmt.Sprintf("checksums=%v", checksums), func(t *testing.T) {
+			counts := map[mutationOutcome]int{}
+			for seed := int64(0); seed < seeds; seed++ {
+				g := GenerateDelta(seed, profile)
+
+				for _, r := range mutateAll(g.Source, g.Target, g.Delta, profile.Windows) {
+					counts[r.outcome]++
+					switch {
+					case r.outcome == mutationPanicked:
+						t.Errorf("seed %d: flipping bit %d of byte %d: %s", seed, r.bit, r.offset, r.detail)
+					case r.outcome == mutationSilent && r.guarded:
+						t.Errorf("seed %d: flipping bit %d of byte %d silently corrupted a checksummed delta: %s",
+							seed, r.bit, r.offset, r.detail)
+					case r.outcome == mutationSilent:
+						t.Logf("seed %d: flipping bit %d of byte %d: silent corruption: %s", seed, r.bit, r.offset, r.detail)
+					case r.outcome == mutationTruncated:
+						// A delta encoding length grown to take in the following windows
+						// leaves them as unused bytes, which Decode tolerates
+						t.Logf("seed %d: flipping bit %d of byte %d: silent truncation: %s", seed, r.bit, r.offset, r.detail)
+					}
+				}
+			}
+
+			t.Logf("mutants: %d correct, %d rejected, %d silently corrupted, %d silently truncated, %d panicked",
+				counts[mutationCorrect], counts[mutationRejected], counts[mutationSilent],
+				counts[mutationTruncated], counts[mutationPanicked])
+		})
+	}
+}
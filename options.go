@@ -0,0 +1,75 @@
+package vcdiff
+
+import (
+	"context"
+	"runtime/pprof"
+	"time"
+)
+
+// DecoderOption configures optional decoder behaviour
+type DecoderOption func(*decoder)
+
+// Decode phases reported through profiler labels
+const (
+	PhaseParse   = "parse"   // Header, window and instruction parsing
+	PhaseExecute = "execute" // Running a window's instructions
+	PhaseVerify  = "verify"  // Source fingerprint and Adler-32 checksum validation
+)
+
+// Profiler label keys applied by WithProfilerLabels
+const (
+	LabelDeltaID = "vcdiff_delta"
+	LabelPhase   = "vcdiff_phase"
+)
+
+// WithProfilerLabels tags the decoding goroutine with pprof labels naming the
+// delta and the current decode phase, so CPU profiles of embedding services
+// attribute time to parse, execute and verify separately. Labels are added on
+// top of any already carried by ctx, and the goroutine is restored to ctx's
+// labels when each phase ends.
+func WithProfilerLabels(ctx context.Context, deltaID string) DecoderOption {
+	return func(d *decoder) {
+		d.labelCtx = ctx
+		d.deltaID = deltaID
+	}
+}
+
+// WithConcatenated makes Decode accept several complete deltas placed back to
+// back, each with its own header. Every delta is decoded against the same
+// source and their targets are concatenated in order.
+func WithConcatenated() DecoderOption {
+	return func(d *decoder) {
+		d.concatenated = true
+	}
+}
+
+// WithCacheSizes sets the number of near and same address cache slots - RFC
+// 3284 Section 5.1 - for deltas from encoders that pair the default code table
+// with non-default cache sizes. The default code table's COPY modes 2-8 are
+// then split between the caches accordingly. Decode fails if the sizes are
+// invalid or a COPY uses a mode beyond the configured caches. A code table in
+// the delta's header carries its own cache sizes, which take precedence.
+func WithCacheSizes(near, same int) DecoderOption {
+	return func(d *decoder) {
+		d.nearSize, d.sameSize = near, same
+	}
+}
+
+// phase runs fn, labelled with the given phase when profiler labels are
+// enabled. When stats are being collected the time taken is added to elapsed.
+func (d *decoder) phase(name string, elapsed *time.Duration, fn func() error) error {
+	if d.stats != nil {
+		start := time.Now()
+		defer func() { *elapsed += time.Since(start) }()
+	}
+
+	if d.labelCtx == nil {
+		return fn()
+	}
+
+	var err error
+	pprof.Do(d.labelCtx, pprof.Labels(LabelDeltaID, d.deltaID, LabelPhase, name), func(context.Context) {
+		err = fn()
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+package vcdiff
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goroutineLabels returns the debug goroutine profile, which lists the
+// labels of every labelled goroutine
+func goroutineLabels(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatalf("writing goroutine profile: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWithProfilerLabels(t *testing.T) {
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("service", "patcher"))
+	d := NewDecoder(nil, WithProfilerLabels(ctx, "delta-42")).(*decoder)
+
+	for _, name := range []string{PhaseParse, PhaseExecute, PhaseVerify} {
+		var profile string
+		var elapsed time.Duration
+		if err := d.phase(name, &elapsed, func() error {
+			profile = goroutineLabels(t)
+			return nil
+		}); err != nil {
+			t.Fatalf("phase %s: %v", name, err)
+		}
+
+		for _, want := range []string{
+			`"` + LabelDeltaID + `":"delta-42"`,
+			`"` + LabelPhase + `":"` + name + `"`,
+			`"service":"patcher"`,
+		} {
+			if !strings.Contains(profile, want) {
+				t.Errorf("phase %s: goroutine profile missing label %s", name, want)
+			}
+		}
+	}
+
+	// Phase labels must not outlive the phase
+	if strings.Contains(goroutineLabels(t), `"`+LabelDeltaID+`":"delta-42"`) {
+		t.Error("profiler labels still set after phase returned")
+	}
+}
+
+func TestWithProfilerLabelsDecodes(t *testing.T) {
+	g := GenerateDelta(7, ProfileCopyHeavy)
+
+	result, err := NewDecoder(g.Source, WithProfilerLabels(context.Background(), "generated")).Decode(g.Delta)
+	if err != nil {
+		t.Fatalf("decode with profiler labels failed: %v", err)
+	}
+	if !bytes.Equal(result, g.Target) {
+		t.Fatal("decode with profiler labels produced a different target")
+	}
+}
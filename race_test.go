@@ -0,0 +1,8 @@
+//go:build race
+
+package vcdiff
+
+// raceEnabled reports whether the race detector is on. It makes sync.Pool
+// drop items at random, so allocation counts that rely on the scratch pool
+// do not hold.
+const raceEnabled = true
@@ -0,0 +1,214 @@
+package vcdiff
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxVarintBytes is the longest encoding of a 32-bit varint - RFC 3284 Section 2
+const maxVarintBytes = 5
+
+// frameReader reads the raw bytes of one header or window from a stream, so
+// the existing parsers can run on them without buffering the whole delta
+type frameReader struct {
+	r   *bufio.Reader
+	raw []byte
+}
+
+func (f *frameReader) byte() (byte, error) {
+	b, err := f.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	f.raw = append(f.raw, b)
+	return b, nil
+}
+
+// varint copies one varint into the frame and returns its value
+func (f *frameReader) varint() (uint32, error) {
+	var v uint32
+	for i := 0; i < maxVarintBytes; i++ {
+		b, err := f.byte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<VarintShiftIncrement | uint32(b&VarintValueMask)
+		if b&VarintContinuationBit == 0 {
+			return v, nil
+		}
+	}
+	// Let the parser report the malformed varint
+	return v, nil
+}
+
+// bytes copies n bytes into the frame. The frame grows only as data
+// arrives, so a corrupt length cannot force a large allocation up front.
+func (f *frameReader) bytes(n uint32) error {
+	buf := bytes.NewBuffer(f.raw)
+	if _, err := io.CopyN(buf, f.r, int64(n)); err != nil {
+		return err
+	}
+	f.raw = buf.Bytes()
+	return nil
+}
+
+// header reads the fixed header and its optional fields - RFC 3284 Section 4.1
+func (f *frameReader) header() ([]byte, error) {
+	f.raw = f.raw[:0]
+	for i := 0; i < MinimumFileSize; i++ {
+		if _, err := f.byte(); err != nil {
+			return nil, err
+		}
+	}
+	indicator, err := f.byte()
+	if err != nil {
+		return nil, err
+	}
+	if indicator&VCDDecompress != 0 {
+		if _, err := f.byte(); err != nil {
+			return nil, err
+		}
+	}
+	for _, flag := range []byte{VCDCodetable, VCDAppHeader} {
+		if indicator&flag == 0 {
+			continue
+		}
+		n, err := f.varint()
+		if err != nil {
+			return nil, err
+		}
+		if err := f.bytes(n); err != nil {
+			return nil, err
+		}
+	}
+	return f.raw, nil
+}
+
+// window reads one window - RFC 3284 Section 4.2. It returns io.EOF if the
+// stream ends cleanly before the window starts.
+func (f *frameReader) window() ([]byte, error) {
+	f.raw = f.raw[:0]
+	indicator, err := f.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := 1 // Length of the delta encoding
+	if indicator&(VCDSource|VCDTarget) != 0 {
+		fields += 2 // Segment size and position
+	}
+	var n uint32
+	for i := 0; i < fields; i++ {
+		if n, err = f.varint(); err != nil {
+			return nil, noEOF(err)
+		}
+	}
+	if err := f.bytes(n); err != nil {
+		return nil, noEOF(err)
+	}
+	return f.raw, nil
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF once a frame has started
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// streamReader decodes a delta window by window as its output is read
+type streamReader struct {
+	decoder      *decoder
+	frames       frameReader
+	addressCache *AddressCache
+	started      bool
+	header       Header
+	index        int
+	offset       int    // Offset in the delta of the next window
+	pending      []byte // Decoded target not yet returned
+	err          error
+}
+
+// NewReader returns a reader over the target reconstructed by applying the
+// delta read from delta to source. Windows are read and decoded only as the
+// output is consumed, so neither the delta nor the target is held in memory
+// beyond the current window.
+func NewReader(source []byte, delta io.Reader) io.Reader {
+	d := NewDecoder(source).(*decoder)
+	return &streamReader{
+		decoder:      d,
+		frames:       frameReader{r: bufio.NewReader(delta)},
+		addressCache: NewAddressCache(d.nearSize, d.sameSize),
+	}
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.pending, s.err = s.next()
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next decodes the next window, reading the header first if needed
+func (s *streamReader) next() ([]byte, error) {
+	if !s.started {
+		s.started = true
+		raw, err := s.frames.header()
+		if err != nil {
+			return nil, truncated("header", noEOF(err))
+		}
+		if err := parseHeader(bytes.NewReader(raw), &s.header); err != nil {
+			return nil, err
+		}
+		s.offset = len(raw)
+		if err := verifySource(&s.header, s.decoder.source); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := s.frames.window()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, truncated(fmt.Sprintf("window %d", s.index), err)
+	}
+
+	var window Window
+	if err := parseWindow(bytes.NewReader(raw), &window, &s.header, s.index); err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErr.Offset += s.offset
+		}
+		return nil, err
+	}
+	s.offset += len(raw)
+	if err := checkSupported(&s.header, &window); err != nil {
+		return nil, err
+	}
+
+	target, err := s.decoder.decodeWindow(s.index, &window, s.decoder.source, s.addressCache)
+	if err != nil {
+		return nil, err
+	}
+	s.index++
+	return target, nil
+}
+
+// truncated reports a stream that ended part way through a frame
+func truncated(context string, err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return &TruncatedError{Context: context}
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewReaderMatchesDecode(t *testing.T) {
+	profiles := map[string]DeltaProfile{
+		"small":        ProfileSmall,
+		"copy-heavy":   ProfileCopyHeavy,
+		"no-source":    ProfileNoSource,
+		"many-windows": ProfileManyWindows,
+	}
+
+	for name, profile := range profiles {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				g := GenerateDelta(seed, profile)
+
+				var out bytes.Buffer
+				if _, err := io.Copy(&out, NewReader(g.Source, bytes.NewReader(g.Delta))); err != nil {
+					t.Fatalf("seed %d: streaming decode failed: %v", seed, err)
+				}
+				if !bytes.Equal(out.Bytes(), g.Target) {
+					t.Fatalf("seed %d: streamed target differs from generated target", seed)
+				}
+			}
+		})
+	}
+}
+
+func TestNewReaderSmallReads(t *testing.T) {
+	g := GenerateDelta(3, ProfileCopyHeavy)
+
+	// Feed the delta a byte at a time and drain the target a byte at a time
+	r := iotest.OneByteReader(NewReader(g.Source, iotest.OneByteReader(bytes.NewReader(g.Delta))))
+	if err := iotest.TestReader(r, g.Target); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewReaderTruncated(t *testing.T) {
+	g := GenerateDelta(5, ProfileCopyHeavy)
+
+	for _, cut := range []int{2, len(VCDIFFMagic) + 5, len(g.Delta) - 1} {
+		_, err := io.ReadAll(NewReader(g.Source, bytes.NewReader(g.Delta[:cut])))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("cut at %d: got %v, expected io.ErrUnexpectedEOF", cut, err)
+		}
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	g := GenerateDelta(6, ProfileCopyHeavy)
+
+	corrupted := append([]byte{}, g.Delta...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	if _, err := io.ReadAll(NewReader(g.Source, bytes.NewReader(corrupted))); err == nil {
+		t.Error("corrupted delta streamed without error")
+	}
+
+	source := []byte("the quick brown fox")
+	delta, _ := fuzzyDelta(source, false, NewSourceFingerprint(source).AppHeader())
+	if _, err := io.ReadAll(NewReader([]byte("the quick brown cat"), bytes.NewReader(delta))); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("wrong base: got %v, expected ErrSourceMismatch", err)
+	}
+
+	// Errors from the delta stream are passed through
+	failing := io.MultiReader(bytes.NewReader(g.Delta[:len(g.Delta)/2]), iotest.ErrReader(io.ErrClosedPipe))
+	if _, err := io.ReadAll(NewReader(g.Source, failing)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("failing stream: got %v, expected io.ErrClosedPipe", err)
+	}
+}
@@ -0,0 +1,145 @@
+package vcdiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RebaseResult is the outcome of Rebase
+type RebaseResult struct {
+	Delta     []byte        // The delta, re-addressed to apply to the new base
+	Shifted   []FuzzyRegion // Regions whose source data was found at a shifted offset
+	Conflicts []FuzzyRegion // Regions whose source data could not be confirmed in the new base
+}
+
+// Rebase rewrites a delta encoded against one base so that it applies to
+// base, a locally modified copy of it. It is the binary counterpart of a
+// three-way merge: source COPYs are located in the new base with the fuzzy
+// matcher of WithFuzzy, searching up to maxShift bytes either way, and their
+// addresses are rewritten to point there.
+//
+// Windows whose checksum cannot be satisfied at any shift are conflicts: the
+// local edits touch data the delta copies. Rather than failing, Rebase keeps
+// such windows as encoded, drops their checksum so the rebased delta still
+// applies, and lists their source-copied regions in Conflicts. When the base
+// fails the delta's source fingerprint, regions of windows without a
+// checksum cannot be confirmed either and are also listed as conflicts. A
+// source fingerprint in the application header is replaced by base's.
+func Rebase(delta, base []byte, maxShift int) (*RebaseResult, error) {
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		return nil, err
+	}
+	for i := range parsed.Windows {
+		if err := checkSupported(&parsed.Header, &parsed.Windows[i]); err != nil {
+			return nil, err
+		}
+	}
+	// Shifted windows are re-encoded with the default code table
+	if parsed.Header.codes != nil {
+		return nil, fmt.Errorf("%w: rebasing a delta with a custom code table (VCD_CODETABLE)", ErrUnsupported)
+	}
+
+	var report FuzzyReport
+	d := NewDecoder(base, WithFuzzy(maxShift, &report)).(*decoder)
+	fingerprint, hasFingerprint, err := ParseSourceFingerprint(parsed.Header.AppHeader)
+	if err != nil {
+		return nil, err
+	}
+	if hasFingerprint {
+		report.SourceMismatch = fingerprint.Verify(base) != nil
+		parsed.Header.AppHeader = NewSourceFingerprint(base).AppHeader()
+	}
+
+	result := &RebaseResult{}
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	var targetOffset uint64
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		before := len(report.Regions)
+		target, err := d.decodeWindow(i, window, base, addressCache)
+		target, err = d.fuzzyWindow(i, window, target, err, addressCache, targetOffset)
+
+		switch {
+		case errors.Is(err, ErrInvalidChecksum):
+			// Not resynchronized: keep the window as encoded, unverified
+			r, err := resolveWindow(window, addressCache)
+			if err != nil {
+				return nil, fmt.Errorf("window %d: %w", i, err)
+			}
+			var regions []FuzzyRegion
+			var ok bool
+			if target, regions, ok = r.execute(base, 0, 0); !ok {
+				return nil, fmt.Errorf("%w: window %d references data outside the new base", ErrSourceTooShort, i)
+			}
+			d.fuzzy.record(i, targetOffset, regions, false)
+			window.WinIndicator &^= VCDAdler32
+			window.HasChecksum = false
+			window.Checksum = 0
+		case err != nil:
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+
+		regions := report.Regions[before:]
+		if len(regions) > 0 && regions[0].Verified {
+			if err := shiftWindow(window, regions, targetOffset, addressCache); err != nil {
+				return nil, fmt.Errorf("window %d: %w", i, err)
+			}
+			result.Shifted = append(result.Shifted, regions...)
+		} else {
+			result.Conflicts = append(result.Conflicts, regions...)
+		}
+		targetOffset += uint64(len(target))
+	}
+
+	if result.Delta, err = MarshalDelta(parsed); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// shiftWindow re-encodes window with the source offsets of the COPYs that
+// produced regions moved by their shift. The source segment is resized to
+// cover every source COPY after the move.
+func shiftWindow(window *Window, regions []FuzzyRegion, targetOffset uint64, addressCache *AddressCache) error {
+	r, err := resolveWindow(window, addressCache)
+	if err != nil {
+		return err
+	}
+
+	// Absolute base offsets of each source COPY after shifting
+	shifts := make(map[uint64]int, len(regions))
+	for _, region := range regions {
+		shifts[region.TargetOffset-targetOffset] = region.Shift
+	}
+	segmentSize := window.SourceSegmentSize
+	starts := make([]int64, len(r.instructions))
+	low, high := int64(math.MaxUint32), int64(0)
+	var position uint64
+	for i, inst := range r.instructions {
+		if inst.Type == Copy && inst.Addr < segmentSize {
+			starts[i] = int64(window.SourceSegmentPosition) + int64(inst.Addr) + int64(shifts[position])
+			low, high = min(low, starts[i]), max(high, starts[i]+int64(inst.Size))
+		}
+		position += uint64(inst.Size)
+	}
+	if high > maxSourceSegment {
+		return fmt.Errorf("%w: shifted source segment would end at %d", ErrInvalidFormat, high)
+	}
+
+	instructions := make([]RuntimeInstruction, 0, len(r.instructions))
+	for i, inst := range r.instructions {
+		if inst.Type == Copy {
+			if inst.Addr < segmentSize {
+				inst.Addr = uint32(starts[i] - low)
+			} else {
+				inst.Addr = inst.Addr - segmentSize + uint32(high-low)
+			}
+		}
+		instructions = append(instructions, inst)
+	}
+	window.SourceSegmentPosition, window.SourceSegmentSize = uint32(low), uint32(high-low)
+	encodeInstructions(window, instructions)
+	return nil
+}
@@ -0,0 +1,113 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// rebaseFixture returns a base and a checksummed delta against it that
+// copies two separate stretches of the base around an ADD
+func rebaseFixture(t *testing.T) (base, delta, target []byte) {
+	t.Helper()
+	base = make([]byte, 300)
+	rand.New(rand.NewSource(7)).Read(base)
+
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(len(base))}
+	encodeInstructions(&window, []RuntimeInstruction{
+		{Type: Copy, Size: 100, Addr: 0},
+		{Type: Add, Size: 2, Data: []byte("XY")},
+		{Type: Copy, Size: 100, Addr: 150},
+	})
+	var err error
+	if target, err = Decode(base, mustMarshal(t, window)); err != nil {
+		t.Fatal(err)
+	}
+	window.WinIndicator |= VCDAdler32
+	window.HasChecksum, window.Checksum = true, ComputeChecksum(1, target)
+	return base, mustMarshal(t, window), target
+}
+
+func mustMarshal(t *testing.T, window Window) []byte {
+	t.Helper()
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func TestRebaseShiftedBase(t *testing.T) {
+	base, delta, target := rebaseFixture(t)
+
+	// Insert three bytes between the two copied stretches
+	modified := append(append(append([]byte{}, base[:120]...), "ins"...), base[120:]...)
+	result, err := Rebase(delta, modified, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conflicts) != 0 || len(result.Shifted) != 1 || result.Shifted[0].Shift != 3 {
+		t.Fatalf("shifted %+v, conflicts %+v", result.Shifted, result.Conflicts)
+	}
+
+	// The rebased delta applies to the modified base without fuzzy mode
+	got, err := Decode(modified, result.Delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatal("rebased delta produces a different target")
+	}
+}
+
+func TestRebaseConflict(t *testing.T) {
+	base, delta, target := rebaseFixture(t)
+
+	// Edit a byte the delta copies
+	modified := append([]byte{}, base...)
+	modified[50] ^= 0xff
+	result, err := Rebase(delta, modified, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Shifted) != 0 || len(result.Conflicts) != 2 || result.Conflicts[0].Length != 100 {
+		t.Fatalf("shifted %+v, conflicts %+v", result.Shifted, result.Conflicts)
+	}
+
+	// The conflicting window still applies, carrying the local edit
+	got, err := Decode(modified, result.Delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{}, target...)
+	want[50] ^= 0xff
+	if !bytes.Equal(got, want) {
+		t.Fatal("rebased delta does not carry the local edit")
+	}
+}
+
+func TestRebaseReplacesFingerprint(t *testing.T) {
+	g := GenerateDelta(2, ProfileCopyHeavy)
+	delta := withHeaderSection(g.Delta, VCDAppHeader, NewSourceFingerprint(g.Source).AppHeader())
+
+	// The same base: nothing moves and the fingerprint stays valid
+	result, err := Rebase(delta, g.Source, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Shifted) != 0 || len(result.Conflicts) != 0 {
+		t.Fatalf("shifted %+v, conflicts %+v", result.Shifted, result.Conflicts)
+	}
+	if got, err := Decode(g.Source, result.Delta); err != nil || !bytes.Equal(got, g.Target) {
+		t.Fatalf("rebased delta does not decode to the target: %v", err)
+	}
+
+	// A modified base gets its own fingerprint
+	modified := append(append([]byte{}, g.Source...), "tail"...)
+	if result, err = Rebase(delta, modified, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(modified, result.Delta); err != nil {
+		t.Fatalf("rebased delta rejects the new base: %v", err)
+	}
+}
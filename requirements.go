@@ -0,0 +1,98 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DeltaRequirements describes what a delta needs from the source and the
+// decoder, so callers can check they can apply it before decoding
+type DeltaRequirements struct {
+	NeedsSource           bool   // Some window copies from the source (VCD_SOURCE)
+	SourceLength          uint64 // Furthest source offset+size referenced by any window
+	NeedsTarget           bool   // Some window copies from earlier target data (VCD_TARGET)
+	CustomCodeTable       bool   // The header carries a custom code table (VCD_CODETABLE)
+	SecondaryCompression  bool   // Some window has compressed sections (Delta_Indicator)
+	SecondaryCompressorID byte   // Compressor named in the header when VCD_DECOMPRESS is set
+	Windows               int    // Number of windows in the delta
+	TargetLength          uint64 // Total length of the reconstructed target
+}
+
+// Requirements reads the header and window framing of a delta, without
+// parsing instructions, and reports what applying it requires
+func Requirements(delta []byte) (*DeltaRequirements, error) {
+	if len(delta) < MinimumFileSize {
+		return nil, errShortDelta(len(delta))
+	}
+
+	reader := bytes.NewReader(delta)
+	var header Header
+	if err := parseHeader(reader, &header); err != nil {
+		return nil, err
+	}
+
+	req := &DeltaRequirements{
+		CustomCodeTable:       header.Indicator&VCDCodetable != 0,
+		SecondaryCompressorID: header.SecondaryCompressorID,
+	}
+	for reader.Len() > 0 {
+		var window Window
+		if err := parseWindow(reader, &window, &header, req.Windows); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		req.Windows++
+		req.TargetLength += uint64(window.TargetWindowLength)
+
+		if window.WinIndicator&VCDSource != 0 {
+			req.NeedsSource = true
+			end := uint64(window.SourceSegmentPosition) + uint64(window.SourceSegmentSize)
+			if end > req.SourceLength {
+				req.SourceLength = end
+			}
+		}
+		if window.WinIndicator&VCDTarget != 0 {
+			req.NeedsTarget = true
+		}
+		if window.DeltaIndicator != 0 {
+			req.SecondaryCompression = true
+		}
+	}
+
+	return req, nil
+}
+
+// Check reports whether this decoder supports everything the delta uses and
+// source is long enough for every source segment. Errors wrap ErrUnsupported
+// or ErrSourceTooShort.
+func (r *DeltaRequirements) Check(source []byte) error {
+	caps := Capabilities()
+	switch {
+	case r.CustomCodeTable && !caps.CustomCodeTables:
+		return fmt.Errorf("%w: custom code table (VCD_CODETABLE)", ErrUnsupported)
+	case r.SecondaryCompression && !caps.SupportsCompressor(r.SecondaryCompressorID):
+		return fmt.Errorf("%w: secondary compression (Delta_Indicator)", ErrUnsupported)
+	case r.NeedsTarget && !caps.TargetSegments:
+		return fmt.Errorf("%w: target segment windows (VCD_TARGET)", ErrUnsupported)
+	}
+
+	if r.SourceLength > uint64(len(source)) {
+		return fmt.Errorf("%w: delta references %d bytes, source has %d", ErrSourceTooShort, r.SourceLength, len(source))
+	}
+	return nil
+}
+
+// checkSupported rejects windows using features the decoder does not
+// implement, rather than decoding them into garbage
+func checkSupported(header *Header, window *Window) error {
+	req := DeltaRequirements{
+		CustomCodeTable:       header.Indicator&VCDCodetable != 0,
+		SecondaryCompression:  window.DeltaIndicator != 0,
+		SecondaryCompressorID: header.SecondaryCompressorID,
+		NeedsTarget:           window.WinIndicator&VCDTarget != 0,
+	}
+	return req.Check(nil)
+}
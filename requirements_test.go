@@ -0,0 +1,180 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// withHeaderSection sets flag in the header indicator of delta and inserts
+// section as the corresponding length-prefixed header field
+func withHeaderSection(delta []byte, flag byte, section []byte) []byte {
+	const indicatorOffset = MinimumFileSize
+	out := append([]byte{}, delta[:indicatorOffset]...)
+	out = append(out, delta[indicatorOffset]|flag)
+	out = AppendVarint(out, uint32(len(section)))
+	out = append(out, section...)
+	return append(out, delta[indicatorOffset+1:]...)
+}
+
+// singleAddDelta assembles a delta with one window that ADDs a single byte,
+// using the given window and delta indicators
+func singleAddDelta(winIndicator, deltaIndicator byte) []byte {
+	const addSize1 = genAddSizedCodeBase + 1
+	encoding := []byte{1, deltaIndicator, 1, 1, 0, 'x', addSize1}
+
+	delta := []byte{VCDIFFMagic1, VCDIFFMagic2, VCDIFFMagic3, VCDIFFVersion, 0, winIndicator}
+	if winIndicator&(VCDSource|VCDTarget) != 0 {
+		delta = append(delta, 0, 0) // empty segment at position 0
+	}
+	delta = AppendVarint(delta, uint32(len(encoding)))
+	return append(delta, encoding...)
+}
+
+func TestRequirements(t *testing.T) {
+	g := GenerateDelta(21, ProfileCopyHeavy)
+
+	req, err := Requirements(g.Delta)
+	if err != nil {
+		t.Fatalf("Requirements failed: %v", err)
+	}
+	want := DeltaRequirements{
+		NeedsSource:  true,
+		SourceLength: uint64(len(g.Source)),
+		Windows:      ProfileCopyHeavy.Windows,
+		TargetLength: uint64(len(g.Target)),
+	}
+	if *req != want {
+		t.Fatalf("got %+v, expected %+v", *req, want)
+	}
+
+	if err := req.Check(g.Source); err != nil {
+		t.Errorf("Check with the full source: %v", err)
+	}
+	if err := req.Check(g.Source[:len(g.Source)-1]); !errors.Is(err, ErrSourceTooShort) {
+		t.Errorf("Check with a short source: got %v, expected ErrSourceTooShort", err)
+	}
+
+	noSource := GenerateDelta(21, ProfileNoSource)
+	req, err = Requirements(noSource.Delta)
+	if err != nil {
+		t.Fatalf("Requirements failed: %v", err)
+	}
+	if req.NeedsSource || req.SourceLength != 0 {
+		t.Errorf("source-less delta reported as needing %d source bytes", req.SourceLength)
+	}
+	if err := req.Check(nil); err != nil {
+		t.Errorf("Check without a source: %v", err)
+	}
+}
+
+func TestRequirementsUnsupportedFeatures(t *testing.T) {
+	plain := singleAddDelta(0, 0)
+
+	tests := []struct {
+		name  string
+		delta []byte
+		check func(*DeltaRequirements) bool
+	}{
+		{"secondary compression", singleAddDelta(0, VCDDataComp),
+			func(r *DeltaRequirements) bool { return r.SecondaryCompression }},
+		{"target segment", singleAddDelta(VCDTarget, 0),
+			func(r *DeltaRequirements) bool { return r.NeedsTarget }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := Requirements(tt.delta)
+			if err != nil {
+				t.Fatalf("Requirements failed: %v", err)
+			}
+			if !tt.check(req) {
+				t.Fatalf("feature not reported: %+v", *req)
+			}
+			if err := req.Check(nil); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Check: got %v, expected ErrUnsupported", err)
+			}
+			if _, err := Decode(nil, tt.delta); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Decode: got %v, expected ErrUnsupported", err)
+			}
+		})
+	}
+
+	if _, err := Decode(nil, plain); err != nil {
+		t.Fatalf("plain delta failed to decode: %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := Capabilities()
+
+	// The features rejected above must not be advertised
+	if caps.TargetSegments || caps.SupportsCompressor(0) {
+		t.Errorf("unsupported features advertised: %+v", caps)
+	}
+	if !caps.CustomCodeTables || !caps.Checksums || !caps.SourceFingerprints || !caps.Concatenated {
+		t.Errorf("supported features not advertised: %+v", caps)
+	}
+	if caps.MaxWindowLength == 0 || caps.MaxSourceLength == 0 {
+		t.Errorf("size limits not reported: %+v", caps)
+	}
+}
+
+func TestParseHeaderOptionalFields(t *testing.T) {
+	g := GenerateDelta(8, ProfileCopyHeavy)
+	appHeader := []byte("base=v1")
+
+	delta := withHeaderSection(g.Delta, VCDAppHeader, appHeader)
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatalf("ParseDelta failed: %v", err)
+	}
+	if !bytes.Equal(parsed.Header.AppHeader, appHeader) {
+		t.Errorf("got app header %q, expected %q", parsed.Header.AppHeader, appHeader)
+	}
+
+	// The app header is framing only, so the delta still decodes
+	result, err := Decode(g.Source, delta)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(result, g.Target) {
+		t.Fatal("delta with an app header decoded to a different target")
+	}
+
+	// A declared app header longer than the delta is rejected
+	truncated := withHeaderSection(g.Delta[:MinimumFileSize+1], VCDAppHeader, appHeader)
+	truncated = truncated[:len(truncated)-1]
+	if _, err := ParseDelta(truncated); err == nil {
+		t.Error("ParseDelta accepted a truncated app header")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	g := GenerateDelta(3, ProfileManyWindows)
+	delta := withHeaderSection(g.Delta, VCDAppHeader, []byte("app data"))
+
+	header, err := ParseHeader(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(header, parsed.Header) {
+		t.Errorf("ParseHeader = %+v, ParseDelta header %+v", header, parsed.Header)
+	}
+
+	// Only the header needs to be present
+	if _, err := ParseHeader(delta[:len(delta)-len(g.Delta)/2]); err != nil {
+		t.Errorf("truncated windows: %v", err)
+	}
+	if _, err := ParseHeader(delta[:MinimumFileSize+3]); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated app header: got %v, expected ErrInvalidFormat", err)
+	}
+	if _, err := ParseHeader([]byte("not a delta")); !errors.Is(err, ErrInvalidMagic) {
+		t.Errorf("not a delta: got %v, expected ErrInvalidMagic", err)
+	}
+}
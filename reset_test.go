@@ -0,0 +1,57 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDecoderReset(t *testing.T) {
+	type pair struct{ source, target, delta []byte }
+	var pairs []pair
+	for i := 0; i < 3; i++ {
+		source := []byte(fmt.Sprintf("base %d: the quick brown fox jumps over the lazy dog", i))
+		target := []byte(fmt.Sprintf("target %d: the quick brown fox jumps over the lazy cat", i))
+		delta, err := Encode(source, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair{source, target, delta})
+	}
+
+	decoder := NewDecoder(nil)
+	for round := 0; round < 2; round++ {
+		for i, p := range pairs {
+			decoder.Reset(p.source)
+			got, err := decoder.Decode(p.delta)
+			if err != nil || !bytes.Equal(got, p.target) {
+				t.Fatalf("round %d, pair %d: got %q, %v", round, i, got, err)
+			}
+		}
+	}
+
+	// Decodes running at once each get their own working memory
+	p := pairs[0]
+	decoder.Reset(p.source)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if got, err := decoder.Decode(p.delta); err != nil || !bytes.Equal(got, p.target) {
+					t.Errorf("concurrent decode: got %q, %v", got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	reused := testing.AllocsPerRun(10, func() { decoder.Decode(p.delta) })
+	fresh := testing.AllocsPerRun(10, func() { NewDecoder(p.source).Decode(p.delta) })
+	if reused >= fresh {
+		t.Errorf("a reused decoder made %v allocations, a new one %v", reused, fresh)
+	}
+}
@@ -0,0 +1,64 @@
+package vcdiff
+
+import "fmt"
+
+// SecondaryCompressor compresses the sections of encoded windows - RFC 3284
+// Section 4.1. Its ID is written to the header as the secondary compressor
+// ID, so the decoder must map it to the matching decompressor; xdelta3 uses
+// 1 for DJW, 2 for LZMA and 16 for FGK. Compress returns a section as it is
+// to be stored, including any framing its decompressor needs, such as the
+// uncompressed length.
+type SecondaryCompressor interface {
+	ID() byte
+	Compress(section []byte) ([]byte, error)
+}
+
+// WithSecondaryCompressor makes Encode compress each window's data,
+// instructions and addresses sections with c, setting the section's
+// Delta_Indicator bit and VCD_DECOMPRESS in the header. A section is only
+// replaced when compressing shrinks it, and a delta in which nothing shrank
+// is written without VCD_DECOMPRESS. This package's decoder does not
+// decompress sections, so such deltas are for peers whose decoders do.
+func WithSecondaryCompressor(c SecondaryCompressor) EncoderOption {
+	return func(e *encoder) {
+		e.compressor = c
+	}
+}
+
+// compressWindow compresses each section of window with c, keeping only
+// those that shrink
+func compressWindow(window *Window, c SecondaryCompressor) error {
+	for _, s := range []struct {
+		section *[]byte
+		bit     byte
+	}{
+		{&window.DataSection, VCDDataComp},
+		{&window.InstructionSection, VCDInstComp},
+		{&window.AddressSection, VCDAddrComp},
+	} {
+		if len(*s.section) == 0 {
+			continue
+		}
+		compressed, err := c.Compress(*s.section)
+		if err != nil {
+			return fmt.Errorf("secondary compression: %w", err)
+		}
+		if len(compressed) < len(*s.section) {
+			*s.section = compressed
+			window.DeltaIndicator |= s.bit
+		}
+	}
+	return nil
+}
+
+// markCompressed sets VCD_DECOMPRESS and c's ID in the header if any window
+// of parsed has a compressed section
+func markCompressed(parsed *ParsedDelta, c SecondaryCompressor) {
+	for i := range parsed.Windows {
+		if parsed.Windows[i].DeltaIndicator != 0 {
+			parsed.Header.Indicator |= VCDDecompress
+			parsed.Header.SecondaryCompressorID = c.ID()
+			return
+		}
+	}
+}
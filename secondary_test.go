@@ -0,0 +1,118 @@
+package vcdiff
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// flateCompressor is a SecondaryCompressor for tests
+type flateCompressor struct{ err error }
+
+func (flateCompressor) ID() byte { return 0x7f }
+
+func (c flateCompressor) Compress(section []byte) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var b bytes.Buffer
+	w, _ := flate.NewWriter(&b, flate.BestCompression)
+	w.Write(section)
+	w.Close()
+	return b.Bytes(), nil
+}
+
+func TestEncodeSecondaryCompression(t *testing.T) {
+	// Thousands of short COPYs separated by single ADDs make a long and
+	// repetitive instructions section
+	rng := rand.New(rand.NewSource(8))
+	source := make([]byte, 1<<16)
+	rng.Read(source)
+	var target []byte
+	for i := 0; i < 2000; i++ {
+		offset := rng.Intn(len(source) - 6)
+		target = append(target, '|')
+		target = append(target, source[offset:offset+6]...)
+	}
+
+	delta, err := Encode(source, target, WithSecondaryCompressor(flateCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := roundTrip(t, source, target)
+	if len(delta) >= len(plain) {
+		t.Fatalf("compressed delta is %d bytes, uncompressed %d", len(delta), len(plain))
+	}
+	if _, err := Decode(source, delta); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("got %v, expected ErrUnsupported from this decoder", err)
+	}
+
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Indicator&VCDDecompress == 0 || parsed.Header.SecondaryCompressorID != 0x7f {
+		t.Fatalf("got header indicator 0x%02x, compressor %d", parsed.Header.Indicator, parsed.Header.SecondaryCompressorID)
+	}
+
+	// Decompressing the marked sections gives back the plain encoding
+	if decompressed := decompressSections(t, delta); !bytes.Equal(decompressed, plain) {
+		t.Fatal("decompressed delta differs from the plain encoding")
+	}
+}
+
+// decompressSections returns delta with the sections flateCompressor
+// compressed decompressed again, as a decoder supporting it would
+func decompressSections(t testing.TB, delta []byte) []byte {
+	t.Helper()
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		for _, s := range []struct {
+			section *[]byte
+			bit     byte
+		}{{&window.DataSection, VCDDataComp}, {&window.InstructionSection, VCDInstComp}, {&window.AddressSection, VCDAddrComp}} {
+			if window.DeltaIndicator&s.bit == 0 {
+				continue
+			}
+			if *s.section, err = io.ReadAll(flate.NewReader(bytes.NewReader(*s.section))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		window.DeltaIndicator = 0
+	}
+	parsed.Header.Indicator &^= VCDDecompress
+	parsed.Header.SecondaryCompressorID = 0
+	decompressed, err := MarshalDelta(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Version == SDCHVersion {
+		// Interleaved sections are parsed apart once decompressed
+		decompressed[len(VCDIFFMagic)] = SDCHVersion
+	}
+	return decompressed
+}
+
+func TestEncodeSecondaryCompressionUnhelpful(t *testing.T) {
+	// Sections too short to shrink are left alone, with no VCD_DECOMPRESS
+	source, target := []byte("abcdefgh"), []byte("abcdefgh!")
+	delta, err := Encode(source, target, WithSecondaryCompressor(flateCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain := roundTrip(t, source, target); !bytes.Equal(delta, plain) {
+		t.Fatal("compressor that never helps changed the delta")
+	}
+
+	failure := errors.New("compressor failed")
+	if _, err := Encode(source, target, WithSecondaryCompressor(flateCompressor{err: failure})); !errors.Is(err, failure) {
+		t.Fatalf("got %v, expected the compressor's error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package vcdiff
+
+import "fmt"
+
+// Similarity estimates, from the delta alone, how closely the target
+// resembles the source it was encoded against. The score is between 0 and 1
+// and is the mean of two ratios:
+//
+//   - the fraction of target bytes produced by COPYs from the source, rather
+//     than by ADDs, RUNs or COPYs of earlier target data
+//   - the fraction of the target's size saved by sending the delta instead,
+//     which is 0 when the delta is as large as the target or larger
+//
+// A low score suggests the source was a poor ancestor and a full transfer
+// would have cost about as much. A delta with an empty target scores 1.
+func Similarity(delta []byte) (float64, error) {
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		return 0, err
+	}
+
+	var targetLength, sourceCopied uint64
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		if err := checkSupported(&parsed.Header, window); err != nil {
+			return 0, fmt.Errorf("window %d: %w", i, err)
+		}
+		r, err := resolveWindow(window, addressCache)
+		if err != nil {
+			return 0, fmt.Errorf("window %d: %w", i, err)
+		}
+		for _, inst := range r.instructions {
+			if inst.Type == Copy && window.WinIndicator&VCDSource != 0 && inst.Addr < window.SourceSegmentSize {
+				sourceCopied += uint64(inst.Size)
+			}
+		}
+		targetLength += uint64(window.TargetWindowLength)
+	}
+	if targetLength == 0 {
+		return 1, nil
+	}
+
+	copied := float64(sourceCopied) / float64(targetLength)
+	saved := max(0, 1-float64(len(delta))/float64(targetLength))
+	return (copied + saved) / 2, nil
+}
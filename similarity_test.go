@@ -0,0 +1,51 @@
+package vcdiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	source := bytes.Repeat([]byte("abcdefgh"), 128)
+
+	// An unchanged source: one COPY reproduces it from a tiny delta
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(len(source))}
+	encodeInstructions(&window, []RuntimeInstruction{{Type: Copy, Size: uint32(len(source))}})
+	same, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A replaced source: the whole target is ADDed
+	window = Window{}
+	encodeInstructions(&window, []RuntimeInstruction{{Type: Add, Size: uint32(len(source)), Data: source}})
+	replaced, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	high, err := Similarity(same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := Similarity(replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if high < 0.95 || low != 0 {
+		t.Errorf("got %.3f for an unchanged source and %.3f for a replaced one", high, low)
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileNoSource} {
+			score, err := Similarity(GenerateDelta(seed, profile).Delta)
+			if err != nil || score < 0 || score > 1 {
+				t.Fatalf("seed %d: score %v, error %v", seed, score, err)
+			}
+		}
+	}
+
+	if _, err := Similarity([]byte("not a delta")); err == nil {
+		t.Error("scored an invalid delta")
+	}
+}
@@ -0,0 +1,83 @@
+package vcdiff
+
+import (
+	"fmt"
+	"io"
+)
+
+// SparseResult summarizes an ApplySparse call
+type SparseResult struct {
+	TargetLength int64 // Length of the reconstructed target
+	Written      int64 // Bytes actually written to the destination
+}
+
+// ApplySparse applies delta to source and writes the target into dst, which
+// must already hold a copy of source, such as a clone of the base file. Only
+// ranges that differ from that copy are written: identity COPYs, which read
+// the source at their own target offset, are skipped. A small change to a
+// large file therefore writes only the changed bytes.
+//
+// dst is not truncated; callers should truncate it to TargetLength when the
+// target is shorter than the source. source must not share storage with dst,
+// for instance by being a memory map of the same file, since writes would
+// otherwise change data still to be copied. Windows are decoded one at a
+// time, so only one window's target is held in memory.
+func ApplySparse(dst io.WriterAt, source, delta []byte) (SparseResult, error) {
+	var result SparseResult
+
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		return result, err
+	}
+	for i := range parsed.Windows {
+		if err := checkSupported(&parsed.Header, &parsed.Windows[i]); err != nil {
+			return result, err
+		}
+	}
+	if err := verifySource(&parsed.Header, source); err != nil {
+		return result, err
+	}
+
+	// Record the window-relative ranges produced by identity COPYs
+	var identity [][2]uint32
+	var position, segmentStart uint32
+	var windowStart int64
+	d := NewDecoder(source).(*decoder)
+	d.hooks.OnInstruction = func(index int, inst RuntimeInstruction) error {
+		window := &parsed.Windows[index]
+		if inst.Type == Copy && window.WinIndicator&VCDSource != 0 && inst.Addr < window.SourceSegmentSize &&
+			int64(segmentStart)+int64(inst.Addr) == windowStart+int64(position) {
+			identity = append(identity, [2]uint32{position, position + inst.Size})
+		}
+		position += inst.Size
+		return nil
+	}
+
+	addressCache := NewAddressCache(d.nearSize, d.sameSize)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		identity, position, segmentStart = identity[:0], 0, window.SourceSegmentPosition
+
+		target, err := d.decodeWindow(i, window, source, addressCache)
+		if err != nil {
+			return result, err
+		}
+
+		// Write the gaps between identity ranges
+		var next uint32
+		for _, r := range append(identity, [2]uint32{uint32(len(target)), uint32(len(target))}) {
+			if r[0] > next {
+				n, err := dst.WriteAt(target[next:r[0]], windowStart+int64(next))
+				result.Written += int64(n)
+				if err != nil {
+					return result, fmt.Errorf("window %d: %w", i, err)
+				}
+			}
+			next = r[1]
+		}
+		windowStart += int64(len(target))
+	}
+
+	result.TargetLength = windowStart
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package vcdiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// fileBuffer is an in-memory io.WriterAt that grows like a file
+type fileBuffer struct {
+	data []byte
+}
+
+func (f *fileBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	return copy(f.data[off:], p), nil
+}
+
+// sparseApply runs ApplySparse on a copy of source and returns the truncated result
+func sparseApply(t *testing.T, source, delta []byte) ([]byte, SparseResult) {
+	t.Helper()
+	dst := &fileBuffer{data: append([]byte{}, source...)}
+	result, err := ApplySparse(dst, source, delta)
+	if err != nil {
+		t.Fatalf("ApplySparse failed: %v", err)
+	}
+	if result.TargetLength > int64(len(dst.data)) {
+		t.Fatalf("target length %d exceeds written file of %d bytes", result.TargetLength, len(dst.data))
+	}
+	return dst.data[:result.TargetLength], result
+}
+
+func TestApplySparseWritesOnlyChanges(t *testing.T) {
+	source := make([]byte, 300)
+	rand.New(rand.NewSource(4)).Read(source)
+
+	// Overwrite two bytes in the middle of the source
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(len(source))}
+	encodeInstructions(&window, []RuntimeInstruction{
+		{Type: Copy, Size: 100, Addr: 0},
+		{Type: Add, Size: 2, Data: []byte("XY")},
+		{Type: Copy, Size: 198, Addr: 102},
+	})
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := Decode(source, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, result := sparseApply(t, source, delta)
+	if !bytes.Equal(got, target) {
+		t.Fatal("sparse output differs from the decoded target")
+	}
+	if result.Written != 2 {
+		t.Errorf("wrote %d bytes, expected only the 2 changed", result.Written)
+	}
+}
+
+func TestApplySparseMatchesDecode(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileAddHeavy, ProfileManyWindows} {
+		for seed := int64(0); seed < 20; seed++ {
+			g := GenerateDelta(seed, profile)
+			if got, _ := sparseApply(t, g.Source, g.Delta); !bytes.Equal(got, g.Target) {
+				t.Fatalf("seed %d: sparse output differs from the generated target", seed)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package vcdiff
+
+import "time"
+
+// DecodeStats records where a decode spent its time, so performance
+// regressions can be localized in production rather than only in benchmarks
+type DecodeStats struct {
+	Parse        time.Duration // Parsing the header and window sections of the delta
+	SourceVerify time.Duration // Checking the source against an embedded fingerprint
+	Total        time.Duration // Wall time of the whole Decode call
+	Windows      []WindowStats // One entry per successfully decoded window
+}
+
+// WindowStats breaks down the decode time of a single window
+type WindowStats struct {
+	TargetLength uint32        // Length of the reconstructed target window
+	Instructions int           // Number of instructions executed
+	Parse        time.Duration // Parsing the instruction section
+	Execute      time.Duration // Running the instructions
+	Checksum     time.Duration // Validating the Adler-32 checksum, if present
+}
+
+// WithStats makes the decoder record timing into stats. The contents are
+// replaced at the start of every Decode call, so a decoder collecting stats
+// must not be used from several goroutines at once.
+func WithStats(stats *DecodeStats) DecoderOption {
+	return func(d *decoder) {
+		d.stats = stats
+	}
+}
@@ -0,0 +1,46 @@
+package vcdiff
+
+import (
+	"testing"
+)
+
+func TestWithStats(t *testing.T) {
+	g := GenerateDelta(11, ProfileCopyHeavy)
+
+	var stats DecodeStats
+	d := NewDecoder(g.Source, WithStats(&stats))
+	if _, err := d.Decode(g.Delta); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if len(stats.Windows) != ProfileCopyHeavy.Windows {
+		t.Fatalf("got stats for %d windows, expected %d", len(stats.Windows), ProfileCopyHeavy.Windows)
+	}
+
+	var targetLength uint32
+	sum := stats.Parse
+	for i, w := range stats.Windows {
+		if w.Instructions == 0 {
+			t.Errorf("window %d: no instructions counted", i)
+		}
+		if w.Execute+w.Checksum <= 0 {
+			t.Errorf("window %d: no execute or checksum time measured", i)
+		}
+		targetLength += w.TargetLength
+		sum += w.Parse + w.Execute + w.Checksum
+	}
+	if targetLength != uint32(len(g.Target)) {
+		t.Errorf("window target lengths sum to %d, expected %d", targetLength, len(g.Target))
+	}
+	if stats.Total < sum {
+		t.Errorf("total %v is less than the sum of phases %v", stats.Total, sum)
+	}
+
+	// A second decode replaces rather than accumulates the stats
+	if _, err := d.Decode(g.Delta); err != nil {
+		t.Fatalf("second decode failed: %v", err)
+	}
+	if len(stats.Windows) != ProfileCopyHeavy.Windows {
+		t.Fatalf("second decode left stats for %d windows, expected %d", len(stats.Windows), ProfileCopyHeavy.Windows)
+	}
+}
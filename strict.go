@@ -0,0 +1,75 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WithStrict makes Decode accept only deltas that any RFC 3284 decoder will
+// decode to the same target, for services that store or forward the deltas
+// they accept. It rejects with ErrUnsupported:
+//
+//   - open-vcdiff's version 0x53 format, with its interleaved sections and
+//     varint checksums
+//   - VCD_ADLER32 checksums, which are not part of RFC 3284
+//   - the conventions enabled by WithConcatenated, WithFuzzy, WithLenient
+//     and WithCacheSizes with non-default sizes
+//   - a source fingerprint in the application header, which other decoders
+//     do not check
+//
+// and with ErrInvalidFormat, behaviour RFC 3284 leaves undefined that is
+// otherwise tolerated: a delta encoding whose length differs from that of its
+// fields and sections, as with trailing bytes or overlong varints, and data
+// or address section bytes no instruction uses.
+//
+// Any other application header is left uninterpreted by RFC 3284 and is
+// accepted.
+func WithStrict() DecoderOption {
+	return func(d *decoder) {
+		d.strict = true
+	}
+}
+
+// checkStrictOptions rejects options that accept deltas beyond RFC 3284
+func (d *decoder) checkStrictOptions() error {
+	switch {
+	case d.concatenated:
+		return fmt.Errorf("%w: concatenated deltas in strict mode", ErrUnsupported)
+	case d.fuzzy != nil:
+		return fmt.Errorf("%w: fuzzy decoding in strict mode", ErrUnsupported)
+	case d.lenient != nil:
+		return fmt.Errorf("%w: lenient decoding in strict mode", ErrUnsupported)
+	case d.nearSize != NearCacheSize || d.sameSize != SameCacheModes:
+		return fmt.Errorf("%w: address cache sizes near=%d same=%d with the default code table in strict mode",
+			ErrUnsupported, d.nearSize, d.sameSize)
+	}
+	return nil
+}
+
+// checkStrictHeader rejects headers outside RFC 3284
+func checkStrictHeader(header *Header) error {
+	if header.Version != VCDIFFVersion {
+		return fmt.Errorf("%w: version 0x%02x in strict mode", ErrUnsupported, header.Version)
+	}
+	if bytes.HasPrefix(header.AppHeader, fingerprintTag) {
+		return fmt.Errorf("%w: source fingerprint in strict mode", ErrUnsupported)
+	}
+	return nil
+}
+
+// checkStrictWindow rejects window index if it uses an extension or relies
+// on behaviour RFC 3284 leaves undefined. Its instructions are run without
+// producing a target, through addressCache.
+func checkStrictWindow(index int, window *Window, addressCache *AddressCache) error {
+	if window.HasChecksum {
+		return fmt.Errorf("%w: window %d: VCD_ADLER32 checksum in strict mode", ErrUnsupported, index)
+	}
+	if length := len(appendEncoding(nil, window)); uint64(length) != uint64(window.DeltaEncodingLength) {
+		return fmt.Errorf("%w: window %d: delta encoding length %d, its fields and sections need %d",
+			ErrInvalidFormat, index, window.DeltaEncodingLength, length)
+	}
+	if err := validateWindow(window, addressCache); err != nil {
+		return fmt.Errorf("window %d: %w", index, err)
+	}
+	return nil
+}
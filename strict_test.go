@@ -0,0 +1,73 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	source := []byte("The quick brown fox jumps over the lazy dog. The quick brown fox.")
+	target := []byte("The quick red fox jumps over the lazy dog. The quick red fox!!!!")
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewDecoder(source, WithStrict()).Decode(delta); err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("strict decode of an encoded delta failed: %v", err)
+	}
+
+	checksummed, err := Recode(delta, source, RecodeOptions{AddChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interleaved, err := Encode(source, target, WithInterleaved())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprinted, err := Encode(source, target, WithAppHeader(NewSourceFingerprint(source).AppHeader()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}
+	unusedData := marshalWindow(t, Window{}, add, func(w *Window) { w.DataSection = append(w.DataSection, '!') })
+	// The delta encoding length of a window without a source follows the
+	// 5 byte header and the window indicator, and fits in one byte
+	const encodingLengthOffset = 6
+	trailing := marshalWindow(t, Window{}, add, nil)
+	trailing[encodingLengthOffset]++
+	trailing = append(trailing, 0)
+
+	tests := []struct {
+		name  string
+		delta []byte
+		want  error
+	}{
+		{"checksum", checksummed, ErrUnsupported},
+		{"interleaved", interleaved, ErrUnsupported},
+		{"source fingerprint", fingerprinted, ErrUnsupported},
+		{"unused data", unusedData, ErrInvalidFormat},
+		{"trailing delta encoding bytes", trailing, ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDecoder(source).Decode(tt.delta); err != nil {
+				t.Fatalf("decode without strict mode failed: %v", err)
+			}
+			if _, err := NewDecoder(source, WithStrict()).Decode(tt.delta); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, expected %v", err, tt.want)
+			}
+		})
+	}
+
+	for name, opt := range map[string]DecoderOption{
+		"concatenated": WithConcatenated(),
+		"fuzzy":        WithFuzzy(8, &FuzzyReport{}),
+		"cache sizes":  WithCacheSizes(2, 2),
+	} {
+		if _, err := NewDecoder(source, WithStrict(), opt).Decode(delta); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: got %v, expected ErrUnsupported", name, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// TargetSize returns the length of the target the delta reconstructs, the sum
+// of its windows' target lengths
+func (p *ParsedDelta) TargetSize() uint64 {
+	return targetSize(p.Windows)
+}
+
+// targetSize returns the sum of windows' target lengths
+func targetSize(windows []Window) uint64 {
+	var size uint64
+	for i := range windows {
+		size += uint64(windows[i].TargetWindowLength)
+	}
+	return size
+}
+
+// TargetSizeOf returns the length of the target delta reconstructs without
+// parsing it. Only the header and the start of each window are read; the
+// sections are skipped unchecked, so a delta that passes here can still fail
+// to decode. Callers can use it to size buffers or reject large targets
+// before decoding.
+func TargetSizeOf(delta []byte) (uint64, error) {
+	if len(delta) < MinimumFileSize {
+		return 0, errShortDelta(len(delta))
+	}
+
+	reader := bytes.NewReader(delta)
+	var header Header
+	if err := parseHeader(reader, &header); err != nil {
+		return 0, err
+	}
+
+	var size uint64
+	for window := 0; reader.Len() > 0; window++ {
+		length, err := skipWindow(reader)
+		if err != nil {
+			return 0, fmt.Errorf("window %d: %w", window, err)
+		}
+		size += uint64(length)
+	}
+	return size, nil
+}
+
+// skipWindow reads a window's indicator, segment and lengths, returns its
+// target window length and moves reader past the rest of the window
+func skipWindow(reader *bytes.Reader) (uint32, error) {
+	offset := int(reader.Size()) - reader.Len()
+	indicator, err := reader.ReadByte()
+	if err != nil {
+		return 0, errUnexpectedEOF("window indicator", 1)
+	}
+	if indicator&^(VCDSource|VCDTarget|VCDAdler32) != 0 {
+		return 0, errReservedBitsSet("window indicator", offset, indicator)
+	}
+	if indicator&(VCDSource|VCDTarget) != 0 {
+		// Segment size and position
+		for i := 0; i < 2; i++ {
+			if _, err := ReadVarint(reader); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	deltaSize, err := ReadVarint(reader)
+	if err != nil {
+		return 0, err
+	}
+	if int64(deltaSize) > int64(reader.Len()) {
+		return 0, errUnexpectedEOF("delta encoding", int(int64(deltaSize)-int64(reader.Len())))
+	}
+	end := reader.Len() - int(deltaSize)
+	targetSize, err := ReadVarint(reader)
+	if err != nil {
+		return 0, err
+	}
+	if reader.Len() < end {
+		return 0, errInvalidValue("delta encoding length", offset, deltaSize, "too short for the target window length")
+	}
+	_, err = reader.Seek(int64(reader.Len()-end), io.SeekCurrent)
+	return targetSize, err
+}
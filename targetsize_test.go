@@ -0,0 +1,46 @@
+package vcdiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTargetSize(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileManyWindows, ProfileNoSource} {
+		for seed := int64(0); seed < 5; seed++ {
+			g := GenerateDelta(seed, profile)
+			parsed, err := ParseDelta(g.Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.TargetSize(); got != uint64(len(g.Target)) {
+				t.Errorf("seed %d: TargetSize() = %d, expected %d", seed, got, len(g.Target))
+			}
+			got, err := TargetSizeOf(g.Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != uint64(len(g.Target)) {
+				t.Errorf("seed %d: TargetSizeOf = %d, expected %d", seed, got, len(g.Target))
+			}
+		}
+	}
+
+	// Interleaved deltas frame their windows the same way
+	target := []byte("a target long enough to make a few instructions, a target")
+	delta, err := Encode(target[:20], target, WithInterleaved())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := TargetSizeOf(delta); err != nil || got != uint64(len(target)) {
+		t.Errorf("interleaved: TargetSizeOf = %d, %v, expected %d", got, err, len(target))
+	}
+
+	g := GenerateDelta(1, ProfileManyWindows)
+	if _, err := TargetSizeOf(g.Delta[:len(g.Delta)-1]); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated delta: got %v, expected ErrInvalidFormat", err)
+	}
+	if _, err := TargetSizeOf([]byte("not a delta")); !errors.Is(err, ErrInvalidMagic) {
+		t.Errorf("not a delta: got %v, expected ErrInvalidMagic", err)
+	}
+}
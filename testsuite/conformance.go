@@ -0,0 +1,111 @@
+package testsuite
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// Categories of the embedded corpus, named as in submodules/vcdiff-tests
+const (
+	CategoryPositive = "targeted-positive" // Cases that must decode to their target
+	CategoryNegative = "targeted-negative" // Cases that must be rejected
+)
+
+// corpus is a minimal conformance corpus in the suite's layout, so the
+// conformance tests run without the submodule checked out. It is generated
+// from the vcdifftest package; see TestCorpusUpToDate.
+//
+//go:embed corpus
+var corpus embed.FS
+
+// DecoderFunc decodes delta against source, as vcdiff.Decode does
+type DecoderFunc func(source, delta []byte) ([]byte, error)
+
+// Case is one test case of the embedded corpus
+type Case struct {
+	Metadata *Metadata
+	Source   []byte
+	Target   []byte // Empty for negative cases
+	Delta    []byte
+}
+
+// Corpus returns the cases of the embedded corpus, positive cases first
+func Corpus() ([]Case, error) {
+	var cases []Case
+	for _, category := range []string{CategoryPositive, CategoryNegative} {
+		dirs, err := fs.ReadDir(corpus, path.Join("corpus", category))
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			c, err := loadCase(path.Join("corpus", category, dir.Name()))
+			if err != nil {
+				return nil, err
+			}
+			cases = append(cases, c)
+		}
+	}
+	return cases, nil
+}
+
+// loadCase reads the case in dir of the embedded corpus
+func loadCase(dir string) (Case, error) {
+	var files [4][]byte
+	for i, name := range []string{"metadata.json", "source", "target", "delta.vcdiff"} {
+		data, err := corpus.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return Case{}, err
+		}
+		files[i] = data
+	}
+	metadata, err := parseMetadata(files[0], path.Join(dir, "metadata.json"))
+	if err != nil {
+		return Case{}, err
+	}
+	return Case{Metadata: metadata, Source: files[1], Target: files[2], Delta: files[3]}, nil
+}
+
+// RunConformance runs decode against every case of the embedded corpus, as a
+// subtest per case named <category>/<name>. Positive cases must decode to
+// their target; negative cases must return an error, and errors carrying a
+// vcdiff.ErrorCode must carry the one the case expects. Decoders in other
+// packages can use it to check they agree with this one.
+func RunConformance(t *testing.T, decode DecoderFunc) {
+	t.Helper()
+	cases, err := Corpus()
+	if err != nil {
+		t.Fatalf("failed to load the conformance corpus: %v", err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("the conformance corpus is empty")
+	}
+
+	for _, c := range cases {
+		t.Run(c.Metadata.Category+"/"+c.Metadata.Name, func(t *testing.T) {
+			result, err := decode(c.Source, c.Delta)
+			if c.Metadata.Category == CategoryNegative {
+				if err == nil {
+					t.Fatalf("expected decode to fail (%s) but it produced %d bytes", c.Metadata.Description, len(result))
+				}
+				// Decoders reporting errors of their own need only fail
+				var code vcdiff.ErrorCode
+				if errors.As(err, &code) {
+					RequireDecodeError(t, err, c.Metadata.ErrorWants()...)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected successful decode but got error: %v", err)
+			}
+			if !bytes.Equal(result, c.Target) {
+				t.Fatalf("result differs from target: got %q, expected %q", result, c.Target)
+			}
+		})
+	}
+}
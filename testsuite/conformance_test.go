@@ -0,0 +1,158 @@
+package testsuite
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+	"github.com/ably/vcdiff-go/vcdifftest"
+)
+
+var update = flag.Bool("update", false, "rewrite the embedded corpus from the vcdifftest vectors")
+
+// writeCorpusCase writes vector as a case directory under corpus
+func writeCorpusCase(dir, category string, v vcdifftest.Vector) error {
+	caseDir := filepath.Join(dir, category, v.Name)
+	if err := os.MkdirAll(caseDir, 0o755); err != nil {
+		return err
+	}
+	files := map[string][]byte{"source": v.Source, "target": v.Target, "delta.vcdiff": v.Delta}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(caseDir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	metadata := &Metadata{}
+	if !v.ExpectError {
+		generated, err := GenerateFromFiles(filepath.Join(caseDir, "source"), filepath.Join(caseDir, "target"), filepath.Join(caseDir, "delta.vcdiff"))
+		if err != nil {
+			return err
+		}
+		metadata = generated
+	}
+	metadata.Name = v.Name
+	metadata.Description = v.Description
+	metadata.Category = category
+	metadata.ExpectedBehavior = "decode"
+	if v.ExpectError {
+		metadata.ExpectedBehavior = "reject"
+		metadata.ExpectedErrorType = string(v.ErrorCode)
+	}
+	return metadata.Save(filepath.Join(caseDir, "metadata.json"))
+}
+
+// TestCorpusUpToDate checks the embedded corpus matches the vcdifftest
+// vectors. Run with -update to regenerate it.
+func TestCorpusUpToDate(t *testing.T) {
+	dir := "corpus"
+	if !*update {
+		dir = t.TempDir()
+	} else if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vcdifftest.Valid() {
+		if err := writeCorpusCase(dir, CategoryPositive, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range vcdifftest.Broken() {
+		if err := writeCorpusCase(dir, CategoryNegative, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *update {
+		return
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		want, _ := os.ReadFile(path)
+		if got, err := corpus.ReadFile(filepath.ToSlash(filepath.Join("corpus", rel))); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("embedded corpus file %s is out of date; run go test ./testsuite -update", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunConformance(t *testing.T) {
+	cases, err := Corpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var positive, negative int
+	for _, c := range cases {
+		switch c.Metadata.Category {
+		case CategoryPositive:
+			positive++
+		case CategoryNegative:
+			negative++
+		}
+	}
+	if positive == 0 || negative == 0 {
+		t.Fatalf("corpus has %d positive and %d negative cases, expected both", positive, negative)
+	}
+
+	for _, c := range cases {
+		if c.Metadata.Category != CategoryNegative {
+			continue
+		}
+		t.Run(c.Metadata.Name, func(t *testing.T) {
+			if c.Metadata.ExpectedErrorType == "" {
+				t.Fatal("negative case declares no expected_error_type")
+			}
+			_, err := vcdiff.Decode(c.Source, c.Delta)
+			RequireDecodeError(t, err, c.Metadata.ErrorWants()...)
+		})
+	}
+
+	RunConformance(t, vcdiff.Decode)
+}
+
+func TestRequireDecodeError(t *testing.T) {
+	// Window 2 copies from a source other than the one it was built against,
+	// so its checksum fails
+	window := vcdifftest.Window{Instructions: []vcdifftest.Instruction{vcdifftest.Add([]byte("ok"))}}
+	delta, _ := vcdifftest.Delta{Windows: []vcdifftest.Window{window, window, {
+		Source:       true,
+		SegmentSize:  4,
+		Instructions: []vcdifftest.Instruction{vcdifftest.Copy(0, 4)},
+		Checksum:     true,
+	}}}.Build([]byte("base"))
+	_, err := vcdiff.Decode([]byte("BASE"), delta)
+	RequireDecodeError(t, err, WantWindow(2), WantCode(vcdiff.ErrCodeChecksum))
+
+	_, err = vcdiff.Decode(nil, []byte("not a delta"))
+	RequireDecodeError(t, err, WantWindow(-1), WantCode(vcdiff.ErrCodeBadMagic))
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorWant
+	}{
+		{"no error", nil, nil},
+		{"wrong code", vcdiff.ErrInvalidMagic, WantCode(vcdiff.ErrCodeChecksum)},
+		{"no code", errors.New("other"), WantCode(vcdiff.ErrCodeChecksum)},
+		{"wrong window", &vcdiff.ChecksumError{Window: 1}, WantWindow(2)},
+		{"no window", vcdiff.ErrInvalidChecksum, WantWindow(0)},
+	}
+	for _, tt := range tests {
+		var wants []ErrorWant
+		if tt.want != nil {
+			wants = append(wants, tt.want)
+		}
+		if err := checkDecodeError(tt.err, wants...); err == nil {
+			t.Errorf("%s: accepted %v", tt.name, tt.err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package testsuite
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// ErrorWant is an expectation of a decode error, checked by
+// RequireDecodeError. It returns why err falls short, or nil if it does not.
+type ErrorWant func(err error) error
+
+// WantCode expects an error carrying code, as found with errors.As
+func WantCode(code vcdiff.ErrorCode) ErrorWant {
+	return func(err error) error {
+		var got vcdiff.ErrorCode
+		if !errors.As(err, &got) {
+			return fmt.Errorf("got error %q with no code, expected code %q", err, code)
+		}
+		if got != code {
+			return fmt.Errorf("got error %q with code %q, expected code %q", err, got, code)
+		}
+		return nil
+	}
+}
+
+// WantWindow expects an error reported against window index, or against the
+// header when index is -1. The window is that of a vcdiff.ParseError or
+// vcdiff.ChecksumError in err's chain; other errors do not name one.
+func WantWindow(index int) ErrorWant {
+	return func(err error) error {
+		got, ok := errorWindow(err)
+		if !ok {
+			return fmt.Errorf("got error %q naming no window, expected window %d", err, index)
+		}
+		if got != index {
+			return fmt.Errorf("got error %q in window %d, expected window %d", err, got, index)
+		}
+		return nil
+	}
+}
+
+// errorWindow returns the index of the window err is reported against
+func errorWindow(err error) (int, bool) {
+	var parseErr *vcdiff.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.WindowIndex, true
+	}
+	var checksumErr *vcdiff.ChecksumError
+	if errors.As(err, &checksumErr) {
+		return checksumErr.Window, true
+	}
+	return 0, false
+}
+
+// RequireDecodeError stops the test unless err is a decode failure meeting
+// every want:
+//
+//	_, err := vcdiff.Decode(source, delta)
+//	testsuite.RequireDecodeError(t, err, testsuite.WantWindow(2), testsuite.WantCode(vcdiff.ErrCodeChecksum))
+func RequireDecodeError(t testing.TB, err error, wants ...ErrorWant) {
+	t.Helper()
+	if err := checkDecodeError(err, wants...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkDecodeError returns why err is not a decode failure meeting every
+// want, or nil if it is
+func checkDecodeError(err error, wants ...ErrorWant) error {
+	if err == nil {
+		return errors.New("expected decode to fail but it succeeded")
+	}
+	for _, want := range wants {
+		if err := want(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package testsuite describes the VCDIFF conformance test suite layout used
+// by submodules/vcdiff-tests, where each test case is a directory holding
+// source, target, delta.vcdiff and metadata.json files.
+package testsuite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// Metadata represents the metadata.json structure for test cases
+type Metadata struct {
+	Name               string     `json:"name"`
+	Description        string     `json:"description"`
+	Category           string     `json:"category"`
+	ExpectedBehavior   string     `json:"expected_behavior"`
+	TestObjectives     []string   `json:"test_objectives"`
+	ExpectedErrorType  string     `json:"expected_error_type"`
+	ExpectedProperties Properties `json:"expected_properties"`
+}
+
+// Properties describes the expected characteristics of a test case's delta
+type Properties struct {
+	SourceSize         int    `json:"source_size"`
+	TargetSize         int    `json:"target_size"`
+	HasChecksum        bool   `json:"has_checksum"`
+	InstructionCount   int    `json:"instruction_count"`
+	WindowCount        int    `json:"window_count"`
+	PrimaryInstruction string `json:"primary_instruction"`
+	ShouldFailFast     bool   `json:"should_fail_fast"`
+	ErrorLocation      string `json:"error_location"`
+}
+
+// ErrInvalidMetadata is wrapped by all Validate failures
+var ErrInvalidMetadata = errors.New("invalid test metadata")
+
+// Load reads and validates a metadata.json file
+func Load(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadata(data, path)
+}
+
+// parseMetadata decodes and validates the contents of the metadata.json at
+// path
+func parseMetadata(data []byte, path string) (*Metadata, error) {
+	metadata := &Metadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata.json %s: %w", path, err)
+	}
+	if err := metadata.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return metadata, nil
+}
+
+// Validate checks that the metadata is internally consistent
+func (m *Metadata) Validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidMetadata)
+	}
+
+	p := m.ExpectedProperties
+	counts := []struct {
+		field string
+		value int
+	}{
+		{"source_size", p.SourceSize},
+		{"target_size", p.TargetSize},
+		{"instruction_count", p.InstructionCount},
+		{"window_count", p.WindowCount},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			return fmt.Errorf("%w: %s must not be negative, got %d", ErrInvalidMetadata, c.field, c.value)
+		}
+	}
+
+	if p.InstructionCount > 0 && p.WindowCount == 0 {
+		return fmt.Errorf("%w: instruction_count %d requires at least one window", ErrInvalidMetadata, p.InstructionCount)
+	}
+
+	return nil
+}
+
+// ErrorWants returns what the metadata expects of a decode error: when
+// expected_error_type is set, the vcdiff.ErrorCode it names
+func (m *Metadata) ErrorWants() []ErrorWant {
+	if m.ExpectedErrorType == "" {
+		return nil
+	}
+	return []ErrorWant{WantCode(vcdiff.ErrorCode(m.ExpectedErrorType))}
+}
+
+// CheckError checks err is a decode failure of the kind the metadata
+// declares, as RequireDecodeError does with ErrorWants
+func (m *Metadata) CheckError(err error) error {
+	return checkDecodeError(err, m.ErrorWants()...)
+}
+
+// Save writes the metadata as indented JSON
+func (m *Metadata) Save(path string) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// GenerateFromFiles computes the expected properties of a test case from its
+// source, target and delta files. Descriptive fields (name, description,
+// category, objectives) are left for the caller to fill in.
+func GenerateFromFiles(sourcePath, targetPath, deltaPath string) (*Metadata, error) {
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	target, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := os.ReadFile(deltaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := vcdiff.ParseDelta(delta)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing delta %s: %w", deltaPath, err)
+	}
+
+	metadata := &Metadata{}
+	p := &metadata.ExpectedProperties
+	p.SourceSize = len(source)
+	p.TargetSize = len(target)
+	p.WindowCount = len(parsed.Windows)
+	p.InstructionCount = len(parsed.Instructions)
+
+	for _, window := range parsed.Windows {
+		if window.HasChecksum {
+			p.HasChecksum = true
+		}
+	}
+
+	// The primary instruction is the one producing the most target bytes
+	produced := map[vcdiff.InstructionType]uint64{}
+	for _, instruction := range parsed.Instructions {
+		produced[instruction.Type] += uint64(instruction.Size)
+	}
+	var most uint64
+	for _, instType := range []vcdiff.InstructionType{vcdiff.Add, vcdiff.Copy, vcdiff.Run} {
+		if produced[instType] > most {
+			most = produced[instType]
+			p.PrimaryInstruction = instType.String()
+		}
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,151 @@
+package testsuite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// addDelta is a single window producing "TEST" with one ADD instruction
+var addDelta = []byte{
+	0xd6, 0xc3, 0xc4, 0x00, 0x00, // header
+	0x00, 0x0a, // window indicator, delta encoding length
+	0x04, 0x00, 0x04, 0x01, 0x00, // target length, delta indicator, section lengths
+	0x54, 0x45, 0x53, 0x54, // data section
+	0x05, // ADD size 4
+}
+
+func writeCase(t *testing.T, source, target, delta []byte) (string, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "source"),
+		filepath.Join(dir, "target"),
+		filepath.Join(dir, "delta.vcdiff"),
+	}
+	for i, data := range [][]byte{source, target, delta} {
+		if err := os.WriteFile(paths[i], data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths[0], paths[1], paths[2]
+}
+
+func TestGenerateFromFiles(t *testing.T) {
+	sourcePath, targetPath, deltaPath := writeCase(t, []byte("base"), []byte("TEST"), addDelta)
+
+	metadata, err := GenerateFromFiles(sourcePath, targetPath, deltaPath)
+	if err != nil {
+		t.Fatalf("GenerateFromFiles failed: %v", err)
+	}
+
+	want := Properties{
+		SourceSize:         4,
+		TargetSize:         4,
+		InstructionCount:   1,
+		WindowCount:        1,
+		PrimaryInstruction: "ADD",
+	}
+	if metadata.ExpectedProperties != want {
+		t.Fatalf("got properties %+v, expected %+v", metadata.ExpectedProperties, want)
+	}
+}
+
+func TestGenerateFromFilesRejectsBadDelta(t *testing.T) {
+	sourcePath, targetPath, deltaPath := writeCase(t, nil, nil, []byte{0x00, 0x01, 0x02, 0x03})
+
+	if _, err := GenerateFromFiles(sourcePath, targetPath, deltaPath); err == nil {
+		t.Fatal("expected error for malformed delta")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	sourcePath, targetPath, deltaPath := writeCase(t, []byte("base"), []byte("TEST"), addDelta)
+
+	metadata, err := GenerateFromFiles(sourcePath, targetPath, deltaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata.Name = "add-only"
+	metadata.Category = "targeted-positive"
+
+	path := filepath.Join(t.TempDir(), "metadata.json")
+	if err := metadata.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Name != metadata.Name || loaded.ExpectedProperties != metadata.ExpectedProperties {
+		t.Fatalf("loaded metadata %+v differs from saved %+v", loaded, metadata)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata Metadata
+		valid    bool
+	}{
+		{
+			name:     "minimal",
+			metadata: Metadata{Name: "case"},
+			valid:    true,
+		},
+		{
+			name:     "missing name",
+			metadata: Metadata{},
+		},
+		{
+			name:     "negative size",
+			metadata: Metadata{Name: "case", ExpectedProperties: Properties{TargetSize: -1}},
+		},
+		{
+			name:     "instructions without windows",
+			metadata: Metadata{Name: "case", ExpectedProperties: Properties{InstructionCount: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metadata.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMetadata) {
+				t.Fatalf("expected ErrInvalidMetadata, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsMalformedJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for malformed JSON")
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	metadata := &Metadata{Name: "case", ExpectedErrorType: string(vcdiff.ErrCodeChecksum)}
+	if err := metadata.CheckError(nil); err == nil {
+		t.Error("expected an error for a decode that succeeded")
+	}
+	if err := metadata.CheckError(vcdiff.ErrInvalidChecksum); err != nil {
+		t.Errorf("unexpected error for a matching code: %v", err)
+	}
+	if err := metadata.CheckError(vcdiff.ErrInvalidMagic); err == nil {
+		t.Error("expected an error for a different code")
+	}
+	if err := (&Metadata{Name: "case"}).CheckError(errors.New("any")); err != nil {
+		t.Errorf("unexpected error without an expected type: %v", err)
+	}
+}
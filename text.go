@@ -0,0 +1,65 @@
+package vcdiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// TextFormat is what WithTextValidation requires the decoded target to be
+type TextFormat int
+
+const (
+	TextUTF8 TextFormat = iota + 1 // Valid UTF-8
+	TextJSON                       // Valid UTF-8 holding exactly one JSON value
+)
+
+// WithTextValidation makes Decode check that the target is valid text in the
+// given format, failing with an error wrapping ErrInvalidText if not. Deltas
+// for realtime text and JSON payloads carry no checksum as a rule, so a delta
+// applied to the wrong base tends to decode successfully into mojibake; this
+// turns that into a distinct, detectable error.
+func WithTextValidation(format TextFormat) DecoderOption {
+	return func(d *decoder) {
+		d.text = format
+	}
+}
+
+// DecodeString decodes delta against source and returns the target as a
+// string, after checking that it is valid UTF-8. Pass
+// WithTextValidation(TextJSON) to also require a JSON value.
+func DecodeString(source, delta []byte, opts ...DecoderOption) (string, error) {
+	opts = append([]DecoderOption{WithTextValidation(TextUTF8)}, opts...)
+	target, err := NewDecoder(source, opts...).Decode(delta)
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
+
+// checkText validates target against format
+func checkText(target []byte, format TextFormat) error {
+	if !utf8.Valid(target) {
+		offset := 0
+		for {
+			r, size := utf8.DecodeRune(target[offset:])
+			if r == utf8.RuneError && size == 1 {
+				return fmt.Errorf("%w: invalid UTF-8 at target offset %d", ErrInvalidText, offset)
+			}
+			offset += size
+		}
+	}
+
+	if format == TextJSON {
+		var value json.RawMessage
+		if err := json.Unmarshal(target, &value); err != nil {
+			var syntax *json.SyntaxError
+			if errors.As(err, &syntax) {
+				return fmt.Errorf("%w: invalid JSON at target offset %d: %v", ErrInvalidText, syntax.Offset, err)
+			}
+			return fmt.Errorf("%w: invalid JSON: %v", ErrInvalidText, err)
+		}
+	}
+	return nil
+}
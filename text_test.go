@@ -0,0 +1,51 @@
+package vcdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// textDelta builds a delta that keeps the first keep bytes of the source and
+// appends add
+func textDelta(t *testing.T, keep int, add string) []byte {
+	t.Helper()
+	window := Window{WinIndicator: VCDSource, SourceSegmentSize: uint32(keep)}
+	encodeInstructions(&window, []RuntimeInstruction{
+		{Type: Copy, Size: uint32(keep)},
+		{Type: Add, Size: uint32(len(add)), Data: []byte(add)},
+	})
+	delta, err := MarshalDelta(&ParsedDelta{Windows: []Window{window}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func TestDecodeString(t *testing.T) {
+	base := []byte(`{"name":"caf`)
+	delta := textDelta(t, len(base), `é"}`)
+
+	got, err := DecodeString(base, delta, WithTextValidation(TextJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"name":"café"}` {
+		t.Fatalf("got %q", got)
+	}
+
+	// A base ending mid-rune leaves a broken sequence in the target
+	wrongBase := []byte("{\"name\":\"ca\xc3")
+	if _, err := DecodeString(wrongBase, delta); !errors.Is(err, ErrInvalidText) || !strings.Contains(err.Error(), "offset 11") {
+		t.Errorf("broken UTF-8: got %v, expected ErrInvalidText at offset 11", err)
+	}
+
+	// Valid UTF-8 that is not JSON passes unless JSON is required
+	notJSON := []byte(`{"name": caf`)
+	if _, err := DecodeString(notJSON, delta); err != nil {
+		t.Errorf("UTF-8 only: %v", err)
+	}
+	if _, err := DecodeString(notJSON, delta, WithTextValidation(TextJSON)); !errors.Is(err, ErrInvalidText) {
+		t.Errorf("invalid JSON: got %v, expected ErrInvalidText", err)
+	}
+}
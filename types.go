@@ -6,6 +6,7 @@ const (
 	VCDIFFMagic2  = 0xC3 // Second magic byte: 'C' with high bit set
 	VCDIFFMagic3  = 0xC4 // Third magic byte: 'D' with high bit set
 	VCDIFFVersion = 0x00 // Version 0 as defined in RFC 3284
+	SDCHVersion   = 0x53 // 'S': open-vcdiff's extended format, allowing interleaved sections and varint checksums
 )
 
 // VCDIFFMagic is the expected magic number sequence - RFC 3284 Section 4.1
@@ -25,6 +26,13 @@ const (
 	VCDAdler32 = 0x04 // VCD_ADLER32: window includes Adler-32 checksum (non-standard extension)
 )
 
+// Delta indicator flags - RFC 3284 Section 4.3
+const (
+	VCDDataComp = 0x01 // VCD_DATACOMP: data section is compressed
+	VCDInstComp = 0x02 // VCD_INSTCOMP: instructions section is compressed
+	VCDAddrComp = 0x04 // VCD_ADDRCOMP: addresses section is compressed
+)
+
 // Variable-length integer encoding constants - RFC 3284 Section 2
 const (
 	VarintContinuationBit = 0x80 // High bit indicates continuation
@@ -46,6 +54,7 @@ const (
 // Address cache configuration - RFC 3284 Section 5.3
 const (
 	NearCacheSize        = 4       // Size of "near" address cache
+	SameCacheModes       = 3       // Number of "same" cache modes (s_same)
 	SameCacheSize        = 3 * 256 // Size of "same" address cache
 	InstructionTableSize = 256     // Size of instruction code table
 )
@@ -63,9 +72,14 @@ const (
 )
 
 type Header struct {
-	Magic     [3]byte
-	Version   byte
-	Indicator byte
+	Magic                 [3]byte
+	Version               byte
+	Indicator             byte
+	SecondaryCompressorID byte   // Secondary compressor ID when VCD_DECOMPRESS is set - RFC 3284 Section 4.1
+	CodeTable             []byte // Encoded code table when VCD_CODETABLE is set - RFC 3284 Section 7
+	AppHeader             []byte // Application data when VCD_APPHEADER is set - RFC 3284 Section 4.1
+
+	codes *headerCodeTable // Table and cache sizes windows are read with, nil for the defaults
 }
 
 type Window struct {
@@ -83,6 +97,9 @@ type Window struct {
 	AddressSection           []byte // Addresses section for COPYs - RFC 3284 Section 4.3
 	Checksum                 uint32 // Adler-32 checksum of target window (VCD_ADLER32 extension)
 	HasChecksum              bool   // Whether VCD_ADLER32 bit is set in WinIndicator
+	Interleaved              bool   // Read from open-vcdiff's interleaved layout; the sections above hold it separated
+
+	codes *headerCodeTable // Table and cache sizes of the delta's header, nil for the defaults
 }
 
 // Legacy instruction type for backwards compatibility
@@ -0,0 +1,87 @@
+package vcdiff
+
+import "fmt"
+
+// Validate checks that delta is well formed without applying it, for
+// services that accept deltas they have no base for. Beyond what ParseDelta
+// checks, every window's instructions must produce exactly its target
+// length, use up its data and address sections, and copy only from its
+// source segment or target bytes already produced. Whether the base is long
+// enough for the source segments, and checksums, can only be checked by
+// decoding. Errors wrap ErrInvalidFormat, or ErrUnsupported for deltas using
+// features this package cannot decode.
+func Validate(delta []byte) error {
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		return err
+	}
+
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		window := &parsed.Windows[i]
+		if err := checkSupported(&parsed.Header, window); err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+		if err := validateWindow(window, addressCache); err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateWindow runs window's instructions without producing its target
+func validateWindow(window *Window, addressCache *AddressCache) error {
+	data, addresses, err := unusedSections(window, addressCache)
+	switch {
+	case err != nil:
+		return err
+	case data != 0:
+		return fmt.Errorf("%w: instructions use %d of %d data section bytes", ErrInvalidFormat, len(window.DataSection)-data, len(window.DataSection))
+	case addresses != 0:
+		return fmt.Errorf("%w: %d address section bytes are not used by any COPY", ErrInvalidFormat, addresses)
+	}
+	return nil
+}
+
+// unusedSections runs window's instructions without producing its target,
+// checking they produce exactly its target length from valid addresses, and
+// returns the number of data and address section bytes they leave unused
+func unusedSections(window *Window, addressCache *AddressCache) (data, addresses int, err error) {
+	addressCache.resetFor(window)
+	segmentSize := uint32(0)
+	if window.WinIndicator&VCDSource != 0 {
+		segmentSize = window.SourceSegmentSize
+	}
+
+	var position uint32
+	var used int
+	err = scanInstructions(window, func(inst RuntimeInstruction) error {
+		if inst.Size > window.TargetWindowLength-position {
+			return &TargetLengthError{Declared: window.TargetWindowLength, Produced: uint64(position) + uint64(inst.Size), Instruction: inst.Type.String()}
+		}
+		switch inst.Type {
+		case Add, Run:
+			used += len(inst.Data)
+		case Copy:
+			addr, err := addressCache.DecodeAddress(segmentSize+position, inst.Mode)
+			if err != nil {
+				return err
+			}
+			if addr < segmentSize && uint64(addr)+uint64(inst.Size) > uint64(segmentSize) {
+				return errOutOfBounds("COPY", addr, inst.Size, segmentSize)
+			}
+			if addr >= segmentSize && addr-segmentSize >= position {
+				return errOutOfBounds("COPY", addr, inst.Size, segmentSize+position)
+			}
+		}
+		position += inst.Size
+		return nil
+	})
+	switch {
+	case err != nil:
+		return 0, 0, err
+	case position != window.TargetWindowLength:
+		return 0, 0, &TargetLengthError{Declared: window.TargetWindowLength, Produced: uint64(position)}
+	}
+	return len(window.DataSection) - used, addressCache.remaining(), nil
+}
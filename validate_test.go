@@ -0,0 +1,60 @@
+package vcdiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileManyWindows, ProfileNoSource} {
+		for seed := int64(0); seed < 5; seed++ {
+			if err := Validate(GenerateDelta(seed, profile).Delta); err != nil {
+				t.Errorf("seed %d: %v", seed, err)
+			}
+		}
+	}
+	g := GenerateDelta(0, ProfileSmall)
+	interleaved, err := Encode(g.Source, g.Target, WithInterleaved())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(interleaved); err != nil {
+		t.Errorf("interleaved: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(w *Window)
+	}{
+		{"target too long", func(w *Window) { w.TargetWindowLength++ }},
+		{"target too short", func(w *Window) { w.TargetWindowLength-- }},
+		{"unused data", func(w *Window) { w.DataSection = append(w.DataSection, 0) }},
+		{"unused address", func(w *Window) { w.AddressSection = append(w.AddressSection, 0) }},
+		{"copy past the source segment", func(w *Window) {
+			*w = Window{WinIndicator: VCDSource, SourceSegmentSize: 8, TargetWindowLength: 4, InstructionSection: []byte{20}, AddressSection: []byte{5}}
+		}},
+		{"copy from unwritten target", func(w *Window) {
+			*w = Window{TargetWindowLength: 5, DataSection: []byte("a"), InstructionSection: []byte{2, 20}, AddressSection: []byte{1}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseDelta(GenerateDelta(1, ProfileCopyHeavy).Delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(&parsed.Windows[0])
+			delta, err := MarshalDelta(parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Validate(delta); !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("got %v, expected ErrInvalidFormat", err)
+			}
+		})
+	}
+
+	if err := Validate(withHeaderSection(g.Delta, VCDCodetable, []byte{0})); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated code table: got %v, expected ErrInvalidFormat", err)
+	}
+}
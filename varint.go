@@ -17,7 +17,7 @@ func ReadVarint(reader *bytes.Reader) (uint32, error) {
 		if err != nil {
 			if err == io.EOF {
 				bytesRead := startLen - reader.Len()
-				return 0, fmt.Errorf("unexpected EOF while reading varint at offset %d: expected continuation or termination byte", bytesRead)
+				return 0, errUnexpectedEOF(fmt.Sprintf("varint at offset %d", bytesRead), 1)
 			}
 			return 0, err
 		}
@@ -34,5 +34,40 @@ func ReadVarint(reader *bytes.Reader) (uint32, error) {
 
 	// If we've read 5 bytes without finding the end, the data is invalid
 	startOffset := startLen - reader.Len() - 5
-	return 0, fmt.Errorf("invalid varint at offset %d: exceeds maximum 5-byte encoding (continuation bit never cleared)", startOffset)
+	return 0, fmt.Errorf("%w at offset %d: exceeds maximum 5-byte encoding (continuation bit never cleared)", errBadVarint, startOffset)
+}
+
+// AppendVarint appends v to dst as a variable-length integer as defined in
+// RFC 3284 Section 2, most significant group first, and returns the extended
+// slice
+func AppendVarint(dst []byte, v uint32) []byte {
+	var buf [maxVarintBytes]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & VarintValueMask)
+	for v >>= VarintShiftIncrement; v > 0; v >>= VarintShiftIncrement {
+		i--
+		buf[i] = byte(v&VarintValueMask) | VarintContinuationBit
+	}
+	return append(dst, buf[i:]...)
+}
+
+// WriteVarint writes v to w as a variable-length integer as defined in
+// RFC 3284 Section 2. It returns the first error from w.
+func WriteVarint(w io.ByteWriter, v uint32) error {
+	var buf [maxVarintBytes]byte
+	for _, b := range AppendVarint(buf[:0], v) {
+		if err := w.WriteByte(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varintLength is the number of bytes AppendVarint uses for v
+func varintLength(v uint32) int {
+	n := 1
+	for v >>= VarintShiftIncrement; v > 0; v >>= VarintShiftIncrement {
+		n++
+	}
+	return n
 }
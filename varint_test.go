@@ -2,6 +2,9 @@ package vcdiff
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"math"
 	"testing"
 )
 
@@ -356,6 +359,63 @@ func TestReadVarintConstants(t *testing.T) {
 	}
 }
 
+func TestAppendVarint(t *testing.T) {
+	tests := []struct {
+		value    uint32
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x81, 0x00}},
+		{16383, []byte{0xFF, 0x7F}},
+		{16384, []byte{0x81, 0x80, 0x00}},
+		{123456789, []byte{0xBA, 0xEF, 0x9A, 0x15}},
+		{math.MaxUint32, []byte{0x8F, 0xFF, 0xFF, 0xFF, 0x7F}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			got := AppendVarint([]byte{0xAA}, tt.value)
+			if !bytes.Equal(got[1:], tt.expected) || got[0] != 0xAA {
+				t.Fatalf("got %x, expected aa%x", got, tt.expected)
+			}
+			if n := varintLength(tt.value); n != len(tt.expected) {
+				t.Errorf("varintLength gave %d, expected %d", n, len(tt.expected))
+			}
+
+			reader := bytes.NewReader(got[1:])
+			if value, err := ReadVarint(reader); err != nil || value != tt.value || reader.Len() != 0 {
+				t.Errorf("ReadVarint gave %d, %v with %d bytes left", value, err, reader.Len())
+			}
+
+			var buf bytes.Buffer
+			if err := WriteVarint(&buf, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.expected) {
+				t.Errorf("WriteVarint wrote %x, expected %x", buf.Bytes(), tt.expected)
+			}
+		})
+	}
+}
+
+// failingByteWriter accepts n bytes and then fails
+type failingByteWriter struct{ n int }
+
+func (w *failingByteWriter) WriteByte(byte) error {
+	if w.n == 0 {
+		return io.ErrShortWrite
+	}
+	w.n--
+	return nil
+}
+
+func TestWriteVarintError(t *testing.T) {
+	if err := WriteVarint(&failingByteWriter{n: 2}, math.MaxUint32); err != io.ErrShortWrite {
+		t.Fatalf("got error %v, expected the writer's error", err)
+	}
+}
+
 // Benchmark tests for performance
 func BenchmarkReadVarint(b *testing.B) {
 	testCases := []struct {
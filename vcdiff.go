@@ -2,156 +2,606 @@ package vcdiff
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var (
-	ErrInvalidMagic    = errors.New("invalid VCDIFF magic bytes")
-	ErrInvalidVersion  = errors.New("unsupported VCDIFF version")
-	ErrInvalidFormat   = errors.New("invalid VCDIFF format")
-	ErrCorruptedData   = errors.New("corrupted VCDIFF data")
-	ErrInvalidChecksum = errors.New("invalid checksum")
-)
+type Decoder interface {
+	Decode(delta []byte) ([]byte, error)
+	DecodeTo(w io.Writer, delta []byte) (int64, error)
+
+	// Reset makes the decoder apply deltas to source from now on, keeping
+	// its options and the working memory it has built up, so one decoder
+	// can serve many deltas against different bases. It must not be called
+	// while a decode is running.
+	Reset(source []byte)
+}
+
+type decoder struct {
+	source []byte
+
+	// Profiler labelling, set by WithProfilerLabels
+	labelCtx context.Context
+	deltaID  string
+
+	hooks        Hooks
+	stats        *DecodeStats
+	concatenated bool
+	fuzzy        *fuzzyConfig
+	checksum     ChecksumValidator
+	skipChecksum bool
+	text         TextFormat
+	deltaCache   *DeltaCache
+	limits       DecodeLimits
+	strict       bool
+	lenient      *LenientReport
+
+	// Address cache sizes, set by WithCacheSizes
+	nearSize int
+	sameSize int
+
+	// Working memory kept between decodes. A decode takes it for its
+	// duration, so concurrent decodes allocate their own.
+	scratch atomic.Pointer[decodeScratch]
+}
 
-// Enhanced error functions for detailed reporting
-func errUnexpectedEOF(context string, bytesNeeded int) error {
-	return fmt.Errorf("unexpected EOF while reading %s: need %d bytes", context, bytesNeeded)
+// decodeScratch is the working memory of a decode
+type decodeScratch struct {
+	addressCache *AddressCache
+	instructions []RuntimeInstruction // Instructions of the current window
+	prepared     preparedDelta        // The delta, unless a DeltaCache holds it
 }
 
-func errDataOverrun(instruction string, offset int, needed int, available int) error {
-	return fmt.Errorf("%s instruction at offset %d requires %d bytes but only %d available in data section",
-		instruction, offset, needed, available)
+// scratchPool holds working memory with the default address cache sizes
+// released by one-shot decoders, such as the one Decode builds
+var scratchPool sync.Pool
+
+// takeScratch returns the decoder's working memory, or other working memory
+// if another decode holds it
+func (d *decoder) takeScratch() *decodeScratch {
+	if scratch := d.scratch.Swap(nil); scratch != nil {
+		return scratch
+	}
+	if d.nearSize == NearCacheSize && d.sameSize == SameCacheModes {
+		if scratch, ok := scratchPool.Get().(*decodeScratch); ok {
+			return scratch
+		}
+	}
+	return &decodeScratch{addressCache: NewAddressCache(d.nearSize, d.sameSize)}
 }
 
-func errInvalidValue(field string, offset int, value interface{}, reason string) error {
-	return fmt.Errorf("invalid %s at offset %d: value %v, %s", field, offset, value, reason)
+// putScratch returns working memory to the decoder for the next decode,
+// dropping its references to the last delta
+func (d *decoder) putScratch(scratch *decodeScratch) {
+	scratch.addressCache.Reset(nil)
+	clear(scratch.instructions)
+	scratch.instructions = scratch.instructions[:0]
+	clear(scratch.prepared.headers)
+	scratch.prepared.headers = scratch.prepared.headers[:0]
+	clear(scratch.prepared.windows)
+	scratch.prepared.windows = scratch.prepared.windows[:0]
+	d.scratch.Store(scratch)
 }
 
-func errOutOfBounds(instruction string, address uint32, size uint32, maxBound uint32) error {
-	return fmt.Errorf("%s instruction address %d + size %d exceeds bounds (max %d)",
-		instruction, address, size, maxBound)
+// caches returns the code table and cache sizes windows are read with when
+// their header carries no table, or nil for the defaults
+func (d *decoder) caches() *headerCodeTable {
+	if d.nearSize == NearCacheSize && d.sameSize == SameCacheModes {
+		return nil
+	}
+	return &headerCodeTable{table: DefaultCodeTable, nearSize: d.nearSize, sameSize: d.sameSize}
 }
 
-type Decoder interface {
-	Decode(delta []byte) ([]byte, error)
+// releaseScratch hands the decoder's working memory to the pool, for
+// decoders that are not used again
+func (d *decoder) releaseScratch() {
+	scratch := d.scratch.Swap(nil)
+	if scratch != nil && d.nearSize == NearCacheSize && d.sameSize == SameCacheModes {
+		scratchPool.Put(scratch)
+	}
 }
 
-type decoder struct {
-	source []byte
+func (d *decoder) Reset(source []byte) {
+	d.source = source
 }
 
-func NewDecoder(source []byte) Decoder {
-	return &decoder{
-		source: source,
+func NewDecoder(source []byte, opts ...DecoderOption) Decoder {
+	d := &decoder{
+		source:   source,
+		checksum: Adler32{},
+		nearSize: NearCacheSize,
+		sameSize: SameCacheModes,
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 func (d *decoder) Decode(delta []byte) ([]byte, error) {
-	// Parse the delta to get structured information
-	parsed, err := ParseDelta(delta)
+	// Windows are built at the end of the target, so a single-window delta
+	// is never copied
+	target, err := d.decode(delta, []byte{}, false, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Process all windows and accumulate target data
-	target := make([]byte, 0)
+	if d.text != 0 {
+		if err := checkText(target, d.text); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
 
-	for _, window := range parsed.Windows {
-		// Decode this window's target data
-		windowTarget, err := d.decodeWindow(&window, d.source)
+// DecodeTo writes each window's target to w as soon as it is decoded, so
+// only one window is held in memory at a time. Text validation needs the
+// whole target, so with WithTextValidation the target is decoded in full
+// and checked before anything is written.
+func (d *decoder) DecodeTo(w io.Writer, delta []byte) (int64, error) {
+	if d.text != 0 {
+		target, err := d.Decode(delta)
 		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(target)
+		if err == nil && n < len(target) {
+			err = io.ErrShortWrite
+		}
+		return int64(n), err
+	}
+
+	var written int64
+	_, err := d.decode(delta, nil, false, func(_ int, window []byte) error {
+		n, err := w.Write(window)
+		written += int64(n)
+		if err == nil && n < len(window) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+	return written, err
+}
+
+// decode decodes delta, passing each window's target to emit, if non-nil, in
+// order. Windows never copy from earlier windows' targets, so emit may
+// discard them. If dst is nil, every window has a buffer of its own.
+// Otherwise windows are appended to dst, in place where its capacity allows,
+// and decode returns the result; with fixed set, the whole target must fit
+// in the capacity of dst.
+func (d *decoder) decode(delta, dst []byte, fixed bool, emit func(index int, window []byte) error) ([]byte, error) {
+	if err := checkCacheSizes(d.nearSize, d.sameSize); err != nil {
+		return nil, err
+	}
+	scratch := d.takeScratch()
+	defer d.putScratch(scratch)
+	if d.strict {
+		if err := d.checkStrictOptions(); err != nil {
 			return nil, err
 		}
+	}
+	if err := d.limits.checkDelta(delta); err != nil {
+		return nil, err
+	}
+	if d.stats != nil {
+		*d.stats = DecodeStats{}
+		start := time.Now()
+		defer func() { d.stats.Total = time.Since(start) }()
+	}
+	if d.fuzzy != nil {
+		*d.fuzzy.report = FuzzyReport{}
+	}
+	var windowOffsets []int
+	if d.lenient != nil {
+		*d.lenient = LenientReport{}
+		delta, windowOffsets = repairDelta(delta, d.concatenated, d.lenient)
+	}
 
-		// Append to overall target
-		target = append(target, windowTarget...)
+	// Parse the delta to get structured information
+	var prepared *preparedDelta
+	var parseTime time.Duration
+	err := d.phase(PhaseParse, &parseTime, func() (err error) {
+		if d.deltaCache != nil {
+			prepared, err = d.deltaCache.prepare(delta, d.concatenated, d.caches())
+			return err
+		}
+		prepared = &scratch.prepared
+		return prepareDelta(delta, d.concatenated, d.caches(), prepared)
+	})
+	if err != nil {
+		return nil, err
+	}
+	headers, windows := prepared.headers, prepared.windows
+	if err := d.limits.checkWindows(windows); err != nil {
+		return nil, err
+	}
+	if fixed {
+		if size := targetSize(windows); size > uint64(cap(dst)) {
+			return nil, fmt.Errorf("%w: target of %d bytes does not fit a %d byte buffer", io.ErrShortBuffer, size, cap(dst))
+		}
+	}
+	if d.strict {
+		if err := checkStrictHeader(&headers[0]); err != nil {
+			return nil, err
+		}
+		for i := range windows {
+			if err := checkStrictWindow(i, &windows[i], scratch.addressCache); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if d.lenient != nil {
+		for i := range windows {
+			if i < len(windowOffsets) {
+				d.lenient.checkWindow(i, windowOffsets[i], &windows[i], scratch.addressCache)
+			}
+		}
+	}
+	if d.stats != nil {
+		d.stats.Parse = parseTime
 	}
 
-	return target, nil
+	if d.hooks.OnHeader != nil {
+		for i := range headers {
+			if err := d.hooks.OnHeader(i, &headers[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Check the source against any embedded fingerprint before executing
+	var verifyTime time.Duration
+	err = d.phase(PhaseVerify, &verifyTime, func() error {
+		for i := range headers {
+			err := verifySource(&headers[i], d.source)
+			if d.fuzzy != nil && errors.Is(err, ErrSourceMismatch) {
+				d.fuzzy.report.SourceMismatch = true
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.stats != nil {
+		d.stats.SourceVerify = verifyTime
+	}
+
+	// Process all windows in order, sharing one address cache between them
+	addressCache := scratch.addressCache
+	var targetOffset uint64
+
+	for i := range windows {
+		// Decode this window's target data, at the end of dst unless each
+		// window has its own buffer
+		window := &windows[i]
+		appended, err := d.decodeWindowInto(i, window, d.source, scratch, dst)
+		var windowTarget []byte
+		if err == nil {
+			windowTarget = appended[len(dst):]
+		}
+		if d.fuzzy != nil {
+			resync := err != nil
+			windowTarget, err = d.fuzzyWindow(i, window, windowTarget, err, addressCache, targetOffset)
+			if resync && err == nil && dst != nil {
+				// A resynchronized window is decoded apart from dst
+				appended = append(dst, windowTarget...)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if emit != nil {
+			if err := emit(i, windowTarget); err != nil {
+				return nil, err
+			}
+		}
+		if dst != nil {
+			dst = appended
+		}
+		targetOffset += uint64(len(windowTarget))
+	}
+	return dst, nil
 }
 
+// Decode applies delta to source and returns the target.
+//
+// A window's delta encoding may be longer than its fields and sections need.
+// RFC 3284 does not say what a decoder should do with the bytes left over,
+// and they cannot change what the window decodes to, so they are skipped.
+// A corrupt delta encoding length can therefore take in the windows after
+// it, which then go undecoded and leave the target short without an error.
+// WithStrict rejects such deltas and WithLenient reports them.
 func Decode(source []byte, delta []byte) ([]byte, error) {
-	decoder := NewDecoder(source)
+	decoder := NewDecoder(source).(*decoder)
+	defer decoder.releaseScratch()
 	return decoder.Decode(delta)
 }
 
+// DecodeInto decodes delta against source into dst and returns the length of
+// the target, so services decoding at high rates can reuse one buffer rather
+// than allocate a target per decode. If the target is longer than dst, it
+// fails with an error wrapping io.ErrShortBuffer before decoding anything;
+// TargetSizeOf gives the length needed. Bytes of dst past the target are
+// left as they were.
+func DecodeInto(dst []byte, source, delta []byte) (int, error) {
+	if dst == nil {
+		dst = []byte{} // A nil dst would leave the target unbounded
+	}
+	d := NewDecoder(source).(*decoder)
+	defer d.releaseScratch()
+	// The target fits, so it is decoded in place without growing dst
+	target, err := d.decode(delta, dst[:0:len(dst)], true, nil)
+	if err != nil {
+		return 0, err
+	}
+	return len(target), nil
+}
+
+// Decompress decodes a delta that needs no source, as produced when a target
+// is compressed on its own: its windows set neither VCD_SOURCE nor
+// VCD_TARGET, and COPYs read only from target data already decoded. A window
+// that reads a source segment fails with ErrSourceTooShort.
+func Decompress(delta []byte) ([]byte, error) {
+	return Decode(nil, delta)
+}
+
+// preparedDelta is a delta parsed and checked for unsupported features,
+// ready to execute. One held by a DeltaCache is never modified once built,
+// so it can be shared between decodes.
+type preparedDelta struct {
+	headers []Header
+	windows []Window
+}
+
+// prepareDelta parses delta, or with concatenated every delta in it, into
+// prepared and checks that all of its windows are supported. Windows whose
+// header has no code table are read with caches, or the defaults if it is
+// nil. Headers and windows are appended to those already in prepared, so a
+// decode can reuse their storage, and the windows' sections alias delta.
+func prepareDelta(delta []byte, concatenated bool, caches *headerCodeTable, prepared *preparedDelta) error {
+	return eachDelta(delta, concatenated, func(reader *bytes.Reader) error {
+		// Windows decode from their sections, so the instructions are not kept
+		parsed := ParsedDelta{Windows: prepared.windows}
+		first := len(parsed.Windows)
+		err := parseDelta(reader, delta, concatenated, false, caches, &parsed)
+		prepared.windows = parsed.Windows
+		if err != nil {
+			return err
+		}
+		prepared.headers = append(prepared.headers, parsed.Header)
+		header := &prepared.headers[len(prepared.headers)-1]
+		for i := first; i < len(prepared.windows); i++ {
+			if err := checkSupported(header, &prepared.windows[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fuzzyWindow applies fuzzy mode to the outcome of decodeWindow, either
+// resynchronizing a failed window or flagging an unverifiable one
+func (d *decoder) fuzzyWindow(index int, window *Window, target []byte, err error, addressCache *AddressCache, targetOffset uint64) ([]byte, error) {
+	switch {
+	case err != nil && d.fuzzy.shouldResync(err):
+		return d.fuzzy.resync(index, window, d.source, addressCache, d.checksum, targetOffset)
+	case err == nil && d.fuzzy.report.SourceMismatch && !d.verifiesChecksum(window):
+		return target, d.fuzzy.recordUnverified(index, window, d.source, addressCache, targetOffset)
+	}
+	return target, err
+}
+
 // decodeWindow decodes a single window using the source data and window instructions
-func (d *decoder) decodeWindow(window *Window, source []byte) ([]byte, error) {
-	// Initialize address cache
-	addressCache := NewAddressCache(NearCacheSize, SameCacheSize)
-	addressCache.Reset(window.AddressSection)
+func (d *decoder) decodeWindow(index int, window *Window, source []byte, addressCache *AddressCache) ([]byte, error) {
+	return d.decodeWindowInto(index, window, source, &decodeScratch{addressCache: addressCache}, nil)
+}
 
-	// Create target buffer
-	target := make([]byte, 0, window.TargetWindowLength)
+// decodeWindowInto is decodeWindow working in scratch, and returning buf
+// with the target appended
+func (d *decoder) decodeWindowInto(index int, window *Window, source []byte, scratch *decodeScratch, buf []byte) ([]byte, error) {
+	addressCache := scratch.addressCache
+
+	if d.hooks.OnWindowStart != nil {
+		if err := d.hooks.OnWindowStart(index, window); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reset the address cache for this window
+	addressCache.resetFor(window)
 
 	// Get source segment for this window
 	var sourceSegment []byte
-	sourceLength := 0
 	if window.WinIndicator&VCDSource != 0 {
 		// Use source data
-		start := window.SourceSegmentPosition
-		end := start + window.SourceSegmentSize
-		if end > uint32(len(source)) {
-			return nil, ErrInvalidFormat
+		start, size := window.SourceSegmentPosition, window.SourceSegmentSize
+		if uint64(start)+uint64(size) > uint64(len(source)) {
+			return nil, fmt.Errorf("%w: window %d reads source bytes %d-%d of %d", errShortForSource,
+				index, start, uint64(start)+uint64(size), len(source))
 		}
-		sourceSegment = source[start:end]
-		sourceLength = len(sourceSegment)
+		sourceSegment = source[start : start+size]
 	}
 
 	// Parse and execute the actual instructions
-	instructions, err := parseInstructions(window.InstructionSection, window.DataSection, addressCache)
+	windowStats := WindowStats{TargetLength: window.TargetWindowLength}
+	// The instructions live only as long as the window, so their data
+	// aliases the data section
+	instructions := scratch.instructions[:0]
+	err := d.phase(PhaseParse, &windowStats.Parse, func() error {
+		return scanInstructions(window, func(inst RuntimeInstruction) error {
+			instructions = append(instructions, inst)
+			return nil
+		})
+	})
+	scratch.instructions = instructions
 	if err != nil {
 		return nil, err
 	}
 
+	var onInstruction func(RuntimeInstruction) error
+	if d.hooks.OnInstruction != nil {
+		onInstruction = func(instruction RuntimeInstruction) error {
+			return d.hooks.OnInstruction(index, instruction)
+		}
+	}
+
+	var appended []byte
+	windowStats.Instructions = len(instructions)
+	err = d.phase(PhaseExecute, &windowStats.Execute, func() (err error) {
+		appended, err = executeInstructions(instructions, sourceSegment, addressCache, window.TargetWindowLength, buf, onInstruction)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	target := appended[len(buf):]
+
+	if d.hooks.OnUnusedSections != nil {
+		data := len(window.DataSection)
+		for _, inst := range instructions {
+			data -= len(inst.Data)
+		}
+		if addresses := addressCache.remaining(); data != 0 || addresses != 0 {
+			if err := d.hooks.OnUnusedSections(index, data, addresses); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Validate Adler32 checksum if present
+	if d.verifiesChecksum(window) {
+		err = d.phase(PhaseVerify, &windowStats.Checksum, func() error {
+			computed := d.checksum.Checksum(window, target)
+			if d.hooks.OnChecksum != nil {
+				if err := d.hooks.OnChecksum(index, window.Checksum, computed); err != nil {
+					return err
+				}
+			}
+			if computed != window.Checksum {
+				return &ChecksumError{Window: index, Expected: window.Checksum, Computed: computed}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.hooks.OnWindowEnd != nil {
+		if err := d.hooks.OnWindowEnd(index, window, target); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.stats != nil {
+		d.stats.Windows = append(d.stats.Windows, windowStats)
+	}
+	return appended, nil
+}
+
+// checkTargetAllocation rejects instructions too short for a target window
+// of targetLength before it is allocated, so a window length larger than its
+// instructions produce is not allocated. It bounds the target only by the
+// declared window length: a small delta whose instructions really do produce
+// gigabytes, such as one large RUN, is still allocated in full. Callers
+// decoding untrusted deltas should cap sizes with WithLimits. Instructions
+// that overrun the window are left for execution to report where they do.
+func checkTargetAllocation(instructions []RuntimeInstruction, targetLength uint32) error {
+	if produced := instructionsLength(instructions); produced < uint64(targetLength) {
+		return &TargetLengthError{Declared: targetLength, Produced: produced}
+	}
+	return nil
+}
+
+// instructionsLength returns the number of target bytes instructions produce
+func instructionsLength(instructions []RuntimeInstruction) uint64 {
+	var length uint64
+	for _, inst := range instructions {
+		length += uint64(inst.Size)
+	}
+	return length
+}
+
+// executeInstructions runs a window's instructions against its source segment
+// and returns the reconstructed target window. If onInstruction is non-nil it
+// is called before each instruction executes, and an error from it aborts.
+// The target is appended to buf, in place if buf has the capacity to hold it
+// and otherwise after growing buf like append, and instructions must fill it
+// exactly. buf is returned with the target appended.
+func executeInstructions(instructions []RuntimeInstruction, sourceSegment []byte, addressCache *AddressCache, targetLength uint32, buf []byte, onInstruction func(RuntimeInstruction) error) ([]byte, error) {
+	if err := checkTargetAllocation(instructions, targetLength); err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		buf = make([]byte, 0, targetLength)
+	}
+	start := len(buf)
+	buf = slices.Grow(buf, int(targetLength))[:start+int(targetLength)]
+	target := buf[start:]
+	sourceLength := uint32(len(sourceSegment))
+	var position uint32
+
 	// Execute each instruction
 	for _, instruction := range instructions {
-		switch instruction.Type {
-		case NoOp:
-			// Skip
+		if instruction.Type == Copy {
+			// Decode the address using the address cache
+			addr, err := addressCache.DecodeAddress(position+sourceLength, instruction.Mode)
+			if err != nil {
+				return nil, err
+			}
+			instruction.Addr = addr
+		}
+
+		if onInstruction != nil && instruction.Type != NoOp {
+			if err := onInstruction(instruction); err != nil {
+				return nil, err
+			}
+		}
+
+		if instruction.Type == NoOp {
 			continue
+		}
+		if instruction.Size > targetLength-position {
+			return nil, &TargetLengthError{Declared: targetLength, Produced: uint64(position) + uint64(instruction.Size), Instruction: instruction.Type.String()}
+		}
+		end := position + instruction.Size
 
+		switch instruction.Type {
 		case Add:
 			// Add data from the instruction's data
 			if len(instruction.Data) != int(instruction.Size) {
 				return nil, ErrInvalidFormat
 			}
-			target = append(target, instruction.Data...)
+			copy(target[position:end], instruction.Data)
 
 		case Copy:
-			// Decode the address using the address cache
-			here := len(target) + sourceLength
-			addr, err := addressCache.DecodeAddress(uint32(here), instruction.Mode)
-			if err != nil {
-				return nil, err
-			}
+			addr := instruction.Addr
 
 			// Determine if copying from source or target
-			if addr < uint32(sourceLength) {
+			if addr < sourceLength {
 				// Copy from source segment
-				end := addr + instruction.Size
-				if end > uint32(sourceLength) {
-					return nil, errOutOfBounds("COPY", addr, instruction.Size, uint32(sourceLength))
+				if addr+instruction.Size > sourceLength || addr+instruction.Size < addr {
+					return nil, errOutOfBounds("COPY", addr, instruction.Size, sourceLength)
 				}
-				target = append(target, sourceSegment[addr:end]...)
+				copy(target[position:end], sourceSegment[addr:addr+instruction.Size])
 			} else {
 				// Copy from target data (self-referential copy)
-				targetAddr := addr - uint32(sourceLength)
-				if targetAddr >= uint32(len(target)) {
-					return nil, fmt.Errorf("COPY instruction address %d references target position %d but target only has %d bytes",
-						addr, targetAddr, len(target))
-				}
-
-				// Handle overlapping copies byte by byte
-				for i := uint32(0); i < instruction.Size; i++ {
-					if targetAddr+i >= uint32(len(target)) {
-						return nil, fmt.Errorf("COPY instruction would read beyond target bounds: position %d, target size %d",
-							targetAddr+i, len(target))
-					}
-					target = append(target, target[targetAddr+i])
+				targetAddr := addr - sourceLength
+				if targetAddr >= position {
+					return nil, errOutOfBounds("COPY", addr, instruction.Size, sourceLength+position)
 				}
+				copyWithin(target, targetAddr, position, instruction.Size)
 			}
 
 		case Run:
@@ -159,155 +609,348 @@ func (d *decoder) decodeWindow(window *Window, source []byte) ([]byte, error) {
 			if len(instruction.Data) != 1 {
 				return nil, ErrInvalidFormat
 			}
-			runByte := instruction.Data[0]
-			for i := uint32(0); i < instruction.Size; i++ {
-				target = append(target, runByte)
-			}
+			fillRun(target[position:end], instruction.Data[0])
 
 		default:
 			return nil, ErrInvalidFormat
 		}
+		position = end
 	}
 
-	// Validate Adler32 checksum if present
-	if window.HasChecksum {
-		computed := ComputeChecksum(1, target) // Adler32 starts with initial value 1
-		if computed != window.Checksum {
-			return nil, fmt.Errorf("checksum validation failed: expected 0x%08x, got 0x%08x", window.Checksum, computed)
-		}
+	if position != targetLength {
+		return nil, &TargetLengthError{Declared: targetLength, Produced: uint64(position)}
 	}
+	return buf, nil
+}
 
-	return target, nil
+// copyWithin copies size bytes of target from offset from to offset to,
+// which is later. Where the two overlap, the copy reads bytes it has just
+// written, so the data repeats with period to-from - RFC 3284 Section 3.
+// Each pass copies everything written so far, doubling the period.
+func copyWithin(target []byte, from, to, size uint32) {
+	for size > 0 {
+		n := uint32(copy(target[to:to+size], target[from:to]))
+		to, size = to+n, size-n
+	}
+}
+
+// fillRun sets every byte of dst to b, doubling the filled prefix with each
+// copy rather than writing a byte at a time
+func fillRun(dst []byte, b byte) {
+	if len(dst) == 0 {
+		return
+	}
+	dst[0] = b
+	for filled := 1; filled < len(dst); filled *= 2 {
+		copy(dst[filled:], dst[:filled])
+	}
 }
 
 // ParseDelta parses a VCDIFF delta and returns a structured representation
 func ParseDelta(delta []byte) (*ParsedDelta, error) {
+	deltas, err := parseDeltas(delta, false)
+	if err != nil {
+		return nil, err
+	}
+	return deltas[0], nil
+}
+
+// ParseHeader reads only the header of a delta: its version, indicator and
+// the secondary compressor ID, code table and application header it carries.
+// Windows are not read, so this is cheap enough to classify large numbers of
+// stored deltas. Use ParseDelta to check that the rest is well formed.
+func ParseHeader(delta []byte) (Header, error) {
+	var header Header
 	if len(delta) < MinimumFileSize {
-		return nil, ErrInvalidFormat
+		return header, errShortDelta(len(delta))
 	}
+	err := parseHeader(bytes.NewReader(delta), &header)
+	return header, err
+}
 
-	parsed := &ParsedDelta{}
-	reader := bytes.NewReader(delta)
+// ParseDeltas parses a stream of one or more complete VCDIFF deltas placed
+// back to back, each with its own header, as produced by tools that
+// concatenate delta files
+func ParseDeltas(data []byte) ([]*ParsedDelta, error) {
+	return parseDeltas(data, true)
+}
 
-	if err := parseHeader(reader, &parsed.Header); err != nil {
+// parseDeltas parses data as a single delta, or as deltas placed back to back
+// when concatenated is set, keeping their instructions
+func parseDeltas(data []byte, concatenated bool) ([]*ParsedDelta, error) {
+	// The deltas are returned to the caller, so their windows' sections are
+	// sliced from one copy of data rather than allocated one by one
+	data = bytes.Clone(data)
+	var deltas []*ParsedDelta
+	err := eachDelta(data, concatenated, func(reader *bytes.Reader) error {
+		parsed := &ParsedDelta{}
+		if err := parseDelta(reader, data, concatenated, true, nil, parsed); err != nil {
+			return err
+		}
+		deltas = append(deltas, parsed)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	return deltas, nil
+}
 
-	// Debug: check reader length after header
+// eachDelta calls parse with a reader at the start of data as a single delta,
+// or at the start of each delta placed back to back when concatenated is set,
+// until data is used up. Errors of concatenated deltas say which failed.
+func eachDelta(data []byte, concatenated bool, parse func(reader *bytes.Reader) error) error {
+	if len(data) < MinimumFileSize {
+		return errShortDelta(len(data))
+	}
+
+	reader := bytes.NewReader(data)
+	if !concatenated {
+		return parse(reader)
+	}
+	for index := 0; reader.Len() > 0; index++ {
+		offset := len(data) - reader.Len()
+		if err := parse(reader); err != nil {
+			return fmt.Errorf("delta %d at offset %d: %w", index, offset, err)
+		}
+	}
+	return nil
+}
+
+// parseDelta parses one delta from reader, which reads from data, into
+// parsed, appending its windows to any already there. The windows' sections
+// alias data. When concatenated is set, parsing stops cleanly at the magic
+// bytes of a following delta; a window indicator can never equal the first
+// magic byte because its reserved bits are set. Instructions are kept when
+// instructions is set; otherwise they are checked without being kept, which
+// saves copying their data when only the windows are needed. Without a code
+// table in the header, windows are read with caches, or the defaults if it
+// is nil.
+func parseDelta(reader *bytes.Reader, data []byte, concatenated, instructions bool, caches *headerCodeTable, parsed *ParsedDelta) error {
+	if err := parseHeader(reader, &parsed.Header); err != nil {
+		return err
+	}
+	if parsed.Header.codes == nil {
+		parsed.Header.codes = caches
+	}
 
+	// One address cache serves every window, reset as each is parsed. Only
+	// kept instructions need their addresses decoded.
+	var addressCache *AddressCache
+	if instructions {
+		addressCache = NewAddressCache(NearCacheSize, SameCacheModes)
+	}
+
+	first := len(parsed.Windows)
 	for reader.Len() > 0 {
+		if concatenated && bytes.HasPrefix(data[len(data)-reader.Len():], VCDIFFMagic[:]) {
+			break
+		}
+
 		window := Window{}
-		if err := parseWindow(reader, &window); err != nil {
+		if err := parseWindowIn(reader, data, &window, &parsed.Header, len(parsed.Windows)-first); err != nil {
 			if err == io.EOF {
 				// If we still have bytes remaining but got EOF, the delta is malformed
 				if reader.Len() > 0 {
-					return nil, fmt.Errorf("malformed VCDIFF delta: %d bytes remain but cannot form valid window", reader.Len())
+					return fmt.Errorf("malformed VCDIFF delta: %d bytes remain but cannot form valid window", reader.Len())
 				}
 				break
 			}
-			return nil, err
+			return err
 		}
 		parsed.Windows = append(parsed.Windows, window)
 
-		// Create address cache for this window
-		addressCache := NewAddressCache(NearCacheSize, SameCacheSize)
-		addressCache.Reset(window.AddressSection)
+		// Compressed sections cannot be read without their decompressor, so
+		// such windows are kept as they are for checkSupported to reject
+		if window.DeltaIndicator != 0 {
+			continue
+		}
 
 		// Parse instructions using the instruction section and data section
-		instructions, err := parseInstructions(window.InstructionSection, window.DataSection, addressCache)
+		var windowInstructions []RuntimeInstruction
+		var err error
+		if instructions {
+			addressCache.resetFor(&window)
+			windowInstructions, err = parseInstructions(&window, addressCache)
+		} else {
+			err = scanInstructions(&window, func(RuntimeInstruction) error { return nil })
+		}
 		if err != nil {
-			return nil, err
+			// The sections end the window. Interleaved sections were split
+			// apart when parsed, so only where they start is known.
+			offset := len(data) - reader.Len() - len(window.DataSection) - len(window.InstructionSection) - len(window.AddressSection)
+			var codeErr *codeError
+			if !window.Interleaved && errors.As(err, &codeErr) {
+				offset += len(window.DataSection) + codeErr.offset
+			}
+			return &ParseError{Offset: offset, Section: "instructions section", WindowIndex: len(parsed.Windows) - first - 1, Cause: err}
 		}
-		parsed.Instructions = append(parsed.Instructions, instructions...)
+		parsed.Instructions = append(parsed.Instructions, windowInstructions...)
 	}
 
-	return parsed, nil
+	return nil
 }
 
-// parseHeader parses the VCDIFF header section
+// parseHeader parses the VCDIFF header section. Errors are ParseErrors
+// giving the offset of the field that could not be parsed.
 func parseHeader(reader *bytes.Reader, header *Header) error {
-	startPos := reader.Len()
+	var field int
+	if err := readHeader(reader, header, &field); err != nil {
+		return &ParseError{Offset: field, Section: "header", WindowIndex: -1, Cause: err}
+	}
+	return nil
+}
 
+// readHeader parses the header, setting field to the offset of each field
+// before reading it
+func readHeader(reader *bytes.Reader, header *Header, field *int) error {
+	position := func() int { return int(reader.Size()) - reader.Len() }
+
+	*field = position()
 	var magic [3]byte // Read 3 magic bytes as defined in RFC 3284
-	n, err := reader.Read(magic[:])
-	if err != nil {
-		if err == io.EOF {
-			return errUnexpectedEOF("VCDIFF magic bytes", 3-n)
-		}
-		return fmt.Errorf("error reading magic bytes at offset %d: %v", startPos-reader.Len(), err)
-	}
-	if n < 3 {
-		return errUnexpectedEOF("VCDIFF magic bytes", 3-n)
+	if err := readFull(reader, magic[:], "VCDIFF magic bytes"); err != nil {
+		return err
 	}
 
 	// Compare magic bytes using bytes.Equal - RFC 3284 Section 4.1
 	if !bytes.Equal(magic[:], VCDIFFMagic[:]) {
-		return fmt.Errorf("invalid VCDIFF magic bytes at offset 0: expected %02x%02x%02x but got %02x%02x%02x",
+		return fmt.Errorf("%w: expected %02x%02x%02x but got %02x%02x%02x", ErrInvalidMagic,
 			VCDIFFMagic[0], VCDIFFMagic[1], VCDIFFMagic[2], magic[0], magic[1], magic[2])
 	}
 
+	*field = position()
 	version, err := reader.ReadByte()
 	if err != nil {
-		if err == io.EOF {
-			return errUnexpectedEOF("version byte", 1)
-		}
-		return fmt.Errorf("error reading version at offset 3: %v", err)
+		return errUnexpectedEOF("version byte", 1)
 	}
-	if version != VCDIFFVersion {
-		return errInvalidValue("version", 3, version, fmt.Sprintf("only version %d is supported", VCDIFFVersion))
+	if version != VCDIFFVersion && version != SDCHVersion {
+		return &ValueError{Field: "version", Offset: *field, Value: version, Err: ErrInvalidVersion,
+			Reason: fmt.Sprintf("only version %d and open-vcdiff's 0x%02x are supported", VCDIFFVersion, SDCHVersion)}
 	}
 
+	*field = position()
 	indicator, err := reader.ReadByte()
 	if err != nil {
-		if err == io.EOF {
-			return errUnexpectedEOF("header indicator", 1)
-		}
-		return fmt.Errorf("error reading header indicator at offset 4: %v", err)
+		return errUnexpectedEOF("header indicator", 1)
 	}
 
 	// Check for reserved bits in header indicator
 	validHeaderBits := byte(VCDDecompress | VCDCodetable | VCDAppHeader)
 	if indicator & ^validHeaderBits != 0 {
-		return errInvalidValue("header indicator", 4, indicator, "reserved bits must be zero")
+		return errReservedBitsSet("header indicator", *field, indicator)
 	}
 
 	header.Magic = magic
 	header.Version = version
 	header.Indicator = indicator
 
+	// Optional header fields follow in this order - RFC 3284 Section 4.1
+	if indicator&VCDDecompress != 0 {
+		*field = position()
+		id, err := reader.ReadByte()
+		if err != nil {
+			return errUnexpectedEOF("secondary compressor ID", 1)
+		}
+		header.SecondaryCompressorID = id
+	}
+
+	if indicator&VCDCodetable != 0 {
+		*field = position()
+		codeTable, err := readHeaderSection(reader, "code table data")
+		if err != nil {
+			return err
+		}
+		header.CodeTable = codeTable
+
+		// Windows are read with the table, so it is decoded up front
+		table, nearSize, sameSize, err := DecodeCodeTable(codeTable)
+		if err != nil {
+			return err
+		}
+		header.codes = &headerCodeTable{table: table, nearSize: nearSize, sameSize: sameSize}
+	}
+
+	if indicator&VCDAppHeader != 0 {
+		*field = position()
+		appHeader, err := readHeaderSection(reader, "application header")
+		if err != nil {
+			return err
+		}
+		header.AppHeader = appHeader
+	}
+
 	return nil
 }
 
-// parseWindow parses a single VCDIFF window
-func parseWindow(reader *bytes.Reader, window *Window) error {
+// readHeaderSection reads a varint length followed by that many bytes
+func readHeaderSection(reader *bytes.Reader, context string) ([]byte, error) {
+	length, err := ReadVarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s length: %w", context, err)
+	}
+	if int64(length) > int64(reader.Len()) {
+		return nil, errUnexpectedEOF(context, int(length)-reader.Len())
+	}
+
+	data := make([]byte, length)
+	if err := readFull(reader, data, context); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseWindow parses window index of a delta with the given header, whose
+// version and code table it is read with. It returns io.EOF if reader is
+// empty; other errors are ParseErrors giving the offset of the field or
+// section that could not be parsed.
+func parseWindow(reader *bytes.Reader, window *Window, header *Header, index int) error {
+	return parseWindowIn(reader, nil, window, header, index)
+}
+
+// parseWindowIn is parseWindow for a reader over data, when data outlives
+// the window and is not modified: the window's sections then alias data
+// rather than being copied. A nil data copies them.
+func parseWindowIn(reader *bytes.Reader, data []byte, window *Window, header *Header, index int) error {
 	if reader.Len() == 0 {
 		return io.EOF
 	}
-	startLen := reader.Len()
-
-	indicator, err := reader.ReadByte()
-	if err != nil {
-		if err == io.EOF {
-			return errUnexpectedEOF("window indicator", 1)
-		}
-		return fmt.Errorf("error reading window indicator at offset %d: %v", startLen-reader.Len(), err)
+	window.codes = header.codes
+	var field int
+	var section string
+	if err := readWindow(reader, data, window, header.Version, &field, &section); err != nil {
+		return &ParseError{Offset: field, Section: section, WindowIndex: index, Cause: err}
 	}
+	return nil
+}
+
+// readWindow parses a window from reader, which reads from data if that is
+// non-nil, setting field and section to the offset and section of each field
+// before reading it
+func readWindow(reader *bytes.Reader, data []byte, window *Window, version byte, field *int, section *string) error {
+	position := func() int { return int(reader.Size()) - reader.Len() }
+
+	*section, *field = "window header", position()
+	indicator, _ := reader.ReadByte()
 
 	// Check for reserved bits in window indicator
 	validBits := byte(VCDSource | VCDTarget | VCDAdler32)
 	if indicator & ^validBits != 0 {
-		return errInvalidValue("window indicator", startLen-reader.Len()-1, indicator, "reserved bits must be zero")
+		return errReservedBitsSet("window indicator", *field, indicator)
 	}
 
 	window.WinIndicator = indicator
 
-	if indicator&VCDSource != 0 {
+	// Segment size and position are present for both source and target
+	// segments - RFC 3284 Section 4.2
+	if indicator&(VCDSource|VCDTarget) != 0 {
+		*field = position()
 		sourceSize, err := ReadVarint(reader)
 		if err != nil {
 			return err
 		}
 		window.SourceSegmentSize = sourceSize
 
+		*field = position()
 		sourcePos, err := ReadVarint(reader)
 		if err != nil {
 			return err
@@ -316,20 +959,36 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 	}
 
 	// Read the length of the delta encoding
+	*field = position()
 	deltaSize, err := ReadVarint(reader)
 	if err != nil {
 		return err
 	}
 	window.DeltaEncodingLength = deltaSize
 
-	// Read the delta encoding section - RFC 3284 Section 4.3
-	deltaData := make([]byte, deltaSize)
-	if _, err := reader.Read(deltaData); err != nil {
-		return err
+	// Read the delta encoding section - RFC 3284 Section 4.3. Lengths are
+	// checked against the bytes present before allocating, so a corrupt
+	// length cannot force a large allocation.
+	*section, *field = "delta encoding", position()
+	if int64(deltaSize) > int64(reader.Len()) {
+		return errUnexpectedEOF("delta encoding", int(int64(deltaSize)-int64(reader.Len())))
+	}
+	var deltaData []byte
+	if data != nil {
+		end := *field + int(deltaSize)
+		deltaData = data[*field:end:end]
+		reader.Seek(int64(end), io.SeekStart)
+	} else {
+		deltaData = make([]byte, deltaSize)
+		if err := readFull(reader, deltaData, "delta encoding"); err != nil {
+			return err
+		}
 	}
 
 	// Parse the delta encoding according to RFC 3284 Section 4.3
 	deltaReader := bytes.NewReader(deltaData)
+	deltaStart := *field
+	deltaPosition := func() int { return deltaStart + len(deltaData) - deltaReader.Len() }
 
 	// 1. Length of the target window
 	targetSize, err := ReadVarint(deltaReader)
@@ -339,13 +998,15 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 	window.TargetWindowLength = targetSize
 
 	// 2. Delta_Indicator byte
+	*field = deltaPosition()
 	deltaIndicator, err := deltaReader.ReadByte()
 	if err != nil {
-		return err
+		return errUnexpectedEOF("delta indicator", 1)
 	}
 	window.DeltaIndicator = deltaIndicator
 
 	// 3. Length of data for ADDs and RUNs
+	*field = deltaPosition()
 	dataLength, err := ReadVarint(deltaReader)
 	if err != nil {
 		return err
@@ -353,6 +1014,7 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 	window.DataSectionLength = dataLength
 
 	// 4. Length of instructions section
+	*field = deltaPosition()
 	instructionLength, err := ReadVarint(deltaReader)
 	if err != nil {
 		return err
@@ -360,6 +1022,7 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 	window.InstructionSectionLength = instructionLength
 
 	// 5. Length of addresses for COPYs
+	*field = deltaPosition()
 	addressLength, err := ReadVarint(deltaReader)
 	if err != nil {
 		return err
@@ -367,13 +1030,20 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 	window.AddressSectionLength = addressLength
 
 	// Handle VCD_ADLER32 extension - checksum comes AFTER section lengths but BEFORE data sections
-	if indicator&VCDAdler32 != 0 {
+	*field = deltaPosition()
+	if indicator&VCDAdler32 != 0 && version == SDCHVersion {
+		// open-vcdiff writes the checksum as a varint
 		window.HasChecksum = true
-		// Read the 4-byte checksum from the delta encoding data
-		checksumBytes := make([]byte, 4)
-		if _, err := deltaReader.Read(checksumBytes); err != nil {
+		if window.Checksum, err = ReadVarint(deltaReader); err != nil {
 			return err
 		}
+	} else if indicator&VCDAdler32 != 0 {
+		window.HasChecksum = true
+		// Read the 4-byte checksum from the delta encoding data
+		var checksumBytes [4]byte
+		if n, _ := deltaReader.Read(checksumBytes[:]); n < len(checksumBytes) {
+			return errUnexpectedEOF("window checksum", len(checksumBytes)-n)
+		}
 		// Convert to uint32 (big-endian)
 		window.Checksum = uint32(checksumBytes[0])<<24 |
 			uint32(checksumBytes[1])<<16 |
@@ -381,58 +1051,112 @@ func parseWindow(reader *bytes.Reader, window *Window) error {
 			uint32(checksumBytes[3])
 	}
 
-	// 6. Data section for ADDs and RUNs
-	window.DataSection = make([]byte, dataLength)
-	if _, err := deltaReader.Read(window.DataSection); err != nil {
-		return err
+	*section, *field = "window sections", deltaPosition()
+	// Bytes after the sections are left unread: RFC 3284 does not require
+	// rejecting them, and WithStrict and WithLenient check for them
+	if int64(dataLength)+int64(instructionLength)+int64(addressLength) > int64(deltaReader.Len()) {
+		return errUnexpectedEOF("window sections", int(int64(dataLength)+int64(instructionLength)+int64(addressLength)-int64(deltaReader.Len())))
 	}
 
+	// The sections share the buffer of the delta encoding. Each is capped at
+	// its length so that appending to one cannot overwrite the next.
+	sections := deltaData[len(deltaData)-deltaReader.Len():]
+
+	// 6. Data section for ADDs and RUNs
+	window.DataSection, sections = sections[:dataLength:dataLength], sections[dataLength:]
+
 	// 7. Instructions and sizes section
-	window.InstructionSection = make([]byte, instructionLength)
-	if _, err := deltaReader.Read(window.InstructionSection); err != nil {
-		return err
-	}
+	window.InstructionSection, sections = sections[:instructionLength:instructionLength], sections[instructionLength:]
 
 	// 8. Addresses section for COPYs
-	window.AddressSection = make([]byte, addressLength)
-	if addressLength > 0 {
-		if _, err := deltaReader.Read(window.AddressSection); err != nil {
-			return err
-		}
-	}
+	window.AddressSection = sections[:addressLength:addressLength]
 
+	// A compressed interleaved section can only be split once decompressed
+	if version == SDCHVersion && dataLength == 0 && addressLength == 0 && instructionLength > 0 &&
+		deltaIndicator&VCDInstComp == 0 {
+		*section = "instructions section"
+		return deinterleave(window)
+	}
 	return nil
 }
 
-// parseInstructions parses the instruction data from a window using the code table
-func parseInstructions(instructionData []byte, dataSection []byte, addressCache *AddressCache) ([]RuntimeInstruction, error) {
-	stream := bytes.NewReader(instructionData)
+// readFull fills buf from reader, reporting a short read as truncation
+// rather than leaving the rest of buf zeroed. A zero-length buf always
+// succeeds, even at the end of reader.
+func readFull(reader io.Reader, buf []byte, context string) error {
+	n, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errUnexpectedEOF(context, len(buf)-n)
+	}
+	return err
+}
+
+// parseInstructions parses the instruction data from a window using its code table
+func parseInstructions(window *Window, addressCache *AddressCache) ([]RuntimeInstruction, error) {
 	var instructions []RuntimeInstruction
+	err := scanInstructions(window, func(inst RuntimeInstruction) error {
+		inst.Data = append([]byte(nil), inst.Data...)
+		instructions = append(instructions, inst)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instructions, nil
+}
+
+// scanInstructions calls fn for each instruction in the window's instruction
+// section, in order, read with its code table. ADD and RUN data alias the
+// data section, and COPY addresses are left undecoded. An error from fn stops
+// the scan and is returned.
+func scanInstructions(window *Window, fn func(RuntimeInstruction) error) error {
+	return scanCodes(window, func(_ byte, _ int, inst RuntimeInstruction) error {
+		return fn(inst)
+	})
+}
+
+// codeError is an error reading the instruction whose code is at offset in
+// the instruction section
+type codeError struct {
+	offset int
+	err    error
+}
+
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+// scanCodes is scanInstructions, also passing fn the code each instruction
+// was read from and its slot in the code table entry. Errors reading the
+// instructions are codeErrors.
+func scanCodes(window *Window, fn func(code byte, slot int, inst RuntimeInstruction) error) error {
+	instructionData, dataSection := window.InstructionSection, window.DataSection
+	table := window.codeTable()
+	stream := bytes.NewReader(instructionData)
 	dataIndex := 0
-	instructionOffset := 0
 
-	for {
-		code, err := stream.ReadByte()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error reading instruction code at offset %d: %v", instructionOffset, err)
-		}
+	for stream.Len() > 0 {
+		instructionOffset := len(instructionData) - stream.Len()
+		code, _ := stream.ReadByte()
 
 		// Each code can have up to 2 instructions
 		for slot := 0; slot < 2; slot++ {
-			instruction := DefaultCodeTable.Get(code, slot)
+			instruction := table.Get(code, slot)
 			if instruction.Type == NoOp {
 				continue
 			}
 
 			size := uint32(instruction.Size)
 			if size == 0 && instruction.Type != NoOp {
+				var err error
 				size, err = ReadVarint(stream)
 				if err != nil {
-					return nil, fmt.Errorf("error reading size for %s instruction at offset %d: %v",
-						instruction.Type, instructionOffset, err)
+					return &codeError{instructionOffset, fmt.Errorf("error reading size for %s instruction at offset %d: %w",
+						instruction.Type, instructionOffset, err)}
 				}
 			}
 
@@ -446,17 +1170,16 @@ func parseInstructions(instructionData []byte, dataSection []byte, addressCache
 			switch instruction.Type {
 			case Add:
 				if dataIndex+int(size) > len(dataSection) {
-					return nil, errDataOverrun("ADD", instructionOffset, int(size), len(dataSection)-dataIndex)
+					return &codeError{instructionOffset, errDataOverrun("ADD", instructionOffset, int(size), len(dataSection)-dataIndex)}
 				}
-				runtimeInst.Data = make([]byte, size)
-				copy(runtimeInst.Data, dataSection[dataIndex:dataIndex+int(size)])
+				runtimeInst.Data = dataSection[dataIndex : dataIndex+int(size) : dataIndex+int(size)]
 				dataIndex += int(size)
 
 			case Run:
 				if dataIndex >= len(dataSection) {
-					return nil, fmt.Errorf("RUN instruction at offset %d requires 1 byte but no data available in data section", instructionOffset)
+					return &codeError{instructionOffset, errDataOverrun("RUN", instructionOffset, 1, 0)}
 				}
-				runtimeInst.Data = []byte{dataSection[dataIndex]}
+				runtimeInst.Data = dataSection[dataIndex : dataIndex+1 : dataIndex+1]
 				dataIndex++
 
 			case Copy:
@@ -464,10 +1187,11 @@ func parseInstructions(instructionData []byte, dataSection []byte, addressCache
 				runtimeInst.Mode = instruction.Mode
 			}
 
-			instructions = append(instructions, runtimeInst)
+			if err := fn(code, slot, runtimeInst); err != nil {
+				return err
+			}
 		}
-		instructionOffset++
 	}
 
-	return instructions, nil
+	return nil
 }
@@ -1,34 +1,16 @@
-package vcdiff
+package vcdiff_test
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
-)
 
-// TestMetadata represents the metadata.json structure for test cases
-type TestMetadata struct {
-	Name               string   `json:"name"`
-	Description        string   `json:"description"`
-	Category           string   `json:"category"`
-	ExpectedBehavior   string   `json:"expected_behavior"`
-	TestObjectives     []string `json:"test_objectives"`
-	ExpectedErrorType  string   `json:"expected_error_type"`
-	ExpectedProperties struct {
-		SourceSize         int    `json:"source_size"`
-		TargetSize         int    `json:"target_size"`
-		HasChecksum        bool   `json:"has_checksum"`
-		InstructionCount   int    `json:"instruction_count"`
-		WindowCount        int    `json:"window_count"`
-		PrimaryInstruction string `json:"primary_instruction"`
-		ShouldFailFast     bool   `json:"should_fail_fast"`
-		ErrorLocation      string `json:"error_location"`
-	} `json:"expected_properties"`
-}
+	vcdiff "github.com/ably/vcdiff-go"
+	"github.com/ably/vcdiff-go/testsuite"
+)
 
 // TestCase represents a single VCDIFF test case
 type TestCase struct {
@@ -38,7 +20,7 @@ type TestCase struct {
 	TargetFile    string
 	DeltaFile     string
 	MetadataFile  string
-	Metadata      *TestMetadata
+	Metadata      *testsuite.Metadata
 	ShouldSucceed bool
 }
 
@@ -69,11 +51,11 @@ func discoverTestCases(categoryDir string, shouldSucceed bool) ([]TestCase, erro
 				}
 
 				// Load metadata if available
-				var metadata *TestMetadata
-				if metadataData, err := os.ReadFile(metadataFile); err == nil {
-					metadata = &TestMetadata{}
-					if err := json.Unmarshal(metadataData, metadata); err != nil {
-						return fmt.Errorf("invalid metadata.json in %s: %v", path, err)
+				var metadata *testsuite.Metadata
+				if _, err := os.Stat(metadataFile); err == nil {
+					metadata, err = testsuite.Load(metadataFile)
+					if err != nil {
+						return err
 					}
 				}
 
@@ -112,6 +94,12 @@ func loadTestFile(filepath string) ([]byte, error) {
 	return data, nil
 }
 
+// TestEmbeddedConformance runs the corpus embedded in the testsuite package,
+// which is present even when the submodule is not checked out
+func TestEmbeddedConformance(t *testing.T) {
+	testsuite.RunConformance(t, vcdiff.Decode)
+}
+
 // TestTargetedPositive tests cases that should succeed in decoding
 func TestTargetedPositive(t *testing.T) {
 	testDir := "submodules/vcdiff-tests/targeted-positive"
@@ -151,7 +139,7 @@ func TestTargetedPositive(t *testing.T) {
 			}
 
 			// Test decoding
-			result, err := Decode(source, delta)
+			result, err := vcdiff.Decode(source, delta)
 			if err != nil {
 				t.Fatalf("Expected successful decode but got error: %v", err)
 			}
@@ -173,6 +161,22 @@ func TestTargetedPositive(t *testing.T) {
 					t.Errorf("Target size mismatch: got %d, expected %d", len(result), tc.Metadata.ExpectedProperties.TargetSize)
 				}
 			}
+
+			// Check the declared structure against what the parser observes
+			if tc.Metadata != nil {
+				generated, err := testsuite.GenerateFromFiles(tc.SourceFile, tc.TargetFile, tc.DeltaFile)
+				if err != nil {
+					t.Fatalf("Failed to generate metadata: %v", err)
+				}
+				expected := tc.Metadata.ExpectedProperties
+				if expected.WindowCount > 0 && expected.WindowCount != generated.ExpectedProperties.WindowCount {
+					t.Errorf("Window count mismatch: metadata declares %d, delta has %d",
+						expected.WindowCount, generated.ExpectedProperties.WindowCount)
+				}
+				if expected.HasChecksum && !generated.ExpectedProperties.HasChecksum {
+					t.Errorf("Metadata declares a checksum but no window carries one")
+				}
+			}
 		})
 	}
 }
@@ -211,15 +215,14 @@ func TestTargetedNegative(t *testing.T) {
 			}
 
 			// Test decoding - should fail
-			result, err := Decode(source, delta)
+			result, err := vcdiff.Decode(source, delta)
 			if err == nil {
 				t.Fatalf("Expected decode to fail but it succeeded, got result of %d bytes", len(result))
 			}
 
-			// Validate error type if specified in metadata
-			if tc.Metadata != nil && tc.Metadata.ExpectedErrorType != "" {
-				// This could be enhanced to check specific error types
-				t.Logf("Got expected error: %v (type: %s)", err, tc.Metadata.ExpectedErrorType)
+			// Check the error code if specified in metadata
+			if tc.Metadata != nil {
+				testsuite.RequireDecodeError(t, err, tc.Metadata.ErrorWants()...)
 			}
 		})
 	}
@@ -264,7 +267,7 @@ func TestGeneralPositive(t *testing.T) {
 			}
 
 			// Test decoding
-			result, err := Decode(source, delta)
+			result, err := vcdiff.Decode(source, delta)
 			if err != nil {
 				t.Fatalf("Expected successful decode but got error: %v", err)
 			}
@@ -339,7 +342,7 @@ func TestFuzz(t *testing.T) {
 					}
 				}()
 
-				result, err := Decode(source, delta)
+				result, err := vcdiff.Decode(source, delta)
 				if err != nil {
 					t.Logf("Fuzz test failed as expected: %v", err)
 				} else {
@@ -353,7 +356,7 @@ func TestFuzz(t *testing.T) {
 // Legacy tests for basic functionality
 func TestNewDecoder(t *testing.T) {
 	source := []byte("hello world")
-	decoder := NewDecoder(source)
+	decoder := vcdiff.NewDecoder(source)
 
 	if decoder == nil {
 		t.Fatal("NewDecoder returned nil")
@@ -365,7 +368,7 @@ func TestDecode(t *testing.T) {
 	// Use a valid empty-to-empty VCDIFF delta
 	delta := []byte{0xd6, 0xc3, 0xc4, 0x00, 0x00, 0x04, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
 
-	decoder := NewDecoder(source)
+	decoder := vcdiff.NewDecoder(source)
 	result, err := decoder.Decode(delta)
 
 	if err != nil {
@@ -387,7 +390,7 @@ func TestDecodeFunction(t *testing.T) {
 	// Use a valid empty-to-empty VCDIFF delta
 	delta := []byte{0xd6, 0xc3, 0xc4, 0x00, 0x00, 0x04, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
 
-	result, err := Decode(source, delta)
+	result, err := vcdiff.Decode(source, delta)
 
 	if err != nil {
 		t.Fatalf("Decode function failed: %v", err)
@@ -0,0 +1,188 @@
+// Package vcdifftest builds VCDIFF deltas instruction by instruction, and
+// provides ready-made valid and deliberately broken test vectors, so that
+// decoders in other languages can share one corpus generated from Go.
+//
+// Deltas are encoded as plainly as RFC 3284 allows: one instruction per code
+// with its size in the instruction stream, and COPY addresses in SELF mode.
+// Targets are computed by this package without calling the decoder under
+// test.
+package vcdifftest
+
+import (
+	"encoding/binary"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// Instruction codes of the default code table with the size in the
+// instruction stream - RFC 3284 Section 5.6
+const (
+	runCode  = 0  // RUN, size 0
+	addCode  = 1  // ADD, size 0
+	copyCode = 19 // COPY mode 0 (SELF), size 0
+)
+
+// Instruction is one instruction of a window
+type Instruction struct {
+	Type vcdiff.InstructionType
+	Size uint32
+	Addr uint32 // COPY address in the window's address space: source segment, then target
+	Data []byte // ADD bytes, or the single RUN byte
+}
+
+// Add returns an ADD of data
+func Add(data []byte) Instruction {
+	return Instruction{Type: vcdiff.Add, Size: uint32(len(data)), Data: data}
+}
+
+// Run returns a RUN of size copies of b
+func Run(b byte, size uint32) Instruction {
+	return Instruction{Type: vcdiff.Run, Size: size, Data: []byte{b}}
+}
+
+// Copy returns a COPY of size bytes from addr
+func Copy(addr, size uint32) Instruction {
+	return Instruction{Type: vcdiff.Copy, Size: size, Addr: addr}
+}
+
+// Window describes one window of a delta
+type Window struct {
+	Source          bool   // Copy from the source segment at SegmentPosition (VCD_SOURCE)
+	SegmentPosition uint32 // Start of the source segment
+	SegmentSize     uint32 // Length of the source segment
+	Instructions    []Instruction
+	Checksum        bool   // Carry an Adler-32 of the target (VCD_ADLER32)
+	TargetLength    uint32 // Declared target length when nonzero, instead of the sum of the instruction sizes
+}
+
+// Delta describes a delta to build
+type Delta struct {
+	AppHeader []byte // Application header, written with VCD_APPHEADER when not nil
+	Windows   []Window
+}
+
+// Build encodes the delta and executes it against source. target is nil if
+// the instructions cannot be executed, as for COPYs outside the source or
+// the target written so far; such deltas are still encoded as described.
+func (d Delta) Build(source []byte) (delta, target []byte) {
+	delta = append([]byte{}, vcdiff.VCDIFFMagic[:]...)
+	delta = append(delta, vcdiff.VCDIFFVersion, 0)
+	if d.AppHeader != nil {
+		delta[len(delta)-1] |= vcdiff.VCDAppHeader
+		delta = vcdiff.AppendVarint(delta, uint32(len(d.AppHeader)))
+		delta = append(delta, d.AppHeader...)
+	}
+
+	target = []byte{}
+	for _, w := range d.Windows {
+		windowTarget := w.execute(source)
+		delta = w.appendTo(delta, windowTarget)
+		if target != nil && windowTarget != nil {
+			target = append(target, windowTarget...)
+		} else {
+			target = nil
+		}
+	}
+	return delta, target
+}
+
+// execute produces the window's target, or nil if it cannot be produced
+func (w Window) execute(source []byte) []byte {
+	var segment []byte
+	if w.Source {
+		end := uint64(w.SegmentPosition) + uint64(w.SegmentSize)
+		if end > uint64(len(source)) {
+			return nil
+		}
+		segment = source[w.SegmentPosition:end]
+	}
+
+	target := []byte{}
+	for _, inst := range w.Instructions {
+		switch inst.Type {
+		case vcdiff.Add:
+			target = append(target, inst.Data...)
+		case vcdiff.Run:
+			for i := uint32(0); i < inst.Size; i++ {
+				target = append(target, inst.Data[0])
+			}
+		case vcdiff.Copy:
+			segmentSize := uint64(len(segment))
+			start, end := uint64(inst.Addr), uint64(inst.Addr)+uint64(inst.Size)
+			switch {
+			case end <= segmentSize:
+				target = append(target, segment[start:end]...)
+			case start >= segmentSize && start-segmentSize < uint64(len(target)):
+				// Byte by byte, so a COPY overlapping the bytes it writes repeats them
+				for i := start - segmentSize; i < end-segmentSize; i++ {
+					target = append(target, target[i])
+				}
+			default:
+				return nil
+			}
+		}
+	}
+	if length := w.targetLength(); uint64(length) != uint64(len(target)) {
+		return nil
+	}
+	return target
+}
+
+// targetLength returns the target length the window declares
+func (w Window) targetLength() uint32 {
+	if w.TargetLength != 0 {
+		return w.TargetLength
+	}
+	var length uint32
+	for _, inst := range w.Instructions {
+		length += inst.Size
+	}
+	return length
+}
+
+// appendTo appends the encoded window to dst. target supplies the checksum,
+// which is zero when the target cannot be produced.
+func (w Window) appendTo(dst, target []byte) []byte {
+	var data, instructions, addresses []byte
+	for _, inst := range w.Instructions {
+		switch inst.Type {
+		case vcdiff.Add:
+			instructions = append(instructions, addCode)
+			data = append(data, inst.Data...)
+		case vcdiff.Run:
+			instructions = append(instructions, runCode)
+			data = append(data, inst.Data[0])
+		case vcdiff.Copy:
+			instructions = append(instructions, copyCode)
+			addresses = vcdiff.AppendVarint(addresses, inst.Addr)
+		}
+		instructions = vcdiff.AppendVarint(instructions, inst.Size)
+	}
+
+	encoding := vcdiff.AppendVarint(nil, w.targetLength())
+	encoding = append(encoding, 0)
+	encoding = vcdiff.AppendVarint(encoding, uint32(len(data)))
+	encoding = vcdiff.AppendVarint(encoding, uint32(len(instructions)))
+	encoding = vcdiff.AppendVarint(encoding, uint32(len(addresses)))
+	var indicator byte
+	if w.Checksum {
+		indicator |= vcdiff.VCDAdler32
+		var checksum uint32
+		if target != nil {
+			checksum = vcdiff.ComputeChecksum(1, target)
+		}
+		encoding = binary.BigEndian.AppendUint32(encoding, checksum)
+	}
+	encoding = append(append(append(encoding, data...), instructions...), addresses...)
+
+	if w.Source {
+		indicator |= vcdiff.VCDSource
+	}
+	dst = append(dst, indicator)
+	if w.Source {
+		dst = vcdiff.AppendVarint(dst, w.SegmentSize)
+		dst = vcdiff.AppendVarint(dst, w.SegmentPosition)
+	}
+	dst = vcdiff.AppendVarint(dst, uint32(len(encoding)))
+	return append(dst, encoding...)
+}
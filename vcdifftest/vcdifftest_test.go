@@ -0,0 +1,67 @@
+package vcdifftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+func TestValidVectorsDecode(t *testing.T) {
+	for _, v := range Valid() {
+		if v.Target == nil {
+			t.Errorf("%s: builder could not execute the delta", v.Name)
+			continue
+		}
+		if err := vcdiff.Validate(v.Delta); err != nil {
+			t.Errorf("%s: Validate failed: %v", v.Name, err)
+		}
+		got, err := vcdiff.Decode(v.Source, v.Delta)
+		if err != nil {
+			t.Errorf("%s: Decode failed: %v", v.Name, err)
+			continue
+		}
+		if !bytes.Equal(got, v.Target) {
+			t.Errorf("%s: decoded %q, expected %q", v.Name, got, v.Target)
+		}
+	}
+}
+
+func TestBrokenVectorsFail(t *testing.T) {
+	for _, v := range Broken() {
+		if !v.ExpectError {
+			t.Errorf("%s: not marked as expecting an error", v.Name)
+		}
+		_, err := vcdiff.Decode(v.Source, v.Delta)
+		var code vcdiff.ErrorCode
+		if !errors.As(err, &code) || code != v.ErrorCode {
+			t.Errorf("%s: got %v with code %q, expected code %q", v.Name, err, code, v.ErrorCode)
+		}
+	}
+}
+
+func TestWriteVectors(t *testing.T) {
+	dir := t.TempDir()
+	vectors := append(Valid(), Broken()...)
+	if err := WriteVectors(dir, vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		data, err := os.ReadFile(filepath.Join(dir, v.Name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if decoded["generator"] != Generator || decoded["expect_error"] != v.ExpectError {
+			t.Errorf("%s: unexpected vector %s", v.Name, data)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package vcdifftest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	vcdiff "github.com/ably/vcdiff-go"
+)
+
+// Generator names this package in the vectors it produces
+const Generator = "github.com/ably/vcdiff-go/vcdifftest"
+
+// Field offsets in the deltas Valid and Broken build from - RFC 3284 Section 4.1
+const (
+	versionOffset   = 3 // Header version byte, after the magic
+	indicatorOffset = 4 // Header indicator byte
+	windowOffset    = 5 // First window, in deltas without header sections
+)
+
+// maxVarintLength is the longest varint a 32-bit field can take - RFC 3284
+// Section 2
+const maxVarintLength = 5
+
+// Vector is a delta with the source it applies to and either the target it
+// must decode to, or ExpectError when decoders must reject it
+type Vector struct {
+	Name        string
+	Description string
+	Source      []byte
+	Delta       []byte
+	Target      []byte
+	ExpectError bool
+	ErrorCode   vcdiff.ErrorCode // Code of this package's error, for vectors expecting one
+}
+
+// MarshalJSON encodes the vector in the exchange format of this
+// repository's testdata/vectors, with bytes hex encoded
+func (v Vector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Generator   string `json:"generator"`
+		SourceHex   string `json:"source_hex"`
+		DeltaHex    string `json:"delta_hex"`
+		TargetHex   string `json:"target_hex"`
+		ExpectError bool   `json:"expect_error"`
+		ErrorCode   string `json:"error_code,omitempty"`
+	}{
+		Name:        v.Name,
+		Description: v.Description,
+		Generator:   Generator,
+		SourceHex:   hex.EncodeToString(v.Source),
+		DeltaHex:    hex.EncodeToString(v.Delta),
+		TargetHex:   hex.EncodeToString(v.Target),
+		ExpectError: v.ExpectError,
+		ErrorCode:   string(v.ErrorCode),
+	})
+}
+
+// WriteVectors writes each vector to dir as <name>.json
+func WriteVectors(dir string, vectors []Vector) error {
+	for _, v := range vectors {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, v.Name+".json"), append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testSource is the source every vector applies to
+var testSource = []byte("The quick brown fox jumps over the lazy dog. ")
+
+// valid builds a vector that must decode
+func valid(name, description string, d Delta) Vector {
+	delta, target := d.Build(testSource)
+	return Vector{Name: name, Description: description, Source: testSource, Delta: delta, Target: target}
+}
+
+// broken returns a vector that must be rejected with code
+func broken(name, description string, code vcdiff.ErrorCode, delta []byte) Vector {
+	return Vector{Name: name, Description: description, Source: testSource, Delta: delta, ExpectError: true, ErrorCode: code}
+}
+
+// sourceWindow is a window over the whole of testSource
+func sourceWindow(instructions ...Instruction) Window {
+	return Window{Source: true, SegmentSize: uint32(len(testSource)), Instructions: instructions}
+}
+
+// Valid returns deltas covering each instruction, the address spaces COPY
+// reads from, and the optional header and window fields
+func Valid() []Vector {
+	return []Vector{
+		valid("empty-target", "A single window producing no bytes", Delta{Windows: []Window{{}}}),
+		valid("no-windows", "A header with no windows, producing an empty target", Delta{}),
+		valid("add", "ADD of literal bytes without a source", Delta{Windows: []Window{{Instructions: []Instruction{Add([]byte("hello"))}}}}),
+		valid("run", "RUN repeating one byte", Delta{Windows: []Window{{Instructions: []Instruction{Run('z', 20)}}}}),
+		valid("copy-source", "COPYs from the start, middle and end of the source segment", Delta{Windows: []Window{
+			sourceWindow(Copy(0, 4), Copy(16, 5), Copy(uint32(len(testSource))-5, 5)),
+		}}),
+		valid("copy-source-segment", "COPY from a source segment that starts part way into the source", Delta{Windows: []Window{
+			{Source: true, SegmentPosition: 10, SegmentSize: 9, Instructions: []Instruction{Copy(4, 5), Copy(0, 5)}},
+		}}),
+		valid("copy-target", "COPY from target bytes written earlier in the window", Delta{Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("abc")), Copy(0, 3)}},
+		}}),
+		valid("copy-target-overlap", "COPY overlapping the bytes it writes, repeating them", Delta{Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("ab")), Copy(0, 11)}},
+		}}),
+		valid("copy-target-after-source", "Target COPY addressed after the source segment", Delta{Windows: []Window{
+			sourceWindow(Copy(4, 6), Copy(uint32(len(testSource)), 6)),
+		}}),
+		valid("mixed", "ADD, COPY and RUN in one window", Delta{Windows: []Window{
+			sourceWindow(Add([]byte("A ")), Copy(4, 6), Run('!', 3), Copy(uint32(len(testSource))+2, 6)),
+		}}),
+		valid("windows", "Several windows, each with its own source segment", Delta{Windows: []Window{
+			{Source: true, SegmentPosition: 40, SegmentSize: 4, Instructions: []Instruction{Copy(0, 4)}},
+			{Instructions: []Instruction{Add([]byte(" and "))}},
+			{Source: true, SegmentSize: 3, Instructions: []Instruction{Copy(0, 3), Run('.', 1)}},
+		}}),
+		valid("checksum", "Windows with VCD_ADLER32 checksums of their targets", Delta{Windows: []Window{
+			{Source: true, SegmentSize: 9, Instructions: []Instruction{Copy(0, 9)}, Checksum: true},
+			{Instructions: []Instruction{Run('-', 4)}, Checksum: true},
+		}}),
+		valid("app-header", "An application header, which decoders skip", Delta{AppHeader: []byte("application data"), Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("x"))}},
+		}}),
+	}
+}
+
+// Broken returns deltas that decoders must reject: damaged framing, and
+// instructions that read or write outside what the window allows
+func Broken() []Vector {
+	base, _ := Delta{Windows: []Window{sourceWindow(Add([]byte("A ")), Copy(4, 6), Run('!', 3))}}.Build(testSource)
+	vectors := []Vector{
+		broken("bad-magic", "First magic byte changed", vcdiff.ErrCodeBadMagic, edit(base, func(d []byte) { d[0] ^= 0xff })),
+		broken("bad-version", "Unknown version byte", vcdiff.ErrCodeBadVersion, edit(base, func(d []byte) { d[versionOffset] = 0x99 })),
+		broken("reserved-header-bits", "Reserved bits set in the header indicator", vcdiff.ErrCodeReservedBits, edit(base, func(d []byte) { d[indicatorOffset] |= 0xf8 })),
+		broken("reserved-window-bits", "Reserved bits set in the window indicator", vcdiff.ErrCodeReservedBits, edit(base, func(d []byte) { d[windowOffset] |= 0xf8 })),
+		broken("truncated-header", "Delta cut off inside the magic", vcdiff.ErrCodeTruncated, base[:2]),
+		broken("truncated-sections", "Delta cut off inside the window sections", vcdiff.ErrCodeTruncated, base[:len(base)-2]),
+		broken("overlong-varint", "Source segment size written as a varint longer than 5 bytes", vcdiff.ErrCodeBadVarint, overlongSegmentSize(base)),
+	}
+
+	for _, b := range []struct {
+		name, description string
+		code              vcdiff.ErrorCode
+		delta             Delta
+	}{
+		{"copy-past-source-segment", "COPY running past the end of the source segment", vcdiff.ErrCodeOutOfBounds, Delta{Windows: []Window{
+			{Source: true, SegmentSize: 8, Instructions: []Instruction{Copy(4, 8)}},
+		}}},
+		{"copy-unwritten-target", "COPY from target bytes not yet written", vcdiff.ErrCodeOutOfBounds, Delta{Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("ab")), Copy(2, 2)}},
+		}}},
+		{"segment-past-source", "Source segment extending beyond the source", vcdiff.ErrCodeSourceTooShort, Delta{Windows: []Window{
+			{Source: true, SegmentPosition: 40, SegmentSize: 40, Instructions: []Instruction{Copy(0, 4)}},
+		}}},
+		{"target-too-long", "Instructions produce fewer bytes than the declared target length", vcdiff.ErrCodeTargetLength, Delta{Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("abc"))}, TargetLength: 4},
+		}}},
+		{"target-too-short", "Instructions produce more bytes than the declared target length", vcdiff.ErrCodeTargetLength, Delta{Windows: []Window{
+			{Instructions: []Instruction{Add([]byte("abc")), Run('d', 3)}, TargetLength: 4},
+		}}},
+	} {
+		delta, _ := b.delta.Build(testSource)
+		vectors = append(vectors, broken(b.name, b.description, b.code, delta))
+	}
+
+	// The checksum sits at the end of the window's lengths, just before
+	// its sections
+	checked, _ := Delta{Windows: []Window{{Instructions: []Instruction{Add([]byte("abc"))}, Checksum: true}}}.Build(testSource)
+	vectors = append(vectors, broken("bad-checksum", "Window checksum that does not match its target", vcdiff.ErrCodeChecksum,
+		edit(checked, func(d []byte) { d[len(d)-len("abc")-2-1] ^= 0xff })))
+	return vectors
+}
+
+// edit returns a copy of delta changed by fn
+func edit(delta []byte, fn func([]byte)) []byte {
+	d := bytes.Clone(delta)
+	fn(d)
+	return d
+}
+
+// overlongSegmentSize rewrites the first window's source segment size, the
+// varint after its indicator, with leading zero digits past the 5 bytes a
+// 32-bit value can take
+func overlongSegmentSize(delta []byte) []byte {
+	size := vcdiff.AppendVarint(nil, uint32(len(testSource)))
+	padding := bytes.Repeat([]byte{0x80}, maxVarintLength+1-len(size))
+	out := append(bytes.Clone(delta[:windowOffset+1]), padding...)
+	return append(out, delta[windowOffset+1:]...)
+}
@@ -0,0 +1,100 @@
+package vcdiff
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crossVector is one entry of the vector-exchange format in testdata/vectors.
+// Each vector records the target its generator expects, or that the delta is
+// rejected. The checked-in vectors are assembled by hand; those decoded by
+// another tool record the version and command it was run with.
+type crossVector struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	Generator        string `json:"generator"`
+	GeneratorVersion string `json:"generator_version"`
+	GeneratorCommand string `json:"generator_command"`
+	SourceHex        string `json:"source_hex"`
+	DeltaHex         string `json:"delta_hex"`
+	TargetHex        string `json:"target_hex"`
+	ExpectError      bool   `json:"expect_error"`
+	ErrorCode        string `json:"error_code"`
+}
+
+// loadCrossVectors reads every vector in testdata/vectors
+func loadCrossVectors(t *testing.T) []crossVector {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "vectors", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []crossVector
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v crossVector
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("invalid vector %s: %v", path, err)
+		}
+		if v.Name == "" || v.Generator == "" {
+			t.Fatalf("vector %s must declare a name and generator", path)
+		}
+		if v.GeneratorCommand != "" && v.GeneratorVersion == "" {
+			t.Fatalf("vector %s must record the version of %s", path, v.Generator)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+func decodeHex(t *testing.T, field, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", field, err)
+	}
+	return b
+}
+
+func TestCrossImplementationVectors(t *testing.T) {
+	vectors := loadCrossVectors(t)
+	if len(vectors) == 0 {
+		t.Skip("no cross-implementation vectors found")
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			source := decodeHex(t, "source_hex", v.SourceHex)
+			delta := decodeHex(t, "delta_hex", v.DeltaHex)
+
+			result, err := Decode(source, delta)
+			if v.ExpectError {
+				if err == nil {
+					t.Fatalf("%s rejects this delta but it decoded to %d bytes", v.Generator, len(result))
+				}
+				var code ErrorCode
+				if v.ErrorCode != "" && (!errors.As(err, &code) || string(code) != v.ErrorCode) {
+					t.Fatalf("got error %q with code %q, expected code %q", err, code, v.ErrorCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s decodes this delta but decoding failed: %v", v.Generator, err)
+			}
+
+			target := decodeHex(t, "target_hex", v.TargetHex)
+			if !bytes.Equal(result, target) {
+				t.Fatalf("decoded target differs from %s output: got %d bytes, expected %d", v.Generator, len(result), len(target))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package vcdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ForEachInstruction calls fn for every instruction of delta, window by
+// window, without collecting them into a slice. COPY addresses are decoded,
+// in the window's combined source and target address space. ADD and RUN
+// data alias the delta's parsed sections rather than being copied, so fn
+// must copy Data to keep it beyond the call. This suits tools scanning the
+// instructions of deltas too large to parse whole.
+//
+// Returning an error from fn stops the walk, and ForEachInstruction returns
+// that error unwrapped.
+func ForEachInstruction(delta []byte, fn func(window int, inst RuntimeInstruction) error) error {
+	if len(delta) < MinimumFileSize {
+		return errShortDelta(len(delta))
+	}
+
+	reader := bytes.NewReader(delta)
+	var header Header
+	if err := parseHeader(reader, &header); err != nil {
+		return err
+	}
+
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for index := 0; reader.Len() > 0; index++ {
+		var window Window
+		if err := parseWindow(reader, &window, &header, index); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		addressCache.resetFor(&window)
+		here := uint32(0)
+		if window.WinIndicator&VCDSource != 0 {
+			here = window.SourceSegmentSize
+		}
+
+		var stopped error
+		err := scanInstructions(&window, func(inst RuntimeInstruction) error {
+			if inst.Type == Copy {
+				addr, err := addressCache.DecodeAddress(here, inst.Mode)
+				if err != nil {
+					return err
+				}
+				inst.Addr = addr
+			}
+			here += inst.Size
+			if err := fn(index, inst); err != nil {
+				stopped = err
+				return err
+			}
+			return nil
+		})
+		if stopped != nil {
+			return stopped
+		}
+		if err != nil {
+			return fmt.Errorf("window %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
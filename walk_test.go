@@ -0,0 +1,112 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestForEachInstruction(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	source := make([]byte, 4096)
+	rng.Read(source)
+	target := append(append([]byte("head"), source[1000:3000]...), bytes.Repeat([]byte{'r'}, 50)...)
+	target = append(target, target[4:500]...)
+
+	delta, err := Encode(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDelta(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []RuntimeInstruction
+	var wantWindows []int
+	addressCache := NewAddressCache(NearCacheSize, SameCacheModes)
+	for i := range parsed.Windows {
+		r, err := resolveWindow(&parsed.Windows[i], addressCache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range r.instructions {
+			wantWindows = append(wantWindows, i)
+		}
+		want = append(want, r.instructions...)
+	}
+
+	var got []RuntimeInstruction
+	err = ForEachInstruction(delta, func(window int, inst RuntimeInstruction) error {
+		if len(got) >= len(want) || window != wantWindows[len(got)] {
+			t.Fatalf("instruction %d reported in window %d", len(got), window)
+		}
+		got = append(got, inst)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d instructions, expected %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Type != w.Type || g.Size != w.Size || g.Mode != w.Mode || g.Addr != w.Addr || !bytes.Equal(g.Data, w.Data) {
+			t.Fatalf("instruction %d: got %+v, expected %+v", i, g, w)
+		}
+	}
+}
+
+func TestForEachInstructionStop(t *testing.T) {
+	delta := runDelta(t, 10, 20, 30)
+	stop := errors.New("stop")
+	var sizes []uint32
+	err := ForEachInstruction(delta, func(window int, inst RuntimeInstruction) error {
+		sizes = append(sizes, inst.Size)
+		if window == 1 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("got error %v, expected the callback's", err)
+	}
+	if len(sizes) != 2 || sizes[0] != 10 || sizes[1] != 20 {
+		t.Fatalf("visited sizes %v", sizes)
+	}
+}
+
+func TestForEachInstructionMalformed(t *testing.T) {
+	delta := marshalWindow(t, Window{}, []RuntimeInstruction{{Type: Add, Size: 5, Data: []byte("hello")}}, func(w *Window) {
+		w.DataSection = w.DataSection[:3]
+	})
+	var overrun *OverrunError
+	if err := ForEachInstruction(delta, func(int, RuntimeInstruction) error { return nil }); !errors.As(err, &overrun) {
+		t.Fatalf("got %v, expected an OverrunError", err)
+	}
+}
+
+func BenchmarkForEachInstruction(b *testing.B) {
+	rng := rand.New(rand.NewSource(5))
+	source := make([]byte, 1<<20)
+	rng.Read(source)
+	target := append([]byte{}, source...)
+	for i := 0; i < len(target); i += 4096 {
+		target[i] ^= 0xff
+	}
+	delta, err := Encode(source, target)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(delta)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var n int
+		if err := ForEachInstruction(delta, func(int, RuntimeInstruction) error { n++; return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
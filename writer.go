@@ -0,0 +1,44 @@
+package vcdiff
+
+import "io"
+
+// streamWriter decodes delta bytes written to it into an underlying writer
+type streamWriter struct {
+	pipe *io.PipeWriter
+	done chan struct{}
+	err  error // Result of decoding, valid once done is closed
+}
+
+// NewWriter returns a writer that accepts the raw bytes of a delta as they
+// arrive and writes the target reconstructed from source to dst, one window
+// at a time. A failed decode is returned from the next Write.
+//
+// Close must be called once the whole delta has been written. It waits for
+// the last window to be decoded and returns an error wrapping
+// io.ErrUnexpectedEOF if the delta ended part way through a window.
+func NewWriter(source []byte, dst io.Writer) io.WriteCloser {
+	r, w := io.Pipe()
+	s := &streamWriter{pipe: w, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		_, s.err = io.Copy(dst, NewReader(source, r))
+		// Unblock the writer if decoding stopped before the delta ended
+		if s.err != nil {
+			r.CloseWithError(s.err)
+		} else {
+			r.Close()
+		}
+	}()
+	return s
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	return s.pipe.Write(p)
+}
+
+func (s *streamWriter) Close() error {
+	s.pipe.Close()
+	<-s.done
+	return s.err
+}
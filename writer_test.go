@@ -0,0 +1,64 @@
+package vcdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestNewWriterMatchesDecode(t *testing.T) {
+	for _, profile := range []DeltaProfile{ProfileSmall, ProfileCopyHeavy, ProfileManyWindows} {
+		for seed := int64(0); seed < 10; seed++ {
+			g := GenerateDelta(seed, profile)
+
+			// Write the delta in uneven chunks, as a network stream would deliver it
+			var out bytes.Buffer
+			w := NewWriter(g.Source, &out)
+			for rest, n := g.Delta, 1; len(rest) > 0; n = n*2 + 1 {
+				n = min(n, len(rest))
+				if _, err := w.Write(rest[:n]); err != nil {
+					t.Fatalf("seed %d: write failed: %v", seed, err)
+				}
+				rest = rest[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("seed %d: close failed: %v", seed, err)
+			}
+			if !bytes.Equal(out.Bytes(), g.Target) {
+				t.Fatalf("seed %d: written target differs from generated target", seed)
+			}
+		}
+	}
+}
+
+func TestNewWriterTruncated(t *testing.T) {
+	g := GenerateDelta(2, ProfileCopyHeavy)
+
+	w := NewWriter(g.Source, io.Discard)
+	if _, err := w.Write(g.Delta[:len(g.Delta)-1]); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("close after truncated delta: got %v, expected io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestNewWriterDecodeError(t *testing.T) {
+	g := GenerateDelta(2, ProfileCopyHeavy)
+	corrupted := append([]byte{}, g.Delta...)
+	corrupted[0] ^= 0xFF
+
+	w := NewWriter(g.Source, io.Discard)
+	// The error surfaces from Write once the decoder has seen the header
+	var err error
+	for i := 0; i < len(corrupted) && err == nil; i++ {
+		_, err = w.Write(corrupted[i : i+1])
+	}
+	if err == nil {
+		t.Fatal("corrupted delta written without error")
+	}
+	if closeErr := w.Close(); closeErr != err {
+		t.Errorf("close of corrupted delta: got %v, expected %v", closeErr, err)
+	}
+}